@@ -21,6 +21,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -70,6 +71,17 @@ func main() {
 		log.Fatalf("❌ Migration failed: %v", err)
 	}
 	log.Println("📦 Migrations OK")
+	if err := services.LoadStoragesFromEnv(); err != nil {
+		log.Fatalf("❌ Stockage de pages: %v", err)
+	}
+	if err := services.LoadObjectStoreFromEnv(); err != nil {
+		log.Fatalf("❌ Stockage de fichiers: %v", err)
+	}
+	if err := services.LoadColumnCipherFromEnv(); err != nil {
+		log.Fatalf("❌ Chiffrement de colonnes: %v", err)
+	}
+	routes.BackfillPageSlugs(db)
+	routes.CheckTableOwnership(db)
 	redisAddr := os.Getenv("REDIS_URL")
 	if redisAddr == "" {
 		log.Fatal("❌ REDIS_URL manquant")
@@ -81,9 +93,58 @@ func main() {
 	}
 	log.Println("✅ Connecté à Redis")
 
+	workers.StartDigestWorker(db)
+	workers.StartKubeVirtSync(db)
+	workers.StartTombstonePurge(db)
+	workers.StartTrashPurge(db)
+
+	health := services.NewHealthFromEnv()
+
+	cache := services.NewCacheFromEnv(rdb).WithHealth(health)
+	if cache.Enabled() {
+		log.Println("🔵 Page cache: redis")
+	}
+	hooks := services.NewWebhooks(db)
+	fingerprints := services.NewFingerprintsFromEnv(rdb).WithHealth(health)
+	events := services.NewPageEvents(rdb).WithHealth(health)
+	events.Start(ctx)
+	debugScopes := services.NewDebugScopes(rdb).WithHealth(health)
+
+	maintenance := services.NewMaintenanceFromEnv(rdb).WithHealth(health)
+	editLocks := services.NewEditLocksFromEnv(rdb).WithHealth(health)
+	workers.StartDeployScheduler(db, routes.ScheduledDeployRunner(db, cache))
+	var replica *gorm.DB
+	if replicaDSN := os.Getenv("DATABASE_REPLICA_URL"); replicaDSN != "" {
+		replica, err = gorm.Open(postgres.Open(replicaDSN), &gorm.Config{})
+		if err != nil {
+			log.Fatalf("❌ Impossible de se connecter au réplica: %v", err)
+		}
+		log.Println("✅ Connecté au réplica Postgres")
+	}
+
 	oidcService := services.InitOIDC()
 	verifier := oidcService.Verifier
 
+	if sqlDB, err := db.DB(); err == nil {
+		health.Register(services.DependencyPostgres, true, time.Second, services.PingSQL(sqlDB))
+	}
+	if replica != nil {
+		if sqlDB, err := replica.DB(); err == nil {
+			health.Register(services.DependencyReplica, false, time.Second, services.PingSQL(sqlDB))
+		}
+	}
+	health.Register(services.DependencyRedis, false, 500*time.Millisecond, services.PingRedis(rdb))
+	health.Register(services.DependencyOIDC, false, 2*time.Second, services.CheckOIDC(os.Getenv("OIDC_ISSUER")))
+	for _, name := range services.StorageNames() {
+		if sqlDB, err := services.StorageDB(name); err == nil {
+			health.Register("storage:"+name, false, time.Second, services.PingSQL(sqlDB))
+		}
+	}
+	if store := services.Objects(); store != nil {
+		health.Register(services.DependencyObjectStore, false, 2*time.Second, store.Check)
+	}
+	health.Start()
+
 	if os.Getenv("TOKEN_VALIDATION_MODE") == "redis" {
 		log.Println("🔵 Token validation mode: redis")
 		workers.StartTokenRefresher(rdb)
@@ -101,21 +162,76 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     allowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "If-Match", middlewares.AllowDuplicateHeader},
+		ExposeHeaders:    []string{"ETag"},
 		AllowCredentials: true,
 	}))
 
+	routes.RegisterHealthRoutes(r, health)
+	routes.RegisterPublicShareRoutes(r.Group("/s"), db)
+
 	api := r.Group("/api")
 	api.Use(
-		middlewares.AuthMiddleware(db, verifier, rdb),
+		middlewares.AuthMiddleware(db, verifier, rdb, health),
 	)
-	routes.RegisterNavRoutes(api, db)
-	routes.RegisterNavigationRoutes(api, db)
-	routes.RegisterPublicPageItemRoutes(api, db)
-	routes.RegisterUserRoutes(api, db)
-	routes.RegisterPublicPageRoutes(api, db)
-	routes.RegisterTagRoutes(api, db)
-	routes.RegisterBuilderRoutes(api, db)
-	routes.RegisterTagCategoryRoutes(api, db)
+	if !debugMode {
+		api.Use(middlewares.ScopedDebugLogger(debugScopes))
+	}
+	adminScopes := []gin.HandlerFunc{middlewares.RequireScope("admin"), middlewares.RequireAdmin()}
+	routes.RegisterMaintenanceRoutes(api.Group("/admin", adminScopes...), maintenance)
+	api.Use(middlewares.Maintenance(maintenance, replica, health))
+	api.Use(middlewares.DedupeSubmissions(fingerprints))
+
+	if os.Getenv("REQUEST_TRANSACTIONS") == "true" {
+		log.Println("🔵 Request transactions: on")
+		api.Use(middlewares.Transaction(db))
+	}
+	navigationAPI := api.Group("", middlewares.RequireScope("navigation"))
+	routes.RegisterNavRoutes(navigationAPI, db, cache)
+	routes.RegisterNavigationRoutes(navigationAPI, db, cache)
+
+	pagesAPI := api.Group("", middlewares.RequireScope("pages"))
+	routes.RegisterPublicPageItemRoutes(pagesAPI, db)
+	routes.RegisterShareLinkRoutes(pagesAPI, db)
+	routes.RegisterPublicPageRoutes(pagesAPI, db, cache, hooks, events)
+	routes.RegisterPageEventRoutes(pagesAPI, db, events)
+	routes.RegisterPageFileRoutes(pagesAPI, db, cache, hooks, events)
+	routes.RegisterPageDistinctRoutes(pagesAPI, db)
+	routes.RegisterPageStatsRoutes(pagesAPI, db)
+	routes.RegisterPageChangesRoutes(pagesAPI, db)
+	routes.RegisterPageReferenceRoutes(pagesAPI, db)
+	routes.RegisterPageCommentRoutes(pagesAPI, db)
+	routes.RegisterGraphQLRoutes(pagesAPI, db)
+
+	routes.RegisterUserRoutes(api.Group("", middlewares.RequireScope("users")), db)
+
+	tagsAPI := api.Group("", middlewares.RequireScope("tags"))
+	routes.RegisterTagRoutes(tagsAPI, db)
+	routes.RegisterTagCategoryRoutes(tagsAPI, db)
+
+	routes.RegisterTemplateRoutes(api.Group("", middlewares.RequireScope("templates")), db)
+	builderAPI := api.Group("", middlewares.RequireScope("builder"), middlewares.RequireEditLock(editLocks))
+	routes.RegisterBuilderRoutes(builderAPI, db, cache)
+	routes.RegisterPageMenuRoutes(builderAPI, db, cache)
+	routes.RegisterPageRolloutRoutes(builderAPI, db, cache)
+	routes.RegisterBuilderDeployRoutes(builderAPI, db, cache)
+	routes.RegisterBuilderUndeployRoutes(builderAPI, db, cache)
+	routes.RegisterBuilderRevisionRoutes(builderAPI, db, cache)
+	routes.RegisterBuilderBundleRoutes(builderAPI, db, cache)
+	routes.RegisterBuilderImportTableRoutes(builderAPI, db, cache)
+	routes.RegisterBuilderSchemaRoutes(builderAPI, db, cache)
+	routes.RegisterBuilderLockRoutes(builderAPI, db, editLocks)
+	routes.RegisterBuilderPublishingRoutes(builderAPI, db, cache)
+	routes.RegisterBuilderBlueprintRoutes(builderAPI, db, cache)
+	routes.RegisterBuilderGraphRoutes(builderAPI, db)
+	routes.RegisterBuilderScheduledDeployRoutes(builderAPI, db)
+	routes.RegisterBuilderAuditRoutes(builderAPI, db)
+	routes.RegisterDigestRoutes(api.Group("", middlewares.RequireScope("digests")), db)
+	routes.RegisterTrashRoutes(api.Group("", adminScopes...), db, cache, hooks, events)
+	adminAPI := api.Group("/admin", adminScopes...)
+	routes.RegisterDeadLetterRoutes(adminAPI, db)
+	routes.RegisterActivityRoutes(adminAPI, db)
+	routes.RegisterWebhookRoutes(adminAPI, db)
+	routes.RegisterDebugScopeRoutes(adminAPI, db, debugScopes)
 	r.Run(":8080")
 }
@@ -0,0 +1,37 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middlewares
+
+import (
+	"api-core-v2/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin only lets through users flagged IsAdmin.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := utils.CurrentUser(c)
+		if user == nil || user.IsAdmin == nil || !*user.IsAdmin {
+			utils.Error(c, http.StatusForbidden, "FORBIDDEN", "Admin access required")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -18,12 +18,14 @@ package middlewares
 
 import (
 	"context"
+	"errors"
 	"log"
 	"os"
 	"strings"
 	"time"
 
 	"api-core-v2/services"
+	"api-core-v2/utils"
 	"api-core-v2/workers"
 
 	"github.com/coreos/go-oidc/v3/oidc"
@@ -33,7 +35,9 @@ import (
 	"gorm.io/gorm"
 )
 
-func AuthMiddleware(db *gorm.DB, verifier *oidc.IDTokenVerifier, rdb *redis.Client) gin.HandlerFunc {
+// AuthMiddleware validates the bearer token. In redis mode, tokens are
+// introspected without the Redis token cache while Redis is down.
+func AuthMiddleware(db *gorm.DB, verifier *oidc.IDTokenVerifier, rdb *redis.Client, health *services.Health) gin.HandlerFunc {
 
 	mode := strings.ToLower(os.Getenv("TOKEN_VALIDATION_MODE"))
 	ctx := context.Background()
@@ -66,7 +70,7 @@ func AuthMiddleware(db *gorm.DB, verifier *oidc.IDTokenVerifier, rdb *redis.Clie
 				return
 			}
 
-			services.SyncUserFromClaims(db, claims)
+			setCurrentUser(c, db, claims)
 
 			c.Next()
 			return
@@ -81,16 +85,20 @@ func AuthMiddleware(db *gorm.DB, verifier *oidc.IDTokenVerifier, rdb *redis.Clie
 				return
 			}
 
-			services.SyncUserFromClaims(db, claims)
+			setCurrentUser(c, db, claims)
 
 			c.Next()
 			return
 		}
 		if mode == "redis" {
+			cached := health.Available(services.DependencyRedis)
 
-			exists, _ := rdb.Exists(ctx, rawToken).Result()
+			exists := int64(0)
+			if cached {
+				exists, _ = rdb.Exists(ctx, rawToken).Result()
+			}
 			if exists == 1 {
-				services.SyncUserFromClaims(db, claims)
+				setCurrentUser(c, db, claims)
 
 				c.Next()
 				return
@@ -114,11 +122,11 @@ func AuthMiddleware(db *gorm.DB, verifier *oidc.IDTokenVerifier, rdb *redis.Clie
 			expTime := time.Unix(exp, 0)
 			ttl := time.Until(expTime)
 
-			if ttl > 0 {
+			if ttl > 0 && cached {
 				rdb.Set(ctx, rawToken, "valid", ttl)
 			}
 
-			services.SyncUserFromClaims(db, claims)
+			setCurrentUser(c, db, claims)
 
 			c.Next()
 			return
@@ -128,3 +136,17 @@ func AuthMiddleware(db *gorm.DB, verifier *oidc.IDTokenVerifier, rdb *redis.Clie
 		c.Abort()
 	}
 }
+
+func setCurrentUser(c *gin.Context, db *gorm.DB, claims jwt.MapClaims) {
+	c.Set(utils.ClaimsKey, claims)
+
+	user, err := services.SyncUserFromClaims(db, claims)
+	if errors.Is(err, services.ErrNoUserClaims) {
+		return
+	}
+	if err != nil {
+		log.Println("⚠️  Unable to sync user from claims:", err)
+		return
+	}
+	c.Set(utils.UserKey, user)
+}
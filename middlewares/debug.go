@@ -17,6 +17,10 @@
 package middlewares
 
 import (
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"bytes"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -25,38 +29,135 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// debugBodyLimit caps the response body logged by ScopedDebugLogger.
+const debugBodyLimit = 16 << 10
+
 func DebugLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		logRequest(c, "")
+		c.Next()
+		log.Printf("⬅️  Response status: %d", c.Writer.Status())
+	}
+}
 
-		log.Println("────────────────────────────────────────")
-		log.Printf("➡️  %s %s", c.Request.Method, c.Request.URL.Path)
+func logRequest(c *gin.Context, tag string) {
+	log.Println("────────────────────────────────────────")
+	log.Printf("➡️  %s%s %s", tag, c.Request.Method, c.Request.URL.Path)
 
-		log.Println("📌 Headers:")
-		for k, v := range c.Request.Header {
+	log.Println("📌 Headers:")
+	for k, v := range c.Request.Header {
 
-			value := strings.Join(v, ", ")
-			if strings.ToLower(k) == "cookie" {
-				value = maskCookies(value)
-			}
+		value := strings.Join(v, ", ")
+		switch strings.ToLower(k) {
+		case "cookie":
+			value = maskCookies(value)
+		case "authorization":
+			value = maskAuthorization(value)
+		}
+
+		log.Printf("   %s: %s", k, value)
+	}
+
+	if c.Request.Method == http.MethodPost ||
+		c.Request.Method == http.MethodPut ||
+		c.Request.Method == http.MethodPatch {
 
-			log.Printf("   %s: %s", k, value)
+		bodyBytes, _ := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(strings.NewReader(string(bodyBytes)))
+
+		if len(bodyBytes) > 0 {
+			log.Println("📦 Body:")
+			log.Println(string(bodyBytes))
 		}
+	}
+}
 
-		if c.Request.Method == http.MethodPost ||
-			c.Request.Method == http.MethodPut ||
-			c.Request.Method == http.MethodPatch {
+// teeWriter keeps a copy of the start of the response for the debug log.
+type teeWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
 
-			bodyBytes, _ := io.ReadAll(c.Request.Body)
-			c.Request.Body = io.NopCloser(strings.NewReader(string(bodyBytes)))
+func (w *teeWriter) keep(b []byte) {
+	if room := debugBodyLimit - w.body.Len(); room > 0 {
+		if len(b) > room {
+			b = b[:room]
+		}
+		w.body.Write(b)
+	}
+}
+
+func (w *teeWriter) Write(b []byte) (int, error) {
+	w.keep(b)
+	return w.ResponseWriter.Write(b)
+}
 
-			if len(bodyBytes) > 0 {
-				log.Println("📦 Body:")
-				log.Println(string(bodyBytes))
+func (w *teeWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// ScopedDebugLogger logs the requests and responses matched by a debug
+// scope, so that verbose logging can be turned on for one user, client or
+// route without DEBUG. It runs after AuthMiddleware to know the caller.
+func ScopedDebugLogger(scopes *services.DebugScopes) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := services.DebugRequest{Method: c.Request.Method, Route: c.FullPath()}
+		if req.Route == "" {
+			req.Route = c.Request.URL.Path
+		}
+		if user := utils.CurrentUser(c); user != nil {
+			req.UserID, req.UserEmail = user.ID, user.Email
+		}
+		if claims := utils.CurrentClaims(c); claims != nil {
+			for _, key := range []string{"azp", "client_id"} {
+				if client, ok := claims[key].(string); ok && client != "" {
+					req.Client = client
+					break
+				}
 			}
 		}
+
+		scope, ok := scopes.Match(c.Request.Context(), req)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		tag := fmt.Sprintf("[debug %s=%s] ", scope.Kind, scope.Value)
+		logRequest(c, tag)
+		if req.UserID != "" || req.Client != "" {
+			log.Printf("👤 User: %s %s client: %s", req.UserID, req.UserEmail, req.Client)
+		}
+
+		tee := &teeWriter{ResponseWriter: c.Writer}
+		c.Writer = tee
 		c.Next()
-		log.Printf("⬅️  Response status: %d", c.Writer.Status())
+
+		log.Printf("⬅️  %sResponse status: %d", tag, c.Writer.Status())
+		if tee.body.Len() > 0 {
+			log.Println("📦 Response body:")
+			log.Println(tee.body.String())
+			if tee.body.Len() >= debugBodyLimit {
+				log.Printf("   … truncated at %d bytes", debugBodyLimit)
+			}
+		}
+	}
+}
+
+// maskAuthorization keeps the scheme and the end of the credentials, enough
+// to tell tokens apart.
+func maskAuthorization(raw string) string {
+	scheme, token, ok := strings.Cut(raw, " ")
+	if !ok {
+		scheme, token = "", raw
+	}
+	if len(token) > 8 {
+		token = "****" + token[len(token)-6:]
+	} else {
+		token = "****"
 	}
+	return strings.TrimSpace(scheme + " " + token)
 }
 
 func maskCookies(raw string) string {
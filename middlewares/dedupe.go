@@ -0,0 +1,108 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middlewares
+
+import (
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	FingerprintHeader    = "X-Request-Fingerprint"
+	AllowDuplicateHeader = "X-Allow-Duplicate"
+)
+
+// dedupedRoutes are the suffixes of the import and bulk routes checked by
+// DedupeSubmissions.
+var dedupedRoutes = []string{"/import", "/createMany"}
+
+func isDedupedRoute(fullPath string) bool {
+	for _, suffix := range dedupedRoutes {
+		if strings.HasSuffix(fullPath, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// DedupeSubmissions rejects with 409 an import or bulk request identical to
+// one the same user sent within the window: same route, query and body. A
+// request that failed does not count, and ?force=true or X-Allow-Duplicate:
+// true submits it again on purpose. It must run outside Transaction to see
+// the final status.
+func DedupeSubmissions(fp *services.Fingerprints) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !fp.Enabled() || c.Request.Method != http.MethodPost || !isDedupedRoute(c.FullPath()) {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		query := c.Request.URL.Query()
+		force := query.Get("force") == "true" || c.GetHeader(AllowDuplicateHeader) == "true"
+		query.Del("force")
+
+		user := ""
+		if id := utils.CurrentUserID(c); id != nil {
+			user = *id
+		}
+		fingerprint := services.Fingerprint(
+			[]byte(user),
+			[]byte(c.Request.URL.Path),
+			[]byte(query.Encode()),
+			[]byte(c.ContentType()),
+			body,
+		)
+		c.Header(FingerprintHeader, fingerprint)
+
+		ctx := c.Request.Context()
+		fresh, left := fp.Claim(ctx, fingerprint)
+		if !fresh && !force {
+			retry := int(math.Ceil(left.Seconds()))
+			if retry < 1 {
+				retry = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retry))
+			utils.Error(c, http.StatusConflict, "DUPLICATE_SUBMISSION",
+				fmt.Sprintf("The same content was already submitted; resend with ?force=true to apply it again, or wait %ds", retry))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+
+		if fresh && c.Writer.Status() >= http.StatusBadRequest {
+			fp.Release(ctx, fingerprint)
+		}
+	}
+}
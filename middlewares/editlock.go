@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middlewares
+
+import (
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireEditLock rejects with 423 the PUT and PATCH of a builder page while
+// another user holds its edit lock. A page nobody locked stays writable.
+func RequireEditLock(locks *services.EditLocks) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !locks.Enabled() || (c.Request.Method != http.MethodPut && c.Request.Method != http.MethodPatch) ||
+			!strings.Contains(c.FullPath(), "/builder/:id") {
+			c.Next()
+			return
+		}
+
+		lock, held := locks.Get(c.Request.Context(), c.Param("id"))
+		userID := utils.CurrentUserID(c)
+		if !held || (userID != nil && lock.UserID == *userID) {
+			c.Next()
+			return
+		}
+		c.JSON(http.StatusLocked, utils.APIResponse{
+			Success: false,
+			Data:    lock,
+			Error: &utils.APIError{
+				Code:    "PAGE_LOCKED",
+				Details: "Page is being edited by " + lock.UserName,
+			},
+		})
+		c.Abort()
+	}
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middlewares
+
+import (
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Maintenance rejects writes with 503 while the API is read-only, and points
+// reads at the replica when one is configured and up.
+func Maintenance(m *services.Maintenance, replica *gorm.DB, health *services.Health) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state := m.State(c.Request.Context())
+		if !state.ReadOnly {
+			c.Next()
+			return
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if replica != nil && health.Available(services.DependencyReplica) {
+				c.Set(utils.ReadDBKey, replica)
+			}
+			c.Next()
+			return
+		}
+
+		message := state.Message
+		if message == "" {
+			message = "API is read-only during maintenance"
+		}
+		c.Header("Retry-After", strconv.Itoa(state.RetryAfter))
+		utils.Error(c, http.StatusServiceUnavailable, "MAINTENANCE", message)
+		c.Abort()
+	}
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middlewares
+
+import (
+	"api-core-v2/utils"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Scope suffixes. "<resource>:admin" grants both read and write.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+func tokenScopes(claims jwt.MapClaims) map[string]bool {
+	scopes := map[string]bool{}
+
+	if s, ok := claims["scope"].(string); ok {
+		for _, scope := range strings.Fields(s) {
+			scopes[scope] = true
+		}
+	}
+
+	switch scp := claims["scp"].(type) {
+	case string:
+		for _, scope := range strings.Fields(scp) {
+			scopes[scope] = true
+		}
+	case []interface{}:
+		for _, v := range scp {
+			if scope, ok := v.(string); ok {
+				scopes[scope] = true
+			}
+		}
+	}
+
+	return scopes
+}
+
+func trustedClients() map[string]bool {
+	trusted := map[string]bool{}
+	for _, client := range strings.Split(os.Getenv("OIDC_TRUSTED_CLIENTS"), ",") {
+		if client = strings.TrimSpace(client); client != "" {
+			trusted[client] = true
+		}
+	}
+	return trusted
+}
+
+// RequireScope guards a route group with "<resource>:read" on safe methods
+// and "<resource>:write" on the others. Tokens issued to a client listed in
+// OIDC_TRUSTED_CLIENTS (the interactive frontends) keep full access; every
+// other authorized party must carry the scope. Enforcement is enabled with
+// SCOPE_ENFORCEMENT=true.
+func RequireScope(resource string) gin.HandlerFunc {
+
+	enforced := os.Getenv("SCOPE_ENFORCEMENT") == "true"
+	trusted := trustedClients()
+
+	return func(c *gin.Context) {
+
+		if !enforced {
+			c.Next()
+			return
+		}
+
+		claims := utils.CurrentClaims(c)
+		if azp, _ := claims["azp"].(string); azp != "" && trusted[azp] {
+			c.Next()
+			return
+		}
+
+		needed := resource + ":" + ScopeWrite
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			needed = resource + ":" + ScopeRead
+		}
+
+		scopes := tokenScopes(claims)
+		if scopes[needed] || scopes[resource+":"+ScopeAdmin] {
+			c.Next()
+			return
+		}
+
+		utils.Error(c, http.StatusForbidden, "INSUFFICIENT_SCOPE", "Missing scope "+needed)
+		c.Abort()
+	}
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middlewares
+
+import (
+	"api-core-v2/utils"
+	"bytes"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// bufferedWriter holds the response back until the transaction outcome is
+// known, so a failed commit never follows a success already sent.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.status != 0 || w.body.Len() > 0
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// Transaction wraps every mutating request in a single database transaction,
+// exposed to handlers through utils.DB. It commits when the handler answered
+// with a status below 400 and rolls back otherwise.
+func Transaction(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		if !isMutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		tx := db.Begin()
+		if tx.Error != nil {
+			utils.Error(c, http.StatusServiceUnavailable, "DB_TX_BEGIN_ERROR", tx.Error.Error())
+			c.Abort()
+			return
+		}
+
+		original := c.Writer
+		buffered := &bufferedWriter{ResponseWriter: original}
+		c.Writer = buffered
+		c.Set(utils.TxKey, tx)
+
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+				c.Writer = original
+				panic(r)
+			}
+		}()
+
+		c.Next()
+
+		c.Writer = original
+
+		status := buffered.Status()
+		if status >= http.StatusBadRequest || len(c.Errors) > 0 {
+			if err := tx.Rollback().Error; err != nil {
+				log.Printf("❌ [TX] Rollback %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+			}
+		} else if err := tx.Commit().Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_TX_COMMIT_ERROR", err.Error())
+			return
+		} else {
+			utils.RunAfterCommit(c)
+		}
+
+		original.WriteHeader(status)
+		original.Write(buffered.body.Bytes())
+	}
+}
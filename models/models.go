@@ -74,9 +74,19 @@ type Template struct {
 }
 
 
+// Publishing states of a page. Pages start as drafts, are submitted for
+// review and published by a reviewer; archived pages are no longer served.
+const (
+	PageStatusDraft     = "draft"
+	PageStatusInReview  = "in_review"
+	PageStatusPublished = "published"
+	PageStatusArchived  = "archived"
+)
+
 type Page struct {
 	ID          string         `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
 	Name        string         `gorm:"unique;not null" json:"name"`
+	Slug        string         `gorm:"type:varchar(255);uniqueIndex" json:"slug"`
 	TemplateID  *string        `gorm:"type:uuid" json:"templateId,omitempty"`
 	Template    *Template      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"template,omitempty" crud:"dependency"`
 
@@ -89,6 +99,7 @@ type Page struct {
 	SchemaMenuUi     datatypes.JSON `gorm:"type:jsonb;column:schema_menu_ui" json:"schemaMenuUi,omitempty"`
 	SchemaConditions datatypes.JSON `gorm:"type:jsonb;column:schema_conditions" json:"schemaConditions,omitempty"`
 	SchemaFunctions datatypes.JSON `gorm:"type:jsonb;column:schema_functions" json:"schemaFunctions,omitempty"`
+	SchemaQuery      datatypes.JSON `gorm:"type:jsonb;column:schema_query" json:"schemaQuery,omitempty"`
 
 	SchemaColumnsDeployed    datatypes.JSON `gorm:"type:jsonb;column:schema_columns_deployed" json:"schemaColumnsDeployed,omitempty"`
 	SchemaRelationsDeployed  datatypes.JSON `gorm:"type:jsonb;column:schema_relations_deployed" json:"schemaRelationsDeployed,omitempty"`
@@ -96,27 +107,73 @@ type Page struct {
 	SchemaMenuUiDeployed     datatypes.JSON `gorm:"type:jsonb;column:schema_menu_ui_deployed" json:"schemaMenuUiDeployed,omitempty"`
 	SchemaConditionsDeployed datatypes.JSON `gorm:"type:jsonb;column:schema_conditions_deployed" json:"schemaConditionsDeployed,omitempty"`
 	SchemaFunctionsDeployed datatypes.JSON `gorm:"type:jsonb;column:schema_functions_deployed" json:"schemaFunctionsDeployed,omitempty"`
+	SchemaQueryDeployed      datatypes.JSON `gorm:"type:jsonb;column:schema_query_deployed" json:"schemaQueryDeployed,omitempty"`
+
+	// SchemaUiRollout is served instead of SchemaUiDeployed to the audience
+	// of UiRollout until it is promoted or abandoned.
+	SchemaUiRollout datatypes.JSON `gorm:"type:jsonb;column:schema_ui_rollout" json:"schemaUiRollout,omitempty"`
+	UiRollout       datatypes.JSON `gorm:"type:jsonb;column:ui_rollout" json:"uiRollout,omitempty"`
 	
 
 	TableName string `gorm:"type:varchar(255)" json:"tableName"`
+	// ViewQuery is the SELECT a deploy creates TableName from, as a view.
+	// Pages over a view, created here or not, are read-only.
+	ViewQuery string `gorm:"type:text;column:view_query" json:"viewQuery,omitempty"`
+	// ReadOnly refuses the row writes of the API; a deploy over a view sets
+	// it.
+	ReadOnly *bool `gorm:"default:false" json:"readOnly"`
+	// Source makes the page a read-only view over a REST endpoint instead of
+	// a table; see routes.RESTSource.
+	Source datatypes.JSON `gorm:"type:jsonb;column:source" json:"source,omitempty"`
+	Storage   string `gorm:"type:varchar(64)" json:"storage,omitempty"`
+	// IDStrategy picks how the ids of new rows are made: uuid, ulid,
+	// bigserial, prefixed (IDPrefix_ULID), or the table default when empty.
+	IDStrategy string `gorm:"type:varchar(16);column:id_strategy" json:"idStrategy,omitempty"`
+	IDPrefix   string `gorm:"type:varchar(16);column:id_prefix" json:"idPrefix,omitempty"`
+	// StampTrigger adds a trigger keeping updated_at current on writes that
+	// bypass the API.
+	StampTrigger *bool `gorm:"default:false" json:"stampTrigger"`
 	Deploy    *bool   `gorm:"default:false" json:"deploy"`
 
 	Tags []Tag `gorm:"many2many:page_tags;constraint:OnDelete:CASCADE;" json:"tags,omitempty" crud:"dependency"`
 
+	// Status is where the page stands in the publishing workflow. Only
+	// published pages are served to non-admins.
+	Status string `gorm:"type:varchar(16);not null;default:published;index" json:"status"`
+
+	// Revision counts the updates of the page, from 1. The builder sends the
+	// revision it edited so that a stale save is refused.
+	Revision int `gorm:"not null;default:1" json:"revision"`
+
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
 }
 
+// BeforeUpdate bumps the revision of the pages written by a map update that
+// does not set it. Struct updates set Revision themselves.
+func (p *Page) BeforeUpdate(tx *gorm.DB) error {
+	if updates, ok := tx.Statement.Dest.(map[string]any); ok {
+		if _, set := updates["revision"]; !set {
+			updates["revision"] = gorm.Expr("revision + 1")
+		}
+	}
+	return nil
+}
+
 type NavigationItem struct {
 	ID       string          `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
 	ParentID *string         `gorm:"type:uuid;index" json:"parentId,omitempty"`
 	Parent   *NavigationItem `gorm:"foreignKey:ParentID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"parent,omitempty" crud:"dependency"`
+	Menu     string          `gorm:"type:varchar(64);not null;default:'sidebar';index" json:"menu"`
 	Lft   int `gorm:"index" json:"lft"`
 	Rgt   int `gorm:"index" json:"rgt"`
 	Depth int `gorm:"default:0" json:"depth"`
 	Title     string `gorm:"not null" json:"title"`
 	Icon      string `json:"icon,omitempty"`
 	Path      string `gorm:"index" json:"path,omitempty"`
+	Href      string `json:"href,omitempty"`
+	Target    string `gorm:"type:varchar(16)" json:"target,omitempty"`
+	Rel       string `json:"rel,omitempty"`
 	Order     int    `gorm:"default:0" json:"order"`
 	Disabled  *bool   `gorm:"default:false" json:"disabled"`
 	Caption   string `json:"caption,omitempty"`
@@ -145,8 +202,179 @@ type AuditLog struct {
 	CreatedAt  time.Time      `gorm:"autoCreateTime" json:"createdAt"`
 }
 
-func AutoMigrateAll(db *gorm.DB) error {
-	return db.AutoMigrate(
+type DigestSubscription struct {
+	ID         string     `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID     string     `gorm:"type:uuid;not null;index" json:"userId"`
+	User       *User      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user,omitempty" crud:"dependency"`
+	PageID     string     `gorm:"type:uuid;not null;index" json:"pageId"`
+	Page       *Page      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"page,omitempty" crud:"dependency"`
+	Frequency  string     `gorm:"type:varchar(16);not null;default:daily" json:"frequency"`
+	Channel    string     `gorm:"type:varchar(16);not null;default:email" json:"channel"`
+	Target     string     `json:"target,omitempty"`
+	LastSentAt *time.Time `json:"lastSentAt,omitempty"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+type PageChangelog struct {
+	ID        string         `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	PageID    string         `gorm:"type:uuid;not null;index" json:"pageId"`
+	Page      *Page          `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	UserID    *string        `gorm:"type:uuid;index" json:"userId,omitempty"`
+	User      *User          `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"user,omitempty" crud:"dependency"`
+	Kind      string         `gorm:"type:varchar(32);not null;index" json:"kind"`
+	Target    string         `json:"target,omitempty"`
+	Details   datatypes.JSON `gorm:"type:jsonb" json:"details,omitempty"`
+	CreatedAt time.Time      `gorm:"autoCreateTime;index" json:"createdAt"`
+}
+
+type ShareLink struct {
+	ID          string     `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Token       string     `gorm:"type:varchar(32);uniqueIndex;not null" json:"token"`
+	PageID      string     `gorm:"type:uuid;not null;index" json:"pageId"`
+	Page        *Page      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	ItemID      string     `gorm:"not null" json:"itemId"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	CreatedByID *string    `gorm:"type:uuid" json:"createdById,omitempty"`
+	CreatedBy   *User      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"-"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+type DeadLetter struct {
+	ID         string         `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Kind       string         `gorm:"type:varchar(64);not null;index" json:"kind"`
+	Target     string         `json:"target,omitempty"`
+	Payload    datatypes.JSON `gorm:"type:jsonb" json:"payload"`
+	Error      string         `gorm:"type:text" json:"error"`
+	Attempts   int            `gorm:"default:0" json:"attempts"`
+	Status     string         `gorm:"type:varchar(16);not null;default:pending;index" json:"status"`
+	ReplayedAt *time.Time     `json:"replayedAt,omitempty"`
+	CreatedAt  time.Time      `gorm:"autoCreateTime;index" json:"createdAt"`
+	UpdatedAt  time.Time      `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// Webhook receives the row changes of deployed pages. PageID restricts it to
+// one page and Events to some events; empty means all of them.
+type Webhook struct {
+	ID          string         `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	URL         string         `gorm:"not null" json:"url"`
+	Secret      string         `gorm:"not null" json:"secret,omitempty"`
+	Events      datatypes.JSON `gorm:"type:jsonb" json:"events"`
+	PageID      *string        `gorm:"type:uuid;index" json:"pageId,omitempty"`
+	Page        *Page          `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"page,omitempty" crud:"dependency"`
+	Active      *bool          `gorm:"default:true" json:"active"`
+	CreatedByID *string        `gorm:"type:uuid" json:"createdById,omitempty"`
+	CreatedAt   time.Time      `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt   time.Time      `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// RowTombstone remembers a row deleted from the table of a page, so that
+// clients syncing the page incrementally learn about the deletion.
+type RowTombstone struct {
+	ID        string    `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	PageID    string    `gorm:"type:uuid;not null;index:idx_row_tombstones_page_deleted" json:"pageId"`
+	RowID     string    `gorm:"not null" json:"rowId"`
+	DeletedAt time.Time `gorm:"not null;index:idx_row_tombstones_page_deleted" json:"deletedAt"`
+}
+
+// Comment is a note left by a user on a row of a deployed page.
+type Comment struct {
+	ID        string    `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	PageID    string    `gorm:"type:uuid;not null;index:idx_comments_item" json:"pageId"`
+	Page      *Page     `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	ItemID    string    `gorm:"not null;index:idx_comments_item" json:"itemId"`
+	UserID    *string   `gorm:"type:uuid;index" json:"userId,omitempty"`
+	User      *User     `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"user,omitempty" crud:"dependency"`
+	Body      string    `gorm:"type:text;not null" json:"body"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// TrashItem is a deleted record kept in the recycle bin until it is
+// restored or its retention expires. Data holds the record as it was: the
+// JSON of a core model, or the columns, links and files of a page row.
+type TrashItem struct {
+	ID         string         `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Kind       string         `gorm:"type:varchar(32);not null;index" json:"kind"`
+	ResourceID string         `gorm:"not null;index" json:"resourceId"`
+	PageID     *string        `gorm:"type:uuid;index" json:"pageId,omitempty"`
+	Label      string         `json:"label,omitempty"`
+	Data       datatypes.JSON `gorm:"type:jsonb;not null" json:"data"`
+	DeletedBy  *string        `gorm:"type:uuid" json:"deletedBy,omitempty"`
+	DeletedAt  time.Time      `gorm:"not null;index" json:"deletedAt"`
+}
+
+// PageDeployment is a version of the deployed schemas of a page, recorded on
+// every deploy so that the page can be rolled back to it.
+type PageDeployment struct {
+	ID                       string         `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	PageID                   string         `gorm:"type:uuid;not null;uniqueIndex:idx_page_deployments_version" json:"pageId"`
+	Page                     *Page          `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Version                  int            `gorm:"not null;uniqueIndex:idx_page_deployments_version" json:"version"`
+	TableName                string         `gorm:"type:varchar(255)" json:"tableName"`
+	SchemaColumnsDeployed    datatypes.JSON `gorm:"type:jsonb" json:"schemaColumns,omitempty"`
+	SchemaRelationsDeployed  datatypes.JSON `gorm:"type:jsonb" json:"schemaRelations,omitempty"`
+	SchemaUiDeployed         datatypes.JSON `gorm:"type:jsonb" json:"schemaUi,omitempty"`
+	SchemaMenuUiDeployed     datatypes.JSON `gorm:"type:jsonb" json:"schemaMenuUi,omitempty"`
+	SchemaConditionsDeployed datatypes.JSON `gorm:"type:jsonb" json:"schemaConditions,omitempty"`
+	SchemaFunctionsDeployed  datatypes.JSON `gorm:"type:jsonb" json:"schemaFunctions,omitempty"`
+	SchemaQueryDeployed      datatypes.JSON `gorm:"type:jsonb" json:"schemaQuery,omitempty"`
+	UserID                   *string        `gorm:"type:uuid;index" json:"userId,omitempty"`
+	User                     *User          `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"user,omitempty" crud:"dependency"`
+	CreatedAt                time.Time      `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// PageRevision is an immutable snapshot of the draft schemas of a page,
+// recorded on every change to them with the diff from the previous ones.
+type PageRevision struct {
+	ID               string         `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	PageID           string         `gorm:"type:uuid;not null;uniqueIndex:idx_page_revisions_number" json:"pageId"`
+	Page             *Page          `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Number           int            `gorm:"not null;uniqueIndex:idx_page_revisions_number" json:"number"`
+	SchemaColumns    datatypes.JSON `gorm:"type:jsonb" json:"schemaColumns,omitempty"`
+	SchemaRelations  datatypes.JSON `gorm:"type:jsonb" json:"schemaRelations,omitempty"`
+	SchemaUi         datatypes.JSON `gorm:"type:jsonb" json:"schemaUi,omitempty"`
+	SchemaMenuUi     datatypes.JSON `gorm:"type:jsonb" json:"schemaMenuUi,omitempty"`
+	SchemaConditions datatypes.JSON `gorm:"type:jsonb" json:"schemaConditions,omitempty"`
+	SchemaFunctions  datatypes.JSON `gorm:"type:jsonb" json:"schemaFunctions,omitempty"`
+	SchemaQuery      datatypes.JSON `gorm:"type:jsonb" json:"schemaQuery,omitempty"`
+	Diff             datatypes.JSON `gorm:"type:jsonb" json:"diff,omitempty"`
+	UserID           *string        `gorm:"type:uuid;index" json:"userId,omitempty"`
+	User             *User          `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"user,omitempty" crud:"dependency"`
+	CreatedAt        time.Time      `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// States of a ScheduledDeploy.
+const (
+	ScheduledDeployPending   = "pending"
+	ScheduledDeployRunning   = "running"
+	ScheduledDeploySucceeded = "succeeded"
+	ScheduledDeployFailed    = "failed"
+	ScheduledDeployCancelled = "cancelled"
+)
+
+// ScheduledDeploy is a deploy of a page planned for ScheduledAt, run by the
+// deploy scheduler with the draft schemas of that time. Migration holds the
+// DeployMigration of the deploy, Plan the statements it ran.
+type ScheduledDeploy struct {
+	ID          string         `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	PageID      string         `gorm:"type:uuid;not null;index" json:"pageId"`
+	Page        *Page          `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	ScheduledAt time.Time      `gorm:"not null;index:idx_scheduled_deploys_due" json:"scheduledAt"`
+	Status      string         `gorm:"type:varchar(16);not null;default:pending;index:idx_scheduled_deploys_due" json:"status"`
+	Migration   datatypes.JSON `gorm:"type:jsonb" json:"migration,omitempty"`
+	Plan        datatypes.JSON `gorm:"type:jsonb" json:"plan,omitempty"`
+	Error       string         `gorm:"type:text" json:"error,omitempty"`
+	UserID      *string        `gorm:"type:uuid;index" json:"userId,omitempty"`
+	User        *User          `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"user,omitempty" crud:"dependency"`
+	StartedAt   *time.Time     `json:"startedAt,omitempty"`
+	FinishedAt  *time.Time     `json:"finishedAt,omitempty"`
+	CreatedAt   time.Time      `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// All lists the core models, owned by the API rather than by builder pages.
+func All() []any {
+	return []any{
 		&User{},
 		&AuditLog{},
 		&TagCategory{},
@@ -154,5 +382,20 @@ func AutoMigrateAll(db *gorm.DB) error {
 		&Template{},
 		&Page{},
 		&NavigationItem{},
-	)
+		&DigestSubscription{},
+		&PageChangelog{},
+		&ShareLink{},
+		&DeadLetter{},
+		&Webhook{},
+		&RowTombstone{},
+		&TrashItem{},
+		&Comment{},
+		&PageDeployment{},
+		&PageRevision{},
+		&ScheduledDeploy{},
+	}
+}
+
+func AutoMigrateAll(db *gorm.DB) error {
+	return db.AutoMigrate(All()...)
 }
@@ -19,6 +19,9 @@ package models
 type NavItem struct {
 	Title        string      `json:"title"`
 	Path         string      `json:"path,omitempty"`
+	Href         string      `json:"href,omitempty"`
+	Target       string      `json:"target,omitempty"`
+	Rel          string      `json:"rel,omitempty"`
 	Icon         string      `json:"icon,omitempty"`
 	Caption      string      `json:"caption,omitempty"`
 	Disabled     bool        `json:"disabled,omitempty"`
@@ -0,0 +1,147 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Sources of the admin activity feed.
+const (
+	ActivityAudit  = "audit"
+	ActivityDeploy = "deploy"
+	ActivityJob    = "job"
+)
+
+const (
+	activityDefaultPageSize = 50
+	activityMaxPageSize     = 200
+)
+
+type ActivityEntry struct {
+	Source     string         `json:"source"`
+	ID         string         `json:"id"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	UserID     *string        `json:"userId,omitempty"`
+	User       *models.User   `gorm:"-" json:"user,omitempty"`
+	Action     string         `json:"action"`
+	Resource   string         `json:"resource"`
+	ResourceID *string        `json:"resourceId,omitempty"`
+	Status     string         `json:"status"`
+	Details    datatypes.JSON `json:"details,omitempty"`
+}
+
+// activityQuery projects audit logs, page schema history and failed jobs
+// onto the ActivityEntry columns.
+func activityQuery(db *gorm.DB) *gorm.DB {
+	db = db.Session(&gorm.Session{NewDB: true})
+
+	audit := db.Model(&models.AuditLog{}).Select(`'` + ActivityAudit + `' AS source, id::text AS id, created_at,
+		user_id::text AS user_id, action, resource, resource_id::text AS resource_id, status, metadata AS details`)
+
+	deploys := db.Model(&models.PageChangelog{}).Select(`'` + ActivityDeploy + `' AS source, id::text AS id, created_at,
+		user_id::text AS user_id, kind AS action, 'page' AS resource, page_id::text AS resource_id,
+		'success' AS status, details`)
+
+	jobs := db.Model(&models.DeadLetter{}).Select(`'` + ActivityJob + `' AS source, id::text AS id, created_at,
+		NULL AS user_id, kind AS action, 'job' AS resource, NULL AS resource_id, status,
+		jsonb_build_object('target', target, 'error', error, 'attempts', attempts) AS details`)
+
+	return db.Table("(? UNION ALL ? UNION ALL ?) AS activity", audit, deploys, jobs)
+}
+
+func RegisterActivityRoutes(group *gin.RouterGroup, db *gorm.DB) {
+	group.GET("/activity", func(c *gin.Context) {
+		pagination := utils.ParsePagination(c, activityDefaultPageSize, activityMaxPageSize)
+
+		query := activityQuery(db)
+		if v := c.Query("source"); v != "" {
+			query = query.Where("source IN ?", strings.Split(v, ","))
+		}
+		for _, filter := range []string{"action", "resource", "status"} {
+			if v := c.Query(filter); v != "" {
+				query = query.Where(filter+" = ?", v)
+			}
+		}
+		if v := c.Query("userId"); v != "" {
+			query = query.Where("user_id = ?", v)
+		}
+		if v := c.Query("resourceId"); v != "" {
+			query = query.Where("resource_id = ?", v)
+		}
+		for param, op := range map[string]string{"since": ">=", "until": "<"} {
+			v := c.Query(param)
+			if v == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				utils.Error(c, http.StatusBadRequest, "INVALID_"+strings.ToUpper(param), param+" must be an RFC3339 timestamp")
+				return
+			}
+			query = query.Where("created_at "+op+" ?", t)
+		}
+
+		var total int64
+		if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+
+		entries := []ActivityEntry{}
+		if err := query.Order("created_at DESC").Limit(pagination.PageSize).Offset(pagination.Offset()).
+			Scan(&entries).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+
+		userIDs := []string{}
+		for _, e := range entries {
+			if e.UserID != nil {
+				userIDs = append(userIDs, *e.UserID)
+			}
+		}
+		if len(userIDs) > 0 {
+			var users []models.User
+			if err := db.Where("id IN ?", userIDs).Find(&users).Error; err == nil {
+				byID := make(map[string]*models.User, len(users))
+				for i := range users {
+					byID[users[i].ID] = &users[i]
+				}
+				for i, e := range entries {
+					if e.UserID != nil {
+						entries[i].User = byID[*e.UserID]
+					}
+				}
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data":    entries,
+			"meta":    pagination.Meta(total, len(entries)),
+			"success": true,
+		})
+	})
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func recordAudit(c *gin.Context, db *gorm.DB, action, resource string, resourceID *string, status string, metadata any) {
+	entry := models.AuditLog{
+		UserID:     utils.CurrentUserID(c),
+		Action:     action,
+		Resource:   resource,
+		ResourceID: resourceID,
+		Status:     status,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+	if metadata != nil {
+		if raw, err := json.Marshal(metadata); err == nil {
+			entry.Metadata = raw
+		}
+	}
+	services.RecordAudit(db, entry)
+}
+
+func recordRowAudit(c *gin.Context, db *gorm.DB, action string, page models.Page, itemID string) {
+	recordAudit(c, db, action, services.AuditResourcePageRow, &page.ID, services.AuditStatusSuccess, gin.H{
+		"itemId": itemID,
+		"table":  page.TableName,
+	})
+}
+
+// BuilderAuditChange is a page field changed by a builder mutation, with
+// its JSON value before and after (null when absent).
+type BuilderAuditChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// builderAuditIgnored are the page fields left out of the builder audit:
+// bookkeeping, preloaded associations, and the status whose transitions
+// are audited on their own.
+var builderAuditIgnored = map[string]bool{
+	"id": true, "revision": true, "status": true, "createdAt": true, "updatedAt": true,
+	"template": true, "ficheTemplate": true, "tags": true,
+}
+
+func pageAuditFields(page models.Page) map[string]json.RawMessage {
+	fields := map[string]json.RawMessage{}
+	if page.ID == "" {
+		return fields
+	}
+	raw, err := json.Marshal(page)
+	if err == nil {
+		_ = json.Unmarshal(raw, &fields)
+	}
+	for field := range builderAuditIgnored {
+		delete(fields, field)
+	}
+	return fields
+}
+
+// diffBuilderAudit lists the fields that differ between two states of a
+// page, by name. A zero page stands for a page created or deleted.
+func diffBuilderAudit(before, after models.Page) []BuilderAuditChange {
+	old, updated := pageAuditFields(before), pageAuditFields(after)
+	names := map[string]bool{}
+	for name := range old {
+		names[name] = true
+	}
+	for name := range updated {
+		names[name] = true
+	}
+	changes := []BuilderAuditChange{}
+	null := json.RawMessage("null")
+	for name := range names {
+		change := BuilderAuditChange{Field: name, Before: old[name], After: updated[name]}
+		if change.Before == nil {
+			change.Before = null
+		}
+		if change.After == nil {
+			change.After = null
+		}
+		if !bytes.Equal(change.Before, change.After) {
+			changes = append(changes, change)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// recordBuilderAudit records a builder mutation of a page in the audit
+// log, with the fields it changed. Nothing is recorded when none did.
+func recordBuilderAudit(c *gin.Context, db *gorm.DB, before, after models.Page) {
+	changes := diffBuilderAudit(before, after)
+	if len(changes) == 0 {
+		return
+	}
+	action, id := services.AuditActionUpdate, after.ID
+	switch {
+	case before.ID == "":
+		action = services.AuditActionCreate
+	case after.ID == "":
+		action, id = services.AuditActionDelete, before.ID
+	}
+	recordAudit(c, db, action, services.AuditResourcePage, &id, services.AuditStatusSuccess, gin.H{
+		"route":   c.Request.Method + " " + c.FullPath(),
+		"changes": changes,
+	})
+}
+
+// RegisterBuilderAuditRoutes exposes the audit trail of a page: its builder
+// mutations and publishing transitions, deleted pages included.
+func RegisterBuilderAuditRoutes(group *gin.RouterGroup, db *gorm.DB) {
+	// GET /builder/:id/audit lists the entries newest first, paged with
+	// ?page and ?pageSize, from ?since (RFC3339) and for ?action when given.
+	group.GET("/builder/:id/audit", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		if !uuidPattern.MatchString(c.Param("id")) {
+			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+			return
+		}
+		query := db.Model(&models.AuditLog{}).
+			Where("resource = ? AND resource_id = ?", services.AuditResourcePage, c.Param("id")).
+			Order("created_at DESC")
+		if since := c.Query("since"); since != "" {
+			t, err := time.Parse(time.RFC3339, since)
+			if err != nil {
+				utils.Error(c, http.StatusBadRequest, "INVALID_SINCE", "since must be an RFC3339 timestamp")
+				return
+			}
+			query = query.Where("created_at > ?", t)
+		}
+		if action := c.Query("action"); action != "" {
+			query = query.Where("action = ?", action)
+		}
+
+		entries := []models.AuditLog{}
+		meta, err := utils.FindPage(query, utils.ParsePagination(c, 0, listMaxPageSize), &entries,
+			func(q *gorm.DB) *gorm.DB { return q.Preload("User", userSummary) })
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_AUDIT_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": entries, "meta": meta, "success": true})
+	})
+}
@@ -0,0 +1,227 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+//go:embed blueprints/*.json
+var builtinBlueprints embed.FS
+
+// A Blueprint is a ready-made page offered to new builders: a bundle with a
+// name to instantiate it by and a short description for the gallery.
+type Blueprint struct {
+	Name        string     `json:"name"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	Bundle      PageBundle `json:"bundle"`
+}
+
+// readBlueprints decodes the JSON blueprints of dir in fsys into byName. A
+// blueprint without a name is named after its file.
+func readBlueprints(fsys fs.FS, dir string, byName map[string]Blueprint) error {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		body, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		var bp Blueprint
+		if err := json.Unmarshal(body, &bp); err != nil {
+			return fmt.Errorf("blueprint %s: %w", file, err)
+		}
+		if bp.Name == "" {
+			bp.Name = strings.TrimSuffix(path.Base(file), ".json")
+		}
+		if bp.Title == "" {
+			bp.Title = bp.Bundle.Name
+		}
+		if bp.Bundle.Format == 0 {
+			bp.Bundle.Format = pageBundleFormat
+		}
+		byName[bp.Name] = bp
+	}
+	return nil
+}
+
+// loadBlueprints lists the built-in blueprints and those of
+// PAGE_BLUEPRINTS_DIR, which replace a built-in one of the same name. The
+// directory is read on every call, so blueprints can be added without a
+// restart.
+func loadBlueprints() ([]Blueprint, error) {
+	byName := map[string]Blueprint{}
+	if err := readBlueprints(builtinBlueprints, "blueprints", byName); err != nil {
+		return nil, err
+	}
+	if dir := os.Getenv("PAGE_BLUEPRINTS_DIR"); dir != "" {
+		if err := readBlueprints(os.DirFS(dir), ".", byName); err != nil {
+			return nil, err
+		}
+	}
+
+	list := make([]Blueprint, 0, len(byName))
+	for _, bp := range byName {
+		list = append(list, bp)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Title < list[j].Title })
+	return list, nil
+}
+
+func RegisterBuilderBlueprintRoutes(group *gin.RouterGroup, db *gorm.DB, cache *services.Cache) {
+	builder := group.Group("/builder")
+
+	builder.GET("/blueprints", func(c *gin.Context) {
+		blueprints, err := loadBlueprints()
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "BLUEPRINTS_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": blueprints, "success": true})
+	})
+
+	// POST /builder/from-blueprint/:name creates a draft page from the
+	// blueprint. The body may rename the page and its table, and deploy it
+	// right away.
+	builder.POST("/from-blueprint/:name", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		var payload struct {
+			Name      string `json:"name"`
+			Slug      string `json:"slug"`
+			TableName string `json:"tableName"`
+			Storage   string `json:"storage"`
+			Deploy    bool   `json:"deploy"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&payload); err != nil {
+				utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+				return
+			}
+		}
+
+		blueprints, err := loadBlueprints()
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "BLUEPRINTS_ERROR", err.Error())
+			return
+		}
+		idx := -1
+		for i, bp := range blueprints {
+			if bp.Name == c.Param("name") {
+				idx = i
+			}
+		}
+		if idx < 0 {
+			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Blueprint not found")
+			return
+		}
+		bundle := blueprints[idx].Bundle
+		if payload.Name != "" {
+			bundle.Name = payload.Name
+		}
+		if payload.Slug != "" {
+			bundle.Slug = payload.Slug
+		}
+		if payload.TableName != "" {
+			bundle.TableName = payload.TableName
+		}
+		if payload.Storage != "" {
+			bundle.Storage = payload.Storage
+		}
+
+		page, tags, err := bundlePage(db, bundle)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrInvalidBundle) {
+				status = http.StatusBadRequest
+			}
+			utils.Error(c, status, "INVALID_BLUEPRINT", err.Error())
+			return
+		}
+		if err := checkPageOwnership(db, page); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_TABLE", err.Error())
+			return
+		}
+		var taken int64
+		if err := db.Model(&models.Page{}).Where("name = ? OR (table_name = ? AND storage = ?)", page.Name, page.TableName, page.Storage).
+			Count(&taken).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+		if taken > 0 {
+			utils.Error(c, http.StatusConflict, "PAGE_EXISTS", fmt.Sprintf("a page named %q or on table %q already exists", page.Name, page.TableName))
+			return
+		}
+		if !checkPageSchemas(c, db, page) {
+			return
+		}
+
+		base := bundle.Slug
+		if base == "" {
+			base = bundle.Name
+		}
+		if page.Slug, err = uniquePageSlug(db, base, ""); err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+		page.Status = models.PageStatusDraft
+		if err := db.Omit("Tags").Create(&page).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_CREATE_ERROR", err.Error())
+			return
+		}
+		if len(tags) > 0 {
+			if err := db.Model(&page).Association("Tags").Replace(tags); err != nil {
+				utils.Error(c, http.StatusInternalServerError, "DB_ASSOCIATION_ERROR", err.Error())
+				return
+			}
+		}
+
+		var plan *deployPlan
+		if payload.Deploy {
+			if plan, err = deployPage(c, db, page, DeployMigration{}); err != nil {
+				utils.Error(c, deployErrorStatus(err), "DEPLOY_ERROR", err.Error())
+				return
+			}
+			invalidatePageCache(c, cache, page.ID)
+		}
+
+		var created models.Page
+		if err := db.Preload("Template").Preload("Tags.Category").First(&created, "id = ?", page.ID).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
+			return
+		}
+		recordSchemaChangelog(c, db, models.Page{}, created)
+		c.JSON(http.StatusCreated, gin.H{"data": created, "deploy": plan, "success": true})
+	})
+}
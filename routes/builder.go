@@ -18,26 +18,54 @@ package routes
 
 import (
 	"api-core-v2/models"
+	"api-core-v2/services"
 	"api-core-v2/utils"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mitchellh/mapstructure"
 	"gorm.io/gorm"
 )
 
-func RegisterBuilderRoutes(group *gin.RouterGroup, db *gorm.DB) {
+func RegisterBuilderRoutes(group *gin.RouterGroup, db *gorm.DB, cache *services.Cache) {
 	builder := group.Group("/builder")
 
 	builder.GET("", func(c *gin.Context) {
-		var pages []models.Page
 		var tags []models.Tag
 		var templates []models.Template
 
-		if err := db.Preload("Template").Preload("Tags.Category").Find(&pages).Error; err != nil {
+		// ?summary=true leaves the schema bodies out of the pages.
+		summary := c.Query("summary") == "true"
+		preload := func(q *gorm.DB) *gorm.DB {
+			if summary {
+				q = q.Omit(pageSchemaColumns...)
+			}
+			return q.Preload("Template").Preload("Tags.Category")
+		}
+		pagination := utils.ParsePagination(c, 0, listMaxPageSize)
+		query, filtered := filterBuilderPages(c, db)
+		if pagination.Paged() {
+			query = query.Order("id")
+		}
+		pages := []models.Page{}
+		meta, err := utils.FindPage(query, pagination, &pages, preload)
+		if err != nil {
 			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_PAGES_ERROR", err.Error())
 			return
 		}
+		// The dependencies keep every page, for the relation pickers.
+		allPages := pages
+		if pagination.Paged() || filtered {
+			allPages = nil
+			if err := preload(db).Find(&allPages).Error; err != nil {
+				utils.Error(c, http.StatusInternalServerError, "DB_FETCH_PAGES_ERROR", err.Error())
+				return
+			}
+		}
 		if err := db.Preload("Category").Find(&tags).Error; err != nil {
 			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_TAGS_ERROR", err.Error())
 			return
@@ -49,21 +77,77 @@ func RegisterBuilderRoutes(group *gin.RouterGroup, db *gorm.DB) {
 
 		c.JSON(http.StatusOK, gin.H{
 			"data": pages,
+			"meta": meta,
 			"dependencies": gin.H{
 				"tags":      tags,
 				"templates": templates,
-				"pages": pages,
+				"pages": allPages,
 			},
 			"success": true,
 		})
 	})
 
+	builder.GET("/:id/preview", func(c *gin.Context) {
+		var page models.Page
+		if err := db.Preload("Template").First(&page, "id = ?", c.Param("id")).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+				return
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+
+		limit := previewDefaultLimit
+		if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+			limit = min(v, previewMaxLimit)
+		}
+
+		payload, err := buildPagePreview(db, page, limit)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if isIdentifierError(err) {
+				status = http.StatusBadRequest
+			}
+			utils.Error(c, status, "PREVIEW_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, payload)
+	})
+
 	builder.POST("", func(c *gin.Context) {
+		db := utils.DB(c, db)
 		var payload models.Page
 		if err := c.ShouldBindJSON(&payload); err != nil {
 			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
 			return
 		}
+		if err := checkPageOwnership(db, payload); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_TABLE", err.Error())
+			return
+		}
+		if err := checkViewAdmin(c, payload.ViewQuery); err != nil {
+			utils.Error(c, http.StatusForbidden, "FORBIDDEN", err.Error())
+			return
+		}
+		if err := checkPageMenus(payload); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_MENU", err.Error())
+			return
+		}
+		if !checkPageSchemas(c, db, payload) {
+			return
+		}
+		base := payload.Slug
+		if base == "" {
+			base = payload.Name
+		}
+		slug, err := uniquePageSlug(db, base, "")
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+		payload.Slug = slug
+		payload.Status = models.PageStatusDraft
 		if err := db.Create(&payload).Error; err != nil {
 			utils.Error(c, http.StatusInternalServerError, "DB_CREATE_ERROR", err.Error())
 			return
@@ -74,10 +158,159 @@ func RegisterBuilderRoutes(group *gin.RouterGroup, db *gorm.DB) {
 			utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
 			return
 		}
+		if err := deployRelationFKs(db, models.Page{}, created); err != nil {
+			utils.Error(c, http.StatusBadRequest, "FOREIGN_KEY_ERROR", err.Error())
+			return
+		}
+		if err := deployRowStamps(db, models.Page{}, created); err != nil {
+			utils.Error(c, http.StatusBadRequest, "ROW_STAMPS_ERROR", err.Error())
+			return
+		}
+		recordSchemaChangelog(c, db, models.Page{}, created)
 		c.JSON(http.StatusCreated, gin.H{"data": created, "success": true})
 	})
 
+	// POST /builder/:id/duplicate copies the page (draft schemas, templates,
+	// storage settings and tags) into a new undeployed page named after the
+	// body. With a tableName the copy gets its own table, which createTable
+	// creates empty and deploys right away.
+	builder.POST("/:id/duplicate", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		var payload struct {
+			Name        string `json:"name"`
+			Slug        string `json:"slug"`
+			TableName   string `json:"tableName"`
+			CreateTable bool   `json:"createTable"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		if payload.Name == "" {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", "name is required")
+			return
+		}
+		if payload.CreateTable && payload.TableName == "" {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", "createTable requires a tableName")
+			return
+		}
+
+		var source models.Page
+		if err := db.Preload("Tags").First(&source, "id = ?", c.Param("id")).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+				return
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+
+		page := duplicatePage(source, payload.Name, payload.TableName)
+		if err := checkPageOwnership(db, page); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_TABLE", err.Error())
+			return
+		}
+		if page.TableName != "" {
+			var owners int64
+			if err := db.Model(&models.Page{}).Where("table_name = ?", page.TableName).Count(&owners).Error; err != nil {
+				utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+				return
+			}
+			if owners > 0 {
+				utils.Error(c, http.StatusConflict, "TABLE_IN_USE", fmt.Sprintf("table %q belongs to another page", page.TableName))
+				return
+			}
+		}
+		if payload.CreateTable {
+			sqlDB, err := PageSQL(db, page)
+			if err != nil {
+				utils.Error(c, http.StatusBadRequest, "INVALID_STORAGE", err.Error())
+				return
+			}
+			exists, err := tableExists(sqlDB, page.TableName)
+			if err != nil {
+				utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+				return
+			}
+			if exists {
+				utils.Error(c, http.StatusConflict, "TABLE_EXISTS", fmt.Sprintf("table %q already exists", page.TableName))
+				return
+			}
+		}
+
+		base := payload.Slug
+		if base == "" {
+			base = payload.Name
+		}
+		slug, err := uniquePageSlug(db, base, "")
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+		page.Slug = slug
+		page.Status = models.PageStatusDraft
+		if err := db.Omit("Tags").Create(&page).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_CREATE_ERROR", err.Error())
+			return
+		}
+		if len(source.Tags) > 0 {
+			if err := db.Model(&page).Association("Tags").Replace(source.Tags); err != nil {
+				utils.Error(c, http.StatusInternalServerError, "DB_ASSOCIATION_ERROR", err.Error())
+				return
+			}
+		}
+
+		var plan *deployPlan
+		if payload.CreateTable {
+			if plan, err = deployPage(c, db, page, DeployMigration{}); err != nil {
+				utils.Error(c, deployErrorStatus(err), "DEPLOY_ERROR", err.Error())
+				return
+			}
+		}
+
+		var created models.Page
+		if err := db.Preload("Template").Preload("Tags.Category").First(&created, "id = ?", page.ID).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
+			return
+		}
+		recordSchemaChangelog(c, db, models.Page{}, created)
+		c.JSON(http.StatusCreated, gin.H{"data": created, "deploy": plan, "success": true})
+	})
+
+	// POST /builder/validate checks the draft schemas of the page in the body
+	// without saving it. A body with the id of a page is checked as an
+	// update of that page.
+	builder.POST("/validate", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		var payload models.Page
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		page := payload
+		if payload.ID != "" {
+			var existing models.Page
+			if err := db.First(&existing, "id = ?", payload.ID).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+					return
+				}
+				utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+				return
+			}
+			page, _ = mergePageSchemas(existing, payload)
+		}
+
+		issues, err := validatePageSchemas(db, page)
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": gin.H{"valid": len(issues) == 0, "issues": issues}, "success": true})
+	})
+
 	builder.PUT("/:id", func(c *gin.Context) {
+		db := utils.DB(c, db)
 		id := c.Param("id")
 		var payload models.Page
 
@@ -90,10 +323,48 @@ func RegisterBuilderRoutes(group *gin.RouterGroup, db *gorm.DB) {
 			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
 			return
 		}
+		before := existing
+		revision := payload.Revision
+		if !checkPageRevision(c, db, existing, revision) {
+			return
+		}
+
+		if err := checkPageOwnership(db, payload); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_TABLE", err.Error())
+			return
+		}
+		if err := checkViewAdmin(c, payload.ViewQuery); err != nil {
+			utils.Error(c, http.StatusForbidden, "FORBIDDEN", err.Error())
+			return
+		}
+		if after, touched := mergePageMenus(existing, payload); touched {
+			if err := checkPageMenus(after); err != nil {
+				utils.Error(c, http.StatusBadRequest, "INVALID_MENU", err.Error())
+				return
+			}
+		}
+		if after, touched := mergePageSchemas(existing, payload); touched && !checkPageSchemas(c, db, after) {
+			return
+		}
+		if payload.Slug != "" {
+			slug, err := uniquePageSlug(db, payload.Slug, id)
+			if err != nil {
+				utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+				return
+			}
+			payload.Slug = slug
+		}
 
 		payload.ID = id
-		if err := db.Model(&existing).Omit("Tags").Updates(&payload).Error; err != nil {
-			utils.Error(c, http.StatusInternalServerError, "DB_UPDATE_ERROR", err.Error())
+		payload.Status = ""
+		payload.Revision = revision + 1
+		res := db.Model(&existing).Where("revision = ?", revision).Omit("Tags").Updates(&payload)
+		if res.Error != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_UPDATE_ERROR", res.Error.Error())
+			return
+		}
+		if res.RowsAffected == 0 {
+			pageRevisionConflict(c, db, id)
 			return
 		}
 
@@ -109,15 +380,27 @@ func RegisterBuilderRoutes(group *gin.RouterGroup, db *gorm.DB) {
 			}
 		}
 
+		invalidatePageCache(c, cache, id)
+
 		var updated models.Page
 		if err := db.Preload("Template").Preload("Tags.Category").First(&updated, "id = ?", id).Error; err != nil {
 			utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
 			return
 		}
+		if err := deployRelationFKs(db, before, updated); err != nil {
+			utils.Error(c, http.StatusBadRequest, "FOREIGN_KEY_ERROR", err.Error())
+			return
+		}
+		if err := deployRowStamps(db, before, updated); err != nil {
+			utils.Error(c, http.StatusBadRequest, "ROW_STAMPS_ERROR", err.Error())
+			return
+		}
+		recordSchemaChangelog(c, db, before, updated)
 		c.JSON(http.StatusOK, gin.H{"data": updated, "success": true})
 	})
 
 	builder.PATCH("/:id", func(c *gin.Context) {
+		db := utils.DB(c, db)
 		id := c.Param("id")
 		var updates map[string]interface{}
 
@@ -125,6 +408,48 @@ func RegisterBuilderRoutes(group *gin.RouterGroup, db *gorm.DB) {
 			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
 			return
 		}
+		var before models.Page
+		if err := db.First(&before, "id = ?", id).Error; err != nil {
+			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+			return
+		}
+		revision, _ := updates["revision"].(float64)
+		delete(updates, "revision")
+		delete(updates, "status")
+		delete(updates, "Status")
+		if !checkPageRevision(c, db, before, int(revision)) {
+			return
+		}
+		if err := checkPageUpdates(db, updates); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_TABLE", err.Error())
+			return
+		}
+		if err := checkViewAdmin(c, pageFromUpdates(updates).ViewQuery); err != nil {
+			utils.Error(c, http.StatusForbidden, "FORBIDDEN", err.Error())
+			return
+		}
+		if after, touched := touchesMenus(before, updates); touched {
+			if err := checkPageMenus(after); err != nil {
+				utils.Error(c, http.StatusBadRequest, "INVALID_MENU", err.Error())
+				return
+			}
+		}
+		if after, touched := touchesSchemas(before, updates); touched && !checkPageSchemas(c, db, after) {
+			return
+		}
+		if raw, ok := updates["slug"]; ok {
+			base, _ := raw.(string)
+			if base == "" {
+				delete(updates, "slug")
+			} else {
+				slug, err := uniquePageSlug(db, base, id)
+				if err != nil {
+					utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+					return
+				}
+				updates["slug"] = slug
+			}
+		}
 		if tagsRaw, ok := updates["tags"]; ok {
 			delete(updates, "tags")
 			var page models.Page
@@ -147,21 +472,37 @@ func RegisterBuilderRoutes(group *gin.RouterGroup, db *gorm.DB) {
 				}
 			}
 		}
-		if len(updates) > 0 {
-			if err := db.Model(&models.Page{}).Where("id = ?", id).Updates(updates).Error; err != nil {
-				utils.Error(c, http.StatusInternalServerError, "DB_PATCH_ERROR", err.Error())
-				return
-			}
+		// The update runs even without fields, to bump the revision.
+		res := db.Model(&models.Page{}).Where("id = ? AND revision = ?", id, int(revision)).Updates(updates)
+		if res.Error != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_PATCH_ERROR", res.Error.Error())
+			return
+		}
+		if res.RowsAffected == 0 {
+			pageRevisionConflict(c, db, id)
+			return
 		}
+		invalidatePageCache(c, cache, id)
+
 		var updated models.Page
 		if err := db.Preload("Template").Preload("Tags.Category").First(&updated, "id = ?", id).Error; err != nil {
 			utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
 			return
 		}
+		if err := deployRelationFKs(db, before, updated); err != nil {
+			utils.Error(c, http.StatusBadRequest, "FOREIGN_KEY_ERROR", err.Error())
+			return
+		}
+		if err := deployRowStamps(db, before, updated); err != nil {
+			utils.Error(c, http.StatusBadRequest, "ROW_STAMPS_ERROR", err.Error())
+			return
+		}
+		recordSchemaChangelog(c, db, before, updated)
 		c.JSON(http.StatusOK, gin.H{"data": updated, "success": true})
 	})
 
 	builder.POST("/deleteMany", func(c *gin.Context) {
+		db := utils.DB(c, db)
 		var ids []string
 		if err := c.ShouldBindJSON(&ids); err != nil {
 			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
@@ -171,17 +512,37 @@ func RegisterBuilderRoutes(group *gin.RouterGroup, db *gorm.DB) {
 			utils.Error(c, http.StatusBadRequest, "NO_IDS_PROVIDED", "No IDs provided")
 			return
 		}
-		if err := db.Delete(&models.Page{}, ids).Error; err != nil {
+		if !guardPageDelete(c, db, cache, ids) {
+			return
+		}
+		var pages []models.Page
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Preload("Tags").Find(&pages, "id IN ?", ids).Error; err != nil {
+				return err
+			}
+			for _, page := range pages {
+				if err := trashRecord(c, tx, services.TrashKindPage, page); err != nil {
+					return err
+				}
+			}
+			return tx.Delete(&models.Page{}, ids).Error
+		})
+		if err != nil {
 			utils.Error(c, http.StatusInternalServerError, "DB_DELETE_MANY_ERROR", err.Error())
 			return
 		}
+		invalidatePageCache(c, cache, ids...)
+		for _, page := range pages {
+			recordBuilderAudit(c, db, page, models.Page{})
+		}
 		c.JSON(http.StatusOK, gin.H{"message": "Pages deleted successfully", "count": len(ids), "success": true})
 	})
 
 	builder.DELETE("/:id", func(c *gin.Context) {
+		db := utils.DB(c, db)
 		id := c.Param("id")
 		var page models.Page
-		if err := db.First(&page, "id = ?", id).Error; err != nil {
+		if err := db.Preload("Tags").First(&page, "id = ?", id).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
 				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
 				return
@@ -189,14 +550,26 @@ func RegisterBuilderRoutes(group *gin.RouterGroup, db *gorm.DB) {
 			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
 			return
 		}
-		if err := db.Delete(&page).Error; err != nil {
+		if !guardPageDelete(c, db, cache, []string{id}) {
+			return
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := trashRecord(c, tx, services.TrashKindPage, page); err != nil {
+				return err
+			}
+			return tx.Delete(&page).Error
+		})
+		if err != nil {
 			utils.Error(c, http.StatusInternalServerError, "DB_DELETE_ERROR", err.Error())
 			return
 		}
+		invalidatePageCache(c, cache, id)
+		recordBuilderAudit(c, db, page, models.Page{})
 		c.JSON(http.StatusOK, gin.H{"message": "Page deleted successfully", "id": id, "success": true})
 	})
 
 	builder.PATCH("/patchMany", func(c *gin.Context) {
+		db := utils.DB(c, db)
 		var payload struct {
 			IDs     []string               `json:"ids"`
 			Updates map[string]interface{} `json:"updates"`
@@ -213,6 +586,33 @@ func RegisterBuilderRoutes(group *gin.RouterGroup, db *gorm.DB) {
 			utils.Error(c, http.StatusBadRequest, "NO_UPDATES_PROVIDED", "No updates provided")
 			return
 		}
+		if err := checkPageUpdates(db, payload.Updates); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_TABLE", err.Error())
+			return
+		}
+		if err := checkViewAdmin(c, pageFromUpdates(payload.Updates).ViewQuery); err != nil {
+			utils.Error(c, http.StatusForbidden, "FORBIDDEN", err.Error())
+			return
+		}
+		// Slugs are unique: they cannot be set on several pages at once.
+		delete(payload.Updates, "slug")
+
+		var befores []models.Page
+		if err := db.Find(&befores, "id IN ?", payload.IDs).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+		for _, before := range befores {
+			if after, touched := touchesMenus(before, payload.Updates); touched {
+				if err := checkPageMenus(after); err != nil {
+					utils.Error(c, http.StatusBadRequest, "INVALID_MENU", fmt.Sprintf("page %s: %v", before.ID, err))
+					return
+				}
+			}
+			if after, touched := touchesSchemas(before, payload.Updates); touched && !checkPageSchemas(c, db, after) {
+				return
+			}
+		}
 		if tagsRaw, ok := payload.Updates["tags"]; ok {
 			delete(payload.Updates, "tags")
 			for _, id := range payload.IDs {
@@ -239,11 +639,143 @@ func RegisterBuilderRoutes(group *gin.RouterGroup, db *gorm.DB) {
 				utils.Error(c, http.StatusBadRequest, "DECODE_ERROR", err.Error())
 				return
 			}
+			updates.Revision = 0
+			updates.Status = ""
 			if err := db.Model(&models.Page{}).Where("id IN ?", payload.IDs).Updates(&updates).Error; err != nil {
 				utils.Error(c, http.StatusInternalServerError, "DB_PATCH_MANY_ERROR", err.Error())
 				return
 			}
+			if err := db.Model(&models.Page{}).Where("id IN ?", payload.IDs).Update("revision", gorm.Expr("revision + 1")).Error; err != nil {
+				utils.Error(c, http.StatusInternalServerError, "DB_PATCH_MANY_ERROR", err.Error())
+				return
+			}
 		}
+		var afters []models.Page
+		if err := db.Find(&afters, "id IN ?", payload.IDs).Error; err == nil {
+			byID := make(map[string]models.Page, len(befores))
+			for _, p := range befores {
+				byID[p.ID] = p
+			}
+			for _, after := range afters {
+				if err := deployRelationFKs(db, byID[after.ID], after); err != nil {
+					utils.Error(c, http.StatusBadRequest, "FOREIGN_KEY_ERROR", fmt.Sprintf("page %s: %v", after.ID, err))
+					return
+				}
+				if err := deployRowStamps(db, byID[after.ID], after); err != nil {
+					utils.Error(c, http.StatusBadRequest, "ROW_STAMPS_ERROR", fmt.Sprintf("page %s: %v", after.ID, err))
+					return
+				}
+				recordSchemaChangelog(c, db, byID[after.ID], after)
+			}
+		}
+		invalidatePageCache(c, cache, payload.IDs...)
 		c.JSON(http.StatusOK, gin.H{"message": "Pages updated successfully", "count": len(payload.IDs), "success": true})
 	})
 }
+
+// duplicatePage is a new page with the draft schemas, templates and storage
+// settings of source, named name and stored in table. The relations of
+// source to its own table point at table instead.
+func duplicatePage(source models.Page, name, table string) models.Page {
+	page := models.Page{
+		Name:             name,
+		TemplateID:       source.TemplateID,
+		FicheTemplateID:  source.FicheTemplateID,
+		SchemaColumns:    source.SchemaColumns,
+		SchemaRelations:  source.SchemaRelations,
+		SchemaUi:         source.SchemaUi,
+		SchemaMenuUi:     source.SchemaMenuUi,
+		SchemaConditions: source.SchemaConditions,
+		SchemaFunctions:  source.SchemaFunctions,
+		SchemaQuery:      source.SchemaQuery,
+		TableName:        table,
+		Storage:          source.Storage,
+		IDStrategy:       source.IDStrategy,
+		IDPrefix:         source.IDPrefix,
+		StampTrigger:     source.StampTrigger,
+	}
+	if table == "" || source.TableName == "" {
+		return page
+	}
+
+	rels := parseRelations(source.SchemaRelations)
+	retargeted := false
+	for i := range rels {
+		if rels[i].ToTable == source.TableName {
+			rels[i].ToTable = table
+			retargeted = true
+		}
+	}
+	if retargeted {
+		page.SchemaRelations, _ = json.Marshal(rels)
+	}
+	return page
+}
+
+// pageSchemaColumns are the schema bodies of a page, left out of the
+// builder list by ?summary=true.
+var pageSchemaColumns = []string{
+	"schema_columns", "schema_relations", "schema_ui", "schema_menu_ui",
+	"schema_conditions", "schema_functions", "schema_query",
+	"schema_columns_deployed", "schema_relations_deployed", "schema_ui_deployed", "schema_menu_ui_deployed",
+	"schema_conditions_deployed", "schema_functions_deployed", "schema_query_deployed",
+	"schema_ui_rollout", "ui_rollout",
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// filterBuilderPages narrows the builder list to the pages whose name
+// contains ?name, that carry one of the ?tag (id or name) and use the
+// ?template (id or name). filtered reports whether any filter was set.
+func filterBuilderPages(c *gin.Context, db *gorm.DB) (query *gorm.DB, filtered bool) {
+	query = db
+	if name := strings.TrimSpace(c.Query("name")); name != "" {
+		query = query.Where("name ILIKE ?", "%"+likeEscaper.Replace(name)+"%")
+		filtered = true
+	}
+	if tags := c.QueryArray("tag"); len(tags) > 0 {
+		query = query.Where("id IN (?)", db.Table("page_tags").Select("page_tags.page_id").
+			Joins("JOIN tags ON tags.id = page_tags.tag_id").
+			Where("tags.id::text IN ? OR tags.name IN ?", tags, tags))
+		filtered = true
+	}
+	if template := c.Query("template"); template != "" {
+		query = query.Where("template_id IN (?)", db.Model(&models.Template{}).Select("id").
+			Where("id::text = ? OR name = ?", template, template))
+		filtered = true
+	}
+	return query, filtered
+}
+
+// checkPageRevision answers 428 when a write does not say which revision of
+// page it edited, and 409 with the current page when it edited an older one.
+func checkPageRevision(c *gin.Context, db *gorm.DB, page models.Page, revision int) bool {
+	if revision <= 0 {
+		utils.Error(c, http.StatusPreconditionRequired, "REVISION_REQUIRED", "The current revision of the page is required")
+		return false
+	}
+	if revision != page.Revision {
+		pageRevisionConflict(c, db, page.ID)
+		return false
+	}
+	return true
+}
+
+// pageRevisionConflict answers 409 with the current page, for the builder to
+// merge or reload.
+func pageRevisionConflict(c *gin.Context, db *gorm.DB, id string) {
+	var current models.Page
+	if err := db.Preload("Template").Preload("Tags.Category").First(&current, "id = ?", id).Error; err != nil {
+		utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
+		return
+	}
+	c.JSON(http.StatusConflict, utils.APIResponse{
+		Success: false,
+		Data:    current,
+		Error: &utils.APIError{
+			Code:    "REVISION_CONFLICT",
+			Details: fmt.Sprintf("The page is at revision %d", current.Revision),
+		},
+	})
+}
@@ -0,0 +1,361 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// pageBundleFormat is the version of the bundle layout, bumped when a
+// bundle of the previous one can no longer be imported as is.
+const pageBundleFormat = 1
+
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+// A PageBundle is the portable definition of a page: its schemas and
+// settings, with templates and tags referenced by name so that it can be
+// imported in another database.
+type PageBundle struct {
+	Format        int           `json:"format"`
+	Name          string        `json:"name"`
+	Slug          string        `json:"slug,omitempty"`
+	TableName     string        `json:"tableName,omitempty"`
+	Storage       string        `json:"storage,omitempty"`
+	IDStrategy    string        `json:"idStrategy,omitempty"`
+	IDPrefix      string        `json:"idPrefix,omitempty"`
+	StampTrigger  bool          `json:"stampTrigger,omitempty"`
+	Template      string        `json:"template,omitempty"`
+	FicheTemplate string        `json:"ficheTemplate,omitempty"`
+	Tags          []bundleTag   `json:"tags,omitempty"`
+	Schemas       bundleSchemas `json:"schemas"`
+	ExportedAt    time.Time     `json:"exportedAt"`
+}
+
+type bundleTag struct {
+	Name     string `json:"name"`
+	Category string `json:"category,omitempty"`
+}
+
+type bundleSchemas struct {
+	Columns    datatypes.JSON `json:"columns,omitempty"`
+	Relations  datatypes.JSON `json:"relations,omitempty"`
+	UI         datatypes.JSON `json:"ui,omitempty"`
+	Menus      datatypes.JSON `json:"menus,omitempty"`
+	Conditions datatypes.JSON `json:"conditions,omitempty"`
+	Functions  datatypes.JSON `json:"functions,omitempty"`
+	Query      datatypes.JSON `json:"query,omitempty"`
+}
+
+// exportPage builds the bundle of page, loaded with its templates and tags.
+// The schemas are the deployed ones, or the draft when draft is set or the
+// page was never deployed.
+func exportPage(page models.Page, draft bool) PageBundle {
+	bundle := PageBundle{
+		Format:       pageBundleFormat,
+		Name:         page.Name,
+		Slug:         page.Slug,
+		TableName:    page.TableName,
+		Storage:      page.Storage,
+		IDStrategy:   page.IDStrategy,
+		IDPrefix:     page.IDPrefix,
+		StampTrigger: Bool(page.StampTrigger),
+		ExportedAt:   time.Now().UTC(),
+	}
+	if page.Template != nil {
+		bundle.Template = page.Template.Name
+	}
+	if page.FicheTemplate != nil {
+		bundle.FicheTemplate = page.FicheTemplate.Name
+	}
+	for _, tag := range page.Tags {
+		ref := bundleTag{Name: tag.Name}
+		if tag.Category != nil {
+			ref.Category = tag.Category.Name
+		}
+		bundle.Tags = append(bundle.Tags, ref)
+	}
+
+	if draft || !Bool(page.Deploy) {
+		bundle.Schemas = bundleSchemas{
+			Columns:    page.SchemaColumns,
+			Relations:  page.SchemaRelations,
+			UI:         page.SchemaUi,
+			Menus:      page.SchemaMenuUi,
+			Conditions: page.SchemaConditions,
+			Functions:  page.SchemaFunctions,
+			Query:      page.SchemaQuery,
+		}
+	} else {
+		bundle.Schemas = bundleSchemas{
+			Columns:    page.SchemaColumnsDeployed,
+			Relations:  page.SchemaRelationsDeployed,
+			UI:         page.SchemaUiDeployed,
+			Menus:      page.SchemaMenuUiDeployed,
+			Conditions: page.SchemaConditionsDeployed,
+			Functions:  page.SchemaFunctionsDeployed,
+			Query:      page.SchemaQueryDeployed,
+		}
+	}
+	return bundle
+}
+
+// resolveTemplate returns the id of the template named name, nil for no name.
+func resolveTemplate(db *gorm.DB, name string) (*string, error) {
+	if name == "" {
+		return nil, nil
+	}
+	var template models.Template
+	if err := db.Select("id").First(&template, "name = ?", name).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w: unknown template %q", ErrInvalidBundle, name)
+		}
+		return nil, err
+	}
+	return &template.ID, nil
+}
+
+// resolveTags finds the tags of the bundle by name and category name.
+func resolveTags(db *gorm.DB, refs []bundleTag) ([]models.Tag, error) {
+	tags := make([]models.Tag, 0, len(refs))
+	for _, ref := range refs {
+		var candidates []models.Tag
+		if err := db.Preload("Category").Where("name = ?", ref.Name).Find(&candidates).Error; err != nil {
+			return nil, err
+		}
+		found := false
+		for _, tag := range candidates {
+			category := ""
+			if tag.Category != nil {
+				category = tag.Category.Name
+			}
+			if category == ref.Category {
+				tags = append(tags, tag)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: unknown tag %q in category %q", ErrInvalidBundle, ref.Name, ref.Category)
+		}
+	}
+	return tags, nil
+}
+
+// bundlePage is the page described by bundle, its templates and tags
+// resolved against db. The schemas of the bundle become its draft.
+func bundlePage(db *gorm.DB, bundle PageBundle) (models.Page, []models.Tag, error) {
+	var page models.Page
+	if bundle.Format != pageBundleFormat {
+		return page, nil, fmt.Errorf("%w: format %d is not supported", ErrInvalidBundle, bundle.Format)
+	}
+	if bundle.Name == "" {
+		return page, nil, fmt.Errorf("%w: name is required", ErrInvalidBundle)
+	}
+
+	templateID, err := resolveTemplate(db, bundle.Template)
+	if err != nil {
+		return page, nil, err
+	}
+	ficheTemplateID, err := resolveTemplate(db, bundle.FicheTemplate)
+	if err != nil {
+		return page, nil, err
+	}
+	tags, err := resolveTags(db, bundle.Tags)
+	if err != nil {
+		return page, nil, err
+	}
+
+	stampTrigger := bundle.StampTrigger
+	page = models.Page{
+		Name:             bundle.Name,
+		TemplateID:       templateID,
+		FicheTemplateID:  ficheTemplateID,
+		SchemaColumns:    bundle.Schemas.Columns,
+		SchemaRelations:  bundle.Schemas.Relations,
+		SchemaUi:         bundle.Schemas.UI,
+		SchemaMenuUi:     bundle.Schemas.Menus,
+		SchemaConditions: bundle.Schemas.Conditions,
+		SchemaFunctions:  bundle.Schemas.Functions,
+		SchemaQuery:      bundle.Schemas.Query,
+		TableName:        bundle.TableName,
+		Storage:          bundle.Storage,
+		IDStrategy:       bundle.IDStrategy,
+		IDPrefix:         bundle.IDPrefix,
+		StampTrigger:     &stampTrigger,
+	}
+	return page, tags, nil
+}
+
+func RegisterBuilderBundleRoutes(group *gin.RouterGroup, db *gorm.DB, cache *services.Cache) {
+	builder := group.Group("/builder")
+
+	// GET /builder/:id/export downloads the bundle of the page, with its
+	// deployed schemas unless ?draft=true.
+	builder.GET("/:id/export", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		var page models.Page
+		if err := db.Preload("Template").Preload("FicheTemplate").Preload("Tags.Category").
+			First(&page, "id = ?", c.Param("id")).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+				return
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+
+		bundle := exportPage(page, c.Query("draft") == "true")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, safeFilename("page", page.Slug)))
+		c.JSON(http.StatusOK, gin.H{"data": bundle, "success": true})
+	})
+
+	// POST /builder/import recreates a page from the JSON of the export, with
+	// or without its envelope. A page of the same name gets the schemas of
+	// the bundle as its draft and keeps its id; otherwise a page is created.
+	// ?deploy=true deploys the page afterwards.
+	builder.POST("/import", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		var envelope struct {
+			Data *PageBundle `json:"data"`
+		}
+		var bundle PageBundle
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		if envelope.Data != nil {
+			bundle = *envelope.Data
+		} else if err := json.Unmarshal(body, &bundle); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+
+		page, tags, err := bundlePage(db, bundle)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrInvalidBundle) {
+				status = http.StatusBadRequest
+			}
+			utils.Error(c, status, "INVALID_BUNDLE", err.Error())
+			return
+		}
+		if err := checkPageOwnership(db, page); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_TABLE", err.Error())
+			return
+		}
+		if err := checkPageMenus(page); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_MENU", err.Error())
+			return
+		}
+		if !checkPageSchemas(c, db, page) {
+			return
+		}
+
+		var before models.Page
+		err = db.First(&before, "name = ?", page.Name).Error
+		created := err == gorm.ErrRecordNotFound
+		if err != nil && !created {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+
+		if created {
+			base := bundle.Slug
+			if base == "" {
+				base = bundle.Name
+			}
+			if page.Slug, err = uniquePageSlug(db, base, ""); err != nil {
+				utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+				return
+			}
+			page.Status = models.PageStatusDraft
+			if err := db.Omit("Tags").Create(&page).Error; err != nil {
+				utils.Error(c, http.StatusInternalServerError, "DB_CREATE_ERROR", err.Error())
+				return
+			}
+		} else {
+			page.ID = before.ID
+			if err := db.Model(&models.Page{}).Where("id = ?", page.ID).Updates(map[string]any{
+				"template_id":       page.TemplateID,
+				"fiche_template_id": page.FicheTemplateID,
+				"schema_columns":    page.SchemaColumns,
+				"schema_relations":  page.SchemaRelations,
+				"schema_ui":         page.SchemaUi,
+				"schema_menu_ui":    page.SchemaMenuUi,
+				"schema_conditions": page.SchemaConditions,
+				"schema_functions":  page.SchemaFunctions,
+				"schema_query":      page.SchemaQuery,
+				"table_name":        page.TableName,
+				"storage":           page.Storage,
+				"id_strategy":       page.IDStrategy,
+				"id_prefix":         page.IDPrefix,
+				"stamp_trigger":     page.StampTrigger,
+			}).Error; err != nil {
+				utils.Error(c, http.StatusInternalServerError, "DB_UPDATE_ERROR", err.Error())
+				return
+			}
+		}
+		if err := db.Model(&page).Association("Tags").Replace(tags); err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_ASSOCIATION_ERROR", err.Error())
+			return
+		}
+
+		var plan *deployPlan
+		if c.Query("deploy") == "true" {
+			var current models.Page
+			if err := db.First(&current, "id = ?", page.ID).Error; err != nil {
+				utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+				return
+			}
+			if plan, err = deployPage(c, db, current, DeployMigration{}); err != nil {
+				utils.Error(c, deployErrorStatus(err), "DEPLOY_ERROR", err.Error())
+				return
+			}
+		}
+		invalidatePageCache(c, cache, page.ID)
+
+		var updated models.Page
+		if err := db.Preload("Template").Preload("Tags.Category").First(&updated, "id = ?", page.ID).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
+			return
+		}
+		recordSchemaChangelog(c, db, before, updated)
+
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		c.JSON(status, gin.H{"data": updated, "created": created, "deploy": plan, "success": true})
+	})
+}
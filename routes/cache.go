@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// pageCacheModes are the dependency modes cached for each page payload.
+var pageCacheModes = []string{DependenciesFull, DependenciesIDs, DependenciesNone}
+
+func pageCacheKey(pageID, mode string) string {
+	if mode == DependenciesFull {
+		return services.PageCacheKey(pageID)
+	}
+	return services.PageCacheKey(pageID) + ":dependencies=" + mode
+}
+
+func invalidatePageCache(c *gin.Context, cache *services.Cache, pageIDs ...string) {
+	if !cache.Enabled() {
+		return
+	}
+	keys := make([]string, 0, len(pageIDs)*len(pageCacheModes))
+	for _, id := range pageIDs {
+		for _, mode := range pageCacheModes {
+			keys = append(keys, pageCacheKey(id, mode))
+		}
+	}
+	utils.AfterCommit(c, func() {
+		cache.Delete(c.Request.Context(), keys...)
+	})
+}
+
+func invalidateTableCache(c *gin.Context, db *gorm.DB, cache *services.Cache, table string) {
+	if !cache.Enabled() || table == "" {
+		return
+	}
+
+	relation, _ := json.Marshal([]map[string]string{{"toTable": table}})
+
+	var ids []string
+	if err := db.Model(&models.Page{}).
+		Where("table_name = ? OR schema_relations_deployed @> ?::jsonb", table, string(relation)).
+		Pluck("id", &ids).Error; err != nil {
+		return
+	}
+	invalidatePageCache(c, cache, ids...)
+}
+
+func invalidateNavigationCache(c *gin.Context, cache *services.Cache) {
+	utils.AfterCommit(c, func() {
+		var keys []string
+		for _, menu := range navMenus() {
+			keys = append(keys, services.NavigationMenuCacheKey(menu))
+		}
+		cache.Delete(c.Request.Context(), keys...)
+	})
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/utils"
+	"bytes"
+	"encoding/json"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	ChangelogDeploy          = "deploy"
+	ChangelogUndeploy        = "undeploy"
+	ChangelogColumnAdded     = "column_added"
+	ChangelogColumnRemoved   = "column_removed"
+	ChangelogColumnChanged   = "column_changed"
+	ChangelogRelationAdded   = "relation_added"
+	ChangelogRelationRemoved = "relation_removed"
+)
+
+func parseColumns(raw datatypes.JSON) []ColumnDefinition {
+	var cols []ColumnDefinition
+	if raw != nil {
+		_ = json.Unmarshal(raw, &cols)
+	}
+	return cols
+}
+
+func parseRelations(raw datatypes.JSON) []RelationDefinition {
+	var rels []RelationDefinition
+	if raw != nil {
+		_ = json.Unmarshal(raw, &rels)
+	}
+	return rels
+}
+
+func relationKey(rel RelationDefinition) string {
+	return rel.Type + ":" + rel.FromColumn + "->" + rel.ToTable
+}
+
+func deployedSchemaChanged(before, after models.Page) bool {
+	pairs := [][2]datatypes.JSON{
+		{before.SchemaColumnsDeployed, after.SchemaColumnsDeployed},
+		{before.SchemaRelationsDeployed, after.SchemaRelationsDeployed},
+		{before.SchemaUiDeployed, after.SchemaUiDeployed},
+		{before.SchemaMenuUiDeployed, after.SchemaMenuUiDeployed},
+		{before.SchemaConditionsDeployed, after.SchemaConditionsDeployed},
+		{before.SchemaFunctionsDeployed, after.SchemaFunctionsDeployed},
+		{before.SchemaQueryDeployed, after.SchemaQueryDeployed},
+	}
+	for _, p := range pairs {
+		if !bytes.Equal(p[0], p[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func diffSchemaChangelog(before, after models.Page) []models.PageChangelog {
+	entries := []models.PageChangelog{}
+	add := func(kind, target string, details any) {
+		entry := models.PageChangelog{PageID: after.ID, Kind: kind, Target: target}
+		if details != nil {
+			entry.Details, _ = json.Marshal(details)
+		}
+		entries = append(entries, entry)
+	}
+
+	wasDeployed, isDeployed := Bool(before.Deploy), Bool(after.Deploy)
+	if wasDeployed && !isDeployed {
+		add(ChangelogUndeploy, after.TableName, nil)
+		return entries
+	}
+	if !isDeployed || (wasDeployed && !deployedSchemaChanged(before, after)) {
+		return entries
+	}
+
+	oldCols := map[string]ColumnDefinition{}
+	for _, col := range parseColumns(before.SchemaColumnsDeployed) {
+		oldCols[col.Name] = col
+	}
+	newCols := map[string]ColumnDefinition{}
+	for _, col := range parseColumns(after.SchemaColumnsDeployed) {
+		newCols[col.Name] = col
+		old, ok := oldCols[col.Name]
+		switch {
+		case !ok:
+			add(ChangelogColumnAdded, col.Name, col)
+		case old.Type != col.Type:
+			add(ChangelogColumnChanged, col.Name, gin.H{"from": old, "to": col})
+		}
+	}
+	for name, col := range oldCols {
+		if _, ok := newCols[name]; !ok {
+			add(ChangelogColumnRemoved, name, col)
+		}
+	}
+
+	oldRels := map[string]RelationDefinition{}
+	for _, rel := range parseRelations(before.SchemaRelationsDeployed) {
+		oldRels[relationKey(rel)] = rel
+	}
+	newRels := map[string]bool{}
+	for _, rel := range parseRelations(after.SchemaRelationsDeployed) {
+		key := relationKey(rel)
+		newRels[key] = true
+		if _, ok := oldRels[key]; !ok {
+			add(ChangelogRelationAdded, rel.FromColumn, rel)
+		}
+	}
+	for key, rel := range oldRels {
+		if !newRels[key] {
+			add(ChangelogRelationRemoved, rel.FromColumn, rel)
+		}
+	}
+
+	add(ChangelogDeploy, after.TableName, gin.H{"changes": len(entries)})
+	return entries
+}
+
+func recordSchemaChangelog(c *gin.Context, db *gorm.DB, before, after models.Page) {
+	// Deploys record their version with the publish; this catches the
+	// other writes of deployed schemas.
+	if err := recordDeployment(c, db, before, after); err != nil {
+		log.Println("⚠️  Unable to write page deployment:", err)
+	}
+	recordRevision(c, db, before, after)
+	recordBuilderAudit(c, db, before, after)
+
+	entries := diffSchemaChangelog(before, after)
+	if len(entries) == 0 {
+		return
+	}
+
+	userID := utils.CurrentUserID(c)
+	for i := range entries {
+		entries[i].UserID = userID
+	}
+	if err := db.Create(&entries).Error; err != nil {
+		log.Println("⚠️  Unable to write page changelog:", err)
+	}
+}
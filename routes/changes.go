@@ -0,0 +1,324 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	changesDefaultPageSize = 500
+	changesMaxPageSize     = 2000
+	// changesSettleDelay keeps the rows written in the last moments for the
+	// next sync: a transaction stamping a row now may commit after the read.
+	changesSettleDelay = 2 * time.Second
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// syncCursor is the position of a client in the changes of a page: the
+// updated_at and id of the last row it received.
+type syncCursor struct {
+	At time.Time
+	ID string
+}
+
+func (cur syncCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cur.At.UTC().Format(time.RFC3339Nano) + "|" + cur.ID))
+}
+
+// parseSyncCursor reads a cursor, or an RFC 3339 timestamp standing for
+// the changes after that time.
+func parseSyncCursor(v string) (syncCursor, error) {
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return syncCursor{At: t}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return syncCursor{}, errInvalidCursor
+	}
+	at, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return syncCursor{}, errInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return syncCursor{}, errInvalidCursor
+	}
+	return syncCursor{At: t, ID: id}, nil
+}
+
+// changedRows lists the cursors of the rows stamped after cur and up to
+// until, oldest first.
+func changedRows(db sqlExecutor, table string, cur syncCursor, until time.Time, limit int) ([]syncCursor, error) {
+	q := newQuery("SELECT id::text, ").Ident(stampUpdatedAt).Write(" FROM ").Ident(table).
+		Write(" WHERE (").Ident(stampUpdatedAt).Write(", id::text) > (").Arg(cur.At).Write(", ").Arg(cur.ID).Write(")").
+		Write(" AND ").Ident(stampUpdatedAt).Write(" <= ").Arg(until).
+		Write(" ORDER BY ").Ident(stampUpdatedAt).Write(", id::text LIMIT ").Arg(limit)
+	rows, err := db.Query(q.SQL(), q.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changed []syncCursor
+	for rows.Next() {
+		var row syncCursor
+		if err := rows.Scan(&row.ID, &row.At); err != nil {
+			return nil, err
+		}
+		changed = append(changed, row)
+	}
+	return changed, rows.Err()
+}
+
+// changeSet is a page of the changes of a table after a cursor.
+type changeSet struct {
+	// ids are the rows to send, oldest change first.
+	ids []string
+	// created marks the rows created after the cursor; when nil, the
+	// created_at of each row decides.
+	created map[string]bool
+	deleted []string
+	next    syncCursor
+	hasMore bool
+}
+
+// stampChanges reads the changes from the updated_at of the rows and the
+// tombstones of the deleted ones.
+func stampChanges(db *gorm.DB, sqlDB *sql.DB, page models.Page, cur syncCursor, full bool, until time.Time, limit int) (changeSet, error) {
+	changed, err := changedRows(sqlDB, page.TableName, cur, until, limit+1)
+	if err != nil {
+		return changeSet{}, err
+	}
+	// The next call starts after the last row sent or, once every change
+	// is sent, after until.
+	set := changeSet{next: syncCursor{At: until}, deleted: []string{}}
+	if set.hasMore = len(changed) > limit; set.hasMore {
+		changed = changed[:limit]
+		set.next = changed[len(changed)-1]
+	}
+	for _, row := range changed {
+		set.ids = append(set.ids, row.ID)
+	}
+	if full {
+		return set, nil
+	}
+
+	// Deletions are read over the same span of time as the rows.
+	var tombstones []models.RowTombstone
+	if err := db.Where("page_id = ? AND deleted_at > ? AND deleted_at <= ?", page.ID, cur.At, set.next.At).
+		Order("deleted_at").Find(&tombstones).Error; err != nil {
+		return changeSet{}, err
+	}
+	for _, t := range tombstones {
+		set.deleted = append(set.deleted, t.RowID)
+	}
+	return set, nil
+}
+
+// auditChanges reads the changes from the audit log of the page rows, for
+// tables without updated_at. Only the writes made through the API are seen.
+func auditChanges(db *gorm.DB, page models.Page, cur syncCursor, until time.Time, limit int) (changeSet, error) {
+	var entries []struct {
+		ID        string
+		CreatedAt time.Time
+		Action    string
+		ItemID    string
+	}
+	err := db.Model(&models.AuditLog{}).
+		Select("id::text AS id, created_at, action, metadata->>'itemId' AS item_id").
+		Where("resource = ? AND resource_id = ? AND status = ?", services.AuditResourcePageRow, page.ID, services.AuditStatusSuccess).
+		Where("(created_at, id::text) > (?, ?) AND created_at <= ?", cur.At, cur.ID, until).
+		Where("metadata->>'itemId' IS NOT NULL").
+		Order("created_at, id").Limit(limit + 1).Scan(&entries).Error
+	if err != nil {
+		return changeSet{}, err
+	}
+
+	set := changeSet{next: syncCursor{At: until}, created: map[string]bool{}, deleted: []string{}}
+	if set.hasMore = len(entries) > limit; set.hasMore {
+		entries = entries[:limit]
+		last := entries[len(entries)-1]
+		set.next = syncCursor{At: last.CreatedAt, ID: last.ID}
+	}
+
+	// The last action on a row decides whether it is sent or deleted.
+	last := map[string]string{}
+	var order []string
+	for _, e := range entries {
+		if _, seen := last[e.ItemID]; !seen {
+			order = append(order, e.ItemID)
+		}
+		last[e.ItemID] = e.Action
+		if e.Action == services.AuditActionCreate {
+			set.created[e.ItemID] = true
+		}
+	}
+	for _, id := range order {
+		if last[id] == services.AuditActionDelete {
+			set.deleted = append(set.deleted, id)
+		} else {
+			set.ids = append(set.ids, id)
+		}
+	}
+	return set, nil
+}
+
+func RegisterPageChangesRoutes(r gin.IRoutes, db *gorm.DB) {
+	// GET /page/:id/changes?since=<cursor|timestamp> returns the rows
+	// created, updated and deleted since the cursor of a previous call (or an
+	// RFC 3339 time), in the shape of the page data, with the cursor to send
+	// next. Without since, every row comes as created, except on tables
+	// without updated_at: their changes come from the audit log, and the
+	// first call only returns the cursor to start from after a full read of
+	// the page. Rows the user may no longer see come as deleted; a cursor
+	// older than the tombstone retention answers 410 and the client must
+	// download the page again.
+	r.GET("/page/:id/changes", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		page, raw, ok := loadDeployedPage(c, db, c.Param("id"))
+		if !ok {
+			return
+		}
+
+		var cur syncCursor
+		since := c.Query("since")
+		if since != "" {
+			var err error
+			if cur, err = parseSyncCursor(since); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Curseur invalide"})
+				return
+			}
+			if time.Since(cur.At) > services.TombstoneRetention() {
+				c.JSON(http.StatusGone, gin.H{"error": "Curseur expiré, resynchronisation complète requise", "code": "RESYNC_REQUIRED"})
+				return
+			}
+		}
+
+		sqlDB, err := PageSQL(db, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		cols, err := getColumns(sqlDB, page.TableName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		pagination := utils.ParsePagination(c, changesDefaultPageSize, changesMaxPageSize)
+		until := time.Now().Add(-changesSettleDelay)
+		var set changeSet
+		switch {
+		case slices.Contains(cols, stampUpdatedAt):
+			set, err = stampChanges(db, sqlDB, page, cur, since == "", until, pagination.PageSize)
+		case since == "":
+			set = changeSet{next: syncCursor{At: until}, deleted: []string{}}
+		default:
+			set, err = auditChanges(db, page, cur, until, pagination.PageSize)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		created, updated, deleted := []map[string]any{}, []map[string]any{}, set.deleted
+		if len(set.ids) > 0 {
+			values := make([]any, len(set.ids))
+			for i, id := range set.ids {
+				values[i] = id
+			}
+			payload, err := buildPagePayload(db, page, payloadOptions{
+				Query:        &QueryDefinition{Filters: []FilterDefinition{{Column: "id", Op: "in", Value: values}}},
+				Dependencies: dependencyOptions{Mode: DependenciesNone},
+				Context:      c.Request.Context(),
+			})
+			if err != nil {
+				c.JSON(queryErrorStatus(err), gin.H{"error": err.Error()})
+				return
+			}
+			data, _ := payload["data"].([]map[string]any)
+			policy := newRowPolicy(c, db, parseConditions(page.SchemaConditionsDeployed), visibilityColumn(parseColumns(page.SchemaColumnsDeployed)))
+			masks := newColumnMasks(c, db, pageMasks(parseColumns(page.SchemaColumnsDeployed)))
+			access, err := loadRelatedAccess(c, db, raw.Relations)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			byID := make(map[string]map[string]any, len(data))
+			for _, row := range data {
+				byID[fmt.Sprint(row["id"])] = row
+			}
+			for _, id := range set.ids {
+				row, ok := byID[id]
+				if !ok {
+					// Deleted outside of the API since it was written.
+					if set.created != nil {
+						deleted = append(deleted, id)
+					}
+					continue
+				}
+				if policy != nil {
+					if !policy.allows(row) {
+						deleted = append(deleted, id)
+						continue
+					}
+					policy.strip(row)
+				}
+				masks.apply(row)
+				access.rows(raw.Relations, expandSet{}, row)
+				isNew := set.created[id]
+				if set.created == nil {
+					at, ok := row[stampCreatedAt].(time.Time)
+					isNew = since == "" || ok && at.After(cur.At)
+				}
+				if isNew {
+					created = append(created, row)
+				} else {
+					updated = append(updated, row)
+				}
+			}
+			signFileColumns(created, fileColumns(parseColumns(page.SchemaColumnsDeployed)))
+			signFileColumns(updated, fileColumns(parseColumns(page.SchemaColumnsDeployed)))
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": gin.H{
+				"created": created,
+				"updated": updated,
+				"deleted": deleted,
+			},
+			"cursor":  set.next.String(),
+			"hasMore": set.hasMore,
+		})
+	})
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/utils"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const commentMaxLength = 10000
+
+// loadCommentedItem checks that the row of /page/:id/:itemId/comments
+// exists and that the user may see it.
+func loadCommentedItem(c *gin.Context, db *gorm.DB) (models.Page, bool) {
+	page, raw, ok := loadDeployedPage(c, db, c.Param("id"))
+	if !ok {
+		return page, false
+	}
+	sqlDB, err := PageSQL(db, page)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return page, false
+	}
+	item, err := loadItem(sqlDB, page, raw, c.Param("itemId"))
+	if err != nil || !applyItemConditions(c, db, page, item) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item introuvable"})
+		return page, false
+	}
+	return page, true
+}
+
+// itemComments lists the comments of a row, oldest first.
+func itemComments(db *gorm.DB, pageID, itemID string) *gorm.DB {
+	return db.Where("page_id = ? AND item_id = ?", pageID, itemID).Order("created_at, id")
+}
+
+func commentBody(c *gin.Context) (string, bool) {
+	var payload struct {
+		Body string `json:"body"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	body := strings.TrimSpace(payload.Body)
+	if body == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le commentaire est vide"})
+		return "", false
+	}
+	if len(body) > commentMaxLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Le commentaire dépasse %d caractères", commentMaxLength)})
+		return "", false
+	}
+	return body, true
+}
+
+func RegisterPageCommentRoutes(r gin.IRoutes, db *gorm.DB) {
+	// loadComment reads a comment of the row and checks that the user wrote
+	// it; admins may also delete the comments of others.
+	loadComment := func(c *gin.Context, db *gorm.DB, page models.Page, allowAdmin bool) (*models.Comment, bool) {
+		var comment models.Comment
+		if err := itemComments(db, page.ID, c.Param("itemId")).First(&comment, "id = ?", c.Param("commentId")).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Commentaire introuvable"})
+				return nil, false
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		user := utils.CurrentUser(c)
+		isAuthor := user != nil && comment.UserID != nil && *comment.UserID == user.ID
+		if !isAuthor && !(allowAdmin && user != nil && Bool(user.IsAdmin)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Seul l'auteur peut modifier ce commentaire"})
+			return nil, false
+		}
+		return &comment, true
+	}
+
+	// GET /page/:id/:itemId/comments lists the comments of a row, oldest
+	// first, with their author.
+	r.GET("/page/:id/:itemId/comments", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		page, ok := loadCommentedItem(c, db)
+		if !ok {
+			return
+		}
+
+		comments := []models.Comment{}
+		meta, err := utils.FindPage(itemComments(db, page.ID, c.Param("itemId")), utils.ParsePagination(c, 0, listMaxPageSize), &comments,
+			func(q *gorm.DB) *gorm.DB { return q.Preload("User", userSummary) })
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": comments, "meta": meta})
+	})
+
+	r.POST("/page/:id/:itemId/comments", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		page, ok := loadCommentedItem(c, db)
+		if !ok {
+			return
+		}
+		body, ok := commentBody(c)
+		if !ok {
+			return
+		}
+
+		comment := models.Comment{
+			PageID: page.ID,
+			ItemID: c.Param("itemId"),
+			UserID: utils.CurrentUserID(c),
+			Body:   body,
+		}
+		if err := db.Create(&comment).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		comment.User = utils.CurrentUser(c)
+		c.JSON(http.StatusCreated, gin.H{"data": comment})
+	})
+
+	r.PATCH("/page/:id/:itemId/comments/:commentId", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		page, ok := loadCommentedItem(c, db)
+		if !ok {
+			return
+		}
+		comment, ok := loadComment(c, db, page, false)
+		if !ok {
+			return
+		}
+		body, ok := commentBody(c)
+		if !ok {
+			return
+		}
+
+		if err := db.Model(comment).Update("body", body).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		comment.User = utils.CurrentUser(c)
+		c.JSON(http.StatusOK, gin.H{"data": comment})
+	})
+
+	r.DELETE("/page/:id/:itemId/comments/:commentId", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		page, ok := loadCommentedItem(c, db)
+		if !ok {
+			return
+		}
+		comment, ok := loadComment(c, db, page, true)
+		if !ok {
+			return
+		}
+
+		if err := db.Delete(comment).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Suppression OK", "id": comment.ID})
+	})
+}
@@ -0,0 +1,465 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/utils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Actions of a schemaCondition marked "server". Other conditions are left to
+// the frontend and returned verbatim.
+const (
+	ConditionFilterRows  = "filterRows"
+	ConditionHideColumns = "hideColumns"
+)
+
+type ConditionSubjects struct {
+	Groups []string `json:"groups,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+// ConditionDefinition applies to the requesting user when they match When
+// (or When is empty) and do not match Unless. filterRows keeps the rows for
+// which Expression is true; it sees the row columns and `user`.
+type ConditionDefinition struct {
+	Name       string             `json:"name"`
+	Server     bool               `json:"server,omitempty"`
+	Action     string             `json:"action,omitempty"`
+	Expression string             `json:"expression,omitempty"`
+	Columns    []string           `json:"columns,omitempty"`
+	When       *ConditionSubjects `json:"when,omitempty"`
+	Unless     *ConditionSubjects `json:"unless,omitempty"`
+}
+
+func parseConditions(raw []byte) []ConditionDefinition {
+	var conds []ConditionDefinition
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &conds)
+	}
+	return conds
+}
+
+type conditionSubject struct {
+	user   map[string]any
+	admin  bool
+	groups map[string]bool
+	tags   map[string]bool
+}
+
+func loadConditionSubject(c *gin.Context, db *gorm.DB) conditionSubject {
+	subject := conditionSubject{
+		user:   map[string]any{},
+		groups: map[string]bool{},
+		tags:   map[string]bool{},
+	}
+
+	user := utils.CurrentUser(c)
+	if user == nil {
+		return subject
+	}
+
+	var groups []string
+	_ = json.Unmarshal(user.Groups, &groups)
+	for _, g := range groups {
+		subject.groups[g] = true
+	}
+
+	var tags []models.Tag
+	if err := db.Model(user).Association("Tags").Find(&tags); err != nil {
+		log.Printf("⚠️  Tags utilisateur indisponibles pour les conditions: %v", err)
+	}
+	tagNames := make([]any, 0, len(tags))
+	for _, t := range tags {
+		subject.tags[t.ID] = true
+		subject.tags[t.Name] = true
+		tagNames = append(tagNames, t.Name)
+	}
+
+	groupList := make([]any, 0, len(groups))
+	for _, g := range groups {
+		groupList = append(groupList, g)
+	}
+
+	subject.admin = Bool(user.IsAdmin)
+	subject.user = map[string]any{
+		"id":       user.ID,
+		"email":    user.Email,
+		"username": user.PreferredUsername,
+		"isAdmin":  Bool(user.IsAdmin),
+		"groups":   groupList,
+		"tags":     tagNames,
+	}
+	return subject
+}
+
+func (s conditionSubject) matches(subjects *ConditionSubjects) bool {
+	for _, g := range subjects.Groups {
+		if s.groups[g] {
+			return true
+		}
+	}
+	for _, t := range subjects.Tags {
+		if s.tags[t] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s conditionSubject) concerned(cond ConditionDefinition) bool {
+	if cond.When != nil && !s.matches(cond.When) {
+		return false
+	}
+	if cond.Unless != nil && s.matches(cond.Unless) {
+		return false
+	}
+	return true
+}
+
+// rowPolicy is the set of server conditions that apply to one request.
+// tagColumn is the page visibility column: rows are kept when their tags
+// intersect the user's.
+type rowPolicy struct {
+	user      map[string]any
+	filters   []*utils.Expr
+	denyAll   bool
+	hidden    map[string]bool
+	tagColumn string
+	tags      map[string]bool
+}
+
+func hasServerConditions(conds []ConditionDefinition) bool {
+	for _, cond := range conds {
+		if cond.Server {
+			return true
+		}
+	}
+	return false
+}
+
+// newRowPolicy returns nil when neither a server condition nor the
+// visibility column concerns the user. A filter that does not compile hides
+// every row: server conditions fail closed. Admins are not restricted by the
+// visibility column.
+func newRowPolicy(c *gin.Context, db *gorm.DB, conds []ConditionDefinition, tagColumn string) *rowPolicy {
+	if !hasServerConditions(conds) && tagColumn == "" {
+		return nil
+	}
+
+	subject := loadConditionSubject(c, db)
+	policy := &rowPolicy{user: subject.user, hidden: map[string]bool{}}
+	applies := false
+
+	if tagColumn != "" && !subject.admin {
+		applies = true
+		policy.tagColumn = tagColumn
+		policy.tags = subject.tags
+	}
+
+	for _, cond := range conds {
+		if !cond.Server || !subject.concerned(cond) {
+			continue
+		}
+		switch cond.Action {
+		case ConditionFilterRows:
+			applies = true
+			expr, err := utils.CompileExpr(cond.Expression)
+			if err != nil {
+				log.Printf("⚠️  schemaCondition %q invalide, lignes masquées: %v", cond.Name, err)
+				policy.denyAll = true
+				continue
+			}
+			policy.filters = append(policy.filters, expr)
+		case ConditionHideColumns:
+			applies = true
+			for _, col := range cond.Columns {
+				policy.hidden[col] = true
+			}
+		}
+	}
+
+	if !applies {
+		return nil
+	}
+	return policy
+}
+
+func (p *rowPolicy) allows(row map[string]any) bool {
+	if p.denyAll {
+		return false
+	}
+	if p.tagColumn != "" && !p.visibleTo(row[p.tagColumn]) {
+		return false
+	}
+	if len(p.filters) == 0 {
+		return true
+	}
+
+	env := make(map[string]any, len(row)+1)
+	for k, v := range row {
+		env[k] = v
+	}
+	env["user"] = p.user
+
+	for _, f := range p.filters {
+		ok, err := f.Eval(env)
+		if err != nil || ok != true {
+			return false
+		}
+	}
+	return true
+}
+
+// visibleTo reports whether one of the row tags is held by the user. Tags
+// are matched by id or name; untagged rows are hidden.
+func (p *rowPolicy) visibleTo(value any) bool {
+	for _, tag := range rowTags(value) {
+		if p.tags[tag] {
+			return true
+		}
+	}
+	return false
+}
+
+// rowTags reads the visibility column, which may be a many-to-many relation
+// to the tags table, a JSON or Postgres array, or a comma separated list.
+func rowTags(value any) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return rowTags(string(v))
+	case []any:
+		var out []string
+		for _, item := range v {
+			if obj, ok := item.(map[string]any); ok {
+				for _, key := range []string{"id", "name"} {
+					if s, ok := obj[key].(string); ok && s != "" {
+						out = append(out, s)
+					}
+				}
+				continue
+			}
+			out = append(out, rowTags(item)...)
+		}
+		return out
+	case []string:
+		return v
+	case string:
+		v = strings.TrimSpace(v)
+		if strings.HasPrefix(v, "[") {
+			var list []any
+			if err := json.Unmarshal([]byte(v), &list); err == nil {
+				return rowTags(list)
+			}
+		}
+		v = strings.TrimSuffix(strings.TrimPrefix(v, "{"), "}")
+
+		var out []string
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.Trim(strings.TrimSpace(part), `"`); part != "" {
+				out = append(out, part)
+			}
+		}
+		return out
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+func (p *rowPolicy) strip(row map[string]any) {
+	for col := range p.hidden {
+		delete(row, col)
+	}
+}
+
+func (p *rowPolicy) apply(rows []map[string]any) []map[string]any {
+	out := make([]map[string]any, 0, len(rows))
+	for _, row := range rows {
+		if !p.allows(row) {
+			continue
+		}
+		p.strip(row)
+		out = append(out, row)
+	}
+	return out
+}
+
+// clientConditions drops the server conditions from what is sent back,
+// keeping the other entries untouched.
+func clientConditions(raw []byte) []json.RawMessage {
+	var entries []json.RawMessage
+	_ = json.Unmarshal(raw, &entries)
+
+	out := []json.RawMessage{}
+	for _, entry := range entries {
+		var cond ConditionDefinition
+		if err := json.Unmarshal(entry, &cond); err == nil && cond.Server {
+			continue
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+// applyPayloadConditions filters a marshalled page payload for the current
+// user. It works on bytes so that it runs after the shared page cache. The
+// rows of guarded tables, embedded or in the dependencies, go through the
+// rules of their own page.
+func applyPayloadConditions(c *gin.Context, db *gorm.DB, body []byte) ([]byte, error) {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, err
+	}
+
+	var tagColumn string
+	_ = json.Unmarshal(envelope["visibilityColumn"], &tagColumn)
+	var rules map[string]MaskRule
+	_ = json.Unmarshal(envelope["masks"], &rules)
+	delete(envelope, "masks")
+	var relations []RelationDefinition
+	_ = json.Unmarshal(envelope["relations"], &relations)
+	access, err := loadRelatedAccess(c, db, relations)
+	if err != nil {
+		return nil, err
+	}
+
+	conds := parseConditions(envelope["conditions"])
+	if !hasServerConditions(conds) && tagColumn == "" && len(rules) == 0 && len(access.guarded) == 0 {
+		return body, nil
+	}
+
+	policy := newRowPolicy(c, db, conds, tagColumn)
+	masks := newColumnMasks(c, db, rules)
+	expand := parseExpand(c)
+	if policy != nil || masks != nil || len(access.guarded) > 0 {
+		var data []map[string]any
+		dec := json.NewDecoder(bytes.NewReader(envelope["data"]))
+		dec.UseNumber()
+		if err := dec.Decode(&data); err != nil {
+			return nil, err
+		}
+
+		kept := data
+		if policy != nil {
+			kept = policy.apply(data)
+		}
+		masks.apply(kept...)
+		access.rows(relations, expand, kept...)
+		filtered, err := json.Marshal(kept)
+		if err != nil {
+			return nil, err
+		}
+		envelope["data"] = filtered
+		if dropped := len(data) - len(kept); dropped > 0 {
+			envelope["meta"] = discountRows(envelope["meta"], dropped)
+		}
+	}
+
+	if len(access.guarded) > 0 && len(envelope["dependencies"]) > 0 {
+		opts, err := parseDependencyOptions(c)
+		if err != nil {
+			return nil, err
+		}
+		var dependencies map[string]any
+		dec := json.NewDecoder(bytes.NewReader(envelope["dependencies"]))
+		dec.UseNumber()
+		if err := dec.Decode(&dependencies); err != nil {
+			return nil, err
+		}
+		access.dependencies(dependencies, relations, opts, expand)
+		if envelope["dependencies"], err = json.Marshal(dependencies); err != nil {
+			return nil, err
+		}
+	}
+
+	visible, err := json.Marshal(clientConditions(envelope["conditions"]))
+	if err != nil {
+		return nil, err
+	}
+	envelope["conditions"] = visible
+
+	return json.Marshal(envelope)
+}
+
+// discountRows takes the rows hidden by the conditions out of the meta of a
+// page payload. A paged payload only knows of the hidden rows of its page,
+// so its total stays an upper bound.
+func discountRows(raw json.RawMessage, dropped int) json.RawMessage {
+	var meta utils.PageMeta
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return raw
+	}
+	unpaged := meta.Page == 1 && !meta.HasMore && int64(meta.PageSize) == meta.Total
+	meta.Total = max(meta.Total-int64(dropped), 0)
+	if unpaged {
+		meta.PageSize = int(meta.Total)
+	}
+	out, err := json.Marshal(meta)
+	if err != nil {
+		return raw
+	}
+	return out
+}
+
+// applyItemConditions reports whether the user may see an item of page,
+// stripping the hidden columns and masking the masked ones in place.
+func applyItemConditions(c *gin.Context, db *gorm.DB, page models.Page, item map[string]any) bool {
+	cols := parseColumns(page.SchemaColumnsDeployed)
+	policy := newRowPolicy(c, db, parseConditions(page.SchemaConditionsDeployed), visibilityColumn(cols))
+	if policy != nil {
+		if !policy.allows(item) {
+			return false
+		}
+		policy.strip(item)
+	}
+	newColumnMasks(c, db, pageMasks(cols)).apply(item)
+	return true
+}
+
+// checkItemWrite keeps the user from writing the columns of page hidden
+// from or masked for them. A masked column sent back as read in current,
+// the masked item being updated, is dropped from fields: a form must not
+// store the mask over the value. current is nil for bulk updates.
+func checkItemWrite(c *gin.Context, db *gorm.DB, page models.Page, current, fields map[string]any) error {
+	cols := parseColumns(page.SchemaColumnsDeployed)
+	policy := newRowPolicy(c, db, parseConditions(page.SchemaConditionsDeployed), visibilityColumn(cols))
+	masks := newColumnMasks(c, db, pageMasks(cols))
+	for col, value := range fields {
+		if policy != nil && policy.hidden[col] {
+			return fmt.Errorf("la colonne %q n'est pas modifiable", col)
+		}
+		if _, masked := masks[col]; !masked {
+			continue
+		}
+		if read, ok := current[col]; ok && fmt.Sprint(read) == fmt.Sprint(value) {
+			delete(fields, col)
+			continue
+		}
+		return fmt.Errorf("la colonne %q est masquée et n'est pas modifiable", col)
+	}
+	return nil
+}
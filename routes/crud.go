@@ -0,0 +1,665 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// CrudResource describes a model exposed through RegisterCrudRoutes.
+type CrudResource struct {
+	Path     string
+	Singular string
+	Plural   string
+	// Trash is the kind under which deleted records go to the trash; they
+	// are deleted for good when empty.
+	Trash string
+	// AdminFields are the JSON keys only admins may write, such as the
+	// fields the access checks rely on.
+	AdminFields []string
+}
+
+// deniedField returns the first key of payloads the current user may not
+// write. JSON keys bind case-insensitively, and so are they compared.
+func (res CrudResource) deniedField(c *gin.Context, payloads ...map[string]any) (string, bool) {
+	if len(res.AdminFields) == 0 {
+		return "", false
+	}
+	if user := utils.CurrentUser(c); user != nil && Bool(user.IsAdmin) {
+		return "", false
+	}
+	for _, payload := range payloads {
+		for key := range payload {
+			for _, field := range res.AdminFields {
+				if strings.EqualFold(key, field) {
+					return field, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+type crudDependency struct {
+	key      string
+	rel      *schema.Relationship
+	preloads []string
+}
+
+// crudMeta is derived once per model from its gorm schema and the
+// `crud:"dependency"` tags on its association fields.
+type crudMeta struct {
+	schema    *schema.Schema
+	preloads  []string
+	columns   map[string]string
+	ignored   map[string]bool
+	many2many map[string]*schema.Relationship
+	deps      []crudDependency
+}
+
+var crudSchemas sync.Map
+
+func jsonName(field *schema.Field) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func pluralize(name string) string {
+	switch {
+	case strings.HasSuffix(name, "s"):
+		return name
+	case strings.HasSuffix(name, "y"):
+		return strings.TrimSuffix(name, "y") + "ies"
+	}
+	return name + "s"
+}
+
+func isCrudDependency(field *schema.Field) bool {
+	return field.Tag.Get("crud") == "dependency"
+}
+
+func singleValued(rel *schema.Relationship) bool {
+	return rel.Type == schema.BelongsTo || rel.Type == schema.HasOne
+}
+
+// nestedPreloads lists the single-valued dependencies of a related schema so
+// that e.g. users come back with Tags.Category but tags do not embed every
+// tag of their category again.
+func nestedPreloads(s *schema.Schema, prefix string) []string {
+	var out []string
+	for _, field := range s.Fields {
+		if !isCrudDependency(field) {
+			continue
+		}
+		if rel, ok := s.Relationships.Relations[field.Name]; ok && singleValued(rel) {
+			out = append(out, prefix+field.Name)
+		}
+	}
+	return out
+}
+
+func newCrudMeta(db *gorm.DB, model any) (*crudMeta, error) {
+	s, err := schema.Parse(model, &crudSchemas, db.NamingStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &crudMeta{
+		schema:    s,
+		columns:   map[string]string{},
+		ignored:   map[string]bool{},
+		many2many: map[string]*schema.Relationship{},
+	}
+
+	seen := map[string]bool{}
+	for _, field := range s.Fields {
+		name := jsonName(field)
+
+		rel, isRel := s.Relationships.Relations[field.Name]
+		switch {
+		case isRel:
+			if rel.Type == schema.Many2Many {
+				meta.many2many[name] = rel
+			} else {
+				meta.ignored[name] = true
+			}
+		case field.DBName == "":
+			continue
+		case field.PrimaryKey || field.AutoCreateTime > 0 || field.AutoUpdateTime > 0:
+			meta.ignored[name] = true
+		default:
+			meta.columns[name] = field.DBName
+		}
+
+		if !isRel || !isCrudDependency(field) {
+			continue
+		}
+
+		meta.preloads = append(meta.preloads, field.Name)
+		meta.preloads = append(meta.preloads, nestedPreloads(rel.FieldSchema, field.Name+".")...)
+
+		key := name
+		if singleValued(rel) {
+			key = pluralize(name)
+		}
+		if !seen[key] {
+			seen[key] = true
+			meta.deps = append(meta.deps, crudDependency{
+				key:      key,
+				rel:      rel,
+				preloads: nestedPreloads(rel.FieldSchema, ""),
+			})
+		}
+	}
+
+	return meta, nil
+}
+
+func (m *crudMeta) preload(db *gorm.DB) *gorm.DB {
+	for _, p := range m.preloads {
+		db = db.Preload(p)
+	}
+	return db
+}
+
+func (m *crudMeta) primaryKey(value any) string {
+	v, _ := m.schema.PrioritizedPrimaryField.ValueOf(context.Background(), reflect.ValueOf(value).Elem())
+	return fmt.Sprintf("%v", v)
+}
+
+func (m *crudMeta) setPrimaryKey(value any, id string) error {
+	return m.schema.PrioritizedPrimaryField.Set(context.Background(), reflect.ValueOf(value).Elem(), id)
+}
+
+func (m *crudMeta) dependencies(db *gorm.DB) (gin.H, error) {
+	out := gin.H{}
+	for _, dep := range m.deps {
+		list := reflect.New(reflect.SliceOf(dep.rel.FieldSchema.ModelType))
+		q := db
+		for _, p := range dep.preloads {
+			q = q.Preload(p)
+		}
+		if err := q.Find(list.Interface()).Error; err != nil {
+			return nil, err
+		}
+		out[dep.key] = list.Elem().Interface()
+	}
+	return out, nil
+}
+
+// columnUpdates maps a JSON patch onto column names. Many-to-many keys are
+// returned separately as id lists; read-only keys are dropped.
+func (m *crudMeta) columnUpdates(patch map[string]any) (map[string]any, map[string][]string, error) {
+	updates := map[string]any{}
+	links := map[string][]string{}
+
+	for key, val := range patch {
+		if col, ok := m.columns[key]; ok {
+			switch val.(type) {
+			case map[string]any, []any:
+				b, err := json.Marshal(val)
+				if err != nil {
+					return nil, nil, err
+				}
+				updates[col] = datatypes.JSON(b)
+			default:
+				updates[col] = val
+			}
+			continue
+		}
+		if _, ok := m.many2many[key]; ok {
+			links[key] = linkIDs(val)
+			continue
+		}
+		if m.ignored[key] {
+			continue
+		}
+		return nil, nil, fmt.Errorf("unknown field %q", key)
+	}
+
+	return updates, links, nil
+}
+
+func linkIDs(val any) []string {
+	items, _ := val.([]any)
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			ids = append(ids, v)
+		case map[string]any:
+			if id, ok := v["id"].(string); ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// structLinks collects the ids of many-to-many associations set on a bound
+// payload. A nil slice means "leave untouched", an empty one clears.
+func (m *crudMeta) structLinks(value any) map[string][]string {
+	links := map[string][]string{}
+	rv := reflect.ValueOf(value).Elem()
+
+	for key, rel := range m.many2many {
+		fv := rv.FieldByName(rel.Name)
+		if fv.Kind() != reflect.Slice || fv.IsNil() {
+			continue
+		}
+		ids := make([]string, 0, fv.Len())
+		for i := 0; i < fv.Len(); i++ {
+			item := fv.Index(i)
+			if item.Kind() == reflect.Ptr {
+				item = item.Elem()
+			}
+			v, zero := rel.FieldSchema.PrioritizedPrimaryField.ValueOf(context.Background(), item)
+			if !zero {
+				ids = append(ids, fmt.Sprintf("%v", v))
+			}
+		}
+		links[key] = ids
+	}
+	return links
+}
+
+func (m *crudMeta) replaceLinks(tx *gorm.DB, record any, links map[string][]string) error {
+	for key, ids := range links {
+		rel := m.many2many[key]
+		assoc := tx.Model(record).Association(rel.Name)
+
+		if len(ids) == 0 {
+			if err := assoc.Clear(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		related := reflect.New(reflect.SliceOf(rel.FieldSchema.ModelType))
+		if err := tx.Find(related.Interface(), "id IN ?", ids).Error; err != nil {
+			return err
+		}
+		if err := assoc.Replace(related.Elem().Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listMaxPageSize caps ?pageSize on the list endpoints.
+const listMaxPageSize = 500
+
+// RegisterCrudRoutes generates list/get/create/update/patch/delete and bulk
+// endpoints for T under res.Path. Associations tagged `crud:"dependency"` are
+// preloaded on every read, and listed under "dependencies" by GET.
+func RegisterCrudRoutes[T any](group *gin.RouterGroup, db *gorm.DB, res CrudResource) {
+	meta, err := newCrudMeta(db, new(T))
+	if err != nil {
+		panic(fmt.Sprintf("crud %s: %v", res.Path, err))
+	}
+
+	r := group.Group(res.Path)
+
+	load := func(c *gin.Context, id string) (*T, bool) {
+		record := new(T)
+		if err := meta.preload(utils.DB(c, db)).First(record, "id = ?", id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", res.Singular+" not found")
+				return nil, false
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return nil, false
+		}
+		return record, true
+	}
+
+	// guard answers 403 when payloads write an admin-only field.
+	guard := func(c *gin.Context, payloads ...map[string]any) bool {
+		if field, denied := res.deniedField(c, payloads...); denied {
+			utils.Error(c, http.StatusForbidden, "FORBIDDEN", fmt.Sprintf("Only admins may set %q", field))
+			return false
+		}
+		return true
+	}
+
+	reload := func(c *gin.Context, id string, status int) {
+		record := new(T)
+		if err := meta.preload(utils.DB(c, db)).First(record, "id = ?", id).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
+			return
+		}
+		c.JSON(status, gin.H{"data": record, "success": true})
+	}
+
+	r.GET("", func(c *gin.Context) {
+		pagination := utils.ParsePagination(c, 0, listMaxPageSize)
+		query := db
+		if pagination.Paged() {
+			query = query.Order("id")
+		}
+		records := []T{}
+		page, err := utils.FindPage(query, pagination, &records, meta.preload)
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+
+		deps, err := meta.dependencies(db)
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_DEPENDENCIES_ERROR", err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data":         records,
+			"meta":         page,
+			"dependencies": deps,
+			"success":      true,
+		})
+	})
+
+	r.GET("/:id", func(c *gin.Context) {
+		record, ok := load(c, c.Param("id"))
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": record, "success": true})
+	})
+
+	r.POST("", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		payload := new(T)
+		var raw map[string]any
+		if err := c.ShouldBindBodyWith(payload, binding.JSON); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		if err := c.ShouldBindBodyWith(&raw, binding.JSON); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		if !guard(c, raw) {
+			return
+		}
+		if err := db.Create(payload).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_CREATE_ERROR", err.Error())
+			return
+		}
+		reload(c, meta.primaryKey(payload), http.StatusCreated)
+	})
+
+	r.POST("/createMany", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		var payload []T
+		var raw []map[string]any
+		if err := c.ShouldBindBodyWith(&payload, binding.JSON); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		if err := c.ShouldBindBodyWith(&raw, binding.JSON); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		if len(payload) == 0 {
+			utils.Error(c, http.StatusBadRequest, "NO_ITEMS_PROVIDED", "No items provided")
+			return
+		}
+		if !guard(c, raw...) {
+			return
+		}
+		if err := db.Create(&payload).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_CREATE_MANY_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{
+			"data":    payload,
+			"count":   len(payload),
+			"success": true,
+		})
+	})
+
+	r.PUT("/:id", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		id := c.Param("id")
+		payload := new(T)
+		var raw map[string]any
+
+		if err := c.ShouldBindBodyWith(payload, binding.JSON); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		if err := c.ShouldBindBodyWith(&raw, binding.JSON); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		if !guard(c, raw) {
+			return
+		}
+
+		existing, ok := load(c, id)
+		if !ok {
+			return
+		}
+
+		if err := meta.setPrimaryKey(payload, id); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(existing).Omit(clause.Associations).Updates(payload).Error; err != nil {
+				return err
+			}
+			return meta.replaceLinks(tx, existing, meta.structLinks(payload))
+		})
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_UPDATE_ERROR", err.Error())
+			return
+		}
+		reload(c, id, http.StatusOK)
+	})
+
+	r.PATCH("/:id", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		id := c.Param("id")
+		var patch map[string]any
+
+		if err := c.ShouldBindJSON(&patch); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		if len(patch) == 0 {
+			utils.Error(c, http.StatusBadRequest, "NO_UPDATES_PROVIDED", "No updates provided")
+			return
+		}
+		if !guard(c, patch) {
+			return
+		}
+
+		updates, links, err := meta.columnUpdates(patch)
+		if err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_FIELD", err.Error())
+			return
+		}
+
+		existing, ok := load(c, id)
+		if !ok {
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if len(updates) > 0 {
+				if err := tx.Model(existing).Omit(clause.Associations).Updates(updates).Error; err != nil {
+					return err
+				}
+			}
+			return meta.replaceLinks(tx, existing, links)
+		})
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_PATCH_ERROR", err.Error())
+			return
+		}
+		reload(c, id, http.StatusOK)
+	})
+
+	r.PATCH("/patchMany", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		var payload struct {
+			IDs     []string       `json:"ids"`
+			Updates map[string]any `json:"updates"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		if len(payload.IDs) == 0 {
+			utils.Error(c, http.StatusBadRequest, "NO_IDS_PROVIDED", "No IDs provided")
+			return
+		}
+		if len(payload.Updates) == 0 {
+			utils.Error(c, http.StatusBadRequest, "NO_UPDATES_PROVIDED", "No updates provided")
+			return
+		}
+		if !guard(c, payload.Updates) {
+			return
+		}
+
+		updates, links, err := meta.columnUpdates(payload.Updates)
+		if err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_FIELD", err.Error())
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if len(updates) > 0 {
+				if err := tx.Model(new(T)).Where("id IN ?", payload.IDs).Updates(updates).Error; err != nil {
+					return err
+				}
+			}
+			if len(links) == 0 {
+				return nil
+			}
+
+			var records []T
+			if err := tx.Find(&records, "id IN ?", payload.IDs).Error; err != nil {
+				return err
+			}
+			for i := range records {
+				if err := meta.replaceLinks(tx, &records[i], links); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_PATCH_MANY_ERROR", err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": res.Plural + " updated successfully",
+			"count":   len(payload.IDs),
+			"success": true,
+		})
+	})
+
+	r.POST("/deleteMany", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		var ids []string
+		if err := c.ShouldBindJSON(&ids); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		if len(ids) == 0 {
+			utils.Error(c, http.StatusBadRequest, "NO_IDS_PROVIDED", "No IDs provided")
+			return
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if res.Trash != "" {
+				var records []T
+				if err := meta.preload(tx).Find(&records, "id IN ?", ids).Error; err != nil {
+					return err
+				}
+				for _, record := range records {
+					if err := trashRecord(c, tx, res.Trash, record); err != nil {
+						return err
+					}
+				}
+			}
+			return tx.Delete(new(T), ids).Error
+		})
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_DELETE_MANY_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": res.Plural + " deleted successfully",
+			"count":   len(ids),
+			"success": true,
+		})
+	})
+
+	r.DELETE("/:id", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		id := c.Param("id")
+
+		record := new(T)
+		if err := db.First(record, "id = ?", id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", res.Singular+" not found")
+				return
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if res.Trash != "" {
+				if err := trashRecord(c, tx, res.Trash, record); err != nil {
+					return err
+				}
+			}
+			return tx.Delete(record).Error
+		})
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_DELETE_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": res.Singular + " deleted successfully",
+			"id":      id,
+			"success": true,
+		})
+	})
+}
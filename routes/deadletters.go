@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func RegisterDeadLetterRoutes(group *gin.RouterGroup, db *gorm.DB) {
+	letters := group.Group("/dead-letters")
+
+	load := func(c *gin.Context) (*models.DeadLetter, bool) {
+		var letter models.DeadLetter
+		if err := db.First(&letter, "id = ?", c.Param("id")).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Dead letter not found")
+				return nil, false
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return nil, false
+		}
+		return &letter, true
+	}
+
+	letters.GET("", func(c *gin.Context) {
+		query := db.Order("created_at DESC")
+		if status := c.DefaultQuery("status", services.DeadLetterPending); status != "all" {
+			query = query.Where("status = ?", status)
+		}
+		if kind := c.Query("kind"); kind != "" {
+			query = query.Where("kind = ?", kind)
+		}
+
+		list := []models.DeadLetter{}
+		meta, err := utils.FindPage(query, utils.ParsePagination(c, 0, listMaxPageSize), &list, nil)
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": list, "meta": meta, "success": true})
+	})
+
+	letters.GET("/:id", func(c *gin.Context) {
+		letter, ok := load(c)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": letter, "success": true})
+	})
+
+	letters.POST("/:id/replay", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		letter, ok := load(c)
+		if !ok {
+			return
+		}
+		if letter.Status == services.DeadLetterReplayed {
+			utils.Error(c, http.StatusConflict, "ALREADY_REPLAYED", "Dead letter was already replayed")
+			return
+		}
+
+		if err := services.ReplayDeadLetter(db, letter); err != nil {
+			recordAudit(c, db, "replay", "dead_letter", &letter.ID, services.AuditStatusFailure, gin.H{"kind": letter.Kind, "error": err.Error()})
+			utils.Error(c, http.StatusBadGateway, "REPLAY_FAILED", err.Error())
+			return
+		}
+		recordAudit(c, db, "replay", "dead_letter", &letter.ID, services.AuditStatusSuccess, gin.H{"kind": letter.Kind})
+		c.JSON(http.StatusOK, gin.H{"data": letter, "success": true})
+	})
+
+	letters.DELETE("/:id", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		letter, ok := load(c)
+		if !ok {
+			return
+		}
+		if err := db.Model(letter).Update("status", services.DeadLetterDiscarded).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_UPDATE_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Dead letter discarded", "id": letter.ID, "success": true})
+	})
+}
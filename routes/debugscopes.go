@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// defaultDebugScopeDuration applies when a scope is added without one.
+const defaultDebugScopeDuration = 15 * time.Minute
+
+func RegisterDebugScopeRoutes(group *gin.RouterGroup, db *gorm.DB, scopes *services.DebugScopes) {
+	group.GET("/debug-scopes", func(c *gin.Context) {
+		list, err := scopes.List(c.Request.Context())
+		if err != nil {
+			utils.Error(c, http.StatusServiceUnavailable, "DEBUG_SCOPES_UNAVAILABLE", err.Error())
+			return
+		}
+		if list == nil {
+			list = []services.DebugScope{}
+		}
+		c.JSON(http.StatusOK, gin.H{"data": list, "success": true})
+	})
+
+	// POST /debug-scopes takes {kind, value, durationSeconds}; logging stops
+	// by itself once the duration is over.
+	group.POST("/debug-scopes", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		var payload struct {
+			Kind            string `json:"kind"`
+			Value           string `json:"value"`
+			DurationSeconds int    `json:"durationSeconds"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		duration := defaultDebugScopeDuration
+		if payload.DurationSeconds != 0 {
+			duration = time.Duration(payload.DurationSeconds) * time.Second
+		}
+
+		scope := services.DebugScope{Kind: payload.Kind, Value: payload.Value}
+		if user := utils.CurrentUser(c); user != nil {
+			scope.CreatedBy = user.Email
+		}
+		scope, err := scopes.Add(c.Request.Context(), scope, duration)
+		if errors.Is(err, services.ErrInvalidDebugScope) {
+			utils.Error(c, http.StatusBadRequest, "INVALID_DEBUG_SCOPE", err.Error())
+			return
+		}
+		if err != nil {
+			utils.Error(c, http.StatusServiceUnavailable, "DEBUG_SCOPES_UNAVAILABLE", err.Error())
+			return
+		}
+		recordAudit(c, db, services.AuditActionCreate, "debug_scope", &scope.ID, services.AuditStatusSuccess,
+			gin.H{"kind": scope.Kind, "value": scope.Value, "expiresAt": scope.ExpiresAt})
+		c.JSON(http.StatusCreated, gin.H{"data": scope, "success": true})
+	})
+
+	group.DELETE("/debug-scopes/:id", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		id := c.Param("id")
+		found, err := scopes.Remove(c.Request.Context(), id)
+		if err != nil {
+			utils.Error(c, http.StatusServiceUnavailable, "DEBUG_SCOPES_UNAVAILABLE", err.Error())
+			return
+		}
+		if !found {
+			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Debug scope not found")
+			return
+		}
+		recordAudit(c, db, services.AuditActionDelete, "debug_scope", &id, services.AuditStatusSuccess, nil)
+		c.JSON(http.StatusOK, gin.H{"message": "Debug scope removed", "id": id, "success": true})
+	})
+}
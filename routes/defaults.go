@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Server-side default expressions accepted in a column's "default". Any other
+// value is inserted as-is.
+const (
+	DefaultNow         = "now()"
+	DefaultCurrentUser = "currentUser()"
+)
+
+// columnDefaults resolves the defaults of the columns missing from fields.
+// They are returned apart so that an upsert only applies them on insert.
+func columnDefaults(c *gin.Context, cols []ColumnDefinition, fields map[string]any) map[string]any {
+	defaults := map[string]any{}
+	now := time.Now()
+
+	for _, col := range cols {
+		if col.Default == nil {
+			continue
+		}
+		if _, ok := fields[col.Name]; ok {
+			continue
+		}
+
+		switch col.Default {
+		case DefaultNow:
+			defaults[col.Name] = now
+		case DefaultCurrentUser:
+			if id := utils.CurrentUserID(c); id != nil {
+				defaults[col.Name] = *id
+			}
+		default:
+			defaults[col.Name] = col.Default
+		}
+	}
+
+	return defaults
+}
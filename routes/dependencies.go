@@ -0,0 +1,264 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/utils"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Values of the ?dependencies query parameter.
+const (
+	DependenciesFull = "full"
+	DependenciesIDs  = "ids"
+	DependenciesNone = "none"
+)
+
+// dependencyOptions trims the dependencies block of page payloads. Limit
+// caps every related table; a relation may set a lower dependencyLimit.
+type dependencyOptions struct {
+	Mode  string
+	Limit int
+}
+
+func parseDependencyOptions(c *gin.Context) (dependencyOptions, error) {
+	opts := dependencyOptions{Mode: c.DefaultQuery("dependencies", DependenciesFull)}
+	switch opts.Mode {
+	case DependenciesFull, DependenciesIDs, DependenciesNone:
+	default:
+		return opts, fmt.Errorf("dependencies must be one of %s, %s or %s", DependenciesFull, DependenciesIDs, DependenciesNone)
+	}
+
+	if v := c.Query("dependencyLimit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return opts, fmt.Errorf("dependencyLimit must be a positive integer")
+		}
+		opts.Limit = n
+	}
+	return opts, nil
+}
+
+func (o dependencyOptions) limitFor(rel RelationDefinition) int {
+	limit := o.Limit
+	if rel.DependencyLimit > 0 && (limit == 0 || rel.DependencyLimit < limit) {
+		limit = rel.DependencyLimit
+	}
+	return limit
+}
+
+// dependencySpec merges the relations pointing at one table. A row is sent
+// when it matches the filter of any of them; columns is nil when one of them
+// wants every column. Rows are sent as id + label when every relation
+// declares labelColumns and none is expanded.
+type dependencySpec struct {
+	table      string
+	columns    map[string]bool
+	filters    []*utils.Expr
+	unfiltered bool
+	limit      int
+	label      *RelationDefinition
+}
+
+func dependencySpecs(relations []RelationDefinition, opts dependencyOptions, expand expandSet) []*dependencySpec {
+	var specs []*dependencySpec
+	byTable := make(map[string]*dependencySpec)
+
+	for _, rel := range relations {
+		spec, ok := byTable[rel.ToTable]
+		if !ok {
+			spec = &dependencySpec{table: rel.ToTable, columns: map[string]bool{"id": true}}
+			if len(rel.DependencyColumns) == 0 && !expand.expands(rel) {
+				spec.label = &rel
+			}
+			byTable[rel.ToTable] = spec
+			specs = append(specs, spec)
+		} else if len(rel.DependencyColumns) > 0 || expand.expands(rel) {
+			spec.label = nil
+		}
+
+		if len(rel.DependencyColumns) == 0 {
+			spec.columns = nil
+		} else if spec.columns != nil {
+			for _, col := range rel.DependencyColumns {
+				spec.columns[col] = true
+			}
+		}
+
+		if rel.DependencyFilter == "" {
+			spec.unfiltered = true
+		} else if expr, err := utils.CompileExpr(rel.DependencyFilter); err != nil {
+			log.Printf("⚠️  dependencyFilter de %s invalide, ignoré: %v", rel.FromColumn, err)
+		} else {
+			spec.filters = append(spec.filters, expr)
+		}
+
+		// The most permissive relation wins: no limit beats any limit.
+		if limit := opts.limitFor(rel); !ok || (spec.limit > 0 && (limit == 0 || limit > spec.limit)) {
+			spec.limit = limit
+		}
+	}
+
+	for _, spec := range specs {
+		if spec.unfiltered {
+			spec.filters = nil
+		}
+		if opts.Mode == DependenciesIDs {
+			spec.columns = map[string]bool{"id": true}
+			spec.label = nil
+		}
+	}
+	return specs
+}
+
+// filtered reports whether rows must go through the filters: the relations
+// all declare one.
+func (s *dependencySpec) filtered() bool {
+	return !s.unfiltered
+}
+
+func (s *dependencySpec) matches(row map[string]any) bool {
+	for _, f := range s.filters {
+		if ok, err := f.Eval(row); err == nil && ok == true {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *dependencySpec) project(row map[string]any) map[string]any {
+	if s.label != nil {
+		return labelled(*s.label, row)
+	}
+	if s.columns == nil {
+		return row
+	}
+	out := make(map[string]any, len(s.columns))
+	for col := range s.columns {
+		if v, ok := row[col]; ok {
+			out[col] = v
+		}
+	}
+	return out
+}
+
+// query selects the declared columns, or every column when a filter or the
+// row policy of a guarded table (whole) needs to see the whole row. The
+// limit is applied in SQL only when nothing is filtered out afterwards.
+func (s *dependencySpec) query(whole bool) (*dynamicQuery, error) {
+	q := newQuery("SELECT ")
+	if s.columns == nil || s.filtered() || whole {
+		q.Write("*")
+	} else {
+		cols := make([]string, 0, len(s.columns))
+		for col := range s.columns {
+			if err := validateIdent(col); err != nil {
+				return nil, err
+			}
+			cols = append(cols, col)
+		}
+		sort.Strings(cols)
+		q.Idents(cols)
+	}
+	q.Write(" FROM ").Ident(s.table)
+	if s.limit > 0 {
+		q.Write(" ORDER BY id")
+		if !s.filtered() && !whole {
+			q.Write(" LIMIT ").Arg(s.limit)
+		}
+	}
+	return q, nil
+}
+
+// loadDependencies reads the tables targeted by relations, keyed by table.
+// In ids mode only the id column is returned. The guarded tables are read
+// whole, unlimited and unprojected: restrictDependencies trims them once
+// the rows the user may not see are out.
+func loadDependencies(sqlDB sqlExecutor, relations []RelationDefinition, opts dependencyOptions, expand expandSet, guarded map[string]models.Page) map[string]any {
+	dependencies := make(map[string]any)
+	if opts.Mode == DependenciesNone {
+		return dependencies
+	}
+
+	for _, spec := range dependencySpecs(relations, opts, expand) {
+		_, whole := guarded[spec.table]
+		q, err := spec.query(whole)
+		if err != nil {
+			continue
+		}
+		rs, err := sqlDB.Query(q.SQL(), q.Args()...)
+		if err != nil {
+			continue
+		}
+
+		cols, _ := rs.Columns()
+		arr := []map[string]any{}
+
+		for rs.Next() {
+			if !whole && spec.limit > 0 && len(arr) >= spec.limit {
+				break
+			}
+			vals := make([]interface{}, len(cols))
+			ptrs := make([]interface{}, len(cols))
+			for i := range cols {
+				ptrs[i] = &vals[i]
+			}
+			if err := rs.Scan(ptrs...); err != nil {
+				continue
+			}
+			row := make(map[string]any, len(cols))
+			for i, c := range cols {
+				row[c] = vals[i]
+			}
+			if spec.filtered() && !spec.matches(row) {
+				continue
+			}
+			if whole {
+				arr = append(arr, row)
+			} else {
+				arr = append(arr, spec.project(row))
+			}
+		}
+		rs.Close()
+
+		if whole {
+			dependencies[spec.table] = arr
+		} else {
+			dependencies[spec.table] = dependencyRows(opts, arr)
+		}
+	}
+	return dependencies
+}
+
+// dependencyRows is the value sent for the rows of a related table: the
+// rows, or their ids in ids mode.
+func dependencyRows(opts dependencyOptions, rows []map[string]any) any {
+	if opts.Mode != DependenciesIDs {
+		return rows
+	}
+	ids := make([]any, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row["id"])
+	}
+	return ids
+}
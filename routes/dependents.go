@@ -0,0 +1,211 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// DependentRelation is a relation of another page pointing at the table of
+// a page being deleted.
+type DependentRelation struct {
+	PageID   string `json:"pageId"`
+	PageName string `json:"pageName"`
+	Column   string `json:"column"`
+	Table    string `json:"table"`
+	Deployed bool   `json:"deployed,omitempty"`
+	Draft    bool   `json:"draft,omitempty"`
+}
+
+// PageDependents is what would break if some pages were deleted: the
+// navigation items opening them and the relations of the other pages to
+// their tables.
+type PageDependents struct {
+	Navigation []GraphNavigation   `json:"navigation"`
+	Relations  []DependentRelation `json:"relations"`
+}
+
+func (d PageDependents) Empty() bool {
+	return len(d.Navigation)+len(d.Relations) == 0
+}
+
+// findPageDependents lists the dependents of the pages ids outside of them.
+// A table another page still owns is not counted.
+func findPageDependents(db *gorm.DB, ids []string) (PageDependents, error) {
+	deps := PageDependents{Navigation: []GraphNavigation{}, Relations: []DependentRelation{}}
+
+	var items []models.NavigationItem
+	if err := db.Select("id", "title", "path", "page_id").Where("page_id IN ?", ids).Order("lft").Find(&items).Error; err != nil {
+		return deps, err
+	}
+	for _, item := range items {
+		deps.Navigation = append(deps.Navigation, GraphNavigation{ID: item.ID, Title: item.Title, Path: item.Path, PageID: *item.PageID})
+	}
+
+	var deleted, others []models.Page
+	if err := db.Select("id", "table_name", "storage").Where("id IN ? AND table_name <> ''", ids).Find(&deleted).Error; err != nil {
+		return deps, err
+	}
+	if err := db.Select("id", "name", "table_name", "storage", "schema_relations", "schema_relations_deployed").
+		Where("id NOT IN ?", ids).Order("name").Find(&others).Error; err != nil {
+		return deps, err
+	}
+	tables := map[string]bool{}
+	for _, page := range deleted {
+		tables[registryKey(page.Storage, page.TableName)] = true
+	}
+	for _, page := range others {
+		delete(tables, registryKey(page.Storage, page.TableName))
+	}
+	if len(tables) == 0 {
+		return deps, nil
+	}
+
+	for _, page := range others {
+		byColumn := map[string]int{}
+		for _, schema := range []struct {
+			raw      []byte
+			deployed bool
+		}{{page.SchemaRelationsDeployed, true}, {page.SchemaRelations, false}} {
+			for _, rel := range parseRelations(schema.raw) {
+				if !tables[registryKey(page.Storage, rel.ToTable)] {
+					continue
+				}
+				i, ok := byColumn[rel.FromColumn]
+				if !ok {
+					i = len(deps.Relations)
+					byColumn[rel.FromColumn] = i
+					deps.Relations = append(deps.Relations, DependentRelation{
+						PageID: page.ID, PageName: page.Name, Column: rel.FromColumn, Table: rel.ToTable,
+					})
+				}
+				if schema.deployed {
+					deps.Relations[i].Deployed = true
+				} else {
+					deps.Relations[i].Draft = true
+				}
+			}
+		}
+	}
+	return deps, nil
+}
+
+// withoutRelations drops from raw the relations of columns.
+func withoutRelations(raw []byte, columns map[string]bool) []byte {
+	if len(raw) == 0 {
+		return raw
+	}
+	rels := parseRelations(raw)
+	kept := make([]RelationDefinition, 0, len(rels))
+	for _, rel := range rels {
+		if !columns[rel.FromColumn] {
+			kept = append(kept, rel)
+		}
+	}
+	out, _ := json.Marshal(kept)
+	return out
+}
+
+// cascadePageDependents deletes the navigation items of deps, moving them to
+// the trash, and removes the dependent relations from the draft and
+// deployed schemas of their pages, with their foreign keys.
+func cascadePageDependents(c *gin.Context, db *gorm.DB, cache *services.Cache, deps PageDependents) error {
+	if len(deps.Navigation) > 0 {
+		ids := make([]string, 0, len(deps.Navigation))
+		for _, item := range deps.Navigation {
+			ids = append(ids, item.ID)
+		}
+		// Their children are kept, under their parents.
+		err := db.Transaction(func(tx *gorm.DB) error {
+			_, err := deleteNavItems(c, tx, ids, NavDeleteReparent)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		invalidateNavigationCache(c, cache)
+	}
+
+	columns := map[string]map[string]bool{}
+	var order []string
+	for _, rel := range deps.Relations {
+		if columns[rel.PageID] == nil {
+			columns[rel.PageID] = map[string]bool{}
+			order = append(order, rel.PageID)
+		}
+		columns[rel.PageID][rel.Column] = true
+	}
+	for _, id := range order {
+		var before models.Page
+		if err := db.First(&before, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := db.Model(&models.Page{}).Where("id = ?", id).Updates(map[string]any{
+			"schema_relations":          withoutRelations(before.SchemaRelations, columns[id]),
+			"schema_relations_deployed": withoutRelations(before.SchemaRelationsDeployed, columns[id]),
+		}).Error; err != nil {
+			return err
+		}
+		invalidatePageCache(c, cache, id)
+
+		var after models.Page
+		if err := db.Preload("Template").Preload("Tags.Category").First(&after, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := deployRelationFKs(db, before, after); err != nil {
+			return err
+		}
+		recordSchemaChangelog(c, db, before, after)
+	}
+	return nil
+}
+
+// guardPageDelete answers 409 with the dependents of the pages ids unless
+// there are none, or ?force=true cascades the delete to them.
+func guardPageDelete(c *gin.Context, db *gorm.DB, cache *services.Cache, ids []string) bool {
+	deps, err := findPageDependents(db, ids)
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+		return false
+	}
+	if deps.Empty() {
+		return true
+	}
+	if c.Query("force") != "true" {
+		c.JSON(http.StatusConflict, utils.APIResponse{
+			Success: false,
+			Data:    deps,
+			Error: &utils.APIError{
+				Code:    "PAGE_HAS_DEPENDENTS",
+				Details: "Other pages or navigation items depend on this page; ?force=true removes them",
+			},
+		})
+		return false
+	}
+	if err := cascadePageDependents(c, db, cache, deps); err != nil {
+		utils.Error(c, http.StatusInternalServerError, "CASCADE_ERROR", err.Error())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,861 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownColumnType = errors.New("unknown column type")
+	ErrNoTableName       = errors.New("the page has no table name")
+	ErrInvalidMigration  = errors.New("invalid migration")
+)
+
+// defaultIDType is the type of the id of the tables deployed with the
+// default strategy, assumed for relations towards tables not deployed yet.
+const defaultIDType = "uuid"
+
+// columnSQLType maps the type of a column to Postgres, using the names
+// format_type returns so that live columns can be compared. File and
+// encrypted columns hold text.
+func columnSQLType(col ColumnDefinition) (string, error) {
+	if col.Encrypted || col.Type == ColumnTypeFile {
+		return "text", nil
+	}
+	switch strings.ToLower(col.Type) {
+	case "", "text", "string":
+		return "text", nil
+	case "int", "integer":
+		return "integer", nil
+	case "bigint":
+		return "bigint", nil
+	case "smallint":
+		return "smallint", nil
+	case "number", "numeric", "decimal":
+		return "numeric", nil
+	case "float", "double":
+		return "double precision", nil
+	case "real":
+		return "real", nil
+	case "bool", "boolean":
+		return "boolean", nil
+	case "date":
+		return "date", nil
+	case "datetime", "timestamp", "timestamptz":
+		return "timestamp with time zone", nil
+	case "time":
+		return "time without time zone", nil
+	case "json", "jsonb":
+		return "jsonb", nil
+	case "uuid":
+		return "uuid", nil
+	}
+	return "", fmt.Errorf("%w: %q (column %s)", ErrUnknownColumnType, col.Type, col.Name)
+}
+
+// idColumnSQL is the definition of the primary key of a new table for the
+// id strategy of page.
+func idColumnSQL(page models.Page) string {
+	switch page.IDStrategy {
+	case IDStrategyBigserial:
+		return "bigserial PRIMARY KEY"
+	case IDStrategyULID, IDStrategyPrefixed:
+		return "text PRIMARY KEY"
+	}
+	return "uuid PRIMARY KEY DEFAULT gen_random_uuid()"
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// columnDefaultSQL renders the "default" of a column as a DEFAULT
+// expression. currentUser() has no SQL form: the API resolves it on insert.
+func columnDefaultSQL(col ColumnDefinition, sqlType string) (string, bool) {
+	switch col.Default {
+	case nil, DefaultCurrentUser:
+		return "", false
+	case DefaultNow:
+		return "now()", true
+	}
+	if s, ok := col.Default.(string); ok && sqlType != "jsonb" {
+		return quoteLiteral(s), true
+	}
+	b, err := json.Marshal(col.Default)
+	if err != nil {
+		return "", false
+	}
+	return quoteLiteral(string(b)), true
+}
+
+// tableColumnTypes reads the columns of table with their type; nil when the
+// table does not exist.
+func tableColumnTypes(db sqlExecutor, table string) (map[string]string, error) {
+	if exists, err := tableExists(db, table); err != nil || !exists {
+		return nil, err
+	}
+	rows, err := db.Query(`
+		SELECT attname, format_type(atttypid, atttypmod) FROM pg_attribute
+		WHERE attrelid = to_regclass($1) AND attnum > 0 AND NOT attisdropped`,
+		quoteIdent(table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := map[string]string{}
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			return nil, err
+		}
+		types[name] = typ
+	}
+	return types, rows.Err()
+}
+
+// DeployColumnChange is a column whose live type differs from its
+// declaration.
+type DeployColumnChange struct {
+	Column string `json:"column"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// A DeployMigration tells a deploy how to carry live columns over to the
+// draft schema instead of only adding columns. Renames maps old column names
+// to their declared new ones; Widen lets it change the type of a column when
+// the declared one holds every value of the live one (integer to bigint,
+// varchar to text, ...).
+type DeployMigration struct {
+	Renames map[string]string `json:"renames,omitempty"`
+	Widen   bool              `json:"widen,omitempty"`
+}
+
+// deployPlan is the DDL bringing the tables of a page to its draft schema.
+// Columns are only added or, as the migration says, renamed and widened: the
+// ones no longer declared are kept, with their data, and the other type
+// changes are reported.
+type deployPlan struct {
+	Table        string               `json:"table"`
+	Created      bool                 `json:"created"`
+	Statements   []string             `json:"statements"`
+	Kept         []string             `json:"kept,omitempty"`
+	Renamed      map[string]string    `json:"renamed,omitempty"`
+	Widened      []DeployColumnChange `json:"widened,omitempty"`
+	TypeMismatch []DeployColumnChange `json:"typeMismatch,omitempty"`
+	// View is set when the table is a view, which the deploy leaves alone
+	// but for its stored query.
+	View bool `json:"view,omitempty"`
+}
+
+func (p *deployPlan) add(q *dynamicQuery) {
+	p.Statements = append(p.Statements, q.SQL())
+}
+
+// targetIDType is the type of the id column of table: the one of the page
+// itself for a relation to its own table, or defaultIDType when the table
+// does not exist yet.
+func targetIDType(db sqlExecutor, page models.Page, table, ownIDType string) (string, error) {
+	if table == page.TableName {
+		return ownIDType, nil
+	}
+	types, err := tableColumnTypes(db, table)
+	if err != nil {
+		return "", err
+	}
+	if typ, ok := types["id"]; ok {
+		return typ, nil
+	}
+	return defaultIDType, nil
+}
+
+// planDeploy compares the draft schema of page with its live table and
+// lists the statements creating the table, its missing columns, the column
+// defaults changed since the last deploy, the natural key index and the
+// pivot tables of many-to-many relations.
+func planDeploy(db sqlExecutor, page models.Page, migration DeployMigration) (*deployPlan, error) {
+	if isProxyPage(page) {
+		return &deployPlan{Statements: []string{}}, nil
+	}
+	table := page.TableName
+	if table == "" {
+		return nil, ErrNoTableName
+	}
+	if err := validateIdent(table); err != nil {
+		return nil, err
+	}
+	kind, err := relationKind(db, table)
+	if err != nil {
+		return nil, err
+	}
+	if page.ViewQuery != "" || isViewKind(kind) {
+		return planView(db, page, kind)
+	}
+	plan := &deployPlan{Table: table, Statements: []string{}}
+
+	live, err := tableColumnTypes(db, table)
+	if err != nil {
+		return nil, err
+	}
+	ownIDType := live["id"]
+	if ownIDType == "" {
+		ownIDType = defaultIDType
+	}
+	if live == nil {
+		plan.Created = true
+		plan.add(newQuery("CREATE TABLE ").Ident(table).Write(" (id ", idColumnSQL(page), ")"))
+		live = map[string]string{}
+		switch page.IDStrategy {
+		case IDStrategyBigserial:
+			ownIDType = "bigint"
+		case IDStrategyULID, IDStrategyPrefixed:
+			ownIDType = "text"
+		}
+	}
+
+	deployed := map[string]ColumnDefinition{}
+	for _, col := range parseColumns(page.SchemaColumnsDeployed) {
+		deployed[col.Name] = col
+	}
+	if err := planRenames(plan, page, migration.Renames, live, deployed); err != nil {
+		return nil, err
+	}
+	declared := map[string]bool{"id": true, stampCreatedAt: true, stampUpdatedAt: true, stampCreatedBy: true, stampUpdatedBy: true}
+
+	addColumn := func(name, sqlType, def string) {
+		q := newQuery("ALTER TABLE ").Ident(table).Write(" ADD COLUMN ").Ident(name).Write(" ", sqlType)
+		if def != "" {
+			q.Write(" DEFAULT ", def)
+		}
+		plan.add(q)
+	}
+
+	for _, col := range parseColumns(page.SchemaColumns) {
+		if declared[col.Name] {
+			continue
+		}
+		if err := validateIdent(col.Name); err != nil {
+			return nil, err
+		}
+		declared[col.Name] = true
+		sqlType, err := columnSQLType(col)
+		if err != nil {
+			return nil, err
+		}
+		def, hasDefault := columnDefaultSQL(col, sqlType)
+
+		current, exists := live[col.Name]
+		switch {
+		case !exists:
+			addColumn(col.Name, sqlType, def)
+		case current != sqlType:
+			change := DeployColumnChange{Column: col.Name, From: current, To: sqlType}
+			if migration.Widen && safeWidening(current, sqlType) {
+				plan.add(newQuery("ALTER TABLE ").Ident(table).Write(" ALTER COLUMN ").Ident(col.Name).
+					Write(" TYPE ", sqlType, " USING ").Ident(col.Name).Write("::", sqlType))
+				plan.Widened = append(plan.Widened, change)
+			} else {
+				plan.TypeMismatch = append(plan.TypeMismatch, change)
+			}
+		}
+		// Defaults follow the declaration once deployed; on the first
+		// deploy over an existing table, only declared ones are set.
+		if old, ok := deployed[col.Name]; exists && (ok && !reflect.DeepEqual(old.Default, col.Default) || !ok && hasDefault) {
+			q := newQuery("ALTER TABLE ").Ident(table).Write(" ALTER COLUMN ").Ident(col.Name)
+			if hasDefault {
+				q.Write(" SET DEFAULT ", def)
+			} else {
+				q.Write(" DROP DEFAULT")
+			}
+			plan.add(q)
+		}
+		if col.NaturalKey {
+			plan.add(newQuery("CREATE UNIQUE INDEX IF NOT EXISTS ").Ident(indexName("uq", table, col.Name)).
+				Write(" ON ").Ident(table).Write(" (").Ident(col.Name).Write(")"))
+		}
+	}
+
+	for _, rel := range parseRelations(page.SchemaRelations) {
+		if err := validateIdent(rel.FromColumn); err != nil {
+			return nil, err
+		}
+		if err := validateIdent(rel.ToTable); err != nil {
+			return nil, err
+		}
+		switch rel.Type {
+		case "one-to-one", "one-to-many":
+			if declared[rel.FromColumn] {
+				continue
+			}
+			declared[rel.FromColumn] = true
+			if _, exists := live[rel.FromColumn]; exists {
+				continue
+			}
+			idType, err := targetIDType(db, page, rel.ToTable, ownIDType)
+			if err != nil {
+				return nil, err
+			}
+			addColumn(rel.FromColumn, idType, "")
+
+		case "many-to-many":
+			pivot := pivotTableName(table, rel)
+			if err := validateIdent(pivot); err != nil {
+				return nil, err
+			}
+			pivotColumns, err := tableColumnTypes(db, pivot)
+			if err != nil {
+				return nil, err
+			}
+			if pivotColumns != nil {
+				if _, ok := pivotColumns[pivotPositionColumn]; !ok {
+					plan.add(newQuery("ALTER TABLE ").Ident(pivot).Write(" ADD COLUMN ").Ident(pivotPositionColumn).
+						Write(" integer NOT NULL DEFAULT 0"))
+				}
+				continue
+			}
+			idType, err := targetIDType(db, page, rel.ToTable, ownIDType)
+			if err != nil {
+				return nil, err
+			}
+			plan.add(newQuery("CREATE TABLE ").Ident(pivot).
+				Write(" (left_id ", ownIDType, " NOT NULL, right_id ", idType, " NOT NULL, ").
+				Ident(pivotPositionColumn).Write(" integer NOT NULL DEFAULT 0, UNIQUE (left_id, right_id))"))
+		}
+	}
+
+	for name := range live {
+		if !declared[name] {
+			plan.Kept = append(plan.Kept, name)
+		}
+	}
+
+	if err := planIndexes(db, plan, parseColumns(page.SchemaColumns), !plan.Created); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// planRenames adds to plan the renames of live columns asked by renames,
+// and moves them to their new name in live and deployed. A column is only
+// renamed onto a declared name that is free.
+func planRenames(plan *deployPlan, page models.Page, renames map[string]string, live map[string]string, deployed map[string]ColumnDefinition) error {
+	if len(renames) == 0 {
+		return nil
+	}
+	if plan.Created {
+		return fmt.Errorf("%w: table %q does not exist yet", ErrInvalidMigration, plan.Table)
+	}
+
+	declared := map[string]bool{}
+	for _, col := range parseColumns(page.SchemaColumns) {
+		declared[col.Name] = true
+	}
+	for _, rel := range parseRelations(page.SchemaRelations) {
+		declared[rel.FromColumn] = true
+	}
+
+	olds := make([]string, 0, len(renames))
+	for old := range renames {
+		olds = append(olds, old)
+	}
+	sort.Strings(olds)
+
+	plan.Renamed = map[string]string{}
+	for _, old := range olds {
+		name := renames[old]
+		for _, ident := range []string{old, name} {
+			if err := validateIdent(ident); err != nil {
+				return err
+			}
+		}
+		switch _, taken := live[name]; {
+		case old == "id" || old == name:
+			return fmt.Errorf("%w: column %q cannot be renamed to %q", ErrInvalidMigration, old, name)
+		case live[old] == "":
+			return fmt.Errorf("%w: column %q does not exist", ErrInvalidMigration, old)
+		case taken:
+			return fmt.Errorf("%w: column %q already exists", ErrInvalidMigration, name)
+		case !declared[name]:
+			return fmt.Errorf("%w: column %q is not declared", ErrInvalidMigration, name)
+		}
+
+		plan.add(newQuery("ALTER TABLE ").Ident(plan.Table).Write(" RENAME COLUMN ").Ident(old).Write(" TO ").Ident(name))
+		plan.Renamed[old] = name
+		live[name] = live[old]
+		delete(live, old)
+		if col, ok := deployed[old]; ok {
+			col.Name = name
+			deployed[name] = col
+			delete(deployed, old)
+		}
+	}
+	return nil
+}
+
+// safeWidenings lists, for a live type without its modifiers, the declared
+// types that can hold all its values.
+var safeWidenings = map[string][]string{
+	"smallint":                    {"integer", "bigint", "numeric", "real", "double precision"},
+	"integer":                     {"bigint", "numeric", "double precision"},
+	"bigint":                      {"numeric"},
+	"numeric":                     {"numeric"},
+	"real":                        {"double precision"},
+	"character varying":           {"text"},
+	"character":                   {"text"},
+	"date":                        {"timestamp with time zone"},
+	"timestamp without time zone": {"timestamp with time zone"},
+}
+
+func safeWidening(from, to string) bool {
+	if i := strings.IndexByte(from, '('); i >= 0 {
+		from = strings.TrimSpace(from[:i])
+	}
+	return slices.Contains(safeWidenings[from], to)
+}
+
+// indexPrefix starts the names of the indexes declared by the columns of a
+// page, so that the deploy only drops its own.
+const indexPrefix = "ix"
+
+// declaredIndexes maps the name of each index declared by cols to its
+// columns: one per indexed column and one per index group.
+func declaredIndexes(table string, cols []ColumnDefinition) ([]string, map[string][]string) {
+	names := []string{}
+	indexes := map[string][]string{}
+	groups := map[string][]string{}
+	groupOrder := []string{}
+	add := func(columns []string) {
+		name := indexName(indexPrefix, table, columns...)
+		if _, ok := indexes[name]; !ok {
+			names = append(names, name)
+			indexes[name] = columns
+		}
+	}
+	for _, col := range cols {
+		if col.Index {
+			add([]string{col.Name})
+		}
+		if col.IndexGroup != "" {
+			if _, ok := groups[col.IndexGroup]; !ok {
+				groupOrder = append(groupOrder, col.IndexGroup)
+			}
+			groups[col.IndexGroup] = append(groups[col.IndexGroup], col.Name)
+		}
+	}
+	for _, group := range groupOrder {
+		add(groups[group])
+	}
+	return names, indexes
+}
+
+// tableIndexes lists the indexes of table named with prefix.
+func tableIndexes(db sqlExecutor, table, prefix string) (map[string]bool, error) {
+	rows, err := db.Query(`
+		SELECT indexname FROM pg_indexes
+		WHERE tablename = $1 AND schemaname = ANY(current_schemas(false))
+	`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	indexes := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(name, prefix) {
+			indexes[name] = true
+		}
+	}
+	return indexes, rows.Err()
+}
+
+// planIndexes adds to plan the creation of the indexes declared by cols
+// that are missing and, when the table exists, the removal of the ones no
+// longer declared.
+func planIndexes(db sqlExecutor, plan *deployPlan, cols []ColumnDefinition, exists bool) error {
+	names, declared := declaredIndexes(plan.Table, cols)
+	live := map[string]bool{}
+	if exists {
+		var err error
+		if live, err = tableIndexes(db, plan.Table, indexName(indexPrefix, plan.Table)); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range names {
+		if live[name] {
+			continue
+		}
+		q := newQuery("CREATE INDEX IF NOT EXISTS ").Ident(name).Write(" ON ").Ident(plan.Table).Write(" (")
+		for i, col := range declared[name] {
+			if err := validateIdent(col); err != nil {
+				return err
+			}
+			if i > 0 {
+				q.Write(", ")
+			}
+			q.Ident(col)
+		}
+		plan.add(q.Write(")"))
+	}
+
+	stale := []string{}
+	for name := range live {
+		if _, ok := declared[name]; !ok {
+			stale = append(stale, name)
+		}
+	}
+	sort.Strings(stale)
+	for _, name := range stale {
+		plan.add(newQuery("DROP INDEX IF EXISTS ").Ident(name))
+	}
+	return nil
+}
+
+// indexName names an index of table, within the 63 bytes of Postgres.
+func indexName(prefix, table string, columns ...string) string {
+	name := prefix + "_" + table + "_" + strings.Join(columns, "_")
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return name
+}
+
+// deployedPage is page with its draft schemas copied to the deployed ones.
+func deployedPage(page models.Page) models.Page {
+	page.SchemaColumnsDeployed = page.SchemaColumns
+	page.SchemaRelationsDeployed = page.SchemaRelations
+	page.SchemaUiDeployed = page.SchemaUi
+	page.SchemaMenuUiDeployed = page.SchemaMenuUi
+	page.SchemaConditionsDeployed = page.SchemaConditions
+	page.SchemaFunctionsDeployed = page.SchemaFunctions
+	page.SchemaQueryDeployed = page.SchemaQuery
+	deploy := true
+	page.Deploy = &deploy
+	return page
+}
+
+// deployPage runs the plan of page and syncs its stamps and relation
+// foreign keys in one transaction of its storage (the request transaction
+// for the core database), then publishes its draft schemas once that
+// transaction is committed.
+func deployPage(c *gin.Context, db *gorm.DB, page models.Page, migration DeployMigration) (*deployPlan, error) {
+	after := deployedPage(page)
+	if err := checkPageOwnership(db, after); err != nil {
+		return nil, err
+	}
+	if err := checkViewAdmin(c, page.ViewQuery); err != nil {
+		return nil, err
+	}
+	if isProxyPage(page) {
+		// A proxy page has no table: deploying publishes its schemas.
+		return &deployPlan{Statements: []string{}}, publishDeployment(c, db, page, after)
+	}
+
+	tx, err := beginPageSQL(c, db, after)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	plan, err := planDeploy(tx, page, migration)
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range plan.Statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return nil, fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+	if plan.View {
+		if err := checkViewTables(db, tx, plan.Table); err != nil {
+			return nil, err
+		}
+		readOnly := true
+		after.ReadOnly = &readOnly
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return plan, publishDeployment(c, db, page, after)
+	}
+	if err := syncRowStamps(tx, after.TableName, Bool(after.StampTrigger)); err != nil {
+		return nil, err
+	}
+	if err := syncRelationFKs(tx, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return plan, publishDeployment(c, db, page, after)
+}
+
+// publishDeployment publishes the schemas of after and records them as the
+// next deployment of the page, in one transaction: a page is never marked
+// deployed without the version a rollback needs.
+func publishDeployment(c *gin.Context, db *gorm.DB, before, after models.Page) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := publishPageSchemas(tx, after); err != nil {
+			return err
+		}
+		return recordDeployment(c, tx, before, after)
+	})
+}
+
+// publishPageSchemas stores the deployed schemas of after and marks it
+// deployed.
+func publishPageSchemas(db *gorm.DB, after models.Page) error {
+	return db.Model(&models.Page{}).Where("id = ?", after.ID).Updates(map[string]any{
+		"schema_columns_deployed":    after.SchemaColumnsDeployed,
+		"schema_relations_deployed":  after.SchemaRelationsDeployed,
+		"schema_ui_deployed":         after.SchemaUiDeployed,
+		"schema_menu_ui_deployed":    after.SchemaMenuUiDeployed,
+		"schema_conditions_deployed": after.SchemaConditionsDeployed,
+		"schema_functions_deployed":  after.SchemaFunctionsDeployed,
+		"schema_query_deployed":      after.SchemaQueryDeployed,
+		"read_only":                  Bool(after.ReadOnly),
+		"deploy":                     true,
+	}).Error
+}
+
+// deployErrorStatus maps an error of deployPage to its HTTP status: 400 for
+// a schema the table cannot be built from, 500 otherwise.
+func deployErrorStatus(err error) int {
+	if errors.Is(err, ErrViewAdminOnly) {
+		return http.StatusForbidden
+	}
+	if isIdentifierError(err) || errors.Is(err, ErrUnknownColumnType) || errors.Is(err, ErrNoTableName) ||
+		errors.Is(err, ErrInvalidOnDelete) || errors.Is(err, ErrInvalidMigration) || errors.Is(err, ErrInvalidView) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func RegisterBuilderDeployRoutes(group *gin.RouterGroup, db *gorm.DB, cache *services.Cache) {
+	builder := group.Group("/builder")
+
+	// GET /builder/:id/diff previews a deploy: the draft columns, relations
+	// and schemas against the deployed ones, and the DDL it would run on the
+	// live table. The migration of the deploy is given as
+	// ?rename=old:new (repeated) and ?widen=true.
+	builder.GET("/:id/diff", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		migration := DeployMigration{Widen: c.Query("widen") == "true"}
+		for _, pair := range c.QueryArray("rename") {
+			old, name, ok := strings.Cut(pair, ":")
+			if !ok {
+				utils.Error(c, http.StatusBadRequest, "INVALID_RENAME", "rename must be old:new")
+				return
+			}
+			if migration.Renames == nil {
+				migration.Renames = map[string]string{}
+			}
+			migration.Renames[old] = name
+		}
+		var page models.Page
+		if err := db.First(&page, "id = ?", c.Param("id")).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+				return
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+
+		diff := diffSchemas(page)
+		if page.TableName != "" {
+			sqlDB, err := PageSQL(db, page)
+			if err != nil {
+				utils.Error(c, http.StatusBadRequest, "INVALID_STORAGE", err.Error())
+				return
+			}
+			if diff.Live, err = planDeploy(sqlDB, page, migration); err != nil {
+				utils.Error(c, deployErrorStatus(err), "DIFF_ERROR", err.Error())
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"data": diff, "empty": diff.Empty(), "success": true})
+	})
+
+	// POST /builder/:id/deploy creates or updates the table of the page from
+	// its draft columns and relations, then publishes the draft schemas. The
+	// optional body is a DeployMigration: {"renames": {"old": "new"},
+	// "widen": true}. With a future "scheduledAt", the deploy is planned for
+	// then instead, with the draft of that time, and 202 is returned.
+	builder.POST("/:id/deploy", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		id := c.Param("id")
+
+		var before models.Page
+		if err := db.First(&before, "id = ?", id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+				return
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+
+		var payload struct {
+			DeployMigration
+			ScheduledAt *time.Time `json:"scheduledAt"`
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err == nil && len(bytes.TrimSpace(body)) > 0 {
+			err = json.Unmarshal(body, &payload)
+		}
+		if err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		migration := payload.DeployMigration
+		if payload.ScheduledAt != nil {
+			scheduleDeploy(c, db, before, migration, *payload.ScheduledAt)
+			return
+		}
+
+		plan, err := deployPage(c, db, before, migration)
+		if err != nil {
+			utils.Error(c, deployErrorStatus(err), "DEPLOY_ERROR", err.Error())
+			return
+		}
+		invalidatePageCache(c, cache, id)
+
+		var updated models.Page
+		if err := db.Preload("Template").Preload("Tags.Category").First(&updated, "id = ?", id).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
+			return
+		}
+		recordSchemaChangelog(c, db, before, updated)
+		c.JSON(http.StatusOK, gin.H{"data": updated, "deploy": plan, "success": true})
+	})
+	// GET /builder/:id/deployments lists the deployed versions of the page,
+	// newest first.
+	builder.GET("/:id/deployments", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		id := c.Param("id")
+
+		var page models.Page
+		if err := db.Select("id").First(&page, "id = ?", id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+				return
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+
+		var deployments []models.PageDeployment
+		if err := db.Preload("User", userSummary).Where("page_id = ?", id).Order("version DESC").Find(&deployments).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_DEPLOYMENTS_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": deployments, "success": true})
+	})
+
+	// POST /builder/:id/rollback/:version deploys the schemas of a previous
+	// version again and makes them the draft. The DDL is the one of a deploy,
+	// so columns and pivots the version needs are restored while the ones
+	// added since are kept with their data; the API only exposes the
+	// version's schema. The rollback is recorded as a new version.
+	builder.POST("/:id/rollback/:version", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		id := c.Param("id")
+
+		version, err := strconv.Atoi(c.Param("version"))
+		if err != nil || version < 1 {
+			utils.Error(c, http.StatusBadRequest, "INVALID_VERSION", "version must be a positive integer")
+			return
+		}
+
+		var before models.Page
+		if err := db.First(&before, "id = ?", id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+				return
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+
+		var deployment models.PageDeployment
+		if err := db.First(&deployment, "page_id = ? AND version = ?", id, version).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "DEPLOYMENT_NOT_FOUND", "Deployment version not found")
+				return
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+		if deployment.TableName != before.TableName {
+			utils.Error(c, http.StatusConflict, "TABLE_CHANGED",
+				"The page table was renamed since version "+strconv.Itoa(version))
+			return
+		}
+
+		target := rolledBackPage(before, deployment)
+		plan, err := deployPage(c, db, target, DeployMigration{})
+		if err != nil {
+			utils.Error(c, deployErrorStatus(err), "ROLLBACK_ERROR", err.Error())
+			return
+		}
+		if err := db.Model(&models.Page{}).Where("id = ?", id).Updates(map[string]any{
+			"schema_columns":    target.SchemaColumns,
+			"schema_relations":  target.SchemaRelations,
+			"schema_ui":         target.SchemaUi,
+			"schema_menu_ui":    target.SchemaMenuUi,
+			"schema_conditions": target.SchemaConditions,
+			"schema_functions":  target.SchemaFunctions,
+			"schema_query":      target.SchemaQuery,
+		}).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_UPDATE_ERROR", err.Error())
+			return
+		}
+		invalidatePageCache(c, cache, id)
+
+		var updated models.Page
+		if err := db.Preload("Template").Preload("Tags.Category").First(&updated, "id = ?", id).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
+			return
+		}
+		recordSchemaChangelog(c, db, before, updated)
+		c.JSON(http.StatusOK, gin.H{"data": updated, "deploy": plan, "version": version, "success": true})
+	})
+}
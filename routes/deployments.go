@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// recordDeployment stores the deployed schemas of after as the next version
+// of the page when they differ from before and from the last version, so
+// that a later deploy can be rolled back to them.
+func recordDeployment(c *gin.Context, db *gorm.DB, before, after models.Page) error {
+	if !Bool(after.Deploy) || (Bool(before.Deploy) && !deployedSchemaChanged(before, after)) {
+		return nil
+	}
+
+	var last models.PageDeployment
+	if err := db.Where("page_id = ?", after.ID).Order("version DESC").Limit(1).Find(&last).Error; err != nil {
+		return err
+	}
+	if last.Version > 0 && !deployedSchemaChanged(deploymentPage(last), after) {
+		return nil
+	}
+	deployment := models.PageDeployment{
+		PageID:                   after.ID,
+		Version:                  last.Version + 1,
+		TableName:                after.TableName,
+		SchemaColumnsDeployed:    after.SchemaColumnsDeployed,
+		SchemaRelationsDeployed:  after.SchemaRelationsDeployed,
+		SchemaUiDeployed:         after.SchemaUiDeployed,
+		SchemaMenuUiDeployed:     after.SchemaMenuUiDeployed,
+		SchemaConditionsDeployed: after.SchemaConditionsDeployed,
+		SchemaFunctionsDeployed:  after.SchemaFunctionsDeployed,
+		SchemaQueryDeployed:      after.SchemaQueryDeployed,
+		UserID:                   utils.CurrentUserID(c),
+	}
+	return db.Create(&deployment).Error
+}
+
+// deploymentPage is a page deployed with the schemas of deployment.
+func deploymentPage(deployment models.PageDeployment) models.Page {
+	return models.Page{
+		SchemaColumnsDeployed:    deployment.SchemaColumnsDeployed,
+		SchemaRelationsDeployed:  deployment.SchemaRelationsDeployed,
+		SchemaUiDeployed:         deployment.SchemaUiDeployed,
+		SchemaMenuUiDeployed:     deployment.SchemaMenuUiDeployed,
+		SchemaConditionsDeployed: deployment.SchemaConditionsDeployed,
+		SchemaFunctionsDeployed:  deployment.SchemaFunctionsDeployed,
+		SchemaQueryDeployed:      deployment.SchemaQueryDeployed,
+	}
+}
+
+// rolledBackPage is page with the schemas of deployment as its draft, ready
+// to be deployed again.
+func rolledBackPage(page models.Page, deployment models.PageDeployment) models.Page {
+	page.SchemaColumns = deployment.SchemaColumnsDeployed
+	page.SchemaRelations = deployment.SchemaRelationsDeployed
+	page.SchemaUi = deployment.SchemaUiDeployed
+	page.SchemaMenuUi = deployment.SchemaMenuUiDeployed
+	page.SchemaConditions = deployment.SchemaConditionsDeployed
+	page.SchemaFunctions = deployment.SchemaFunctionsDeployed
+	page.SchemaQuery = deployment.SchemaQueryDeployed
+	return page
+}
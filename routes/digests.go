@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func RegisterDigestRoutes(group *gin.RouterGroup, db *gorm.DB) {
+	digests := group.Group("/digests")
+
+	digests.GET("", func(c *gin.Context) {
+		user := utils.CurrentUser(c)
+		if user == nil {
+			utils.Error(c, http.StatusUnauthorized, "UNKNOWN_USER", "Current user not found")
+			return
+		}
+
+		var subs []models.DigestSubscription
+		if err := db.Preload("Page").Where("user_id = ?", user.ID).Find(&subs).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_DIGESTS_ERROR", err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": subs, "success": true})
+	})
+
+	digests.POST("", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		user := utils.CurrentUser(c)
+		if user == nil {
+			utils.Error(c, http.StatusUnauthorized, "UNKNOWN_USER", "Current user not found")
+			return
+		}
+
+		var payload models.DigestSubscription
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+
+		if payload.Frequency == "" {
+			payload.Frequency = services.DigestDaily
+		}
+		if payload.Frequency != services.DigestDaily && payload.Frequency != services.DigestWeekly {
+			utils.Error(c, http.StatusBadRequest, "INVALID_FREQUENCY", "Frequency must be daily or weekly")
+			return
+		}
+		if payload.Channel == "" {
+			payload.Channel = services.DigestChannelEmail
+		}
+		if payload.Channel != services.DigestChannelEmail && payload.Channel != services.DigestChannelWebhook {
+			utils.Error(c, http.StatusBadRequest, "INVALID_CHANNEL", "Channel must be email or webhook")
+			return
+		}
+		if payload.Channel == services.DigestChannelWebhook && payload.Target == "" {
+			utils.Error(c, http.StatusBadRequest, "MISSING_TARGET", "Webhook digests require a target URL")
+			return
+		}
+		// The API sends the page data itself: only admins may pick any
+		// webhook URL or email address.
+		if !Bool(user.IsAdmin) {
+			if payload.Channel == services.DigestChannelWebhook {
+				if err := services.CheckDigestWebhook(payload.Target); err != nil {
+					utils.Error(c, http.StatusBadRequest, "INVALID_TARGET", err.Error())
+					return
+				}
+			} else if payload.Target != "" && !strings.EqualFold(strings.TrimSpace(payload.Target), user.Email) {
+				utils.Error(c, http.StatusForbidden, "INVALID_TARGET", "Email digests can only be sent to your own address")
+				return
+			}
+		}
+
+		var page models.Page
+		if err := db.First(&page, "id = ?", payload.PageID).Error; err != nil || !pageVisible(c, page.Status) {
+			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+			return
+		}
+
+		payload.ID = ""
+		payload.UserID = user.ID
+		payload.LastSentAt = nil
+		payload.User = nil
+		payload.Page = nil
+
+		if err := db.Create(&payload).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_CREATE_ERROR", err.Error())
+			return
+		}
+
+		var created models.DigestSubscription
+		if err := db.Preload("Page").First(&created, "id = ?", payload.ID).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"data": created, "success": true})
+	})
+
+	digests.DELETE("/:id", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		id := c.Param("id")
+		user := utils.CurrentUser(c)
+		if user == nil {
+			utils.Error(c, http.StatusUnauthorized, "UNKNOWN_USER", "Current user not found")
+			return
+		}
+
+		var sub models.DigestSubscription
+		if err := db.First(&sub, "id = ? AND user_id = ?", id, user.ID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Digest subscription not found")
+				return
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+
+		if err := db.Delete(&sub).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_DELETE_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Digest subscription deleted successfully", "id": id, "success": true})
+	})
+}
@@ -0,0 +1,263 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	distinctDefaultPageSize = 100
+	distinctMaxPageSize     = 1000
+)
+
+// DistinctValue is a value of a column and the number of rows holding it.
+type DistinctValue struct {
+	Value any   `json:"value"`
+	Count int64 `json:"count"`
+}
+
+// queryableColumn reports whether column may be aggregated: a deployed
+// column of the page that is not encrypted, or one of the stamp columns.
+func queryableColumn(page models.Page, column string) bool {
+	switch column {
+	case "id", stampCreatedAt, stampUpdatedAt, stampCreatedBy, stampUpdatedBy:
+		return true
+	}
+	for _, col := range parseColumns(page.SchemaColumnsDeployed) {
+		if col.Name == column {
+			return !col.Encrypted
+		}
+	}
+	return false
+}
+
+// ErrRestrictedColumn rejects a sort or filter on a restricted column.
+var ErrRestrictedColumn = errors.New("restricted column")
+
+// restrictedColumn reports whether the user may not filter, sort or group
+// on column: it is encrypted, hidden from them by the row policy or masked
+// for them. Either would let its values be guessed.
+func restrictedColumn(page models.Page, policy *rowPolicy, masks columnMasks, column string) bool {
+	if isEncryptedColumn(parseColumns(page.SchemaColumnsDeployed), column) {
+		return true
+	}
+	if policy != nil && policy.hidden[column] {
+		return true
+	}
+	_, masked := masks[column]
+	return masked
+}
+
+// checkQueryColumns rejects the sorts and filters a request asks for on a
+// restricted column of page.
+func checkQueryColumns(page models.Page, policy *rowPolicy, masks columnMasks, sorts []SortDefinition, filters []FilterDefinition) error {
+	for _, s := range sorts {
+		if restrictedColumn(page, policy, masks, s.Column) {
+			return fmt.Errorf("%w: cannot sort on column %q", ErrRestrictedColumn, s.Column)
+		}
+	}
+	for _, f := range filters {
+		if restrictedColumn(page, policy, masks, f.Column) {
+			return fmt.Errorf("%w: cannot filter on column %q", ErrRestrictedColumn, f.Column)
+		}
+	}
+	return nil
+}
+
+// distinctSQL groups the rows matching the page filters in the database.
+func distinctSQL(sqlDB *sql.DB, page models.Page, column string, query QueryDefinition, p utils.Pagination) ([]DistinctValue, int64, error) {
+	query.Sort = nil
+	grouped := newQuery("SELECT ").Ident(column).Write(" AS value, COUNT(*) AS count FROM ").Ident(page.TableName)
+	if err := query.apply(sqlDB, page.TableName, grouped, 0); err != nil {
+		return nil, 0, err
+	}
+	grouped.Write(" GROUP BY ").Ident(column)
+
+	var total int64
+	q := newQuery("SELECT COUNT(*) FROM (").Write(grouped.SQL()).Write(") AS d")
+	if err := sqlDB.QueryRow(q.SQL(), grouped.Args()...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	grouped.Write(" ORDER BY count DESC, value ASC NULLS LAST LIMIT ").Arg(p.PageSize).Write(" OFFSET ").Arg(p.Offset())
+	rows, err := sqlDB.Query(grouped.SQL(), grouped.Args()...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	values := []DistinctValue{}
+	for rows.Next() {
+		var v DistinctValue
+		if err := rows.Scan(&v.Value, &v.Count); err != nil {
+			return nil, 0, err
+		}
+		values = append(values, v)
+	}
+	return values, total, rows.Err()
+}
+
+// visibleValues reads column from the rows of the page the user may see,
+// for pages whose server conditions are evaluated row by row. Resolved
+// relations are read as the id they point at.
+func visibleValues(db *gorm.DB, page models.Page, policy *rowPolicy, column string, query QueryDefinition) ([]any, error) {
+	payload, err := buildPagePayload(db, page, payloadOptions{Query: &query, Dependencies: dependencyOptions{Mode: DependenciesNone}})
+	if err != nil {
+		return nil, err
+	}
+	data, _ := payload["data"].([]map[string]any)
+
+	values := make([]any, 0, len(data))
+	for _, row := range data {
+		if !policy.allows(row) {
+			continue
+		}
+		value := row[column]
+		if obj, ok := value.(map[string]any); ok {
+			value = obj["id"]
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// distinctRows counts the values of the rows the user may see.
+func distinctRows(db *gorm.DB, page models.Page, policy *rowPolicy, column string, query QueryDefinition, p utils.Pagination) ([]DistinctValue, int64, error) {
+	visible, err := visibleValues(db, page, policy, column, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	counts := map[string]*DistinctValue{}
+	for _, value := range visible {
+		key := fmt.Sprintf("%T:%v", value, value)
+		if counts[key] == nil {
+			counts[key] = &DistinctValue{Value: value}
+		}
+		counts[key].Count++
+	}
+
+	values := make([]DistinctValue, 0, len(counts))
+	for _, v := range counts {
+		values = append(values, *v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return fmt.Sprint(values[i].Value) < fmt.Sprint(values[j].Value)
+	})
+
+	total := int64(len(values))
+	start := min(p.Offset(), len(values))
+	end := min(start+p.PageSize, len(values))
+	return values[start:end], total, nil
+}
+
+// columnAggregate is what the aggregate routes of a page column work on.
+// policy is nil unless server conditions filter the rows.
+type columnAggregate struct {
+	page   models.Page
+	sqlDB  *sql.DB
+	policy *rowPolicy
+	column string
+	query  QueryDefinition
+}
+
+// loadColumnAggregate reads the page and column of /page/:id/<route>/:column.
+// The page filters apply unless ?defaultFilters=off; a column hidden from
+// or masked for the user is reported unknown.
+func loadColumnAggregate(c *gin.Context, db *gorm.DB) (columnAggregate, bool) {
+	agg := columnAggregate{column: c.Param("column")}
+	page, _, ok := loadDeployedPage(c, db, c.Param("id"))
+	if !ok {
+		return agg, false
+	}
+	agg.page = page
+
+	agg.policy = newRowPolicy(c, db, parseConditions(page.SchemaConditionsDeployed), visibilityColumn(parseColumns(page.SchemaColumnsDeployed)))
+	masks := newColumnMasks(c, db, pageMasks(parseColumns(page.SchemaColumnsDeployed)))
+	if !queryableColumn(page, agg.column) || restrictedColumn(page, agg.policy, masks, agg.column) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Colonne %q inconnue", agg.column)})
+		return agg, false
+	}
+
+	sqlDB, err := PageSQL(db, page)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return agg, false
+	}
+	agg.sqlDB = sqlDB
+	agg.query, _ = pageQueryOverrides(c, parseQueryDefinition(page.SchemaQueryDeployed))
+	if _, ok := c.GetQuery("sort"); ok {
+		if err := checkQueryColumns(page, agg.policy, masks, agg.query.Sort, nil); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return agg, false
+		}
+	}
+	return agg, true
+}
+
+func aggregateErrorStatus(err error) int {
+	if isIdentifierError(err) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func RegisterPageDistinctRoutes(r gin.IRoutes, db *gorm.DB) {
+	// GET /page/:id/distinct/:column lists the values of a column with their
+	// row counts, most frequent first, for filter dropdowns and facets.
+	r.GET("/page/:id/distinct/:column", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		agg, ok := loadColumnAggregate(c, db)
+		if !ok {
+			return
+		}
+		column := agg.column
+		pagination := utils.ParsePagination(c, distinctDefaultPageSize, distinctMaxPageSize)
+
+		var values []DistinctValue
+		var total int64
+		var err error
+		if agg.policy == nil {
+			values, total, err = distinctSQL(agg.sqlDB, agg.page, column, agg.query, pagination)
+		} else {
+			values, total, err = distinctRows(db, agg.page, agg.policy, column, agg.query, pagination)
+		}
+		if err != nil {
+			c.JSON(aggregateErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"column": column,
+			"data":   values,
+			"meta":   pagination.Meta(total, len(values)),
+		})
+	})
+}
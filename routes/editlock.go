@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// lockHolderName is the name other users see on a page locked by user.
+func lockHolderName(user *models.User) string {
+	switch {
+	case user.Name != "":
+		return user.Name
+	case user.PreferredUsername != "":
+		return user.PreferredUsername
+	}
+	return user.Email
+}
+
+func RegisterBuilderLockRoutes(group *gin.RouterGroup, db *gorm.DB, locks *services.EditLocks) {
+	builder := group.Group("/builder/:id/lock")
+
+	builder.GET("", func(c *gin.Context) {
+		lock, held := locks.Get(c.Request.Context(), c.Param("id"))
+		if !held {
+			c.JSON(http.StatusOK, gin.H{"data": nil, "success": true})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": lock, "success": true})
+	})
+
+	// POST takes the lock for the current user. The builder sends it again
+	// before the TTL runs out to keep the lock.
+	builder.POST("", func(c *gin.Context) {
+		if !locks.Enabled() {
+			utils.Error(c, http.StatusNotFound, "LOCKS_DISABLED", "Edit locks are disabled")
+			return
+		}
+		user := utils.CurrentUser(c)
+		if user == nil {
+			utils.Error(c, http.StatusUnauthorized, "UNAUTHORIZED", "No current user")
+			return
+		}
+		var count int64
+		if err := db.Model(&models.Page{}).Where("id = ?", c.Param("id")).Count(&count).Error; err != nil || count == 0 {
+			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+			return
+		}
+
+		lock, acquired := locks.Acquire(c.Request.Context(), c.Param("id"), user.ID, lockHolderName(user))
+		if !acquired {
+			c.JSON(http.StatusLocked, utils.APIResponse{
+				Success: false,
+				Data:    lock,
+				Error: &utils.APIError{
+					Code:    "PAGE_LOCKED",
+					Details: "Page is being edited by " + lock.UserName,
+				},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": lock, "ttl": int(locks.TTL().Seconds()), "success": true})
+	})
+
+	builder.DELETE("", func(c *gin.Context) {
+		if userID := utils.CurrentUserID(c); userID != nil {
+			locks.Release(c.Request.Context(), c.Param("id"), *userID)
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+}
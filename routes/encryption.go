@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/services"
+	"fmt"
+	"log"
+)
+
+// encryptedColumns lists the columns of a page stored encrypted. File
+// columns are never encrypted: their key is needed to manage the object.
+func encryptedColumns(cols []ColumnDefinition) []string {
+	var names []string
+	for _, col := range cols {
+		if col.Encrypted && col.Type != ColumnTypeFile {
+			names = append(names, col.Name)
+		}
+	}
+	return names
+}
+
+func isEncryptedColumn(cols []ColumnDefinition, name string) bool {
+	for _, col := range encryptedColumns(cols) {
+		if col == name {
+			return true
+		}
+	}
+	return false
+}
+
+// encryptFields seals the encrypted columns present in fields, in place.
+// It runs after the write functions, which see the plain values.
+func encryptFields(cols []ColumnDefinition, fields map[string]any) error {
+	names := encryptedColumns(cols)
+	if len(names) == 0 {
+		return nil
+	}
+	cipher := services.Cipher()
+	for _, col := range names {
+		v, ok := fields[col]
+		if !ok || v == nil {
+			continue
+		}
+		if cipher == nil {
+			return fmt.Errorf("colonne %q: %w", col, services.ErrNoColumnCipher)
+		}
+		sealed, err := cipher.Encrypt(v)
+		if err != nil {
+			return fmt.Errorf("colonne %q: %w", col, err)
+		}
+		fields[col] = sealed
+	}
+	return nil
+}
+
+// decryptRows opens the encrypted columns of rows in place, before the read
+// functions run. A value that cannot be opened, e.g. without the key, is
+// sent as null.
+func decryptRows(cols []ColumnDefinition, rows ...map[string]any) {
+	names := encryptedColumns(cols)
+	if len(names) == 0 {
+		return
+	}
+	cipher := services.Cipher()
+	for _, row := range rows {
+		if row == nil {
+			continue
+		}
+		for _, col := range names {
+			v, ok := row[col]
+			if !ok || v == nil {
+				continue
+			}
+			if cipher == nil {
+				if services.IsEncrypted(v) {
+					row[col] = nil
+				}
+				continue
+			}
+			plain, err := cipher.Decrypt(v)
+			if err != nil {
+				log.Printf("⚠️  Déchiffrement de %q impossible: %v", col, err)
+				plain = nil
+			}
+			row[col] = plain
+		}
+	}
+}
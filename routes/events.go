@@ -0,0 +1,109 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// eventsHeartbeat keeps idle streams open through proxies.
+const eventsHeartbeat = 25 * time.Second
+
+// notifyRowChange fires the webhooks and the live streams of page once the
+// request commits. load returns the row sent to webhooks and is only called
+// when one wants it. The encrypted columns are left out of it: webhook
+// payloads leave the API and are kept in the dead letters.
+func notifyRowChange(c *gin.Context, hooks *services.Webhooks, events *services.PageEvents, event string, page models.Page, itemID string, load func() map[string]any) {
+	if names := encryptedColumns(parseColumns(page.SchemaColumnsDeployed)); len(names) > 0 && load != nil {
+		read := load
+		load = func() map[string]any {
+			row := read()
+			if row == nil {
+				return nil
+			}
+			row = maps.Clone(row)
+			for _, col := range names {
+				delete(row, col)
+			}
+			return row
+		}
+	}
+	utils.AfterCommit(c, func() {
+		e := services.NewWebhookEvent(event)
+		e.PageID = page.ID
+		e.Table = page.TableName
+		e.ItemID = itemID
+
+		events.Publish(services.PageEvent{
+			ID:         e.ID,
+			Event:      e.Event,
+			PageID:     e.PageID,
+			ItemID:     e.ItemID,
+			OccurredAt: e.OccurredAt,
+		})
+		hooks.Dispatch(e, load)
+	})
+}
+
+func RegisterPageEventRoutes(r gin.IRoutes, db *gorm.DB, events *services.PageEvents) {
+	// GET /page/:id/events streams the row changes of a deployed page as
+	// Server-Sent Events, named after the change (row.created, ...).
+	r.GET("/page/:id/events", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		page, _, ok := loadDeployedPage(c, db, c.Param("id"))
+		if !ok {
+			return
+		}
+
+		stream, cancel := events.Subscribe(page.ID)
+		defer cancel()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.SSEvent("ready", gin.H{"pageId": page.ID})
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(eventsHeartbeat)
+		defer heartbeat.Stop()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case event := <-stream:
+				body, _ := json.Marshal(event)
+				_, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Event, body)
+				return err == nil
+			case <-heartbeat.C:
+				_, err := io.WriteString(w, ": ping\n\n")
+				return err == nil
+			}
+		})
+	})
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/utils"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+func safeFilename(parts ...string) string {
+	cleaned := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = unsafeFilenameChars.ReplaceAllString(p, "_"); p != "" {
+			cleaned = append(cleaned, p)
+		}
+	}
+	return strings.Join(cleaned, "-")
+}
+
+func uiFieldName(block map[string]any) string {
+	for _, key := range []string{"name", "field", "column"} {
+		if v, ok := block[key].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func uiFieldLabel(block map[string]any, fallback string) string {
+	for _, key := range []string{"label", "title", "headerName"} {
+		if v, ok := block[key].(string); ok && v != "" {
+			return v
+		}
+	}
+	return fallback
+}
+
+func displayLabel(obj map[string]any) string {
+	for _, key := range []string{"name", "title", "label", "email"} {
+		if v, ok := obj[key]; ok && v != nil {
+			return formatValue(v)
+		}
+	}
+	if v, ok := obj["id"]; ok {
+		return formatValue(v)
+	}
+	return ""
+}
+
+func formatValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "-"
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.Format("02/01/2006 15:04")
+	case bool:
+		if val {
+			return "Oui"
+		}
+		return "Non"
+	case map[string]any:
+		return displayLabel(val)
+	case []any:
+		parts := make([]string, 0, len(val))
+		for _, e := range val {
+			parts = append(parts, formatValue(e))
+		}
+		return strings.Join(parts, ", ")
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func renderFichePDF(page models.Page, raw schemaRaw, item map[string]any) *utils.PDFDocument {
+	doc := utils.NewPDF()
+	doc.Heading(page.Name)
+
+	if page.FicheTemplate != nil {
+		doc.Subheading("Fiche " + page.FicheTemplate.Name)
+	}
+	doc.Text(fmt.Sprintf("Référence : %s", formatValue(item["id"])))
+	doc.Text(fmt.Sprintf("Exporté le %s", time.Now().Format("02/01/2006 15:04")))
+	doc.Text("")
+
+	printed := map[string]bool{"id": true}
+	for _, block := range raw.UI {
+		name := uiFieldName(block)
+		if name == "" || printed[name] {
+			continue
+		}
+		value, ok := item[name]
+		if !ok {
+			continue
+		}
+		printed[name] = true
+		doc.Field(uiFieldLabel(block, name), formatValue(value))
+	}
+
+	remaining := make([]string, 0, len(item))
+	for name := range item {
+		if !printed[name] {
+			remaining = append(remaining, name)
+		}
+	}
+	sort.Strings(remaining)
+	for _, name := range remaining {
+		doc.Field(name, formatValue(item[name]))
+	}
+
+	return doc
+}
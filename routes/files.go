@@ -0,0 +1,310 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ColumnTypeFile marks a column holding the object key of an uploaded file.
+const ColumnTypeFile = "file"
+
+func fileColumns(cols []ColumnDefinition) []string {
+	var names []string
+	for _, col := range cols {
+		if col.Type == ColumnTypeFile {
+			names = append(names, col.Name)
+		}
+	}
+	return names
+}
+
+func isFileColumn(cols []ColumnDefinition, name string) bool {
+	for _, col := range cols {
+		if col.Name == name {
+			return col.Type == ColumnTypeFile
+		}
+	}
+	return false
+}
+
+// objectKey names the object of a file: its page, column and row, then a
+// random part so that a new upload never overwrites a URL already handed
+// out, then the sanitized file name.
+func objectKey(pageID, column, itemID, filename string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, path.Base(filename))
+	if name == "" || name == "." {
+		name = "file"
+	}
+	token, _ := newShareToken()
+	if len(token) > 12 {
+		token = token[:12]
+	}
+	return fmt.Sprintf("pages/%s/%s/%s/%s-%s", pageID, column, itemID, token, name)
+}
+
+// fileName is the name the file was uploaded under.
+func fileName(key string) string {
+	base := path.Base(key)
+	if _, name, ok := strings.Cut(base, "-"); ok {
+		return name
+	}
+	return base
+}
+
+// fileRef is what the API returns for a file column: the stored key, the
+// file name and a presigned download URL.
+func fileRef(store *services.ObjectStore, key string) gin.H {
+	return gin.H{"key": key, "name": fileName(key), "url": store.PresignGet(key, fileName(key))}
+}
+
+// signFileColumns replaces the keys held by columns with file references.
+func signFileColumns(rows []map[string]any, columns []string) {
+	store := services.Objects()
+	if store == nil || len(columns) == 0 {
+		return
+	}
+	for _, row := range rows {
+		for _, col := range columns {
+			if key, ok := row[col].(string); ok && key != "" {
+				row[col] = fileRef(store, key)
+			}
+		}
+	}
+}
+
+// applyFileURLs signs the file columns of a page payload. URLs expire, so
+// they are added when the payload is sent rather than when it is cached.
+func applyFileURLs(body []byte) ([]byte, error) {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, err
+	}
+	var columns []string
+	_ = json.Unmarshal(envelope["fileColumns"], &columns)
+	if len(columns) == 0 || services.Objects() == nil {
+		return body, nil
+	}
+
+	var data []map[string]any
+	dec := json.NewDecoder(bytes.NewReader(envelope["data"]))
+	dec.UseNumber()
+	if err := dec.Decode(&data); err != nil {
+		return nil, err
+	}
+	signFileColumns(data, columns)
+
+	signed, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	envelope["data"] = signed
+	return json.Marshal(envelope)
+}
+
+// deleteFiles removes objects no row points at anymore. A failure only
+// leaves an orphan object behind.
+func deleteFiles(keys ...string) {
+	store := services.Objects()
+	if store == nil {
+		return
+	}
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := store.Delete(ctx, key); err != nil {
+			log.Printf("⚠️  Fichier %s non supprimé: %v", key, err)
+		}
+		cancel()
+	}
+}
+
+// deleteFilesAfterCommit deletes the objects once the change that dropped
+// them is committed.
+func deleteFilesAfterCommit(c *gin.Context, keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	utils.AfterCommit(c, func() { deleteFiles(keys...) })
+}
+
+// rowFileKeys lists the keys held by the file columns of row.
+func rowFileKeys(row map[string]any, columns []string) []string {
+	var keys []string
+	for _, col := range columns {
+		if key, ok := row[col].(string); ok && key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func RegisterPageFileRoutes(r gin.IRoutes, db *gorm.DB, cache *services.Cache, hooks *services.Webhooks, events *services.PageEvents) {
+	// setFile points a file column of a row at the key returned by upload, or
+	// clears it when upload is nil. The previous object is deleted once the
+	// change is committed.
+	setFile := func(c *gin.Context, db *gorm.DB, upload func(page models.Page, itemID, column string) (string, bool)) {
+		itemID, column := c.Param("itemId"), c.Param("column")
+		store := services.Objects()
+		if store == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Stockage de fichiers non configuré"})
+			return
+		}
+
+		page, raw, ok := loadDeployedPage(c, db, c.Param("id"))
+		if !ok || rejectReadOnlyPage(c, page) {
+			return
+		}
+		if !isFileColumn(parseColumns(page.SchemaColumnsDeployed), column) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("La colonne %q n'est pas de type fichier", column)})
+			return
+		}
+
+		sqlDB, err := PageSQL(db, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		current, err := loadItem(sqlDB, page, raw, itemID)
+		if err != nil || !applyItemConditions(c, db, page, current) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item introuvable"})
+			return
+		}
+
+		var key any
+		if upload != nil {
+			k, ok := upload(page, itemID, column)
+			if !ok {
+				return
+			}
+			key = k
+		}
+
+		fields := map[string]any{column: key}
+		stampUpdate(c, current, fields)
+
+		tx, err := beginPageSQL(c, db, page)
+		if err == nil {
+			defer tx.Rollback()
+			err = UpdateDynamic(tx, page.TableName, itemID, fields)
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			if k, ok := key.(string); ok {
+				deleteFiles(k)
+			}
+			c.JSON(updateErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		if previous, ok := current[column].(string); ok && previous != "" && previous != key {
+			deleteFilesAfterCommit(c, previous)
+		}
+		invalidateTableCache(c, db, cache, page.TableName)
+		recordRowAudit(c, db, services.AuditActionUpdate, page, itemID)
+		notifyRowChange(c, hooks, events, services.WebhookRowUpdated, page, itemID, func() map[string]any {
+			item, _ := loadItem(sqlDB, page, raw, itemID)
+			return item
+		})
+
+		body := gin.H{"message": "Fichier enregistré", "id": itemID, "file": nil}
+		if k, ok := key.(string); ok {
+			body["file"] = fileRef(store, k)
+		} else {
+			body["message"] = "Fichier supprimé"
+		}
+		c.JSON(http.StatusOK, body)
+	}
+
+	// POST /page/:id/:itemId/files/:column uploads the multipart "file" field
+	// into a file column, replacing the previous file.
+	r.POST("/page/:id/:itemId/files/:column", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		setFile(c, db, func(page models.Page, itemID, column string) (string, bool) {
+			store := services.Objects()
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, store.MaxSize()+1<<20)
+			header, err := c.FormFile("file")
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Fichier trop volumineux (max %d Mo)", store.MaxSize()>>20)})
+					return "", false
+				}
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Champ multipart \"file\" manquant: " + err.Error()})
+				return "", false
+			}
+			if header.Size > store.MaxSize() {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Fichier trop volumineux (max %d Mo)", store.MaxSize()>>20)})
+				return "", false
+			}
+
+			file, err := header.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return "", false
+			}
+			defer file.Close()
+			content, err := io.ReadAll(file)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return "", false
+			}
+
+			contentType := header.Header.Get("Content-Type")
+			if contentType == "" {
+				contentType = http.DetectContentType(content)
+			}
+			key := objectKey(page.ID, column, itemID, header.Filename)
+			if err := store.Put(c.Request.Context(), key, contentType, content); err != nil {
+				c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+				return "", false
+			}
+			return key, true
+		})
+	})
+
+	r.DELETE("/page/:id/:itemId/files/:column", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		setFile(c, db, nil)
+	})
+}
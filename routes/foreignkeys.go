@@ -0,0 +1,225 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// Relation foreign keys are named with this prefix so that a deploy can
+// drop the ones it created without touching hand-made constraints.
+const relationFKPrefix = "fkrel_"
+
+// 23503: foreign_key_violation, raised by a RESTRICT or NO ACTION delete.
+const pgForeignKeyViolation = "23503"
+
+var ErrInvalidOnDelete = errors.New("invalid onDelete")
+
+// onDeleteAction maps RelationDefinition.OnDelete to SQL. An empty value
+// falls back to def.
+func onDeleteAction(value, def string) (string, error) {
+	switch strings.ToLower(strings.NewReplacer("_", "", " ", "", "-", "").Replace(value)) {
+	case "":
+		return def, nil
+	case "cascade":
+		return "CASCADE", nil
+	case "setnull":
+		return "SET NULL", nil
+	case "restrict":
+		return "RESTRICT", nil
+	case "noaction":
+		return "NO ACTION", nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidOnDelete, value)
+}
+
+type relationFK struct {
+	table, column, refTable, onDelete string
+	// implicit marks a key the relation did not ask for with OnDelete. It is
+	// only created when the column and the referenced id have the same type.
+	implicit bool
+}
+
+func (fk relationFK) name() string {
+	name := relationFKPrefix + fk.table + "_" + fk.column
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return name
+}
+
+// relationFKs lists the foreign keys implied by the deployed relations of
+// page. One-to-one and one-to-many relations follow OnDelete, and without it
+// clear their column when the related row goes; pivot rows always go with
+// their left row, and with their right row unless OnDelete says otherwise.
+func relationFKs(page models.Page) ([]relationFK, error) {
+	var fks []relationFK
+	for _, rel := range parseRelations(page.SchemaRelationsDeployed) {
+		switch rel.Type {
+		case "one-to-one", "one-to-many":
+			action, err := onDeleteAction(rel.OnDelete, "SET NULL")
+			if err != nil {
+				return nil, err
+			}
+			fks = append(fks, relationFK{page.TableName, rel.FromColumn, rel.ToTable, action, rel.OnDelete == ""})
+		case "many-to-many":
+			action, err := onDeleteAction(rel.OnDelete, "CASCADE")
+			if err != nil {
+				return nil, err
+			}
+			pivot := pivotTableName(page.TableName, rel)
+			fks = append(fks,
+				relationFK{pivot, "left_id", page.TableName, "CASCADE", false},
+				relationFK{pivot, "right_id", rel.ToTable, action, false},
+			)
+		}
+	}
+	return fks, nil
+}
+
+// syncRelationFKs recreates the relation foreign keys of a deployed page and
+// drops the ones of removed relations, within tx. Constraints are added NOT
+// VALID: rows written before the deploy are not checked, every later change
+// is. An implicit key whose column does not match the referenced id, or
+// whose table is not there yet, is skipped rather than failing the deploy.
+func syncRelationFKs(tx sqlExecutor, page models.Page) error {
+	fks, err := relationFKs(page)
+	if err != nil {
+		return err
+	}
+
+	tables := map[string]bool{page.TableName: true}
+	for _, fk := range fks {
+		tables[fk.table] = true
+	}
+
+	for table := range tables {
+		if err := validateIdent(table); err != nil {
+			return err
+		}
+		rows, err := tx.Query(`
+			SELECT conname FROM pg_constraint
+			WHERE contype = 'f' AND conrelid = to_regclass($1) AND conname LIKE $2`,
+			quoteIdent(table), relationFKPrefix+"%")
+		if err != nil {
+			return err
+		}
+		var existing []string
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err == nil {
+				existing = append(existing, name)
+			}
+		}
+		rows.Close()
+
+		for _, name := range existing {
+			q := newQuery("ALTER TABLE ").Ident(table).Write(" DROP CONSTRAINT ").Ident(name)
+			if _, err := tx.Exec(q.SQL()); err != nil {
+				return err
+			}
+		}
+	}
+
+	types := map[string]map[string]string{}
+	columnType := func(table, column string) (string, error) {
+		if _, ok := types[table]; !ok {
+			cols, err := tableColumnTypes(tx, table)
+			if err != nil {
+				return "", err
+			}
+			types[table] = cols
+		}
+		return types[table][column], nil
+	}
+
+	for _, fk := range fks {
+		for _, ident := range []string{fk.column, fk.refTable} {
+			if err := validateIdent(ident); err != nil {
+				return err
+			}
+		}
+		if fk.implicit {
+			from, err := columnType(fk.table, fk.column)
+			if err != nil {
+				return err
+			}
+			to, err := columnType(fk.refTable, "id")
+			if err != nil {
+				return err
+			}
+			if from == "" || from != to {
+				log.Printf("⚠️  Clé étrangère %s.%s ignorée: type %q, %s.id de type %q", fk.table, fk.column, from, fk.refTable, to)
+				continue
+			}
+		}
+		q := newQuery("ALTER TABLE ").Ident(fk.table).
+			Write(" ADD CONSTRAINT ").Ident(fk.name()).
+			Write(" FOREIGN KEY (").Ident(fk.column).Write(") REFERENCES ").Ident(fk.refTable).
+			Write(" (id) ON DELETE ", fk.onDelete, " NOT VALID")
+		if _, err := tx.Exec(q.SQL()); err != nil {
+			return fmt.Errorf("relation %s.%s: %w", fk.table, fk.column, err)
+		}
+	}
+	return nil
+}
+
+// deployRelationFKs applies syncRelationFKs after a builder save that
+// deployed the page or changed its deployed relations.
+func deployRelationFKs(db *gorm.DB, before, after models.Page) error {
+	if !Bool(after.Deploy) || after.TableName == "" {
+		return nil
+	}
+	if Bool(before.Deploy) && before.TableName == after.TableName &&
+		string(before.SchemaRelationsDeployed) == string(after.SchemaRelationsDeployed) {
+		return nil
+	}
+	sqlDB, err := PageSQL(db, after)
+	if err != nil {
+		return err
+	}
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// Views have no foreign keys.
+	if kind, err := relationKind(tx, after.TableName); err != nil || isViewKind(kind) {
+		return err
+	}
+	if err := syncRelationFKs(tx, after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// isRestrictViolation reports whether err comes from a foreign key refusing
+// a delete, returning the referencing table.
+func isRestrictViolation(err error) (string, bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation {
+		return pgErr.TableName, true
+	}
+	return "", false
+}
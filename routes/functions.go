@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"gorm.io/datatypes"
+)
+
+// Where a schemaFunction runs. Functions without "server" stay client-side
+// and are only returned verbatim.
+const (
+	FunctionOnRead  = "read"
+	FunctionOnWrite = "write"
+)
+
+type FunctionDefinition struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+	Target     string `json:"target,omitempty"`
+	Server     string `json:"server,omitempty"`
+}
+
+func (f FunctionDefinition) target() string {
+	if f.Target != "" {
+		return f.Target
+	}
+	return f.Name
+}
+
+func parseFunctions(raw datatypes.JSON) []FunctionDefinition {
+	var fns []FunctionDefinition
+	if raw != nil {
+		_ = json.Unmarshal(raw, &fns)
+	}
+	return fns
+}
+
+type compiledFunction struct {
+	def  FunctionDefinition
+	expr *utils.Expr
+}
+
+func compileFunctions(fns []FunctionDefinition, on string) []compiledFunction {
+	out := []compiledFunction{}
+	for _, fn := range fns {
+		if fn.Server != on || fn.Expression == "" || fn.target() == "" {
+			continue
+		}
+		expr, err := utils.CompileExpr(fn.Expression)
+		if err != nil {
+			log.Printf("⚠️  schemaFunction %q ignorée: %v", fn.Name, err)
+			continue
+		}
+		out = append(out, compiledFunction{def: fn, expr: expr})
+	}
+	return out
+}
+
+// applyReadFunctions adds the computed columns to each row. A row whose
+// evaluation fails gets a null value rather than failing the whole response.
+func applyReadFunctions(fns []FunctionDefinition, rows ...map[string]any) {
+	compiled := compileFunctions(fns, FunctionOnRead)
+	if len(compiled) == 0 {
+		return
+	}
+	for _, row := range rows {
+		for _, fn := range compiled {
+			value, err := fn.expr.Eval(row)
+			if err != nil {
+				value = nil
+			}
+			row[fn.def.target()] = value
+		}
+	}
+}
+
+// applyWriteFunctions stores derived values in fields before insert. Unlike
+// reads, an evaluation error rejects the write.
+func applyWriteFunctions(fns []FunctionDefinition, fields map[string]any) error {
+	for _, fn := range compileFunctions(fns, FunctionOnWrite) {
+		value, err := fn.expr.Eval(fields)
+		if err != nil {
+			return fmt.Errorf("schemaFunction %q: %w", fn.def.Name, err)
+		}
+		if err := validateIdent(fn.def.target()); err != nil {
+			return err
+		}
+		fields[fn.def.target()] = value
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/utils"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Kinds of the edges of the page graph.
+const (
+	GraphEdgeTable      = "table"
+	GraphEdgeRelation   = "relation"
+	GraphEdgePivot      = "pivot"
+	GraphEdgeNavigation = "navigation"
+)
+
+type GraphPage struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Slug      string `json:"slug"`
+	TableName string `json:"tableName,omitempty"`
+	Storage   string `json:"storage,omitempty"`
+	Deployed  bool   `json:"deployed"`
+	Status    string `json:"status"`
+}
+
+// GraphTable is a table reached by a page, with the pages it belongs to.
+type GraphTable struct {
+	Key     string   `json:"key"`
+	Name    string   `json:"name"`
+	Storage string   `json:"storage,omitempty"`
+	Pages   []string `json:"pages"`
+}
+
+type GraphNavigation struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Path   string `json:"path,omitempty"`
+	PageID string `json:"pageId"`
+}
+
+// GraphEdge goes from a page to a table (its own, a related one, or the
+// pivot of a many-to-many relation), or from a navigation item to a page.
+// Deployed and Draft tell which schemas declare a relation.
+type GraphEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Kind     string `json:"kind"`
+	Column   string `json:"column,omitempty"`
+	Relation string `json:"relation,omitempty"`
+	Deployed bool   `json:"deployed,omitempty"`
+	Draft    bool   `json:"draft,omitempty"`
+}
+
+type PageGraph struct {
+	Pages      []GraphPage       `json:"pages"`
+	Tables     []GraphTable      `json:"tables"`
+	Navigation []GraphNavigation `json:"navigation"`
+	Edges      []GraphEdge       `json:"edges"`
+}
+
+// graphTableKey identifies a table across storages.
+func graphTableKey(storage, table string) string {
+	if storage == "" {
+		return table
+	}
+	return storage + ":" + table
+}
+
+// buildPageGraph links pages to the tables they own and relate to, and the
+// navigation items to the pages they open.
+func buildPageGraph(pages []models.Page, items []models.NavigationItem) PageGraph {
+	graph := PageGraph{
+		Pages:      make([]GraphPage, 0, len(pages)),
+		Tables:     []GraphTable{},
+		Navigation: make([]GraphNavigation, 0, len(items)),
+		Edges:      []GraphEdge{},
+	}
+	tables := map[string]*GraphTable{}
+	table := func(storage, name string) string {
+		key := graphTableKey(storage, name)
+		if tables[key] == nil {
+			tables[key] = &GraphTable{Key: key, Name: name, Storage: storage, Pages: []string{}}
+		}
+		return key
+	}
+
+	for _, page := range pages {
+		graph.Pages = append(graph.Pages, GraphPage{
+			ID:        page.ID,
+			Name:      page.Name,
+			Slug:      page.Slug,
+			TableName: page.TableName,
+			Storage:   page.Storage,
+			Deployed:  Bool(page.Deploy),
+			Status:    page.Status,
+		})
+		if page.TableName == "" {
+			continue
+		}
+		own := table(page.Storage, page.TableName)
+		tables[own].Pages = append(tables[own].Pages, page.ID)
+		graph.Edges = append(graph.Edges, GraphEdge{From: page.ID, To: own, Kind: GraphEdgeTable})
+
+		// A relation declared by both schemas is one edge.
+		edges := map[[3]string]*GraphEdge{}
+		var order [][3]string
+		add := func(rels []RelationDefinition, deployed bool) {
+			for _, rel := range rels {
+				if rel.ToTable == "" {
+					continue
+				}
+				k := [3]string{rel.FromColumn, rel.ToTable, rel.Type}
+				pivotKey := [3]string{rel.FromColumn, "", GraphEdgePivot}
+				if edges[k] == nil {
+					edges[k] = &GraphEdge{From: page.ID, To: table(page.Storage, rel.ToTable), Kind: GraphEdgeRelation,
+						Column: rel.FromColumn, Relation: rel.Type}
+					order = append(order, k)
+					if rel.Type == "many-to-many" {
+						edges[pivotKey] = &GraphEdge{From: page.ID, To: table(page.Storage, pivotTableName(page.TableName, rel)),
+							Kind: GraphEdgePivot, Column: rel.FromColumn, Relation: rel.Type}
+						order = append(order, pivotKey)
+					}
+				}
+				for _, e := range []*GraphEdge{edges[k], edges[pivotKey]} {
+					if e != nil {
+						e.Deployed = e.Deployed || deployed
+						e.Draft = e.Draft || !deployed
+					}
+				}
+			}
+		}
+		add(parseRelations(page.SchemaRelationsDeployed), true)
+		add(parseRelations(page.SchemaRelations), false)
+		for _, k := range order {
+			graph.Edges = append(graph.Edges, *edges[k])
+		}
+	}
+
+	for _, item := range items {
+		if item.PageID == nil {
+			continue
+		}
+		graph.Navigation = append(graph.Navigation, GraphNavigation{ID: item.ID, Title: item.Title, Path: item.Path, PageID: *item.PageID})
+		graph.Edges = append(graph.Edges, GraphEdge{From: item.ID, To: *item.PageID, Kind: GraphEdgeNavigation})
+	}
+
+	for _, t := range tables {
+		graph.Tables = append(graph.Tables, *t)
+	}
+	sort.Slice(graph.Tables, func(i, j int) bool { return graph.Tables[i].Key < graph.Tables[j].Key })
+	return graph
+}
+
+func RegisterBuilderGraphRoutes(group *gin.RouterGroup, db *gorm.DB) {
+	// GET /builder/graph shows what depends on what before a delete or an
+	// undeploy: the tables each page owns and relates to, and the
+	// navigation items opening each page.
+	group.GET("/builder/graph", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		var pages []models.Page
+		if err := db.Select("id", "name", "slug", "table_name", "storage", "deploy", "status",
+			"schema_relations", "schema_relations_deployed").Order("name").Find(&pages).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_PAGES_ERROR", err.Error())
+			return
+		}
+		var items []models.NavigationItem
+		if err := db.Select("id", "title", "path", "page_id").Where("page_id IS NOT NULL").
+			Order("lft").Find(&items).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_NAVIGATION_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": buildPageGraph(pages, items), "success": true})
+	})
+}
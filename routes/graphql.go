@@ -0,0 +1,673 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/utils"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// graphQLMaxDepth bounds the nesting of relations in a query; every level
+// costs one batched query per relation.
+const graphQLMaxDepth = 8
+
+// graphQLType is the object type of a deployed page. Each page is exposed
+// as a root list field named after its slug ("client-contacts" becomes
+// clientContacts, of type ClientContacts).
+type graphQLType struct {
+	name      string
+	field     string
+	page      models.Page
+	fields    []graphQLField
+	relations map[string]RelationDefinition
+	functions []FunctionDefinition
+}
+
+// graphQLReservedTypes are the names a page type may not take.
+var graphQLReservedTypes = map[string]bool{
+	"Query": true, "Mutation": true, "Subscription": true, "Filter": true,
+	"JSON": true, "ID": true, "Int": true, "Float": true, "String": true, "Boolean": true,
+}
+
+type graphQLField struct {
+	name string
+	typ  string
+}
+
+type graphQLSchema struct {
+	roots  map[string]*graphQLType
+	tables map[string]*graphQLType
+}
+
+// graphQLName turns a slug into a camelCase name; exported marks the
+// PascalCase type name.
+func graphQLName(slug string, exported bool) string {
+	var b strings.Builder
+	upper := exported
+	for _, r := range slug {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			upper = b.Len() > 0 || exported
+			continue
+		}
+		if b.Len() == 0 && unicode.IsDigit(r) {
+			b.WriteByte('_')
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+func graphQLScalar(col ColumnDefinition) string {
+	if col.Name == "id" {
+		return "ID"
+	}
+	switch strings.ToLower(col.Type) {
+	case "int", "integer", "bigint", "smallint":
+		return "Int"
+	case "number", "numeric", "decimal", "float", "double", "real":
+		return "Float"
+	case "bool", "boolean":
+		return "Boolean"
+	case "json", "jsonb":
+		return "JSON"
+	}
+	return "String"
+}
+
+// loadGraphQLSchema builds the schema from the deployed pages the user may
+// see. Pages and relations whose tables fail the registry checks are left
+// out.
+func loadGraphQLSchema(c *gin.Context, db *gorm.DB) (*graphQLSchema, error) {
+	var pages []models.Page
+	if err := db.Where("deploy = ? AND table_name <> ''", true).Order("slug").Find(&pages).Error; err != nil {
+		return nil, err
+	}
+	registry, err := loadTableRegistry(db)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := &graphQLSchema{roots: map[string]*graphQLType{}, tables: map[string]*graphQLType{}}
+	for _, page := range pages {
+		if !pageVisible(c, page.Status) || registry.check(page.Storage, page.TableName) != nil {
+			continue
+		}
+		slug := page.Slug
+		if slug == "" {
+			slug = slugify(page.Name)
+		}
+		t := &graphQLType{
+			name:      graphQLName(slug, true),
+			field:     graphQLName(slug, false),
+			page:      page,
+			relations: map[string]RelationDefinition{},
+			functions: parseFunctions(page.SchemaFunctionsDeployed),
+		}
+		if _, taken := schema.roots[t.field]; taken || graphQLReservedTypes[t.name] {
+			continue
+		}
+		schema.roots[t.field] = t
+		schema.tables[registryKey(page.Storage, page.TableName)] = t
+	}
+
+	for _, t := range schema.roots {
+		page := t.page
+		seen := map[string]bool{"id": true}
+		t.fields = append(t.fields, graphQLField{name: "id", typ: "ID"})
+
+		for _, rel := range parseRelations(page.SchemaRelationsDeployed) {
+			target, ok := schema.tables[registryKey(page.Storage, rel.ToTable)]
+			if !ok || validateIdent(rel.FromColumn) != nil || seen[rel.FromColumn] {
+				continue
+			}
+			if rel.Type == "many-to-many" {
+				if registry.check(page.Storage, pivotTableName(page.TableName, rel)) != nil {
+					continue
+				}
+				t.fields = append(t.fields, graphQLField{name: rel.FromColumn, typ: "[" + target.name + "!]!"})
+			} else {
+				t.fields = append(t.fields, graphQLField{name: rel.FromColumn, typ: target.name})
+			}
+			t.relations[rel.FromColumn] = rel
+			seen[rel.FromColumn] = true
+		}
+		for _, col := range parseColumns(page.SchemaColumnsDeployed) {
+			if !seen[col.Name] && validateIdent(col.Name) == nil {
+				t.fields = append(t.fields, graphQLField{name: col.Name, typ: graphQLScalar(col)})
+				seen[col.Name] = true
+			}
+		}
+		for _, fn := range compileFunctions(t.functions, FunctionOnRead) {
+			if name := fn.def.target(); !seen[name] && validateIdent(name) == nil {
+				t.fields = append(t.fields, graphQLField{name: name, typ: "JSON"})
+				seen[name] = true
+			}
+		}
+	}
+	return schema, nil
+}
+
+func (t *graphQLType) hasField(name string) bool {
+	for _, f := range t.fields {
+		if f.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SDL renders the schema in the GraphQL schema definition language.
+func (s *graphQLSchema) SDL() string {
+	fields := make([]string, 0, len(s.roots))
+	for field := range s.roots {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var b strings.Builder
+	b.WriteString("scalar JSON\n\n")
+	b.WriteString("input Filter {\n  column: String!\n  op: String!\n  value: JSON\n}\n\n")
+	b.WriteString("type Query {\n")
+	for _, field := range fields {
+		fmt.Fprintf(&b, "  %s(id: ID, limit: Int, offset: Int, sort: String, filters: [Filter!]): [%s!]!\n", field, s.roots[field].name)
+	}
+	b.WriteString("}\n")
+	for _, field := range fields {
+		t := s.roots[field]
+		fmt.Fprintf(&b, "\ntype %s {\n", t.name)
+		for _, f := range t.fields {
+			fmt.Fprintf(&b, "  %s: %s\n", f.name, f.typ)
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+// graphQLExecution runs one operation. Row policies and column masks are
+// built once per page and shared by every level of the query.
+type graphQLExecution struct {
+	c        *gin.Context
+	db       *gorm.DB
+	schema   *graphQLSchema
+	doc      *utils.GraphQLDocument
+	vars     map[string]any
+	policies map[string]*rowPolicy
+	masks    map[string]columnMasks
+	errors   []graphQLError
+	// rejected is set when a root sorts or filters on a restricted column:
+	// the request is answered 400.
+	rejected bool
+}
+
+func (e *graphQLExecution) fail(path []any, format string, args ...any) {
+	e.errors = append(e.errors, graphQLError{Message: fmt.Sprintf(format, args...), Path: append([]any{}, path...)})
+}
+
+func (e *graphQLExecution) policy(t *graphQLType) *rowPolicy {
+	if policy, ok := e.policies[t.page.ID]; ok {
+		return policy
+	}
+	tagColumn := visibilityColumn(parseColumns(t.page.SchemaColumnsDeployed))
+	policy := newRowPolicy(e.c, e.db, parseConditions(t.page.SchemaConditionsDeployed), tagColumn)
+	e.policies[t.page.ID] = policy
+	return policy
+}
+
+func (e *graphQLExecution) columnMasks(t *graphQLType) columnMasks {
+	if masks, ok := e.masks[t.page.ID]; ok {
+		return masks
+	}
+	masks := newColumnMasks(e.c, e.db, pageMasks(parseColumns(t.page.SchemaColumnsDeployed)))
+	e.masks[t.page.ID] = masks
+	return masks
+}
+
+// prepare decrypts rows, computes their read functions and applies the page
+// row policy and column masks: rows the user may not see come back nil.
+func (e *graphQLExecution) prepare(t *graphQLType, rows []map[string]any) []map[string]any {
+	decryptRows(parseColumns(t.page.SchemaColumnsDeployed), rows...)
+	applyReadFunctions(t.functions, rows...)
+	policy := e.policy(t)
+	if policy == nil {
+		e.columnMasks(t).apply(rows...)
+		return rows
+	}
+	out := make([]map[string]any, len(rows))
+	for i, row := range rows {
+		if policy.allows(row) {
+			policy.strip(row)
+			e.columnMasks(t).apply(row)
+			out[i] = row
+		}
+	}
+	return out
+}
+
+// collectFields flattens fragments and merges the selections sharing a
+// response key.
+func (e *graphQLExecution) collectFields(set []*utils.GraphQLSelection, typeName string, visited map[string]bool) []*utils.GraphQLSelection {
+	var out []*utils.GraphQLSelection
+	byKey := map[string]*utils.GraphQLSelection{}
+
+	var walk func(set []*utils.GraphQLSelection)
+	walk = func(set []*utils.GraphQLSelection) {
+		for _, sel := range set {
+			if !sel.Included(e.vars) {
+				continue
+			}
+			switch {
+			case sel.Fragment != "":
+				frag, ok := e.doc.Fragments[sel.Fragment]
+				if !ok || visited[sel.Fragment] || frag.On != typeName {
+					continue
+				}
+				visited[sel.Fragment] = true
+				walk(frag.SelectionSet)
+				delete(visited, sel.Fragment)
+			case sel.Inline:
+				if sel.On == "" || sel.On == typeName {
+					walk(sel.SelectionSet)
+				}
+			default:
+				key := sel.ResponseKey()
+				if prev, ok := byKey[key]; ok {
+					merged := *prev
+					merged.SelectionSet = append(append([]*utils.GraphQLSelection{}, prev.SelectionSet...), sel.SelectionSet...)
+					*prev = merged
+					continue
+				}
+				copied := *sel
+				byKey[key] = &copied
+				out = append(out, &copied)
+			}
+		}
+	}
+	walk(set)
+	return out
+}
+
+func (e *graphQLExecution) executeQuery(set []*utils.GraphQLSelection) map[string]any {
+	data := map[string]any{}
+	for _, sel := range e.collectFields(set, "Query", map[string]bool{}) {
+		key := sel.ResponseKey()
+		path := []any{key}
+		if sel.Name == "__typename" {
+			data[key] = "Query"
+			continue
+		}
+		t, ok := e.schema.roots[sel.Name]
+		if !ok {
+			e.fail(path, "Cannot query field %q on type Query", sel.Name)
+			data[key] = nil
+			continue
+		}
+		if len(sel.SelectionSet) == 0 {
+			e.fail(path, "Field %q of type [%s!]! must have a selection of subfields", sel.Name, t.name)
+			data[key] = nil
+			continue
+		}
+
+		rows, sqlDB, err := e.selectRoot(t, sel)
+		if err != nil {
+			e.rejected = e.rejected || errors.Is(err, ErrRestrictedColumn)
+			e.fail(path, "%s", err.Error())
+			data[key] = nil
+			continue
+		}
+		objects := e.resolveObjects(sqlDB, t, e.prepare(t, rows), sel.SelectionSet, path, 1)
+		list := make([]any, 0, len(objects))
+		for _, obj := range objects {
+			if obj != nil {
+				list = append(list, obj)
+			}
+		}
+		data[key] = list
+	}
+	return data
+}
+
+func graphQLInt(v any) (int, bool) {
+	switch n := v.(type) {
+	case int64:
+		return int(n), n >= 0
+	case float64:
+		return int(n), n >= 0 && n == float64(int(n))
+	case json.Number:
+		i, err := n.Int64()
+		return int(i), err == nil && i >= 0
+	}
+	return 0, false
+}
+
+// selectRoot reads the rows of a root field. The page default sort and
+// filters apply; sort replaces the sort and filters are added to the
+// baseline ones.
+func (e *graphQLExecution) selectRoot(t *graphQLType, sel *utils.GraphQLSelection) ([]map[string]any, *sql.DB, error) {
+	query := parseQueryDefinition(t.page.SchemaQueryDeployed)
+	var limit, offset int
+	var sorts []SortDefinition
+	var filters []FilterDefinition
+
+	for name, raw := range sel.Arguments {
+		value := utils.ResolveGraphQLValue(raw, e.vars)
+		if value == nil {
+			continue
+		}
+		var ok bool
+		switch name {
+		case "id":
+			query.Filters = append(query.Filters, FilterDefinition{Column: "id", Op: "eq", Value: fmt.Sprint(value)})
+			ok = true
+		case "limit":
+			limit, ok = graphQLInt(value)
+		case "offset":
+			offset, ok = graphQLInt(value)
+		case "sort":
+			var s string
+			if s, ok = value.(string); ok {
+				sorts = parseSortParam(s)
+				query.Sort = sorts
+			}
+		case "filters":
+			if b, err := json.Marshal(value); err == nil && json.Unmarshal(b, &filters) == nil {
+				query.Filters = append(query.Filters, filters...)
+				ok = true
+			}
+		default:
+			return nil, nil, fmt.Errorf("Unknown argument %q on field Query.%s", name, sel.Name)
+		}
+		if !ok {
+			return nil, nil, fmt.Errorf("Invalid value for argument %q on field Query.%s", name, sel.Name)
+		}
+	}
+
+	if err := checkQueryColumns(t.page, e.policy(t), e.columnMasks(t), sorts, filters); err != nil {
+		return nil, nil, err
+	}
+
+	sqlDB, err := PageSQL(e.db, t.page)
+	if err != nil {
+		return nil, nil, err
+	}
+	q := newQuery("SELECT * FROM ").Ident(t.page.TableName)
+	if err := query.apply(sqlDB, t.page.TableName, q, limit); err != nil {
+		return nil, nil, err
+	}
+	if offset > 0 {
+		q.Write(" OFFSET ").Arg(offset)
+	}
+
+	rs, err := sqlDB.Query(q.SQL(), q.Args()...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rs.Close()
+
+	cols, _ := rs.Columns()
+	rows := []map[string]any{}
+	for rs.Next() {
+		values := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range cols {
+			ptrs[i] = &values[i]
+		}
+		if err := rs.Scan(ptrs...); err != nil {
+			continue
+		}
+		row := make(map[string]any, len(cols))
+		for i, col := range cols {
+			row[col] = values[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, sqlDB, rs.Err()
+}
+
+// resolveObjects projects rows onto the selection set. Relations are loaded
+// for all rows at once; a nil row stays nil.
+func (e *graphQLExecution) resolveObjects(sqlDB *sql.DB, t *graphQLType, rows []map[string]any, set []*utils.GraphQLSelection, path []any, depth int) []map[string]any {
+	out := make([]map[string]any, len(rows))
+	for i, row := range rows {
+		if row != nil {
+			out[i] = map[string]any{}
+		}
+	}
+
+	for _, sel := range e.collectFields(set, t.name, map[string]bool{}) {
+		key := sel.ResponseKey()
+		fieldPath := append(append([]any{}, path...), key)
+
+		if sel.Name == "__typename" {
+			for _, obj := range out {
+				if obj != nil {
+					obj[key] = t.name
+				}
+			}
+			continue
+		}
+		if !t.hasField(sel.Name) {
+			e.fail(fieldPath, "Cannot query field %q on type %s", sel.Name, t.name)
+			continue
+		}
+		if len(sel.Arguments) > 0 {
+			e.fail(fieldPath, "Field %s.%s takes no arguments", t.name, sel.Name)
+			continue
+		}
+
+		rel, isRelation := t.relations[sel.Name]
+		switch {
+		case !isRelation && len(sel.SelectionSet) > 0:
+			e.fail(fieldPath, "Field %s.%s is a scalar and cannot have a selection", t.name, sel.Name)
+		case !isRelation:
+			for i, obj := range out {
+				if obj != nil {
+					obj[key] = rows[i][sel.Name]
+				}
+			}
+		case len(sel.SelectionSet) == 0:
+			e.fail(fieldPath, "Field %s.%s must have a selection of subfields", t.name, sel.Name)
+		case depth >= graphQLMaxDepth:
+			e.fail(fieldPath, "Query nested deeper than %d relations", graphQLMaxDepth)
+		default:
+			e.resolveRelation(sqlDB, t, rel, rows, out, key, sel.SelectionSet, fieldPath, depth)
+		}
+	}
+	return out
+}
+
+// resolveRelation loads the related rows of rel for every row, resolves them
+// against the sub-selection and stores them under key.
+func (e *graphQLExecution) resolveRelation(sqlDB *sql.DB, t *graphQLType, rel RelationDefinition, rows, out []map[string]any, key string, set []*utils.GraphQLSelection, path []any, depth int) {
+	target := e.schema.tables[registryKey(t.page.Storage, rel.ToTable)]
+	many := rel.Type == "many-to-many"
+
+	fkByTable := map[string]map[string]struct{}{}
+	var pairs map[string][]string
+	if many {
+		ids := make([]string, 0, len(rows))
+		for _, row := range rows {
+			if row != nil && row["id"] != nil {
+				ids = append(ids, fmt.Sprintf("%v", row["id"]))
+			}
+		}
+		if len(ids) > 0 {
+			var err error
+			pairs, err = loadPivotPairs(sqlDB, pivotTableName(t.page.TableName, rel), rel.Symmetric && rel.selfReferencing(t.page.TableName), ids)
+			if err != nil {
+				e.fail(path, "%s", err.Error())
+			}
+		}
+		for _, rights := range pairs {
+			for _, rid := range rights {
+				addFK(fkByTable, rel.ToTable, rid)
+			}
+		}
+	} else {
+		for _, row := range rows {
+			if row != nil && row[rel.FromColumn] != nil {
+				addFK(fkByTable, rel.ToTable, fmt.Sprintf("%v", row[rel.FromColumn]))
+			}
+		}
+	}
+
+	loaded := batchLoadRelated(sqlDB, fkByTable, nil)
+	keys := make([]string, 0, len(loaded))
+	related := make([]map[string]any, 0, len(loaded))
+	for k, row := range loaded {
+		keys = append(keys, k)
+		related = append(related, row)
+	}
+	resolved := e.resolveObjects(sqlDB, target, e.prepare(target, related), set, path, depth+1)
+	byKey := make(map[string]map[string]any, len(keys))
+	for i, k := range keys {
+		if resolved[i] != nil {
+			byKey[k] = resolved[i]
+		}
+	}
+
+	for i, obj := range out {
+		if obj == nil {
+			continue
+		}
+		if !many {
+			if fk := rows[i][rel.FromColumn]; fk != nil {
+				if related, ok := byKey[rel.ToTable+":"+fmt.Sprintf("%v", fk)]; ok {
+					obj[key] = related
+					continue
+				}
+			}
+			obj[key] = nil
+			continue
+		}
+		list := []any{}
+		for _, rid := range pairs[fmt.Sprintf("%v", rows[i]["id"])] {
+			if related, ok := byKey[rel.ToTable+":"+rid]; ok {
+				list = append(list, related)
+			}
+		}
+		obj[key] = list
+	}
+}
+
+type graphQLRequest struct {
+	Query         string         `json:"query" form:"query"`
+	OperationName string         `json:"operationName" form:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+func runGraphQL(c *gin.Context, db *gorm.DB, req graphQLRequest) {
+	requestError := func(err error) {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []graphQLError{{Message: err.Error()}}})
+	}
+
+	doc, err := utils.ParseGraphQL(req.Query)
+	if err != nil {
+		requestError(err)
+		return
+	}
+	op, err := doc.Operation(req.OperationName)
+	if err != nil {
+		requestError(err)
+		return
+	}
+	if op.Type != "query" {
+		requestError(fmt.Errorf("%w: only queries are supported", utils.ErrGraphQL))
+		return
+	}
+	vars, err := op.VariableValues(req.Variables)
+	if err != nil {
+		requestError(err)
+		return
+	}
+
+	schema, err := loadGraphQLSchema(c, db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"errors": []graphQLError{{Message: err.Error()}}})
+		return
+	}
+
+	exec := &graphQLExecution{c: c, db: db, schema: schema, doc: doc, vars: vars, policies: map[string]*rowPolicy{}, masks: map[string]columnMasks{}}
+	data := exec.executeQuery(op.SelectionSet)
+	if exec.rejected {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": exec.errors})
+		return
+	}
+	if len(exec.errors) > 0 {
+		c.JSON(http.StatusOK, gin.H{"data": data, "errors": exec.errors})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// RegisterGraphQLRoutes exposes the deployed pages as a read-only GraphQL
+// API. Queries may be sent as GET, which keeps them available during
+// maintenance.
+func RegisterGraphQLRoutes(r gin.IRoutes, db *gorm.DB) {
+	r.POST("/graphql", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		var req graphQLRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": []graphQLError{{Message: err.Error()}}})
+			return
+		}
+		runGraphQL(c, db, req)
+	})
+
+	r.GET("/graphql", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		req := graphQLRequest{Query: c.Query("query"), OperationName: c.Query("operationName")}
+		if v := c.Query("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"errors": []graphQLError{{Message: "variables: " + err.Error()}}})
+				return
+			}
+		}
+		runGraphQL(c, db, req)
+	})
+
+	r.GET("/graphql/schema", func(c *gin.Context) {
+		schema, err := loadGraphQLSchema(c, utils.ReadDB(c, db))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.String(http.StatusOK, schema.SDL())
+	})
+}
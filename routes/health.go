@@ -0,0 +1,38 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterHealthRoutes exposes GET /health. A degraded API still answers
+// 200; only a critical dependency down answers 503.
+func RegisterHealthRoutes(r gin.IRoutes, health *services.Health) {
+	r.GET("/health", func(c *gin.Context) {
+		status, deps := health.Report()
+		code := http.StatusOK
+		if status == services.HealthDown {
+			code = http.StatusServiceUnavailable
+		}
+		utils.HealthResponse(c, code, status, deps)
+	})
+}
@@ -17,54 +17,326 @@
 package routes
 
 import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"strings"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 
-func InsertDynamic(db *sql.DB, table string, fields map[string]any) (string, error) {
+type sqlExecutor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+type sqlTx interface {
+	sqlExecutor
+	Commit() error
+	Rollback() error
+}
+
+// contextExecutor is implemented by *sql.DB and *sql.Tx.
+type contextExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// contextSQL runs every statement of db under ctx.
+type contextSQL struct {
+	ctx context.Context
+	db  contextExecutor
+}
+
+func (s contextSQL) Exec(query string, args ...any) (sql.Result, error) {
+	return s.db.ExecContext(s.ctx, query, args...)
+}
+
+func (s contextSQL) Query(query string, args ...any) (*sql.Rows, error) {
+	return s.db.QueryContext(s.ctx, query, args...)
+}
+
+func (s contextSQL) QueryRow(query string, args ...any) *sql.Row {
+	return s.db.QueryRowContext(s.ctx, query, args...)
+}
+
+// withQueryTimeout binds db to ctx, bounded by services.QueryTimeout, so
+// the dynamic queries of a request stop when the client goes away or when
+// they take too long. cancel must be called once the rows are read.
+func withQueryTimeout(ctx context.Context, db contextExecutor) (sqlExecutor, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if timeout := services.QueryTimeout(); timeout > 0 {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		return contextSQL{ctx, db}, cancel
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return contextSQL{ctx, db}, cancel
+}
+
+// isQueryTimeout reports whether err comes from a query stopped by
+// withQueryTimeout.
+func isQueryTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+}
+
+// queryErrorStatus is 504 for a query that ran out of time, 400 for an
+// invalid identifier, 502 for a failing page source and 500 otherwise.
+func queryErrorStatus(err error) int {
+	switch {
+	case isQueryTimeout(err):
+		return http.StatusGatewayTimeout
+	case isIdentifierError(err):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrSourceUnavailable):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// requestScopedTx runs on the request transaction; its outcome belongs to the
+// transaction middleware, so Commit and Rollback are no-ops here.
+type requestScopedTx struct {
+	*sql.Tx
+}
+
+func (requestScopedTx) Commit() error   { return nil }
+func (requestScopedTx) Rollback() error { return nil }
+
+// beginSQL starts a raw SQL transaction, joining the request transaction when
+// the middleware opened one.
+func beginSQL(c *gin.Context, db *gorm.DB) (sqlTx, error) {
+	if tx, ok := utils.DB(c, db).Statement.ConnPool.(*sql.Tx); ok {
+		return requestScopedTx{tx}, nil
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	return sqlDB.Begin()
+}
+
+// PageSQL returns the database holding the table of page: the core database
+// unless the page names a storage.
+func PageSQL(db *gorm.DB, page models.Page) (*sql.DB, error) {
+	if page.Storage == "" {
+		return db.DB()
+	}
+	sqlDB, err := services.StorageDB(page.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStorage, page.Storage)
+	}
+	return sqlDB, nil
+}
+
+// beginPageSQL is beginSQL on the storage of page. Only the core database
+// joins the request transaction.
+func beginPageSQL(c *gin.Context, db *gorm.DB, page models.Page) (sqlTx, error) {
+	if page.Storage == "" {
+		return beginSQL(c, db)
+	}
+	sqlDB, err := PageSQL(db, page)
+	if err != nil {
+		return nil, err
+	}
+	return sqlDB.Begin()
+}
+
+func InsertDynamic(db sqlExecutor, table string, fields map[string]any) (string, error) {
 	if len(fields) == 0 {
 		return "", fmt.Errorf("aucune donnée à insérer")
 	}
 
 	cols := []string{}
-	params := []string{}
 	args := []any{}
-	i := 1
 
 	for col, val := range fields {
-		cols = append(cols, quoteIdent(col))
-		params = append(params, fmt.Sprintf("$%d", i))
+		if err := validateIdent(col); err != nil {
+			return "", err
+		}
+		cols = append(cols, col)
 		args = append(args, val)
-		i++
 	}
 
-	query := fmt.Sprintf(
-		`INSERT INTO %s (%s) VALUES (%s) RETURNING id`,
-		quoteIdent(table),
-		strings.Join(cols, ", "),
-		strings.Join(params, ", "),
-	)
+	q := newQuery("INSERT INTO ").Ident(table).
+		Write(" (").Idents(cols).Write(") VALUES (").ArgList(args...).Write(") RETURNING id")
 
 	var newID string
-	err := db.QueryRow(query, args...).Scan(&newID)
+	err := db.QueryRow(q.SQL(), q.Args()...).Scan(&newID)
 	return newID, err
 }
 
 
-func InsertPivotM2M(db *sql.DB, pivotTable string, leftID string, rightIDs []string) error {
-	if len(rightIDs) == 0 {
+// UpsertDynamic inserts the row or, when keyColumn already holds the same
+// value, overwrites the other provided columns. insertOnly columns are
+// written on insert but left untouched on conflict (e.g. created_at).
+// keyColumn must carry a unique constraint in the target table. inserted
+// reports which path ran.
+func UpsertDynamic(db sqlExecutor, table string, keyColumn string, fields map[string]any, insertOnly map[string]any) (id string, inserted bool, err error) {
+	if err := validateIdent(keyColumn); err != nil {
+		return "", false, err
+	}
+	if _, ok := fields[keyColumn]; !ok {
+		return "", false, fmt.Errorf("la clé %q est absente des données", keyColumn)
+	}
+
+	cols := []string{}
+	args := []any{}
+	updatable := []string{}
+
+	for col, val := range fields {
+		if err := validateIdent(col); err != nil {
+			return "", false, err
+		}
+		cols = append(cols, col)
+		args = append(args, val)
+		if col != keyColumn {
+			updatable = append(updatable, col)
+		}
+	}
+	for col, val := range insertOnly {
+		if _, ok := fields[col]; ok {
+			continue
+		}
+		if err := validateIdent(col); err != nil {
+			return "", false, err
+		}
+		cols = append(cols, col)
+		args = append(args, val)
+	}
+
+	q := newQuery("INSERT INTO ").Ident(table).
+		Write(" (").Idents(cols).Write(") VALUES (").ArgList(args...).Write(")").
+		Write(" ON CONFLICT (").Ident(keyColumn).Write(") DO UPDATE SET ")
+
+	if len(updatable) == 0 {
+		// Nothing to overwrite: touch the key so RETURNING still yields the id.
+		updatable = []string{keyColumn}
+	}
+	for i, col := range updatable {
+		if i > 0 {
+			q.Write(", ")
+		}
+		q.Ident(col).Write(" = EXCLUDED.").Ident(col)
+	}
+
+	// xmax is only zero on a freshly inserted tuple.
+	q.Write(" RETURNING id, (xmax = 0)")
+
+	err = db.QueryRow(q.SQL(), q.Args()...).Scan(&id, &inserted)
+	return id, inserted, err
+}
+
+// UpdateMissingDynamic applies fields to every row whose keyColumn is not in
+// keep, e.g. to flag records that disappeared from an external source.
+func UpdateMissingDynamic(db sqlExecutor, table string, keyColumn string, keep []string, fields map[string]any) error {
+	if len(fields) == 0 {
 		return nil
 	}
+	if err := validateIdent(keyColumn); err != nil {
+		return err
+	}
+
+	q := newQuery("UPDATE ").Ident(table).Write(" SET ")
+	first := true
+
+	for col, val := range fields {
+		if err := validateIdent(col); err != nil {
+			return err
+		}
+		if !first {
+			q.Write(", ")
+		}
+		first = false
+		q.Ident(col).Write(" = ").Arg(val)
+	}
 
-	query := fmt.Sprintf(
-		`INSERT INTO %s (left_id, right_id) VALUES ($1, $2)`,
-		quoteIdent(pivotTable),
-	)
+	if len(keep) > 0 {
+		q.Write(" WHERE ").Ident(keyColumn).Write(" NOT IN (").ArgList(stringArgs(keep)...).Write(")")
+	}
+
+	_, err := db.Exec(q.SQL(), q.Args()...)
+	return err
+}
 
+func TableColumns(db *sql.DB, table string) ([]string, error) {
+	return getColumns(db, table)
+}
+
+const pivotBatchSize = 1000
+
+// pivotPositionColumn, when a pivot has it, keeps the order in which the
+// right ids were given.
+const pivotPositionColumn = "position"
+
+func InsertPivotM2M(db sqlExecutor, pivotTable string, leftID string, rightIDs []string) error {
+	return insertPivotRows(db, pivotTable, leftID, rightIDs, false)
+}
+
+func UpsertPivotM2M(db sqlExecutor, pivotTable string, leftID string, rightIDs []string) error {
+	return insertPivotRows(db, pivotTable, leftID, rightIDs, true)
+}
+
+func ReplacePivotM2M(db sqlExecutor, pivotTable string, leftID string, rightIDs []string) error {
+	if err := ClearPivot(db, pivotTable, leftID); err != nil {
+		return err
+	}
+	return UpsertPivotM2M(db, pivotTable, leftID, rightIDs)
+}
+
+func insertPivotRows(db sqlExecutor, pivotTable string, leftID string, rightIDs []string, upsert bool) error {
+	seen := make(map[string]bool, len(rightIDs))
+	unique := make([]string, 0, len(rightIDs))
 	for _, r := range rightIDs {
-		if _, err := db.Exec(query, leftID, r); err != nil {
+		if !seen[r] {
+			seen[r] = true
+			unique = append(unique, r)
+		}
+	}
+
+	// Pivots created by hand may lack the position column.
+	cols, err := getColumns(db, pivotTable)
+	if err != nil {
+		return err
+	}
+	ordered := slices.Contains(cols, pivotPositionColumn)
+
+	for start := 0; start < len(unique); start += pivotBatchSize {
+		end := start + pivotBatchSize
+		if end > len(unique) {
+			end = len(unique)
+		}
+
+		q := newQuery("INSERT INTO ").Ident(pivotTable).Write(" (left_id, right_id")
+		if ordered {
+			q.Write(", ").Ident(pivotPositionColumn)
+		}
+		q.Write(") VALUES ")
+		for i, r := range unique[start:end] {
+			if i > 0 {
+				q.Write(", ")
+			}
+			if ordered {
+				q.Write("(").ArgList(leftID, r, start+i).Write(")")
+			} else {
+				q.Write("(").ArgList(leftID, r).Write(")")
+			}
+		}
+		if upsert {
+			q.Write(" ON CONFLICT DO NOTHING")
+		}
+
+		if _, err := db.Exec(q.SQL(), q.Args()...); err != nil {
 			return err
 		}
 	}
@@ -73,36 +345,75 @@ func InsertPivotM2M(db *sql.DB, pivotTable string, leftID string, rightIDs []str
 }
 
 
-func ClearPivot(db *sql.DB, pivotTable, leftID string) error {
-	q := fmt.Sprintf(`DELETE FROM %s WHERE left_id = $1`, quoteIdent(pivotTable))
-	_, err := db.Exec(q, leftID)
+func ClearPivot(db sqlExecutor, pivotTable, leftID string) error {
+	q := newQuery("DELETE FROM ").Ident(pivotTable).Write(" WHERE left_id = ").Arg(leftID)
+	_, err := db.Exec(q.SQL(), q.Args()...)
 	return err
 }
 
-func UpdateDynamic(db *sql.DB, table string, id string, fields map[string]any) error {
+// ClearPivotReverse removes the pivot rows pointing at rightID, the other
+// side of a symmetric relation.
+func ClearPivotReverse(db sqlExecutor, pivotTable, rightID string) error {
+	q := newQuery("DELETE FROM ").Ident(pivotTable).Write(" WHERE right_id = ").Arg(rightID)
+	_, err := db.Exec(q.SQL(), q.Args()...)
+	return err
+}
+
+func UpdateDynamic(db sqlExecutor, table string, id string, fields map[string]any) error {
 	if len(fields) == 0 {
 		return nil
 	}
 
-	sets := []string{}
-	args := []any{}
-	i := 1
+	q := newQuery("UPDATE ").Ident(table).Write(" SET ")
+	first := true
 
 	for col, val := range fields {
-		sets = append(sets, fmt.Sprintf("%s = $%d", quoteIdent(col), i))
-		args = append(args, val)
-		i++
+		if err := validateIdent(col); err != nil {
+			return err
+		}
+		if !first {
+			q.Write(", ")
+		}
+		first = false
+		q.Ident(col).Write(" = ").Arg(val)
 	}
 
-	args = append(args, id)
+	q.Write(" WHERE id = ").Arg(id)
 
-	q := fmt.Sprintf(
-		`UPDATE %s SET %s WHERE id = $%d`,
-		quoteIdent(table),
-		strings.Join(sets, ", "),
-		len(args),
-	)
-
-	_, err := db.Exec(q, args...)
+	_, err := db.Exec(q.SQL(), q.Args()...)
 	return err
 }
+
+// UpdateDynamicIf is UpdateDynamic applied only while versionColumn still
+// holds version. updated is false when the row changed in the meantime.
+func UpdateDynamicIf(db sqlExecutor, table string, id string, fields map[string]any, versionColumn string, version any) (updated bool, err error) {
+	if len(fields) == 0 {
+		return true, nil
+	}
+	if err := validateIdent(versionColumn); err != nil {
+		return false, err
+	}
+
+	q := newQuery("UPDATE ").Ident(table).Write(" SET ")
+	first := true
+
+	for col, val := range fields {
+		if err := validateIdent(col); err != nil {
+			return false, err
+		}
+		if !first {
+			q.Write(", ")
+		}
+		first = false
+		q.Ident(col).Write(" = ").Arg(val)
+	}
+
+	q.Write(" WHERE id = ").Arg(id).Write(" AND ").Ident(versionColumn).Write(" IS NOT DISTINCT FROM ").Arg(version)
+
+	res, err := db.Exec(q.SQL(), q.Args()...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
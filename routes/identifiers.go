@@ -0,0 +1,254 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	ErrInvalidIdentifier = errors.New("invalid identifier")
+	ErrUnknownTable      = errors.New("table is not a deployed page table")
+	ErrReservedTable     = errors.New("table belongs to the core schema")
+	ErrUnknownStorage    = errors.New("unknown page storage")
+)
+
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
+func validateIdent(name string) error {
+	if !identPattern.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidIdentifier, name)
+	}
+	return nil
+}
+
+var (
+	coreTablesOnce sync.Once
+	coreTables     map[string]bool
+)
+
+// isCoreTable reports whether table holds core models (or their join tables)
+// or is a Postgres catalog reachable through the search path. Builder pages
+// never own those.
+func isCoreTable(db *gorm.DB, table string) bool {
+	coreTablesOnce.Do(func() {
+		coreTables = map[string]bool{}
+		cache := &sync.Map{}
+		for _, model := range models.All() {
+			s, err := schema.Parse(model, cache, db.NamingStrategy)
+			if err != nil {
+				continue
+			}
+			coreTables[s.Table] = true
+			for _, rel := range s.Relationships.Relations {
+				if rel.JoinTable != nil {
+					coreTables[rel.JoinTable.Table] = true
+				}
+			}
+		}
+	})
+	return coreTables[strings.ToLower(table)] || strings.HasPrefix(strings.ToLower(table), "pg_")
+}
+
+// checkOwnableTable validates a table name a page is about to be pointed at.
+func checkOwnableTable(db *gorm.DB, table string) error {
+	if err := validateIdent(table); err != nil {
+		return err
+	}
+	if isCoreTable(db, table) {
+		return fmt.Errorf("%w: %q", ErrReservedTable, table)
+	}
+	return nil
+}
+
+// checkPageOwnership refuses a page whose table or relations target a core
+// table, in its draft or deployed schema.
+func checkPageOwnership(db *gorm.DB, page models.Page) error {
+	if page.Storage != "" && !services.HasStorage(page.Storage) {
+		return fmt.Errorf("%w: %q", ErrUnknownStorage, page.Storage)
+	}
+	if err := pageIDStrategy(page).check(); err != nil {
+		return err
+	}
+	if page.TableName != "" {
+		if err := checkOwnableTable(db, page.TableName); err != nil {
+			return err
+		}
+	}
+	if err := checkRESTSource(page); err != nil {
+		return err
+	}
+	if err := checkViewQuery(page); err != nil {
+		return err
+	}
+	for _, raw := range []datatypes.JSON{page.SchemaRelations, page.SchemaRelationsDeployed} {
+		for _, rel := range parseRelations(raw) {
+			for _, table := range []string{rel.ToTable, rel.PivotTable} {
+				if table == "" {
+					continue
+				}
+				if err := checkOwnableTable(db, table); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkPageUpdates is checkPageOwnership for the raw maps of the builder
+// PATCH routes.
+func checkPageUpdates(db *gorm.DB, updates map[string]any) error {
+	return checkPageOwnership(db, pageFromUpdates(updates))
+}
+
+// pageFromUpdates reads the checked fields of the raw maps of the builder
+// PATCH routes into a page.
+func pageFromUpdates(updates map[string]any) models.Page {
+	var page models.Page
+	for key, value := range updates {
+		switch key {
+		case "tableName", "table_name", "TableName":
+			page.TableName, _ = value.(string)
+		case "viewQuery", "view_query", "ViewQuery":
+			page.ViewQuery, _ = value.(string)
+		case "source", "Source":
+			page.Source, _ = json.Marshal(value)
+		case "storage", "Storage":
+			page.Storage, _ = value.(string)
+		case "idStrategy", "id_strategy", "IDStrategy":
+			page.IDStrategy, _ = value.(string)
+		case "idPrefix", "id_prefix", "IDPrefix":
+			page.IDPrefix, _ = value.(string)
+		case "schemaRelations", "schema_relations", "SchemaRelations":
+			page.SchemaRelations, _ = json.Marshal(value)
+		case "schemaRelationsDeployed", "schema_relations_deployed", "SchemaRelationsDeployed":
+			page.SchemaRelationsDeployed, _ = json.Marshal(value)
+		}
+	}
+	return page
+}
+
+// CheckTableOwnership logs the pages pointed at a core table at startup.
+// The table registry leaves those tables out, so they are never queried.
+func CheckTableOwnership(db *gorm.DB) {
+	var pages []models.Page
+	if err := db.Select("id", "name", "table_name", "schema_relations", "schema_relations_deployed").
+		Find(&pages).Error; err != nil {
+		log.Printf("❌ Contrôle des tables de pages: %v", err)
+		return
+	}
+	for _, page := range pages {
+		if err := checkPageOwnership(db, page); err != nil && errors.Is(err, ErrReservedTable) {
+			log.Printf("⚠️  Page %q (%s) ignorée: %v", page.Name, page.ID, err)
+		}
+	}
+}
+
+// tableRegistry holds the tables of deployed pages, per storage: a page only
+// reaches the tables of its own storage.
+type tableRegistry map[string]bool
+
+func registryKey(storage, table string) string {
+	return storage + "/" + table
+}
+
+func loadTableRegistry(db *gorm.DB) (tableRegistry, error) {
+	var pages []models.Page
+	if err := db.Select("id", "table_name", "storage", "schema_relations_deployed").
+		Where("deploy = ? AND table_name <> ''", true).
+		Find(&pages).Error; err != nil {
+		return nil, err
+	}
+
+	registry := tableRegistry{}
+	for _, page := range pages {
+		if isCoreTable(db, page.TableName) {
+			continue
+		}
+		registry[registryKey(page.Storage, page.TableName)] = true
+		for _, rel := range parseRelations(page.SchemaRelationsDeployed) {
+			if pivot := pivotTableName(page.TableName, rel); rel.Type == "many-to-many" && !isCoreTable(db, pivot) {
+				registry[registryKey(page.Storage, pivot)] = true
+			}
+		}
+	}
+	return registry, nil
+}
+
+func (r tableRegistry) check(storage, table string) error {
+	if err := validateIdent(table); err != nil {
+		return err
+	}
+	if !r[registryKey(storage, table)] {
+		return fmt.Errorf("%w: %q", ErrUnknownTable, table)
+	}
+	return nil
+}
+
+// CheckOwnedTable fails unless table belongs to a deployed builder page of
+// storage ("" for the core database).
+func CheckOwnedTable(db *gorm.DB, storage, table string) error {
+	registry, err := loadTableRegistry(db)
+	if err != nil {
+		return err
+	}
+	return registry.check(storage, table)
+}
+
+func checkPageTables(db *gorm.DB, page models.Page, relations []RelationDefinition) error {
+	registry, err := loadTableRegistry(db)
+	if err != nil {
+		return err
+	}
+
+	if err := registry.check(page.Storage, page.TableName); err != nil {
+		return err
+	}
+	for _, rel := range relations {
+		if err := validateIdent(rel.FromColumn); err != nil {
+			return err
+		}
+		if err := registry.check(page.Storage, rel.ToTable); err != nil {
+			return err
+		}
+		if rel.Type == "many-to-many" {
+			if err := registry.check(page.Storage, pivotTableName(page.TableName, rel)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isIdentifierError(err error) bool {
+	return errors.Is(err, ErrInvalidIdentifier) || errors.Is(err, ErrUnknownTable) ||
+		errors.Is(err, ErrReservedTable) || errors.Is(err, ErrUnknownStorage) ||
+		errors.Is(err, ErrInvalidSource)
+}
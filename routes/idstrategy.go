@@ -0,0 +1,147 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"regexp"
+	"time"
+)
+
+// Primary key strategies of deployed tables. The default leaves the id to
+// the column default of the table.
+const (
+	IDStrategyDatabase  = ""
+	IDStrategyUUID      = "uuid"
+	IDStrategyULID      = "ulid"
+	IDStrategyBigserial = "bigserial"
+	IDStrategyPrefixed  = "prefixed"
+)
+
+var (
+	uuidPattern      = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	ulidPattern      = regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Za-hjkmnp-tv-z]{25}$`)
+	bigserialPattern = regexp.MustCompile(`^[0-9]{1,19}$`)
+	idPrefixPattern  = regexp.MustCompile(`^[a-z][a-z0-9]{0,15}$`)
+	idSuffixPattern  = regexp.MustCompile(`^[0-9A-Za-z]+$`)
+)
+
+// idStrategy generates the ids of the rows inserted through a page and
+// checks the ids it is asked for. Prefixed ids are Prefix, "_" and a ULID
+// (inv_01J9...), so that they sort by creation too.
+type idStrategy struct {
+	Kind   string
+	Prefix string
+}
+
+func pageIDStrategy(page models.Page) idStrategy {
+	return idStrategy{Kind: page.IDStrategy, Prefix: page.IDPrefix}
+}
+
+// check validates the strategy itself, for the builder.
+func (s idStrategy) check() error {
+	switch s.Kind {
+	case IDStrategyDatabase, IDStrategyUUID, IDStrategyULID, IDStrategyBigserial:
+		return nil
+	case IDStrategyPrefixed:
+		if !idPrefixPattern.MatchString(s.Prefix) {
+			return fmt.Errorf("%w: id prefix %q must be 1-16 lowercase letters or digits", ErrInvalidIdentifier, s.Prefix)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: unknown id strategy %q", ErrInvalidIdentifier, s.Kind)
+}
+
+// generate returns the id of a new row, or "" when the table default (or
+// sequence) provides it.
+func (s idStrategy) generate() string {
+	switch s.Kind {
+	case IDStrategyUUID:
+		return newUUID()
+	case IDStrategyULID:
+		return newULID(time.Now())
+	case IDStrategyPrefixed:
+		return s.Prefix + "_" + newULID(time.Now())
+	}
+	return ""
+}
+
+// validate reports whether id has the shape of the strategy.
+func (s idStrategy) validate(id string) error {
+	ok := true
+	switch s.Kind {
+	case IDStrategyUUID:
+		ok = uuidPattern.MatchString(id)
+	case IDStrategyULID:
+		ok = ulidPattern.MatchString(id)
+	case IDStrategyBigserial:
+		ok = bigserialPattern.MatchString(id)
+	case IDStrategyPrefixed:
+		prefix := s.Prefix + "_"
+		ok = len(id) > len(prefix) && id[:len(prefix)] == prefix && idSuffixPattern.MatchString(id[len(prefix):])
+	}
+	if !ok {
+		return fmt.Errorf("identifiant %q invalide (stratégie %s)", id, s.Kind)
+	}
+	return nil
+}
+
+// assign validates the id sent with a new row or, without one, generates it
+// into insertOnly (the same map as fields outside of upserts).
+func (s idStrategy) assign(fields, insertOnly map[string]any) error {
+	if v, ok := fields["id"]; ok && v != nil {
+		return s.validate(fmt.Sprint(v))
+	}
+	if id := s.generate(); id != "" {
+		insertOnly["id"] = id
+	}
+	return nil
+}
+
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID is a 48-bit millisecond timestamp and 80 random bits, in
+// Crockford base32.
+func newULID(t time.Time) string {
+	var b [16]byte
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(t.UnixMilli()))
+	copy(b[:6], ms[2:])
+	_, _ = rand.Read(b[6:])
+
+	n := new(big.Int).SetBytes(b[:])
+	out := make([]byte, 26)
+	mod := new(big.Int)
+	base := big.NewInt(32)
+	for i := len(out) - 1; i >= 0; i-- {
+		n.DivMod(n, base, mod)
+		out[i] = crockford[mod.Int64()]
+	}
+	return string(out)
+}
@@ -0,0 +1,252 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// introspectedTypes maps the information_schema data types to the column
+// types of the builder. Other types are left out of the generated schema.
+var introspectedTypes = map[string]string{
+	"text":                        "text",
+	"character varying":           "text",
+	"character":                   "text",
+	"smallint":                    "smallint",
+	"integer":                     "integer",
+	"bigint":                      "bigint",
+	"numeric":                     "numeric",
+	"real":                        "real",
+	"double precision":            "double",
+	"boolean":                     "boolean",
+	"date":                        "date",
+	"timestamp with time zone":    "timestamp",
+	"timestamp without time zone": "timestamp",
+	"time without time zone":      "time",
+	"json":                        "json",
+	"jsonb":                       "jsonb",
+	"uuid":                        "uuid",
+}
+
+// onDeleteActions maps pg_constraint.confdeltype to RelationDefinition.OnDelete.
+var onDeleteActions = map[string]string{"c": "cascade", "n": "set_null", "r": "restrict", "a": "no_action"}
+
+// IntrospectedColumn is a column of the table left out of the generated
+// schema, with the reason.
+type IntrospectedColumn struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+type tableIntrospection struct {
+	Columns   []ColumnDefinition   `json:"columns"`
+	Relations []RelationDefinition `json:"relations"`
+	Skipped   []IntrospectedColumn `json:"skipped"`
+}
+
+// introspectTable reads the columns of table and its single column foreign
+// keys to page tables ids. The foreign keys become one-to-many relations,
+// the other columns of a known type become columns; the id and row stamps
+// are implied by every page.
+func introspectTable(db sqlExecutor, table string) (*tableIntrospection, error) {
+	result := &tableIntrospection{
+		Columns:   []ColumnDefinition{},
+		Relations: []RelationDefinition{},
+		Skipped:   []IntrospectedColumn{},
+	}
+
+	rows, err := db.Query(`
+		SELECT a.attname, rel.relname, c.confdeltype
+		FROM pg_constraint c
+		JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = c.conkey[1]
+		JOIN pg_class rel ON rel.oid = c.confrelid
+		JOIN pg_attribute ra ON ra.attrelid = c.confrelid AND ra.attnum = c.confkey[1]
+		WHERE c.contype = 'f' AND c.conrelid = to_regclass($1)
+		  AND array_length(c.conkey, 1) = 1 AND ra.attname = 'id'
+		ORDER BY a.attnum
+	`, quoteIdent(table))
+	if err != nil {
+		return nil, err
+	}
+	fks := map[string]bool{}
+	for rows.Next() {
+		var column, target, action string
+		if err := rows.Scan(&column, &target, &action); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if fks[column] {
+			continue
+		}
+		fks[column] = true
+		result.Relations = append(result.Relations, RelationDefinition{
+			Type:       "one-to-many",
+			FromColumn: column,
+			ToTable:    target,
+			OnDelete:   onDeleteActions[action],
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(`
+		SELECT column_name, data_type FROM information_schema.columns
+		WHERE table_name = $1 AND table_schema = ANY(current_schemas(false))
+		ORDER BY ordinal_position
+	`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	implied := map[string]bool{"id": true, stampCreatedAt: true, stampUpdatedAt: true, stampCreatedBy: true, stampUpdatedBy: true}
+	hasID := false
+	for rows.Next() {
+		var name, dataType string
+		if err := rows.Scan(&name, &dataType); err != nil {
+			return nil, err
+		}
+		hasID = hasID || name == "id"
+		if implied[name] || fks[name] {
+			continue
+		}
+		switch typ, ok := introspectedTypes[dataType]; {
+		case validateIdent(name) != nil:
+			result.Skipped = append(result.Skipped, IntrospectedColumn{name, dataType, "invalid column name"})
+		case !ok:
+			result.Skipped = append(result.Skipped, IntrospectedColumn{name, dataType, "unsupported type"})
+		default:
+			result.Columns = append(result.Columns, ColumnDefinition{Name: name, Type: typ})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !hasID {
+		return nil, fmt.Errorf("%w: table %q has no id column", ErrInvalidIdentifier, table)
+	}
+	return result, nil
+}
+
+func RegisterBuilderImportTableRoutes(group *gin.RouterGroup, db *gorm.DB, cache *services.Cache) {
+	// POST /builder/import-table creates a page over an existing table: its
+	// columns and foreign keys become the draft schema, and deploy publishes
+	// it right away. The table is left as it is.
+	group.POST("/builder/import-table", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		var payload struct {
+			Table   string `json:"table"`
+			Name    string `json:"name"`
+			Storage string `json:"storage"`
+			Deploy  bool   `json:"deploy"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		if payload.Name == "" {
+			payload.Name = payload.Table
+		}
+
+		page := models.Page{Name: payload.Name, TableName: payload.Table, Storage: payload.Storage}
+		if payload.Table == "" {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", "table is required")
+			return
+		}
+		if err := checkPageOwnership(db, page); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_TABLE", err.Error())
+			return
+		}
+		var owners int64
+		if err := db.Model(&models.Page{}).Where("table_name = ? AND storage = ?", page.TableName, page.Storage).
+			Count(&owners).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+		if owners > 0 {
+			utils.Error(c, http.StatusConflict, "TABLE_IN_USE", fmt.Sprintf("table %q belongs to another page", page.TableName))
+			return
+		}
+
+		sqlDB, err := PageSQL(db, page)
+		if err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_STORAGE", err.Error())
+			return
+		}
+		exists, err := tableExists(sqlDB, page.TableName)
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+		if !exists {
+			utils.Error(c, http.StatusNotFound, "TABLE_NOT_FOUND", fmt.Sprintf("table %q does not exist", page.TableName))
+			return
+		}
+		introspection, err := introspectTable(sqlDB, page.TableName)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if isIdentifierError(err) {
+				status = http.StatusBadRequest
+			}
+			utils.Error(c, status, "INTROSPECTION_ERROR", err.Error())
+			return
+		}
+		page.SchemaColumns, _ = json.Marshal(introspection.Columns)
+		page.SchemaRelations, _ = json.Marshal(introspection.Relations)
+
+		if !checkPageSchemas(c, db, page) {
+			return
+		}
+		if page.Slug, err = uniquePageSlug(db, page.Name, ""); err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+		page.Status = models.PageStatusDraft
+		if err := db.Create(&page).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_CREATE_ERROR", err.Error())
+			return
+		}
+
+		var plan *deployPlan
+		if payload.Deploy {
+			if plan, err = deployPage(c, db, page, DeployMigration{}); err != nil {
+				utils.Error(c, deployErrorStatus(err), "DEPLOY_ERROR", err.Error())
+				return
+			}
+			invalidatePageCache(c, cache, page.ID)
+		}
+
+		var created models.Page
+		if err := db.Preload("Template").Preload("Tags.Category").First(&created, "id = ?", page.ID).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
+			return
+		}
+		recordSchemaChangelog(c, db, models.Page{}, created)
+		c.JSON(http.StatusCreated, gin.H{"data": created, "import": introspection, "deploy": plan, "success": true})
+	})
+}
@@ -18,10 +18,12 @@ package routes
 
 import (
 	"api-core-v2/models"
+	"api-core-v2/utils"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -30,163 +32,255 @@ import (
 func RegisterPublicPageItemRoutes(r gin.IRoutes, db *gorm.DB) {
 
 	r.GET("/page/:id/:itemId", func(c *gin.Context) {
-		pageID := c.Param("id")
+		db := utils.ReadDB(c, db)
 		itemID := c.Param("itemId")
 
-		var page models.Page
-		if err := db.
-			Preload("Template").
-			Preload("FicheTemplate").
-			First(&page, "id = ?", pageID).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Page introuvable"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		deps, err := parseDependencyOptions(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		page, raw, ok := loadDeployedPage(c, db, c.Param("id"))
+		if !ok {
 			return
 		}
 
-		var raw schemaRaw
-		if page.SchemaRelationsDeployed != nil {
-			_ = json.Unmarshal(page.SchemaRelationsDeployed, &raw.Relations)
+		pageDB, err := PageSQL(db, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		sqlDB, cancel := withQueryTimeout(c.Request.Context(), pageDB)
+		defer cancel()
+		item, err := loadItem(sqlDB, page, raw, itemID)
+		if isQueryTimeout(err) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item introuvable"})
+			return
 		}
-		if page.SchemaUiDeployed != nil {
-			_ = json.Unmarshal(page.SchemaUiDeployed, &raw.UI)
+		// The ETag is the one If-Match is checked against on writes: it is
+		// computed on the whole row.
+		etag := rowVersion(item)
+		if !applyItemConditions(c, db, page, item) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item introuvable"})
+			return
 		}
 
-		if !Bool(page.Deploy) || page.TableName == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Cette page ne contient pas de table déployée"})
+		c.Header("ETag", etag)
+		signFileColumns([]map[string]any{item}, fileColumns(parseColumns(page.SchemaColumnsDeployed)))
+		expand := parseExpand(c)
+		access, err := loadRelatedAccess(c, db, raw.Relations)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		applyRelationLabels(unguardedRelations(raw.Relations, access.guarded), expand, item)
+		access.rows(raw.Relations, expand, item)
+		dependencies := loadDependencies(sqlDB, raw.Relations, deps, expand, access.guarded)
+		access.dependencies(dependencies, raw.Relations, deps, expand)
+
+		c.Header("Vary", "Accept")
+		if wantsJSONAPI(c) {
+			doc := jsonAPIDocument(page.TableName, raw.Relations, []map[string]any{item}, true, gin.H{
+				"id":           page.ID,
+				"name":         page.Name,
+				"template":     page.Template,
+				"fiche":        page.FicheTemplate,
+				"schema":       raw.UI,
+				"relations":    raw.Relations,
+				"dependencies": dependencies,
+			})
+			c.Header("Content-Type", JSONAPIMediaType)
+			c.JSON(http.StatusOK, doc)
+			return
+		}
+
+		body := gin.H{
+			"id":        page.ID,
+			"name":      page.Name,
+			"template":  page.Template,
+			"fiche":  page.FicheTemplate,
+			"schema":    raw.UI,
+			"relations": raw.Relations,
+			"dependencies": dependencies,
+			"item":      item,
+		}
+		// ?comments=true adds the comments of the row, oldest first.
+		if c.Query("comments") == "true" {
+			comments := []models.Comment{}
+			if err := itemComments(db, page.ID, itemID).Preload("User", userSummary).Find(&comments).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			body["comments"] = comments
+		}
+		c.JSON(http.StatusOK, body)
+	})
 
-		sqlDB, _ := db.DB()
-		query := fmt.Sprintf(`SELECT * FROM %s WHERE id = $1`, quoteIdent(page.TableName))
-		row := sqlDB.QueryRow(query, itemID)
+	r.GET("/page/:id/:itemId/export.pdf", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		itemID := c.Param("itemId")
 
-		cols, _ := getColumns(sqlDB, page.TableName)
-		values := make([]interface{}, len(cols))
-		ptrs := make([]interface{}, len(cols))
-		for i := range cols {
-			ptrs[i] = &values[i]
+		page, raw, ok := loadDeployedPage(c, db, c.Param("id"))
+		if !ok {
+			return
 		}
 
-		if err := row.Scan(ptrs...); err != nil {
+		pageDB, err := PageSQL(db, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		sqlDB, cancel := withQueryTimeout(c.Request.Context(), pageDB)
+		defer cancel()
+		item, err := loadItem(sqlDB, page, raw, itemID)
+		if isQueryTimeout(err) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil || !applyItemConditions(c, db, page, item) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Item introuvable"})
 			return
 		}
 
-		item := make(map[string]any)
-		for i, col := range cols {
-			item[col] = values[i]
+		doc := renderFichePDF(page, raw, item)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, safeFilename(page.Name, itemID)))
+		c.Data(http.StatusOK, "application/pdf", doc.Bytes())
+	})
+}
+
+// rowVersionColumn, when a table has it, versions its rows for If-Match.
+const rowVersionColumn = "updated_at"
+
+// rowVersion is the ETag of a row loaded by loadItem: its updated_at, or a
+// hash of the row when the table has no such column.
+func rowVersion(item map[string]any) string {
+	if v, ok := item[rowVersionColumn]; ok && v != nil {
+		if t, ok := v.(time.Time); ok {
+			return `"` + t.UTC().Format(time.RFC3339Nano) + `"`
 		}
+		return `"` + fmt.Sprint(v) + `"`
+	}
+	body, _ := json.Marshal(item)
+	return utils.ETag(body)
+}
 
-		fkByTable := make(map[string]map[string]struct{})
-		for _, rel := range raw.Relations {
-			if rel.Type == "one-to-one" || rel.Type == "one-to-many" {
-				if fk, ok := item[rel.FromColumn]; ok && fk != nil {
-					idStr := fmt.Sprintf("%v", fk)
-					addFK(fkByTable, rel.ToTable, idStr)
-				}
-			}
+func loadDeployedPage(c *gin.Context, db *gorm.DB, pageID string) (models.Page, schemaRaw, bool) {
+	var page models.Page
+	var raw schemaRaw
+	if err := db.
+		Preload("Template").
+		Preload("FicheTemplate").
+		First(&page, "id = ?", pageID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Page introuvable"})
+			return page, raw, false
 		}
-		pivotData := make(map[string][]string)
-		for _, rel := range raw.Relations {
-			if rel.Type != "many-to-many" {
-				continue
-			}
-			pivot := pivotTableName(page.TableName, rel)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return page, raw, false
+	}
+	if !pageVisible(c, page.Status) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Page introuvable"})
+		return page, raw, false
+	}
 
-			q := fmt.Sprintf(`SELECT right_id FROM %s WHERE left_id = $1`, quoteIdent(pivot))
-			rs, err := sqlDB.Query(q, itemID)
-			if err != nil {
-				continue
-			}
-			var rid string
-			for rs.Next() {
-				rs.Scan(&rid)
-				pivotData[pivot] = append(pivotData[pivot], rid)
-				addFK(fkByTable, rel.ToTable, rid)
-			}
-			rs.Close()
-		}
-
-		objCache := batchLoadRelated(sqlDB, fkByTable)
-		for _, rel := range raw.Relations {
-			switch rel.Type {
-			case "one-to-one", "one-to-many":
-				if fk, ok := item[rel.FromColumn]; ok && fk != nil {
-					idStr := fmt.Sprintf("%v", fk)
-					key := rel.ToTable + ":" + idStr
-					if obj, ok := objCache[key]; ok {
-						item[rel.FromColumn] = obj
-					}
-				}
-
-			case "many-to-many":
-				pivot := pivotTableName(page.TableName, rel)
-				rightIDs := pivotData[pivot]
-				list := make([]any, 0)
-				for _, rid := range rightIDs {
-					key := rel.ToTable + ":" + rid
-					if obj, ok := objCache[key]; ok {
-						list = append(list, obj)
-					} else {
-						list = append(list, rid)
-					}
-				}
-				item[rel.FromColumn] = list
-			}
+	if page.SchemaRelationsDeployed != nil {
+		_ = json.Unmarshal(page.SchemaRelationsDeployed, &raw.Relations)
+	}
+	if page.SchemaUiDeployed != nil {
+		_ = json.Unmarshal(page.SchemaUiDeployed, &raw.UI)
+	}
+	if ui := rolloutUI(c, db, page); ui != nil {
+		_ = json.Unmarshal(ui, &raw.UI)
+	}
+	localizeUI(c, raw.UI)
+
+	if isProxyPage(page) {
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"error": "Cette page lit une source externe et est en lecture seule"})
+		return page, raw, false
+	}
+	if !Bool(page.Deploy) || page.TableName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cette page ne contient pas de table déployée"})
+		return page, raw, false
+	}
+
+	if err := checkPageTables(db, page, raw.Relations); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return page, raw, false
+	}
+	if itemID := c.Param("itemId"); itemID != "" {
+		if err := pageIDStrategy(page).validate(itemID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return page, raw, false
 		}
+	}
 
+	return page, raw, true
+}
 
-		dependencies := make(map[string]any)
-		loaded := make(map[string]bool)
+func loadItem(sqlDB sqlExecutor, page models.Page, raw schemaRaw, itemID string) (map[string]any, error) {
+	return loadItemBy(sqlDB, page, raw, "id", itemID)
+}
 
-		for _, rel := range raw.Relations {
-			if loaded[rel.ToTable] {
-				continue
-			}
-			loaded[rel.ToTable] = true
+// loadItemBy reads the item of page whose column holds value, such as the
+// row matching a natural key.
+func loadItemBy(sqlDB sqlExecutor, page models.Page, raw schemaRaw, column string, value any) (map[string]any, error) {
+	rows, err := loadRelatedRows(sqlDB, page.TableName, raw.Relations, func(q *dynamicQuery) error {
+		q.Write(" WHERE ").Ident(column).Write(" = ").Arg(value).Write(" LIMIT 1")
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	item := rows[0]
 
-			q := fmt.Sprintf(`SELECT * FROM %s`, quoteIdent(rel.ToTable))
-			rs, err := sqlDB.Query(q)
-			if err != nil {
-				continue
-			}
+	decryptRows(parseColumns(page.SchemaColumnsDeployed), item)
+	applyReadFunctions(parseFunctions(page.SchemaFunctionsDeployed), item)
 
-			cols, _ := rs.Columns()
-			var arr []map[string]any
-
-			for rs.Next() {
-				vals := make([]interface{}, len(cols))
-				ptrs := make([]interface{}, len(cols))
-				for i := range cols {
-					ptrs[i] = &vals[i]
-				}
-				if err := rs.Scan(ptrs...); err == nil {
-					row := make(map[string]any, len(cols))
-					for i, c := range cols {
-						row[c] = vals[i]
-					}
-					arr = append(arr, row)
-				}
-			}
+	return item, nil
+}
 
-			rs.Close()
-			dependencies[rel.FromColumn] = arr
+// loadRow reads the stored columns of a row, without resolving its
+// relations or applying read functions.
+func loadRow(db sqlExecutor, table, id string) (map[string]any, error) {
+	q := newQuery("SELECT * FROM ").Ident(table).Write(" WHERE id = ").Arg(id)
+	rows, err := db.Query(q.SQL(), q.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
 		}
+		return nil, sql.ErrNoRows
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range cols {
+		ptrs[i] = &values[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return nil, err
+	}
 
-		c.JSON(http.StatusOK, gin.H{
-			"id":        page.ID,
-			"name":      page.Name,
-			"template":  page.Template,
-			"fiche":  page.FicheTemplate,
-			"schema":    raw.UI,
-			"relations": raw.Relations,
-			"dependencies": dependencies,
-			"item":      item,
-		})
-	})
+	row := make(map[string]any, len(cols))
+	for i, col := range cols {
+		row[col] = values[i]
+	}
+	return row, nil
 }
 
 func addFK(m map[string]map[string]struct{}, table string, id string) {
@@ -196,11 +290,11 @@ func addFK(m map[string]map[string]struct{}, table string, id string) {
 	m[table][id] = struct{}{}
 }
 
-func getColumns(db *sql.DB, table string) ([]string, error) {
+func getColumns(db sqlExecutor, table string) ([]string, error) {
     q := `
         SELECT column_name 
         FROM information_schema.columns
-        WHERE table_name = $1
+        WHERE table_name = $1 AND table_schema = ANY(current_schemas(false))
         ORDER BY ordinal_position
     `
     rows, err := db.Query(q, table)
@@ -0,0 +1,161 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const JSONAPIMediaType = "application/vnd.api+json"
+
+// wantsJSONAPI reports whether the page endpoints should answer in JSON:API
+// format (Accept: application/vnd.api+json or ?format=jsonapi).
+func wantsJSONAPI(c *gin.Context) bool {
+	return c.Query("format") == "jsonapi" || strings.Contains(c.GetHeader("Accept"), JSONAPIMediaType)
+}
+
+// jsonAPIIncluded collects the related resources once each, in the order
+// they are first referenced.
+type jsonAPIIncluded struct {
+	seen      map[string]bool
+	resources []map[string]any
+}
+
+func jsonAPIID(v any) string {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+// link returns the resource identifier of a related value, which may be a
+// resolved row, an id + label pair or a bare id. Rows are added to included.
+func (inc *jsonAPIIncluded) link(typ string, value any) any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case map[string]any:
+		if v["id"] == nil {
+			return nil
+		}
+		id := jsonAPIID(v["id"])
+		if key := typ + ":" + id; !inc.seen[key] {
+			inc.seen[key] = true
+			inc.resources = append(inc.resources, gin.H{"type": typ, "id": id, "attributes": jsonAPIAttributes(v, nil)})
+		}
+		return gin.H{"type": typ, "id": id}
+	default:
+		return gin.H{"type": typ, "id": jsonAPIID(v)}
+	}
+}
+
+func jsonAPIAttributes(row map[string]any, relations []RelationDefinition) map[string]any {
+	attributes := make(map[string]any, len(row))
+	for k, v := range row {
+		if k != "id" && findRelation(relations, k) == nil {
+			attributes[k] = v
+		}
+	}
+	return attributes
+}
+
+func jsonAPIResource(typ string, row map[string]any, relations []RelationDefinition, inc *jsonAPIIncluded) map[string]any {
+	resource := gin.H{"type": typ, "id": jsonAPIID(row["id"]), "attributes": jsonAPIAttributes(row, relations)}
+
+	relationships := gin.H{}
+	for _, rel := range relations {
+		value, ok := row[rel.FromColumn]
+		if !ok {
+			continue
+		}
+		if rel.Type == "many-to-many" {
+			list, _ := value.([]any)
+			linkage := make([]any, 0, len(list))
+			for _, item := range list {
+				if l := inc.link(rel.ToTable, item); l != nil {
+					linkage = append(linkage, l)
+				}
+			}
+			relationships[rel.FromColumn] = gin.H{"data": linkage}
+			continue
+		}
+		relationships[rel.FromColumn] = gin.H{"data": inc.link(rel.ToTable, value)}
+	}
+	if len(relationships) > 0 {
+		resource["relationships"] = relationships
+	}
+	return resource
+}
+
+// jsonAPIDocument renders rows of table (a single row when one is true) as
+// a JSON:API document. meta carries the rest of the page payload.
+func jsonAPIDocument(table string, relations []RelationDefinition, rows []map[string]any, one bool, meta map[string]any) gin.H {
+	inc := &jsonAPIIncluded{seen: map[string]bool{}}
+
+	var data any
+	if one {
+		data = nil
+		if len(rows) > 0 {
+			data = jsonAPIResource(table, rows[0], relations, inc)
+		}
+	} else {
+		list := make([]any, 0, len(rows))
+		for _, row := range rows {
+			list = append(list, jsonAPIResource(table, row, relations, inc))
+		}
+		data = list
+	}
+
+	doc := gin.H{"data": data, "jsonapi": gin.H{"version": "1.0"}}
+	if len(inc.resources) > 0 {
+		doc["included"] = inc.resources
+	}
+	if len(meta) > 0 {
+		doc["meta"] = meta
+	}
+	return doc
+}
+
+// pageJSONAPIDocument converts a marshalled page payload: data becomes the
+// primary resources, everything else goes to meta.
+func pageJSONAPIDocument(body []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var payload map[string]any
+	if err := dec.Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	var envelope struct {
+		TableName string               `json:"tableName"`
+		Relations []RelationDefinition `json:"relations"`
+		Data      []map[string]any     `json:"data"`
+	}
+	dec = json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&envelope); err != nil {
+		return nil, err
+	}
+
+	delete(payload, "data")
+	return json.Marshal(jsonAPIDocument(envelope.TableName, envelope.Relations, envelope.Data, false, payload))
+}
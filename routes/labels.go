@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// expandSet lists the relations sent as full objects (?expand=owner,tags or
+// ?expand=*). Relations without labelColumns are always sent in full.
+type expandSet struct {
+	all     bool
+	columns map[string]bool
+}
+
+func parseExpand(c *gin.Context) expandSet {
+	set := expandSet{columns: map[string]bool{}}
+	for _, col := range strings.Split(c.Query("expand"), ",") {
+		switch col = strings.TrimSpace(col); col {
+		case "":
+		case "*", "all":
+			set.all = true
+		default:
+			set.columns[col] = true
+		}
+	}
+	return set
+}
+
+func (e expandSet) empty() bool {
+	return !e.all && len(e.columns) == 0
+}
+
+func (e expandSet) expands(rel RelationDefinition) bool {
+	return len(rel.LabelColumns) == 0 || e.all || e.columns[rel.FromColumn]
+}
+
+// relationLabel joins the non-empty label columns of a related row.
+func relationLabel(rel RelationDefinition, row map[string]any) string {
+	sep := rel.LabelSeparator
+	if sep == "" {
+		sep = " "
+	}
+	parts := make([]string, 0, len(rel.LabelColumns))
+	for _, col := range rel.LabelColumns {
+		if v, ok := row[col]; ok && v != nil {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			if s := fmt.Sprint(v); s != "" {
+				parts = append(parts, s)
+			}
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+func labelled(rel RelationDefinition, row map[string]any) map[string]any {
+	return map[string]any{"id": row["id"], "label": relationLabel(rel, row)}
+}
+
+// applyRelationLabels replaces the related objects of rows by id + label for
+// the relations that declare labelColumns and are not expanded. Unresolved
+// ids are left as they are.
+func applyRelationLabels(relations []RelationDefinition, expand expandSet, rows ...map[string]any) {
+	for _, rel := range relations {
+		if expand.expands(rel) {
+			continue
+		}
+		for _, row := range rows {
+			switch v := row[rel.FromColumn].(type) {
+			case map[string]any:
+				row[rel.FromColumn] = labelled(rel, v)
+			case []any:
+				list := make([]any, len(v))
+				for i, item := range v {
+					if obj, ok := item.(map[string]any); ok {
+						list[i] = labelled(rel, obj)
+					} else {
+						list[i] = item
+					}
+				}
+				row[rel.FromColumn] = list
+			}
+		}
+	}
+}
@@ -0,0 +1,190 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// i18nKey holds the locale variants of a UI element or menu: its fields
+// per locale, merged over the element when the locale is served, e.g.
+// {"label": "Nom", "i18n": {"en": {"label": "Name"}}}.
+const i18nKey = "i18n"
+
+// defaultLocale is the locale of the base fields of the schemas, from
+// PAGE_DEFAULT_LOCALE (fr by default).
+func defaultLocale() string {
+	if locale := os.Getenv("PAGE_DEFAULT_LOCALE"); locale != "" {
+		return strings.ToLower(locale)
+	}
+	return "fr"
+}
+
+// requestedLocales lists the locales asked for, by preference: ?locale,
+// then the Accept-Language tags by weight.
+func requestedLocales(c *gin.Context) []string {
+	var locales []string
+	if locale := strings.TrimSpace(c.Query("locale")); locale != "" {
+		locales = append(locales, strings.ToLower(locale))
+	}
+	type tag struct {
+		name   string
+		weight float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if name == "" || name == "*" {
+			continue
+		}
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if w, err := strconv.ParseFloat(q, 64); err == nil {
+				weight = w
+			}
+		}
+		if weight > 0 {
+			tags = append(tags, tag{strings.ToLower(name), weight})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].weight > tags[j].weight })
+	for _, t := range tags {
+		locales = append(locales, t.name)
+	}
+	return locales
+}
+
+// collectLocales adds the locales node has variants for to into.
+func collectLocales(node any, into map[string]bool) {
+	switch n := node.(type) {
+	case []any:
+		for _, v := range n {
+			collectLocales(v, into)
+		}
+	case map[string]any:
+		for key, v := range n {
+			if variants, ok := v.(map[string]any); ok && key == i18nKey {
+				for locale := range variants {
+					into[strings.ToLower(locale)] = true
+				}
+				continue
+			}
+			collectLocales(v, into)
+		}
+	}
+}
+
+// negotiateLocale picks the first requested locale available, by exact tag
+// then by language ("en-GB" serves "en"); the default locale otherwise.
+func negotiateLocale(requested []string, available map[string]bool) string {
+	for _, locale := range requested {
+		if available[locale] {
+			return locale
+		}
+		if lang, _, found := strings.Cut(locale, "-"); found && available[lang] {
+			return lang
+		}
+	}
+	return defaultLocale()
+}
+
+// localize merges the variant of locale over every object of node that has
+// some and drops the variants.
+func localize(node any, locale string) any {
+	switch n := node.(type) {
+	case []any:
+		for i, v := range n {
+			n[i] = localize(v, locale)
+		}
+	case map[string]any:
+		variants, _ := n[i18nKey].(map[string]any)
+		delete(n, i18nKey)
+		for key, v := range n {
+			n[key] = localize(v, locale)
+		}
+		for tag, fields := range variants {
+			if strings.ToLower(tag) != locale {
+				continue
+			}
+			if fields, ok := fields.(map[string]any); ok {
+				for key, v := range fields {
+					n[key] = v
+				}
+			}
+		}
+	}
+	return node
+}
+
+// localizedKeys are the parts of a page payload that carry labels.
+var localizedKeys = []string{"schema", "menus"}
+
+// applyLocale serves the schema and menus of a page payload in the locale
+// the request negotiates, reported as "locale" and Content-Language.
+func applyLocale(c *gin.Context, body []byte) ([]byte, error) {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, err
+	}
+	parts := map[string]any{}
+	available := map[string]bool{defaultLocale(): true}
+	for _, key := range localizedKeys {
+		raw, ok := envelope[key]
+		if !ok {
+			continue
+		}
+		var part any
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&part); err != nil {
+			return nil, err
+		}
+		parts[key] = part
+		collectLocales(part, available)
+	}
+
+	locale := negotiateLocale(requestedLocales(c), available)
+	for key, part := range parts {
+		localized, err := json.Marshal(localize(part, locale))
+		if err != nil {
+			return nil, err
+		}
+		envelope[key] = localized
+	}
+	envelope["locale"], _ = json.Marshal(locale)
+	c.Header("Content-Language", locale)
+	return json.Marshal(envelope)
+}
+
+// localizeUI serves a UI schema in the locale the request negotiates.
+func localizeUI(c *gin.Context, ui []map[string]any) {
+	available := map[string]bool{defaultLocale(): true}
+	for _, el := range ui {
+		collectLocales(map[string]any(el), available)
+	}
+	locale := negotiateLocale(requestedLocales(c), available)
+	for _, el := range ui {
+		localize(map[string]any(el), locale)
+	}
+}
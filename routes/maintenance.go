@@ -0,0 +1,46 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterMaintenanceRoutes must be registered before the maintenance
+// middleware so that read-only mode can be turned off again.
+func RegisterMaintenanceRoutes(group *gin.RouterGroup, maintenance *services.Maintenance) {
+	group.GET("/maintenance", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"data": maintenance.State(c.Request.Context()), "success": true})
+	})
+
+	group.PUT("/maintenance", func(c *gin.Context) {
+		var state services.MaintenanceState
+		if err := c.ShouldBindJSON(&state); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		if err := maintenance.Set(c.Request.Context(), state); err != nil {
+			utils.Error(c, http.StatusInternalServerError, "MAINTENANCE_UPDATE_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": maintenance.State(c.Request.Context()), "success": true})
+	})
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Masking modes of a column.
+const (
+	// MaskNull sends null instead of the value.
+	MaskNull = "null"
+	// MaskRedact replaces every character but spaces and separators.
+	MaskRedact = "redact"
+	// MaskLast keeps the last Keep characters (4 by default).
+	MaskLast = "last"
+	// MaskFirst keeps the first Keep characters (4 by default).
+	MaskFirst = "first"
+	// MaskEmail keeps the first character of the local part and the domain.
+	MaskEmail = "email"
+)
+
+const (
+	maskChar        = '•'
+	maskDefaultKeep = 4
+)
+
+// MaskRule masks a column for every user except admins and the users
+// matching Unless. Masking runs after the server conditions, which still see
+// the real values.
+type MaskRule struct {
+	Mode   string             `json:"mode"`
+	Keep   int                `json:"keep,omitempty"`
+	Unless *ConditionSubjects `json:"unless,omitempty"`
+}
+
+func (m MaskRule) keep() int {
+	if m.Keep > 0 {
+		return m.Keep
+	}
+	return maskDefaultKeep
+}
+
+// mask returns the masked form of v. Values other than strings and numbers
+// are nulled, as is every value under an unknown mode.
+func (m MaskRule) mask(v any) any {
+	if v == nil || m.Mode == MaskNull {
+		return nil
+	}
+	var s string
+	switch t := v.(type) {
+	case string:
+		s = t
+	case []byte:
+		s = string(t)
+	case json.Number:
+		s = t.String()
+	case int, int32, int64, float32, float64:
+		s = fmt.Sprint(t)
+	default:
+		return nil
+	}
+
+	runes := []rune(s)
+	hide := func(from, to int) {
+		for i := from; i < to; i++ {
+			if runes[i] != ' ' && runes[i] != '-' && runes[i] != '@' && runes[i] != '.' {
+				runes[i] = maskChar
+			}
+		}
+	}
+	switch m.Mode {
+	case MaskRedact:
+		hide(0, len(runes))
+	case MaskLast:
+		hide(0, max(len(runes)-m.keep(), 0))
+	case MaskFirst:
+		hide(min(m.keep(), len(runes)), len(runes))
+	case MaskEmail:
+		at := strings.LastIndex(s, "@")
+		if at < 0 {
+			hide(0, len(runes))
+			break
+		}
+		hide(min(1, at), len([]rune(s[:at])))
+	default:
+		return nil
+	}
+	return string(runes)
+}
+
+// columnMasks are the mask rules concerning one request, by column.
+type columnMasks map[string]MaskRule
+
+// pageMasks lists the mask rules declared on the columns of a page. File
+// columns are never masked: their key is needed to manage the object.
+func pageMasks(cols []ColumnDefinition) map[string]MaskRule {
+	var rules map[string]MaskRule
+	for _, col := range cols {
+		if col.Mask == nil || col.Type == ColumnTypeFile {
+			continue
+		}
+		if rules == nil {
+			rules = map[string]MaskRule{}
+		}
+		rules[col.Name] = *col.Mask
+	}
+	return rules
+}
+
+// newColumnMasks keeps the rules of the page that concern the user; nil
+// when none does.
+func newColumnMasks(c *gin.Context, db *gorm.DB, rules map[string]MaskRule) columnMasks {
+	if len(rules) == 0 {
+		return nil
+	}
+	subject := loadConditionSubject(c, db)
+	if subject.admin {
+		return nil
+	}
+	var masks columnMasks
+	for col, rule := range rules {
+		if rule.Unless != nil && subject.matches(rule.Unless) {
+			continue
+		}
+		if masks == nil {
+			masks = columnMasks{}
+		}
+		masks[col] = rule
+	}
+	return masks
+}
+
+// apply masks the columns of row in place.
+func (m columnMasks) apply(rows ...map[string]any) {
+	for _, row := range rows {
+		for col, rule := range m {
+			if v, ok := row[col]; ok {
+				row[col] = rule.mask(v)
+			}
+		}
+	}
+}
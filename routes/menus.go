@@ -0,0 +1,374 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidMenu = errors.New("invalid menu")
+
+// A menu entry points at a UI block through refId, which also identifies
+// the entry: a block appears at most once in the menu. Keys other than
+// name, order and refId are kept as they are.
+type menuEntry map[string]any
+
+func (m menuEntry) refID() string {
+	if v, ok := m["refId"]; ok && v != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return ""
+}
+
+func (m menuEntry) order() float64 {
+	switch v := m["order"].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	}
+	return 0
+}
+
+func parseMenu(raw datatypes.JSON) []menuEntry {
+	var entries []menuEntry
+	if raw != nil {
+		_ = json.Unmarshal(raw, &entries)
+	}
+	return entries
+}
+
+// sortMenu orders entries by their order key and renumbers them from 0.
+func sortMenu(entries []menuEntry) []menuEntry {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].order() < entries[j].order()
+	})
+	for i, entry := range entries {
+		entry["order"] = i
+	}
+	return entries
+}
+
+// uiBlockIDs collects the ids of the UI blocks, nested ones included.
+func uiBlockIDs(raw datatypes.JSON) map[string]bool {
+	ids := map[string]bool{}
+	var ui any
+	if raw != nil {
+		_ = json.Unmarshal(raw, &ui)
+	}
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string]any:
+			if id, ok := v["id"]; ok && id != nil {
+				ids[fmt.Sprintf("%v", id)] = true
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(ui)
+	return ids
+}
+
+// checkMenuRefs fails when an entry of menu has no refId, repeats one, or
+// points at a block missing from ui.
+func checkMenuRefs(ui, menu datatypes.JSON) error {
+	blocks := uiBlockIDs(ui)
+	seen := map[string]bool{}
+	var missing []string
+	for _, entry := range parseMenu(menu) {
+		ref := entry.refID()
+		switch {
+		case ref == "":
+			return fmt.Errorf("%w: entry %v has no refId", ErrInvalidMenu, entry["name"])
+		case seen[ref]:
+			return fmt.Errorf("%w: refId %q is used twice", ErrInvalidMenu, ref)
+		case !blocks[ref]:
+			missing = append(missing, ref)
+		}
+		seen[ref] = true
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: unknown UI blocks %s", ErrInvalidMenu, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkPageMenus validates the draft and deployed menus of page against
+// their UI.
+func checkPageMenus(page models.Page) error {
+	if err := checkMenuRefs(page.SchemaUi, page.SchemaMenuUi); err != nil {
+		return err
+	}
+	return checkMenuRefs(page.SchemaUiDeployed, page.SchemaMenuUiDeployed)
+}
+
+// touchesMenus reports whether a builder update changes a UI or a menu, and
+// returns the page as it will be once updated.
+func touchesMenus(before models.Page, updates map[string]any) (models.Page, bool) {
+	after := before
+	touched := false
+	for key, value := range updates {
+		var target *datatypes.JSON
+		switch key {
+		case "schemaUi", "schema_ui", "SchemaUi":
+			target = &after.SchemaUi
+		case "schemaMenuUi", "schema_menu_ui", "SchemaMenuUi":
+			target = &after.SchemaMenuUi
+		case "schemaUiDeployed", "schema_ui_deployed", "SchemaUiDeployed":
+			target = &after.SchemaUiDeployed
+		case "schemaMenuUiDeployed", "schema_menu_ui_deployed", "SchemaMenuUiDeployed":
+			target = &after.SchemaMenuUiDeployed
+		default:
+			continue
+		}
+		*target, _ = json.Marshal(value)
+		touched = true
+	}
+	return after, touched
+}
+
+// mergePageMenus is touchesMenus for the struct payload of the builder PUT,
+// where a nil field is left unchanged.
+func mergePageMenus(before, payload models.Page) (models.Page, bool) {
+	after := before
+	touched := false
+	for _, f := range []struct{ from, to *datatypes.JSON }{
+		{&payload.SchemaUi, &after.SchemaUi},
+		{&payload.SchemaMenuUi, &after.SchemaMenuUi},
+		{&payload.SchemaUiDeployed, &after.SchemaUiDeployed},
+		{&payload.SchemaMenuUiDeployed, &after.SchemaMenuUiDeployed},
+	} {
+		if *f.from != nil {
+			*f.to = *f.from
+			touched = true
+		}
+	}
+	return after, touched
+}
+
+// insertMenuEntry puts entry at position, or last when position is out of
+// range.
+func insertMenuEntry(entries []menuEntry, entry menuEntry, position int) []menuEntry {
+	if position < 0 || position > len(entries) {
+		position = len(entries)
+	}
+	entries = append(entries, nil)
+	copy(entries[position+1:], entries[position:])
+	entries[position] = entry
+	return entries
+}
+
+func findMenuEntry(entries []menuEntry, ref string) int {
+	for i, entry := range entries {
+		if entry.refID() == ref {
+			return i
+		}
+	}
+	return -1
+}
+
+// RegisterPageMenuRoutes manages the draft menu of a page (schemaMenuUi).
+// Entries are kept sorted, their order numbered from 0.
+func RegisterPageMenuRoutes(group *gin.RouterGroup, db *gorm.DB, cache *services.Cache) {
+	menus := group.Group("/builder/:id/menus")
+
+	load := func(c *gin.Context, db *gorm.DB) (models.Page, []menuEntry, bool) {
+		var page models.Page
+		if err := db.First(&page, "id = ?", c.Param("id")).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+				return page, nil, false
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return page, nil, false
+		}
+		return page, sortMenu(parseMenu(page.SchemaMenuUi)), true
+	}
+
+	save := func(c *gin.Context, db *gorm.DB, page models.Page, entries []menuEntry) bool {
+		raw, err := json.Marshal(sortMenu(entries))
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "ENCODE_ERROR", err.Error())
+			return false
+		}
+		if err := checkMenuRefs(page.SchemaUi, raw); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_MENU", err.Error())
+			return false
+		}
+		if err := db.Model(&models.Page{}).Where("id = ?", page.ID).
+			Update("schema_menu_ui", datatypes.JSON(raw)).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_UPDATE_ERROR", err.Error())
+			return false
+		}
+		invalidatePageCache(c, cache, page.ID)
+		after := page
+		after.SchemaMenuUi = raw
+		recordBuilderAudit(c, db, page, after)
+		return true
+	}
+
+	menus.GET("", func(c *gin.Context) {
+		_, entries, ok := load(c, db)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": entries, "success": true})
+	})
+
+	menus.POST("", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		var entry menuEntry
+		if err := c.ShouldBindJSON(&entry); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		page, entries, ok := load(c, db)
+		if !ok {
+			return
+		}
+		if ref := entry.refID(); ref != "" && findMenuEntry(entries, ref) >= 0 {
+			utils.Error(c, http.StatusConflict, "DUPLICATE_REF", fmt.Sprintf("Block %q is already in the menu", ref))
+			return
+		}
+
+		position := -1
+		if _, ok := entry["order"]; ok {
+			position = int(entry.order())
+		}
+		entries = insertMenuEntry(entries, entry, position)
+		for i, e := range entries {
+			e["order"] = i
+		}
+		if !save(c, db, page, entries) {
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"data": entries, "success": true})
+	})
+
+	menus.PUT("/:refId", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		var updates menuEntry
+		if err := c.ShouldBindJSON(&updates); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		page, entries, ok := load(c, db)
+		if !ok {
+			return
+		}
+		i := findMenuEntry(entries, c.Param("refId"))
+		if i < 0 {
+			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Menu entry not found")
+			return
+		}
+		if ref := updates.refID(); ref != "" && ref != c.Param("refId") && findMenuEntry(entries, ref) >= 0 {
+			utils.Error(c, http.StatusConflict, "DUPLICATE_REF", fmt.Sprintf("Block %q is already in the menu", ref))
+			return
+		}
+
+		entry := entries[i]
+		for k, v := range updates {
+			if k != "order" {
+				entry[k] = v
+			}
+		}
+		if _, ok := updates["order"]; ok {
+			entries = insertMenuEntry(append(entries[:i], entries[i+1:]...), entry, int(updates.order()))
+			for i, e := range entries {
+				e["order"] = i
+			}
+		}
+		if !save(c, db, page, entries) {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": entries, "success": true})
+	})
+
+	menus.DELETE("/:refId", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		page, entries, ok := load(c, db)
+		if !ok {
+			return
+		}
+		i := findMenuEntry(entries, c.Param("refId"))
+		if i < 0 {
+			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Menu entry not found")
+			return
+		}
+		entries = append(entries[:i], entries[i+1:]...)
+		if !save(c, db, page, entries) {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": entries, "success": true})
+	})
+
+	// POST /reorder takes every refId of the menu in the new order.
+	menus.POST("/reorder", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		var refs []string
+		if err := c.ShouldBindJSON(&refs); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		page, entries, ok := load(c, db)
+		if !ok {
+			return
+		}
+		if len(refs) != len(entries) {
+			utils.Error(c, http.StatusBadRequest, "INVALID_ORDER", "Every menu entry must be listed once")
+			return
+		}
+		positions := make(map[string]int, len(refs))
+		for i, ref := range refs {
+			if _, dup := positions[ref]; dup || findMenuEntry(entries, ref) < 0 {
+				utils.Error(c, http.StatusBadRequest, "INVALID_ORDER", "Every menu entry must be listed once")
+				return
+			}
+			positions[ref] = i
+		}
+		for _, entry := range entries {
+			entry["order"] = positions[entry.refID()]
+		}
+		if !save(c, db, page, entries) {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": sortMenu(entries), "success": true})
+	})
+}
@@ -18,7 +18,11 @@ package routes
 
 import (
 	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -32,131 +36,269 @@ func Bool(b *bool) bool {
 	return *b
 }
 
-func RegisterNavigationRoutes(r *gin.RouterGroup, db *gorm.DB) {
-	n := r.Group("/navigation")
+// navEntry is a navigation item as cached for GET /navigation, with what
+// decides who sees it.
+type navEntry struct {
+	models.NavigationItem
+	PageStatus string `json:"pageStatus,omitempty"`
+}
 
-	n.GET("", func(c *gin.Context) {
-		var items []models.NavigationItem
-		if err := db.Find(&items).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+// loadNavEntries reads the items of menu in tree order, with their tags and
+// the status of their page, from the cache when it has them.
+func loadNavEntries(c *gin.Context, db *gorm.DB, cache *services.Cache, menu string) ([]navEntry, error) {
+	key := services.NavigationMenuCacheKey(menu)
+	var entries []navEntry
+	if body, ok := cache.Get(c.Request.Context(), key); ok {
+		if err := json.Unmarshal(body, &entries); err == nil {
+			return entries, nil
 		}
-		tree := map[string]*models.NavItem{}
-		children := map[string][]*models.NavItem{}
-
-		for _, item := range items {
-			node := &models.NavItem{
-				Title:     item.Title,
-				Path:      item.Path,
-				Icon:      item.Icon,
-				Caption:   item.Caption,
-				Disabled:  Bool(item.Disabled),
-				DeepMatch: Bool(item.DeepMatch),
-			}
+	}
 
-			tree[item.ID] = node
+	var items []models.NavigationItem
+	if err := db.Preload("Tags").
+		Preload("Page", func(db *gorm.DB) *gorm.DB { return db.Select("id", "status") }).
+		Where("menu = ?", menu).Order("lft ASC").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	entries = make([]navEntry, 0, len(items))
+	for _, item := range items {
+		entry := navEntry{NavigationItem: item}
+		if item.Page != nil {
+			entry.PageStatus = item.Page.Status
+			entry.Page = nil
+		}
+		entries = append(entries, entry)
+	}
+	if body, err := json.Marshal(entries); err == nil {
+		cache.Set(c.Request.Context(), key, body)
+	}
+	return entries, nil
+}
 
-			if item.ParentID != nil {
-				children[*item.ParentID] = append(children[*item.ParentID], node)
-			}
+// sees reports whether subject may see entry, leaving its ancestors aside.
+func (subject conditionSubject) sees(entry navEntry) bool {
+	if subject.admin {
+		return true
+	}
+	if Bool(entry.IsAdmin) || entry.PageStatus != "" && entry.PageStatus != models.PageStatusPublished {
+		return false
+	}
+	if len(entry.Tags) == 0 {
+		return true
+	}
+	for _, tag := range entry.Tags {
+		if subject.tags[tag.ID] || subject.tags[tag.Name] || subject.groups[tag.Name] {
+			return true
+		}
+	}
+	return false
+}
+
+// navSections builds the menu of subject: a section per header item with
+// the subtrees under it. Sections left empty by the filtering are dropped.
+func navSections(entries []navEntry, subject conditionSubject) []models.NavSection {
+	byID := make(map[string]navEntry, len(entries))
+	children := map[string][]string{}
+	for _, entry := range entries {
+		byID[entry.ID] = entry
+		if entry.ParentID != nil {
+			children[*entry.ParentID] = append(children[*entry.ParentID], entry.ID)
 		}
+	}
 
-		for id, node := range tree {
-			for _, child := range children[id] {
-				node.Children = append(node.Children, *child)
+	visible := map[string]bool{}
+	var sees func(id string, depth int) bool
+	sees = func(id string, depth int) bool {
+		if v, ok := visible[id]; ok {
+			return v
+		}
+		entry := byID[id]
+		v := subject.sees(entry)
+		// The depth bound stops at a parent cycle.
+		if v && entry.ParentID != nil && depth < len(entries) {
+			if _, ok := byID[*entry.ParentID]; ok {
+				v = sees(*entry.ParentID, depth+1)
 			}
 		}
+		visible[id] = v
+		return v
+	}
 
-		var navSections []models.NavSection
-		for _, item := range items {
-			if item.IsHeader != nil && *item.IsHeader {
-				section := models.NavSection{
-					Subheader: item.Title,
-					Items:     []models.NavItem{},
-				}
-
-				for _, child := range children[item.ID] {
-					section.Items = append(section.Items, *child)
+	var build func(id string, depth int) models.NavItem
+	build = func(id string, depth int) models.NavItem {
+		entry := byID[id]
+		node := models.NavItem{
+			Title:     entry.Title,
+			Path:      entry.Path,
+			Href:      entry.Href,
+			Target:    entry.Target,
+			Rel:       entry.Rel,
+			Icon:      entry.Icon,
+			Caption:   entry.Caption,
+			Disabled:  Bool(entry.Disabled),
+			DeepMatch: Bool(entry.DeepMatch),
+		}
+		// A new tab must not get a handle on the menu's window.
+		if node.Target == "_blank" && node.Rel == "" {
+			node.Rel = "noopener noreferrer"
+		}
+		if depth < len(entries) {
+			for _, child := range children[id] {
+				if sees(child, 0) {
+					node.Children = append(node.Children, build(child, depth+1))
 				}
+			}
+		}
+		return node
+	}
 
-				navSections = append(navSections, section)
+	sections := []models.NavSection{}
+	for _, entry := range entries {
+		if !Bool(entry.IsHeader) || !sees(entry.ID, 0) {
+			continue
+		}
+		section := models.NavSection{Subheader: entry.Title, Items: []models.NavItem{}}
+		for _, child := range children[entry.ID] {
+			if sees(child, 0) {
+				section.Items = append(section.Items, build(child, 1))
 			}
 		}
+		if len(section.Items) == 0 && !subject.admin {
+			continue
+		}
+		sections = append(sections, section)
+	}
+	return sections
+}
 
-		c.JSON(http.StatusOK, navSections)
-	})
+func RegisterNavigationRoutes(r *gin.RouterGroup, db *gorm.DB, cache *services.Cache) {
+	n := r.Group("/navigation")
 
+	// GET /navigation returns the sections of ?menu, the default menu
+	// without one, the caller may see: admins get every item, the others
+	// neither the admin items, nor the items of unpublished pages, nor the
+	// tagged items none of their tags or groups match. Hidden items hide
+	// their subtree.
+	n.GET("", func(c *gin.Context) {
+		menu, ok := navMenu(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown menu"})
+			return
+		}
+		entries, err := loadNavEntries(c, db, cache, menu)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		body, err := json.Marshal(navSections(entries, loadConditionSubject(c, db)))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		utils.JSONBytesWithETag(c, http.StatusOK, body)
+	})
 
 	n.POST("", func(c *gin.Context) {
+		db := utils.DB(c, db)
 		var input models.NavigationItem
 		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-
-		tx := db.Begin()
-
-		if input.ParentID != nil {
-			var parent models.NavigationItem
-			if err := tx.First(&parent, "id = ?", *input.ParentID).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Parent not found"})
-				return
+		if err := checkNavLink(input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// An item goes in the menu of its parent; a root in its menu, ?menu or
+		// the default one.
+		if input.ParentID == nil {
+			if input.Menu == "" {
+				input.Menu = c.DefaultQuery("menu", navMenus()[0])
 			}
-
-			if err := tx.Model(&models.NavigationItem{}).
-				Where("rgt >= ?", parent.Rgt).
-				Update("rgt", gorm.Expr("rgt + 2")).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			if !isNavMenu(input.Menu) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown menu"})
 				return
 			}
+		}
 
-			if err := tx.Model(&models.NavigationItem{}).
-				Where("lft > ?", parent.Rgt).
-				Update("lft", gorm.Expr("lft + 2")).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Concurrent inserts would read the same parent rgt or max(rgt).
+			if err := lockNavigation(tx); err != nil {
+				return err
 			}
+			if input.ParentID != nil {
+				var parent models.NavigationItem
+				if err := tx.First(&parent, "id = ?", *input.ParentID).Error; err != nil {
+					if errors.Is(err, gorm.ErrRecordNotFound) {
+						return errParentNotFound
+					}
+					return err
+				}
 
-			input.Lft = parent.Rgt
-			input.Rgt = parent.Rgt + 1
-			input.Depth = parent.Depth + 1
+				if err := tx.Model(&models.NavigationItem{}).
+					Where("menu = ? AND rgt >= ?", parent.Menu, parent.Rgt).
+					Update("rgt", gorm.Expr("rgt + 2")).Error; err != nil {
+					return err
+				}
 
-		} else {
-			var maxRgt sql.NullInt64
-			if err := tx.Model(&models.NavigationItem{}).Select("MAX(rgt)").Scan(&maxRgt).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
+				if err := tx.Model(&models.NavigationItem{}).
+					Where("menu = ? AND lft > ?", parent.Menu, parent.Rgt).
+					Update("lft", gorm.Expr("lft + 2")).Error; err != nil {
+					return err
+				}
 
-			start := 1
-			if maxRgt.Valid {
-				start = int(maxRgt.Int64) + 1
+				input.Menu = parent.Menu
+				input.Lft = parent.Rgt
+				input.Rgt = parent.Rgt + 1
+				input.Depth = parent.Depth + 1
+
+			} else {
+				var maxRgt sql.NullInt64
+				if err := tx.Model(&models.NavigationItem{}).Where("menu = ?", input.Menu).Select("MAX(rgt)").Scan(&maxRgt).Error; err != nil {
+					return err
+				}
+
+				start := 1
+				if maxRgt.Valid {
+					start = int(maxRgt.Int64) + 1
+				}
+
+				input.Lft = start
+				input.Rgt = start + 1
+				input.Depth = 0
 			}
 
-			input.Lft = start
-			input.Rgt = start + 1
-			input.Depth = 0
+			return tx.Create(&input).Error
+		})
+		if errors.Is(err, errParentNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent not found"})
+			return
 		}
-
-		if err := tx.Create(&input).Error; err != nil {
-			tx.Rollback()
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		tx.Commit()
+		invalidateNavigationCache(c, cache)
 		c.JSON(http.StatusCreated, input)
 	})
 
 	n.DELETE("/:id", func(c *gin.Context) {
-		if err := db.Delete(&models.NavigationItem{}, "id = ?", c.Param("id")).Error; err != nil {
+		db := utils.DB(c, db)
+		strategy, ok := navDeleteStrategy(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "strategy must be cascade or reparent"})
+			return
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			_, err := deleteNavItems(c, tx, []string{c.Param("id")}, strategy)
+			return err
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		invalidateNavigationCache(c, cache)
 		c.Status(http.StatusNoContent)
 	})
 }
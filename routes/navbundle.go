@@ -0,0 +1,291 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// navBundleFormat is the version of the navigation export layout.
+const navBundleFormat = 1
+
+// Modes of a navigation import.
+const (
+	NavImportReplace = "replace"
+	NavImportAppend  = "append"
+)
+
+// A NavBundle is the portable tree of a menu, with pages referenced by slug
+// and tags by name so that it can be imported in another database.
+type NavBundle struct {
+	Format     int             `json:"format"`
+	Menu       string          `json:"menu,omitempty"`
+	Items      []NavBundleItem `json:"items"`
+	ExportedAt time.Time       `json:"exportedAt"`
+}
+
+// A NavBundleItem is an item of a NavBundle with its children, in order.
+type NavBundleItem struct {
+	Title     string            `json:"title"`
+	Icon      string            `json:"icon,omitempty"`
+	Path      string            `json:"path,omitempty"`
+	Href      string            `json:"href,omitempty"`
+	Target    string            `json:"target,omitempty"`
+	Rel       string            `json:"rel,omitempty"`
+	Caption   string            `json:"caption,omitempty"`
+	Disabled  bool              `json:"disabled,omitempty"`
+	DeepMatch bool              `json:"deepMatch,omitempty"`
+	IsHeader  bool              `json:"isHeader,omitempty"`
+	IsAdmin   bool              `json:"isAdmin,omitempty"`
+	Page      string            `json:"page,omitempty"`
+	Tags      []bundleTag       `json:"tags,omitempty"`
+	Extras    datatypes.JSONMap `json:"extras,omitempty"`
+	Children  []NavBundleItem   `json:"children,omitempty"`
+}
+
+// exportNavigation builds the bundle of menu. Items whose parent is missing
+// are exported as roots.
+func exportNavigation(db *gorm.DB, menu string) (NavBundle, error) {
+	bundle := NavBundle{Format: navBundleFormat, Menu: menu, Items: []NavBundleItem{}, ExportedAt: time.Now().UTC()}
+	var items []models.NavigationItem
+	if err := db.Preload("Page").Preload("Tags.Category").Where("menu = ?", menu).Order("lft ASC").Find(&items).Error; err != nil {
+		return bundle, err
+	}
+	known := make(map[string]bool, len(items))
+	for _, item := range items {
+		known[item.ID] = true
+	}
+	children := map[string][]models.NavigationItem{}
+	var roots []models.NavigationItem
+	for _, item := range items {
+		if item.ParentID != nil && known[*item.ParentID] && *item.ParentID != item.ID {
+			children[*item.ParentID] = append(children[*item.ParentID], item)
+		} else {
+			roots = append(roots, item)
+		}
+	}
+
+	seen := map[string]bool{}
+	var export func(items []models.NavigationItem) []NavBundleItem
+	export = func(items []models.NavigationItem) []NavBundleItem {
+		out := []NavBundleItem{}
+		for _, item := range items {
+			if seen[item.ID] {
+				continue
+			}
+			seen[item.ID] = true
+			entry := NavBundleItem{
+				Title:     item.Title,
+				Icon:      item.Icon,
+				Path:      item.Path,
+				Href:      item.Href,
+				Target:    item.Target,
+				Rel:       item.Rel,
+				Caption:   item.Caption,
+				Disabled:  Bool(item.Disabled),
+				DeepMatch: Bool(item.DeepMatch),
+				IsHeader:  Bool(item.IsHeader),
+				IsAdmin:   Bool(item.IsAdmin),
+				Extras:    item.Extras,
+				Children:  export(children[item.ID]),
+			}
+			if item.Page != nil {
+				entry.Page = item.Page.Slug
+			}
+			for _, tag := range item.Tags {
+				ref := bundleTag{Name: tag.Name}
+				if tag.Category != nil {
+					ref.Category = tag.Category.Name
+				}
+				entry.Tags = append(entry.Tags, ref)
+			}
+			out = append(out, entry)
+		}
+		return out
+	}
+	bundle.Items = export(roots)
+	return bundle, nil
+}
+
+// resolveNavPages maps the page references of items, slugs or names, to
+// page ids.
+func resolveNavPages(db *gorm.DB, items []NavBundleItem) (map[string]string, error) {
+	refs := []string{}
+	var collect func(items []NavBundleItem)
+	collect = func(items []NavBundleItem) {
+		for _, item := range items {
+			if item.Page != "" {
+				refs = append(refs, item.Page)
+			}
+			collect(item.Children)
+		}
+	}
+	collect(items)
+	pages := map[string]string{}
+	if len(refs) == 0 {
+		return pages, nil
+	}
+
+	var found []models.Page
+	if err := db.Select("id", "name", "slug").Where("slug IN ? OR name IN ?", refs, refs).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	// A slug wins over the name of another page.
+	for _, page := range found {
+		pages[page.Name] = page.ID
+	}
+	for _, page := range found {
+		if page.Slug != "" {
+			pages[page.Slug] = page.ID
+		}
+	}
+	for _, ref := range refs {
+		if _, ok := pages[ref]; !ok {
+			return nil, fmt.Errorf("%w: unknown page %q", ErrInvalidBundle, ref)
+		}
+	}
+	return pages, nil
+}
+
+// importNavigation creates the items of bundle in menu in tx, after its
+// roots in append mode or in place of its items, trashed, in replace mode.
+// Pages and tags are resolved before anything is written. It returns how
+// many items were created.
+func importNavigation(c *gin.Context, tx *gorm.DB, bundle NavBundle, menu, mode string) (int, error) {
+	if bundle.Format != navBundleFormat {
+		return 0, fmt.Errorf("%w: format %d is not supported", ErrInvalidBundle, bundle.Format)
+	}
+	pages, err := resolveNavPages(tx, bundle.Items)
+	if err != nil {
+		return 0, err
+	}
+	tags := map[*NavBundleItem][]models.Tag{}
+	var resolve func(items []NavBundleItem) error
+	resolve = func(items []NavBundleItem) error {
+		for i := range items {
+			item := &items[i]
+			if item.Title == "" {
+				return fmt.Errorf("%w: title is required", ErrInvalidBundle)
+			}
+			link := models.NavigationItem{Href: item.Href, Target: item.Target}
+			if err := checkNavLink(link); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+			}
+			resolved, err := resolveTags(tx, item.Tags)
+			if err != nil {
+				return err
+			}
+			for j := range resolved {
+				resolved[j].Category = nil
+			}
+			tags[item] = resolved
+			if err := resolve(item.Children); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := resolve(bundle.Items); err != nil {
+		return 0, err
+	}
+
+	if mode == NavImportReplace {
+		tree, err := loadNavTree(tx, menu, false)
+		if err != nil {
+			return 0, err
+		}
+		ids := make([]string, 0, len(tree.byID))
+		for id := range tree.byID {
+			ids = append(ids, id)
+		}
+		if _, err := deleteNavItems(c, tx, ids, NavDeleteCascade); err != nil {
+			return 0, err
+		}
+	}
+	tree, err := loadNavTree(tx, menu, false)
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	var create func(items []NavBundleItem, parent *navNode) error
+	create = func(items []NavBundleItem, parent *navNode) error {
+		for i := range items {
+			entry := &items[i]
+			disabled, deepMatch, isHeader, isAdmin := entry.Disabled, entry.DeepMatch, entry.IsHeader, entry.IsAdmin
+			item := models.NavigationItem{
+				Menu:      menu,
+				Title:     entry.Title,
+				Icon:      entry.Icon,
+				Path:      entry.Path,
+				Href:      entry.Href,
+				Target:    entry.Target,
+				Rel:       entry.Rel,
+				Caption:   entry.Caption,
+				Disabled:  &disabled,
+				DeepMatch: &deepMatch,
+				IsHeader:  &isHeader,
+				IsAdmin:   &isAdmin,
+				Extras:    entry.Extras,
+				Tags:      tags[entry],
+			}
+			if parent != nil {
+				item.ParentID = &parent.item.ID
+			}
+			if entry.Page != "" {
+				pageID := pages[entry.Page]
+				item.PageID = &pageID
+			}
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+			created++
+			node := &navNode{item: item}
+			tree.byID[item.ID] = node
+			tree.original[item.ID] = item
+			siblings := tree.siblings(parent)
+			*siblings = append(*siblings, node)
+			numberNavSiblings(*siblings)
+			if err := create(entry.Children, node); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := create(bundle.Items, nil); err != nil {
+		return 0, err
+	}
+	if _, err := tree.save(tx); err != nil {
+		return 0, err
+	}
+	return created, nil
+}
+
+// navImportMode reads ?mode, replace by default.
+func navImportMode(c *gin.Context) (string, bool) {
+	switch mode := c.DefaultQuery("mode", NavImportReplace); mode {
+	case NavImportReplace, NavImportAppend:
+		return mode, true
+	}
+	return "", false
+}
@@ -18,18 +18,32 @@ package routes
 
 import (
 	"api-core-v2/models"
+	"api-core-v2/services"
 	"api-core-v2/utils"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
 	"net/http"
+	"slices"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func RegisterNavRoutes(group *gin.RouterGroup, db *gorm.DB) {
+func RegisterNavRoutes(group *gin.RouterGroup, db *gorm.DB, cache *services.Cache) {
 	navigation := group.Group("/nav")
+	// GET /nav lists the items of ?menu in tree order, with the menus and
+	// the parents, pages and tags to pick from.
 	navigation.GET("", func(c *gin.Context) {
-		var items []models.NavigationItem
+		menu, ok := navMenu(c)
+		if !ok {
+			utils.Error(c, http.StatusBadRequest, "INVALID_MENU", "Unknown menu")
+			return
+		}
+		items := []models.NavigationItem{}
 		var pages []models.Page
 		var tags []models.Tag
 		var navDeps []struct {
@@ -37,16 +51,17 @@ func RegisterNavRoutes(group *gin.RouterGroup, db *gorm.DB) {
 			Title string `json:"title"`
 		}
 
-		if err := db.Preload("Parent").
-			Preload("Page").
-			Preload("Tags.Category").
-			Order("lft ASC").
-			Find(&items).Error; err != nil {
+		pagination := utils.ParsePagination(c, 0, listMaxPageSize)
+		meta, err := utils.FindPage(db.Where("menu = ?", menu).Order("lft ASC"), pagination, &items, func(q *gorm.DB) *gorm.DB {
+			return q.Preload("Parent").Preload("Page").Preload("Tags.Category")
+		})
+		if err != nil {
 			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_NAVIGATION_ERROR", err.Error())
 			return
 		}
 		if err := db.Model(&models.NavigationItem{}).
 			Select("id", "title").
+			Where("menu = ?", menu).
 			Order("lft ASC").
 			Find(&navDeps).Error; err != nil {
 			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_NAV_DEPS_ERROR", err.Error())
@@ -63,9 +78,11 @@ func RegisterNavRoutes(group *gin.RouterGroup, db *gorm.DB) {
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
+		utils.JSONWithETag(c, http.StatusOK, gin.H{
 			"data": items,
+			"meta": meta,
 			"dependencies": gin.H{
+				"menus":      navMenus(),
 				"navigation": navDeps,
 				"pages":      pages,
 				"tags":       tags,
@@ -76,65 +93,81 @@ func RegisterNavRoutes(group *gin.RouterGroup, db *gorm.DB) {
 
 
 	navigation.POST("", func(c *gin.Context) {
+		db := utils.DB(c, db)
 		var input models.NavigationItem
 		if err := c.ShouldBindJSON(&input); err != nil {
 			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
 			return
 		}
-
-		tx := db.Begin()
-		defer func() {
-			if r := recover(); r != nil {
-				tx.Rollback()
-			}
-		}()
-
-		if input.ParentID != nil {
-			var parent models.NavigationItem
-			if err := tx.First(&parent, "id = ?", *input.ParentID).Error; err != nil {
-				tx.Rollback()
-				utils.Error(c, http.StatusBadRequest, "PARENT_NOT_FOUND", "Parent not found")
-				return
-			}
-			if err := tx.Model(&models.NavigationItem{}).
-				Where("rgt >= ?", parent.Rgt).
-				Update("rgt", gorm.Expr("rgt + 2")).Error; err != nil {
-				tx.Rollback()
-				utils.Error(c, http.StatusInternalServerError, "UPDATE_RGT_ERROR", err.Error())
-				return
+		if err := checkNavLink(input); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_LINK", err.Error())
+			return
+		}
+		// An item goes in the menu of its parent; a root in its menu, ?menu or
+		// the default one.
+		if input.ParentID == nil {
+			if input.Menu == "" {
+				input.Menu = c.DefaultQuery("menu", navMenus()[0])
 			}
-			if err := tx.Model(&models.NavigationItem{}).
-				Where("lft > ?", parent.Rgt).
-				Update("lft", gorm.Expr("lft + 2")).Error; err != nil {
-				tx.Rollback()
-				utils.Error(c, http.StatusInternalServerError, "UPDATE_LFT_ERROR", err.Error())
+			if !isNavMenu(input.Menu) {
+				utils.Error(c, http.StatusBadRequest, "INVALID_MENU", "Unknown menu")
 				return
 			}
-			input.Lft = parent.Rgt
-			input.Rgt = parent.Rgt + 1
-			input.Depth = parent.Depth + 1
+		}
 
-		} else {
-			var maxRgt sql.NullInt64
-			if err := tx.Model(&models.NavigationItem{}).Select("MAX(rgt)").Scan(&maxRgt).Error; err != nil {
-				tx.Rollback()
-				utils.Error(c, http.StatusInternalServerError, "MAX_RGT_ERROR", err.Error())
-				return
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Concurrent inserts would read the same parent rgt or max(rgt).
+			if err := lockNavigation(tx); err != nil {
+				return err
 			}
-			start := 1
-			if maxRgt.Valid {
-				start = int(maxRgt.Int64) + 1
+			if input.ParentID != nil {
+				var parent models.NavigationItem
+				if err := tx.First(&parent, "id = ?", *input.ParentID).Error; err != nil {
+					if errors.Is(err, gorm.ErrRecordNotFound) {
+						return errParentNotFound
+					}
+					return err
+				}
+				if err := tx.Model(&models.NavigationItem{}).
+					Where("menu = ? AND rgt >= ?", parent.Menu, parent.Rgt).
+					Update("rgt", gorm.Expr("rgt + 2")).Error; err != nil {
+					return err
+				}
+				if err := tx.Model(&models.NavigationItem{}).
+					Where("menu = ? AND lft > ?", parent.Menu, parent.Rgt).
+					Update("lft", gorm.Expr("lft + 2")).Error; err != nil {
+					return err
+				}
+				input.Menu = parent.Menu
+				input.Lft = parent.Rgt
+				input.Rgt = parent.Rgt + 1
+				input.Depth = parent.Depth + 1
+
+			} else {
+				var maxRgt sql.NullInt64
+				if err := tx.Model(&models.NavigationItem{}).Where("menu = ?", input.Menu).Select("MAX(rgt)").Scan(&maxRgt).Error; err != nil {
+					return err
+				}
+				start := 1
+				if maxRgt.Valid {
+					start = int(maxRgt.Int64) + 1
+				}
+				input.Lft = start
+				input.Rgt = start + 1
+				input.Depth = 0
 			}
-			input.Lft = start
-			input.Rgt = start + 1
-			input.Depth = 0
-		}
-		if err := tx.Create(&input).Error; err != nil {
-			tx.Rollback()
+			return tx.Create(&input).Error
+		})
+		switch {
+		case errors.Is(err, errParentNotFound):
+			utils.Error(c, http.StatusBadRequest, "PARENT_NOT_FOUND", "Parent not found")
+			return
+		case err != nil:
 			utils.Error(c, http.StatusInternalServerError, "DB_CREATE_ERROR", err.Error())
 			return
 		}
-		tx.Commit()
+		invalidateNavigationCache(c, cache)
+
 		var created models.NavigationItem
 		if err := db.Preload("Parent").
 			Preload("Page").
@@ -151,6 +184,7 @@ func RegisterNavRoutes(group *gin.RouterGroup, db *gorm.DB) {
 	})
 
 	navigation.PUT("/:id", func(c *gin.Context) {
+		db := utils.DB(c, db)
 		id := c.Param("id")
 		var payload models.NavigationItem
 
@@ -158,6 +192,10 @@ func RegisterNavRoutes(group *gin.RouterGroup, db *gorm.DB) {
 			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
 			return
 		}
+		if err := checkNavLink(payload); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_LINK", err.Error())
+			return
+		}
 
 		var existing models.NavigationItem
 		if err := db.Preload("Tags").First(&existing, "id = ?", id).Error; err != nil {
@@ -167,7 +205,26 @@ func RegisterNavRoutes(group *gin.RouterGroup, db *gorm.DB) {
 
 		payload.ID = id
 
-		if err := db.Model(&existing).Omit("Tags").Updates(&payload).Error; err != nil {
+		// The tree columns are only written by the tree: a new parent moves
+		// the item with its subtree, at the end of its new siblings.
+		if navParentChanged(existing, payload) {
+			err := db.Transaction(func(tx *gorm.DB) error {
+				return moveNavItem(tx, id, payload.ParentID, -1)
+			})
+			switch {
+			case errors.Is(err, errParentNotFound):
+				utils.Error(c, http.StatusBadRequest, "PARENT_NOT_FOUND", "Parent not found")
+				return
+			case errors.Is(err, ErrInvalidParent):
+				utils.Error(c, http.StatusBadRequest, "INVALID_PARENT", err.Error())
+				return
+			case err != nil:
+				utils.Error(c, http.StatusInternalServerError, "DB_MOVE_ERROR", err.Error())
+				return
+			}
+		}
+
+		if err := db.Model(&existing).Omit("Tags", "Menu", "ParentID", "Lft", "Rgt", "Depth").Updates(&payload).Error; err != nil {
 			utils.Error(c, http.StatusInternalServerError, "DB_UPDATE_ERROR", err.Error())
 			return
 		}
@@ -193,6 +250,8 @@ func RegisterNavRoutes(group *gin.RouterGroup, db *gorm.DB) {
 			}
 		}
 
+		invalidateNavigationCache(c, cache)
+
 		var updated models.NavigationItem
 		if err := db.Preload("Parent").
 			Preload("Page").
@@ -206,6 +265,7 @@ func RegisterNavRoutes(group *gin.RouterGroup, db *gorm.DB) {
 	})
 
 	navigation.PATCH("/:id", func(c *gin.Context) {
+		db := utils.DB(c, db)
 		id := c.Param("id")
 		var payload models.NavigationItem
 
@@ -213,6 +273,10 @@ func RegisterNavRoutes(group *gin.RouterGroup, db *gorm.DB) {
 			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
 			return
 		}
+		if err := checkNavLink(payload); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_LINK", err.Error())
+			return
+		}
 
 		var existing models.NavigationItem
 		if err := db.Preload("Tags").First(&existing, "id = ?", id).Error; err != nil {
@@ -222,7 +286,26 @@ func RegisterNavRoutes(group *gin.RouterGroup, db *gorm.DB) {
 
 		payload.ID = id
 
-		if err := db.Model(&existing).Omit("Tags").Updates(&payload).Error; err != nil {
+		// The tree columns are only written by the tree: a new parent moves
+		// the item with its subtree, at the end of its new siblings.
+		if navParentChanged(existing, payload) {
+			err := db.Transaction(func(tx *gorm.DB) error {
+				return moveNavItem(tx, id, payload.ParentID, -1)
+			})
+			switch {
+			case errors.Is(err, errParentNotFound):
+				utils.Error(c, http.StatusBadRequest, "PARENT_NOT_FOUND", "Parent not found")
+				return
+			case errors.Is(err, ErrInvalidParent):
+				utils.Error(c, http.StatusBadRequest, "INVALID_PARENT", err.Error())
+				return
+			case err != nil:
+				utils.Error(c, http.StatusInternalServerError, "DB_MOVE_ERROR", err.Error())
+				return
+			}
+		}
+
+		if err := db.Model(&existing).Omit("Tags", "Menu", "ParentID", "Lft", "Rgt", "Depth").Updates(&payload).Error; err != nil {
 			utils.Error(c, http.StatusInternalServerError, "DB_UPDATE_ERROR", err.Error())
 			return
 		}
@@ -250,6 +333,8 @@ func RegisterNavRoutes(group *gin.RouterGroup, db *gorm.DB) {
 			}
 		}
 
+		invalidateNavigationCache(c, cache)
+
 		var updated models.NavigationItem
 		if err := db.Preload("Parent").
 			Preload("Page").
@@ -263,6 +348,7 @@ func RegisterNavRoutes(group *gin.RouterGroup, db *gorm.DB) {
 	})
 
 	navigation.PATCH("/patchMany", func(c *gin.Context) {
+		db := utils.DB(c, db)
 		var payload struct {
 			IDs     []string              `json:"ids"`
 			Updates models.NavigationItem `json:"updates"`
@@ -272,11 +358,19 @@ func RegisterNavRoutes(group *gin.RouterGroup, db *gorm.DB) {
 			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
 			return
 		}
+		if err := checkNavLink(payload.Updates); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_LINK", err.Error())
+			return
+		}
 
 		if len(payload.IDs) == 0 {
 			utils.Error(c, http.StatusBadRequest, "NO_IDS_PROVIDED", "No IDs provided")
 			return
 		}
+		if payload.Updates.ParentID != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_PARENT", "Items are moved one by one through POST /nav/:id/move")
+			return
+		}
 
 		if payload.Updates.Tags != nil {
 			for _, id := range payload.IDs {
@@ -302,12 +396,14 @@ func RegisterNavRoutes(group *gin.RouterGroup, db *gorm.DB) {
 
 		if err := db.Model(&models.NavigationItem{}).
 			Where("id IN ?", payload.IDs).
-			Omit("Tags").
+			Omit("Tags", "Menu", "ParentID", "Lft", "Rgt", "Depth").
 			Updates(&payload.Updates).Error; err != nil {
 			utils.Error(c, http.StatusInternalServerError, "DB_PATCH_MANY_ERROR", err.Error())
 			return
 		}
 
+		invalidateNavigationCache(c, cache)
+
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Navigation items updated successfully",
 			"count":   len(payload.IDs),
@@ -315,7 +411,15 @@ func RegisterNavRoutes(group *gin.RouterGroup, db *gorm.DB) {
 		})
 	})
 
+	// POST /nav/deleteMany deletes items like DELETE /nav/:id, with the same
+	// ?strategy.
 	navigation.POST("/deleteMany", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		strategy, ok := navDeleteStrategy(c)
+		if !ok {
+			utils.Error(c, http.StatusBadRequest, "INVALID_STRATEGY", "strategy must be cascade or reparent")
+			return
+		}
 		var ids []string
 		if err := c.ShouldBindJSON(&ids); err != nil {
 			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
@@ -325,28 +429,387 @@ func RegisterNavRoutes(group *gin.RouterGroup, db *gorm.DB) {
 			utils.Error(c, http.StatusBadRequest, "NO_IDS_PROVIDED", "No IDs provided")
 			return
 		}
-		if err := db.Delete(&models.NavigationItem{}, ids).Error; err != nil {
+		var deleted []string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			deleted, err = deleteNavItems(c, tx, ids, strategy)
+			return err
+		})
+		if err != nil {
 			utils.Error(c, http.StatusInternalServerError, "DB_DELETE_MANY_ERROR", err.Error())
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "Navigation items deleted successfully", "count": len(ids), "success": true})
+		invalidateNavigationCache(c, cache)
+		c.JSON(http.StatusOK, gin.H{"message": "Navigation items deleted successfully", "count": len(deleted), "deleted": deleted, "success": true})
 	})
 
-	navigation.DELETE("/:id", func(c *gin.Context) {
-		id := c.Param("id")
-		var item models.NavigationItem
-		if err := db.First(&item, "id = ?", id).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Navigation item not found")
+	// GET /nav/export downloads the tree of ?menu as a bundle, pages
+	// referenced by slug and tags by name.
+	navigation.GET("/export", func(c *gin.Context) {
+		menu, ok := navMenu(c)
+		if !ok {
+			utils.Error(c, http.StatusBadRequest, "INVALID_MENU", "Unknown menu")
+			return
+		}
+		bundle, err := exportNavigation(utils.ReadDB(c, db), menu)
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_NAVIGATION_ERROR", err.Error())
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="navigation-%s.json"`, menu))
+		c.JSON(http.StatusOK, gin.H{"data": bundle, "success": true})
+	})
+
+	// POST /nav/import recreates the tree of a menu from the JSON of the
+	// export, with or without its envelope: ?menu, or else the menu of the
+	// bundle. The current items are trashed and replaced, or kept with
+	// ?mode=append and the imported roots added after theirs.
+	navigation.POST("/import", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		mode, ok := navImportMode(c)
+		if !ok {
+			utils.Error(c, http.StatusBadRequest, "INVALID_MODE", "mode must be replace or append")
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		var envelope struct {
+			Data *NavBundle `json:"data"`
+		}
+		var bundle NavBundle
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		if envelope.Data != nil {
+			bundle = *envelope.Data
+		} else if err := json.Unmarshal(body, &bundle); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		menu := c.Query("menu")
+		if menu == "" {
+			menu = bundle.Menu
+		}
+		if menu == "" {
+			menu = navMenus()[0]
+		}
+		if !isNavMenu(menu) {
+			utils.Error(c, http.StatusBadRequest, "INVALID_MENU", "Unknown menu")
+			return
+		}
+
+		var created int
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			created, err = importNavigation(c, tx, bundle, menu, mode)
+			return err
+		})
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrInvalidBundle) {
+				status = http.StatusBadRequest
+			}
+			utils.Error(c, status, "INVALID_BUNDLE", err.Error())
+			return
+		}
+		invalidateNavigationCache(c, cache)
+		c.JSON(http.StatusOK, gin.H{"data": gin.H{"created": created, "menu": menu, "mode": mode}, "success": true})
+	})
+
+	// POST /nav/rebuild recomputes lft, rgt and depth of the tree of ?menu,
+	// of every menu without one, from the parent ids and orders, to repair a
+	// tree broken by concurrent writes. Items with a missing parent, or in a
+	// parent cycle, are moved to the root level.
+	navigation.POST("/rebuild", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		menus, ok := navMenusParam(c)
+		if !ok {
+			utils.Error(c, http.StatusBadRequest, "INVALID_MENU", "Unknown menu")
+			return
+		}
+		var total, updated int
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			total, updated, err = rebuildNavMenus(tx, menus)
+			return err
+		})
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_REBUILD_ERROR", err.Error())
+			return
+		}
+		invalidateNavigationCache(c, cache)
+		c.JSON(http.StatusOK, gin.H{"data": gin.H{"items": total, "updated": updated}, "success": true})
+	})
+
+	// GET /nav/validate checks the nested set of ?menu, of every menu
+	// without one, and lists the broken items. With ?fix=true the menus with
+	// issues are rebuilt like POST /nav/rebuild; the issues listed are those
+	// found before.
+	navigation.GET("/validate", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		menus, ok := navMenusParam(c)
+		if !ok {
+			utils.Error(c, http.StatusBadRequest, "INVALID_MENU", "Unknown menu")
+			return
+		}
+		query := db.Select("id", "title", "menu", "parent_id", "lft", "rgt", "depth")
+		if menus != nil {
+			query = query.Where("menu IN ?", menus)
+		}
+		var items []models.NavigationItem
+		if err := query.Find(&items).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_NAVIGATION_ERROR", err.Error())
+			return
+		}
+		byMenu := map[string][]models.NavigationItem{}
+		for _, item := range items {
+			byMenu[item.Menu] = append(byMenu[item.Menu], item)
+		}
+		issues := []NavIssue{}
+		broken := []string{}
+		for _, menu := range slices.Sorted(maps.Keys(byMenu)) {
+			if found := validateNavItems(byMenu[menu]); len(found) > 0 {
+				issues = append(issues, found...)
+				broken = append(broken, menu)
+			}
+		}
+
+		fixed := 0
+		if c.Query("fix") == "true" && len(broken) > 0 {
+			err := db.Transaction(func(tx *gorm.DB) error {
+				var err error
+				_, fixed, err = rebuildNavMenus(tx, broken)
+				return err
+			})
+			if err != nil {
+				utils.Error(c, http.StatusInternalServerError, "DB_REBUILD_ERROR", err.Error())
 				return
 			}
-			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			invalidateNavigationCache(c, cache)
+		}
+		c.JSON(http.StatusOK, gin.H{"data": gin.H{
+			"valid":  len(issues) == 0,
+			"issues": issues,
+			"menus":  broken,
+			"fixed":  fixed,
+		}, "success": true})
+	})
+
+	// PATCH /nav/reorder applies the drag and drop of the admin in ?menu: a
+	// list of {id, parentId, position}, placed at once and renumbered as a
+	// whole in one transaction.
+	navigation.PATCH("/reorder", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		menu, ok := navMenu(c)
+		if !ok {
+			utils.Error(c, http.StatusBadRequest, "INVALID_MENU", "Unknown menu")
+			return
+		}
+		var placements []NavPlacement
+		if err := c.ShouldBindJSON(&placements); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		if len(placements) == 0 {
+			utils.Error(c, http.StatusBadRequest, "NO_IDS_PROVIDED", "No items provided")
+			return
+		}
+		var updated int
+		err := db.Transaction(func(tx *gorm.DB) error {
+			tree, err := loadNavTree(tx, menu, false)
+			if err != nil {
+				return err
+			}
+			if err := tree.place(placements); err != nil {
+				return err
+			}
+			updated, err = tree.save(tx)
+			return err
+		})
+		switch {
+		case errors.Is(err, errItemNotFound), errors.Is(err, errInvalidPlacement):
+			utils.Error(c, http.StatusBadRequest, "INVALID_ORDER", err.Error())
+			return
+		case errors.Is(err, errParentNotFound):
+			utils.Error(c, http.StatusBadRequest, "PARENT_NOT_FOUND", err.Error())
+			return
+		case errors.Is(err, ErrInvalidParent):
+			utils.Error(c, http.StatusBadRequest, "INVALID_PARENT", err.Error())
+			return
+		case err != nil:
+			utils.Error(c, http.StatusInternalServerError, "DB_REORDER_ERROR", err.Error())
+			return
+		}
+		invalidateNavigationCache(c, cache)
+		c.JSON(http.StatusOK, gin.H{"data": gin.H{"updated": updated}, "success": true})
+	})
+
+	// GET /nav/:id/breadcrumb lists the ancestors of an item from the root
+	// down, the item itself last, found by lft/rgt containment.
+	navigation.GET("/:id/breadcrumb", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		if !uuidPattern.MatchString(c.Param("id")) {
+			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Navigation item not found")
+			return
+		}
+		var item models.NavigationItem
+		err := db.First(&item, "id = ?", c.Param("id")).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Navigation item not found")
+			return
+		case err != nil:
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_NAVIGATION_ERROR", err.Error())
+			return
+		}
+
+		items := []models.NavigationItem{}
+		if err := db.Where("menu = ? AND lft <= ? AND rgt >= ?", item.Menu, item.Lft, item.Rgt).
+			Order("lft ASC").
+			Find(&items).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_NAVIGATION_ERROR", err.Error())
+			return
+		}
+		utils.JSONWithETag(c, http.StatusOK, gin.H{"data": items, "success": true})
+	})
+
+	// POST /nav/:id/clone deep-copies an item with its subtree (titles,
+	// paths followed by suffix, "-copy" by default, pages and tags) under
+	// parentId (the root level when null), in the same menu, at position, at
+	// the end without one.
+	navigation.POST("/:id/clone", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		var payload struct {
+			ParentID *string `json:"parentId"`
+			Position *int    `json:"position"`
+			Suffix   *string `json:"suffix"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		position, suffix := -1, "-copy"
+		if payload.Position != nil {
+			position = *payload.Position
+		}
+		if payload.Suffix != nil {
+			suffix = *payload.Suffix
+		}
+
+		var cloneID string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			menu, err := navItemMenu(tx, c.Param("id"))
+			if err != nil {
+				return err
+			}
+			tree, err := loadNavTree(tx, menu, false)
+			if err != nil {
+				return err
+			}
+			node, ok := tree.byID[c.Param("id")]
+			if !ok {
+				return gorm.ErrRecordNotFound
+			}
+			var parent *navNode
+			if payload.ParentID != nil && *payload.ParentID != "" {
+				if parent, ok = tree.byID[*payload.ParentID]; !ok {
+					return errParentNotFound
+				}
+			}
+			copied, err := tree.clone(tx, node, parent, position, suffix)
+			if err != nil {
+				return err
+			}
+			cloneID = copied.item.ID
+			_, err = tree.save(tx)
+			return err
+		})
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Navigation item not found")
+			return
+		case errors.Is(err, errParentNotFound):
+			utils.Error(c, http.StatusBadRequest, "PARENT_NOT_FOUND", "Parent not found")
+			return
+		case err != nil:
+			utils.Error(c, http.StatusInternalServerError, "DB_CLONE_ERROR", err.Error())
 			return
 		}
-		if err := db.Delete(&item).Error; err != nil {
+		invalidateNavigationCache(c, cache)
+		respondNavItem(c, db, cloneID, http.StatusCreated)
+	})
+
+	// POST /nav/:id/move moves an item with its subtree under newParentId
+	// (the root level when null), in the same menu, at position among its new
+	// siblings, at the end without one. The orders of both sibling lists are
+	// renumbered.
+	navigation.POST("/:id/move", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		id := c.Param("id")
+		var payload struct {
+			NewParentID *string `json:"newParentId"`
+			Position    *int    `json:"position"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		position := -1
+		if payload.Position != nil {
+			position = *payload.Position
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return moveNavItem(tx, id, payload.NewParentID, position)
+		})
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Navigation item not found")
+			return
+		case errors.Is(err, errParentNotFound):
+			utils.Error(c, http.StatusBadRequest, "PARENT_NOT_FOUND", "Parent not found")
+			return
+		case errors.Is(err, ErrInvalidParent):
+			utils.Error(c, http.StatusBadRequest, "INVALID_PARENT", err.Error())
+			return
+		case err != nil:
+			utils.Error(c, http.StatusInternalServerError, "DB_MOVE_ERROR", err.Error())
+			return
+		}
+		invalidateNavigationCache(c, cache)
+		respondNavItem(c, db, id, http.StatusOK)
+	})
+
+	// DELETE /nav/:id deletes an item with its subtree, or with
+	// ?strategy=reparent hands its children to its parent at its place. The
+	// tree is compacted in the same transaction.
+	navigation.DELETE("/:id", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		id := c.Param("id")
+		strategy, ok := navDeleteStrategy(c)
+		if !ok {
+			utils.Error(c, http.StatusBadRequest, "INVALID_STRATEGY", "strategy must be cascade or reparent")
+			return
+		}
+		var deleted []string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			deleted, err = deleteNavItems(c, tx, []string{id}, strategy)
+			return err
+		})
+		if err != nil {
 			utils.Error(c, http.StatusInternalServerError, "DB_DELETE_ERROR", err.Error())
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "Navigation item deleted successfully", "id": id, "success": true})
+		if len(deleted) == 0 {
+			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Navigation item not found")
+			return
+		}
+		invalidateNavigationCache(c, cache)
+		c.JSON(http.StatusOK, gin.H{"message": "Navigation item deleted successfully", "id": id, "deleted": deleted, "success": true})
 	})
 }
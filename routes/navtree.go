@@ -0,0 +1,659 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidParent    = errors.New("an item cannot move under itself or its descendants")
+	errParentNotFound   = errors.New("parent not found")
+	errItemNotFound     = errors.New("navigation item not found")
+	errInvalidPlacement = errors.New("invalid placement")
+	ErrInvalidNavLink   = errors.New("invalid navigation link")
+)
+
+// navLinkTargets are the browsing contexts an external link may open in.
+var navLinkTargets = []string{"_self", "_blank", "_parent", "_top"}
+
+// checkNavLink validates the external link of item when it has one: an
+// absolute http(s) or mailto URL and a known target.
+func checkNavLink(item models.NavigationItem) error {
+	if item.Href != "" {
+		u, err := url.Parse(item.Href)
+		if err != nil || !slices.Contains([]string{"http", "https", "mailto"}, u.Scheme) ||
+			u.Scheme != "mailto" && u.Host == "" {
+			return fmt.Errorf("%w: href must be an absolute http(s) or mailto URL", ErrInvalidNavLink)
+		}
+	}
+	if item.Target != "" && !slices.Contains(navLinkTargets, item.Target) {
+		return fmt.Errorf("%w: target must be one of %s", ErrInvalidNavLink, strings.Join(navLinkTargets, ", "))
+	}
+	return nil
+}
+
+// navNode is a navigation item with its children, in order.
+type navNode struct {
+	item     models.NavigationItem
+	children []*navNode
+}
+
+// navTree is a menu of the navigation as read by loadNavTree, with the
+// values each item had so that saveNavTree only writes the ones that
+// changed.
+type navTree struct {
+	menu     string
+	roots    []*navNode
+	byID     map[string]*navNode
+	original map[string]models.NavigationItem
+}
+
+// navMenus lists the menus from NAV_MENUS, comma separated, by default
+// sidebar, topbar and footer. The first one is the default menu.
+func navMenus() []string {
+	var menus []string
+	for _, m := range strings.Split(os.Getenv("NAV_MENUS"), ",") {
+		if m = strings.ToLower(strings.TrimSpace(m)); m != "" {
+			menus = append(menus, m)
+		}
+	}
+	if len(menus) == 0 {
+		return []string{"sidebar", "topbar", "footer"}
+	}
+	return menus
+}
+
+func isNavMenu(menu string) bool {
+	return slices.Contains(navMenus(), menu)
+}
+
+// navMenu reads ?menu, the default menu without one, and reports whether
+// it is a known menu.
+func navMenu(c *gin.Context) (string, bool) {
+	menu := c.DefaultQuery("menu", navMenus()[0])
+	return menu, isNavMenu(menu)
+}
+
+// navMenusParam reads ?menu as a list of one menu, nil without one, and
+// reports whether it is a known menu.
+func navMenusParam(c *gin.Context) ([]string, bool) {
+	if c.Query("menu") == "" {
+		return nil, true
+	}
+	menu, ok := navMenu(c)
+	return []string{menu}, ok
+}
+
+// navItemMenu returns the menu of the item id, gorm.ErrRecordNotFound for
+// an unknown item.
+func navItemMenu(tx *gorm.DB, id string) (string, error) {
+	var item models.NavigationItem
+	if !uuidPattern.MatchString(id) {
+		return "", gorm.ErrRecordNotFound
+	}
+	if err := tx.Select("id", "menu").First(&item, "id = ?", id).Error; err != nil {
+		return "", err
+	}
+	return item.Menu, nil
+}
+
+// lockNavigation serializes the writers of the tree for the rest of tx;
+// readers are not blocked.
+func lockNavigation(tx *gorm.DB) error {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(&models.NavigationItem{}); err != nil {
+		return err
+	}
+	return tx.Exec(newQuery("LOCK TABLE ").Ident(stmt.Table).Write(" IN SHARE ROW EXCLUSIVE MODE").SQL()).Error
+}
+
+// loadNavTree locks the navigation and reads menu as a forest built from
+// the parent ids, each menu having its own lft/rgt space. Siblings are sorted by lft, or by order then lft with
+// byOrder. Items whose parent is missing, or caught in a parent cycle,
+// become roots, as do those whose parent is in another menu.
+func loadNavTree(tx *gorm.DB, menu string, byOrder bool) (*navTree, error) {
+	if err := lockNavigation(tx); err != nil {
+		return nil, err
+	}
+	var items []models.NavigationItem
+	if err := tx.Where("menu = ?", menu).Order("lft ASC").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	if byOrder {
+		sort.SliceStable(items, func(i, j int) bool { return items[i].Order < items[j].Order })
+	}
+	return newNavTree(menu, items), nil
+}
+
+// newNavTree builds the forest of menu from items, siblings in the order
+// of items, with the same fallbacks as loadNavTree.
+func newNavTree(menu string, items []models.NavigationItem) *navTree {
+	tree := &navTree{menu: menu, byID: map[string]*navNode{}, original: map[string]models.NavigationItem{}}
+	for _, item := range items {
+		tree.byID[item.ID] = &navNode{item: item}
+		tree.original[item.ID] = item
+	}
+	reached := map[string]bool{}
+	var reach func(n *navNode)
+	reach = func(n *navNode) {
+		reached[n.item.ID] = true
+		for _, child := range n.children {
+			reach(child)
+		}
+	}
+	for _, item := range items {
+		node := tree.byID[item.ID]
+		if item.ParentID != nil {
+			if parent, ok := tree.byID[*item.ParentID]; ok && *item.ParentID != item.ID {
+				parent.children = append(parent.children, node)
+				continue
+			}
+		}
+		node.item.ParentID = nil
+		tree.roots = append(tree.roots, node)
+	}
+	for _, root := range tree.roots {
+		reach(root)
+	}
+	// What the roots do not reach hangs from a cycle: its first item in
+	// order is made a root, which breaks it.
+	for _, item := range items {
+		if reached[item.ID] {
+			continue
+		}
+		node := tree.byID[item.ID]
+		parent := tree.byID[*node.item.ParentID]
+		parent.children = removeNavNode(parent.children, node)
+		node.item.ParentID = nil
+		tree.roots = append(tree.roots, node)
+		reach(node)
+	}
+	return tree
+}
+
+func removeNavNode(nodes []*navNode, node *navNode) []*navNode {
+	for i, n := range nodes {
+		if n == node {
+			return append(nodes[:i:i], nodes[i+1:]...)
+		}
+	}
+	return nodes
+}
+
+// contains tells whether id is node or one of its descendants.
+func (n *navNode) contains(id string) bool {
+	if n.item.ID == id {
+		return true
+	}
+	for _, child := range n.children {
+		if child.contains(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// siblings returns the children of parent, the roots for nil.
+func (t *navTree) siblings(parent *navNode) *[]*navNode {
+	if parent == nil {
+		return &t.roots
+	}
+	return &parent.children
+}
+
+// move detaches node and inserts it among the children of parent (the
+// roots for nil) at position, at the end when out of range.
+func (t *navTree) move(node, parent *navNode, position int) error {
+	if parent != nil && node.contains(parent.item.ID) {
+		return ErrInvalidParent
+	}
+	var from *navNode
+	if node.item.ParentID != nil {
+		from = t.byID[*node.item.ParentID]
+	}
+	old := t.siblings(from)
+	*old = removeNavNode(*old, node)
+
+	to := t.siblings(parent)
+	if position < 0 || position > len(*to) {
+		position = len(*to)
+	}
+	*to = append((*to)[:position], append([]*navNode{node}, (*to)[position:]...)...)
+	node.item.ParentID = nil
+	if parent != nil {
+		node.item.ParentID = &parent.item.ID
+	}
+	numberNavSiblings(*old)
+	numberNavSiblings(*to)
+	return nil
+}
+
+// moveNavItem moves the item id with its subtree under parentID (the root
+// level when nil or empty), in the same menu, at position among its new
+// siblings, and saves the tree.
+func moveNavItem(tx *gorm.DB, id string, parentID *string, position int) error {
+	menu, err := navItemMenu(tx, id)
+	if err != nil {
+		return err
+	}
+	tree, err := loadNavTree(tx, menu, false)
+	if err != nil {
+		return err
+	}
+	node, ok := tree.byID[id]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	var parent *navNode
+	if parentID != nil && *parentID != "" {
+		if parent, ok = tree.byID[*parentID]; !ok {
+			return errParentNotFound
+		}
+	}
+	if err := tree.move(node, parent, position); err != nil {
+		return err
+	}
+	_, err = tree.save(tx)
+	return err
+}
+
+// restore places the item id, just put back with no bounds, last among
+// the children of parentID, or last among the roots when that parent is
+// not in the menu.
+func (t *navTree) restore(id string, parentID *string) error {
+	node, ok := t.byID[id]
+	if !ok {
+		return errItemNotFound
+	}
+	var parent *navNode
+	if parentID != nil {
+		parent = t.byID[*parentID]
+	}
+	return t.move(node, parent, -1)
+}
+
+// navParentChanged reports whether an update payload names another parent
+// than the item's: an empty id stands for the root level, a missing one for
+// no change.
+func navParentChanged(item, payload models.NavigationItem) bool {
+	if payload.ParentID == nil {
+		return false
+	}
+	if item.ParentID == nil {
+		return *payload.ParentID != ""
+	}
+	return *payload.ParentID != *item.ParentID
+}
+
+// NavPlacement puts an item under ParentID (the root level when nil) at
+// Position among its siblings.
+type NavPlacement struct {
+	ID       string  `json:"id"`
+	ParentID *string `json:"parentId"`
+	Position int     `json:"position"`
+}
+
+// place applies placements at once: the items listed take their positions
+// in their new sibling lists and the others keep their relative order in
+// the remaining slots. The resulting tree must have no parent cycle.
+func (t *navTree) place(placements []NavPlacement) error {
+	parentKey := func(id *string) string {
+		if id == nil {
+			return ""
+		}
+		return *id
+	}
+	touched := map[string]bool{}
+	positions := map[string]int{}
+	for _, p := range placements {
+		node, ok := t.byID[p.ID]
+		if !ok {
+			return fmt.Errorf("%w: %q", errItemNotFound, p.ID)
+		}
+		if _, dup := positions[p.ID]; dup {
+			return fmt.Errorf("%w: %q is listed twice", errInvalidPlacement, p.ID)
+		}
+		if p.ParentID != nil && *p.ParentID == "" {
+			p.ParentID = nil
+		}
+		if p.ParentID != nil {
+			if _, ok := t.byID[*p.ParentID]; !ok {
+				return fmt.Errorf("%w: %q", errParentNotFound, *p.ParentID)
+			}
+		}
+		positions[p.ID] = p.Position
+		touched[parentKey(node.item.ParentID)] = true
+		touched[parentKey(p.ParentID)] = true
+		node.item.ParentID = p.ParentID
+	}
+	for id := range positions {
+		steps := 0
+		for at := t.byID[id]; at.item.ParentID != nil; at = t.byID[*at.item.ParentID] {
+			if steps++; steps > len(t.byID) {
+				return ErrInvalidParent
+			}
+		}
+	}
+
+	// Members of the touched lists, in their current order.
+	members := map[string][]*navNode{}
+	var collect func(nodes []*navNode)
+	collect = func(nodes []*navNode) {
+		for _, n := range nodes {
+			if key := parentKey(n.item.ParentID); touched[key] {
+				members[key] = append(members[key], n)
+			}
+			collect(n.children)
+		}
+	}
+	collect(t.roots)
+
+	for key := range touched {
+		var listed, others []*navNode
+		for _, n := range members[key] {
+			if _, ok := positions[n.item.ID]; ok {
+				listed = append(listed, n)
+			} else {
+				others = append(others, n)
+			}
+		}
+		sort.SliceStable(listed, func(i, j int) bool { return positions[listed[i].item.ID] < positions[listed[j].item.ID] })
+		list := make([]*navNode, 0, len(listed)+len(others))
+		for len(listed) > 0 || len(others) > 0 {
+			if len(listed) > 0 && (len(others) == 0 || positions[listed[0].item.ID] <= len(list)) {
+				list, listed = append(list, listed[0]), listed[1:]
+			} else {
+				list, others = append(list, others[0]), others[1:]
+			}
+		}
+		numberNavSiblings(list)
+		if key == "" {
+			t.roots = list
+		} else {
+			t.byID[key].children = list
+		}
+	}
+	return nil
+}
+
+// clone deep-copies the subtree of node in tx, the paths suffixed with
+// suffix, and inserts the copy among the children of parent (the roots for
+// nil) at position, at the end when out of range. It returns the copy.
+func (t *navTree) clone(tx *gorm.DB, node, parent *navNode, position int, suffix string) (*navNode, error) {
+	var ids []string
+	var walk func(n *navNode)
+	walk = func(n *navNode) {
+		ids = append(ids, n.item.ID)
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(node)
+	var items []models.NavigationItem
+	if err := tx.Preload("Tags").Find(&items, "id IN ?", ids).Error; err != nil {
+		return nil, err
+	}
+	tags := make(map[string][]models.Tag, len(items))
+	for _, item := range items {
+		tags[item.ID] = item.Tags
+	}
+
+	var copyNode func(n *navNode, parentID *string) (*navNode, error)
+	copyNode = func(n *navNode, parentID *string) (*navNode, error) {
+		item := n.item
+		item.ID = ""
+		item.ParentID = parentID
+		item.Parent, item.Page = nil, nil
+		item.Tags = tags[n.item.ID]
+		item.CreatedAt, item.UpdatedAt = time.Time{}, time.Time{}
+		if item.Path != "" {
+			item.Path += suffix
+		}
+		if err := tx.Create(&item).Error; err != nil {
+			return nil, err
+		}
+		copied := &navNode{item: item}
+		t.byID[item.ID] = copied
+		t.original[item.ID] = item
+		for _, child := range n.children {
+			c, err := copyNode(child, &copied.item.ID)
+			if err != nil {
+				return nil, err
+			}
+			copied.children = append(copied.children, c)
+		}
+		return copied, nil
+	}
+	var parentID *string
+	if parent != nil {
+		parentID = &parent.item.ID
+	}
+	copied, err := copyNode(node, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	to := t.siblings(parent)
+	if position < 0 || position > len(*to) {
+		position = len(*to)
+	}
+	*to = slices.Insert(*to, position, copied)
+	numberNavSiblings(*to)
+	return copied, nil
+}
+
+// Strategies of a navigation delete for the children of the item.
+const (
+	NavDeleteCascade  = "cascade"
+	NavDeleteReparent = "reparent"
+)
+
+// remove takes node out of the tree, with its subtree for cascade; for
+// reparent its children take its place under its parent. It returns the
+// ids removed.
+func (t *navTree) remove(node *navNode, strategy string) []string {
+	var parent *navNode
+	if node.item.ParentID != nil {
+		parent = t.byID[*node.item.ParentID]
+	}
+	siblings := t.siblings(parent)
+	i := slices.Index(*siblings, node)
+	removed := []string{node.item.ID}
+	if strategy == NavDeleteReparent {
+		for _, child := range node.children {
+			child.item.ParentID = node.item.ParentID
+		}
+		*siblings = slices.Concat((*siblings)[:i], node.children, (*siblings)[i+1:])
+	} else {
+		*siblings = slices.Delete(*siblings, i, i+1)
+		var walk func(n *navNode)
+		walk = func(n *navNode) {
+			for _, child := range n.children {
+				removed = append(removed, child.item.ID)
+				walk(child)
+			}
+		}
+		walk(node)
+	}
+	numberNavSiblings(*siblings)
+	for _, id := range removed {
+		delete(t.byID, id)
+	}
+	return removed
+}
+
+// deleteNavItems removes the items of ids from the navigation in tx, per
+// strategy, trashing each, and compacts the menus they were in. Unknown
+// ids, and those already removed with an ancestor, are skipped. It returns
+// the ids deleted.
+func deleteNavItems(c *gin.Context, tx *gorm.DB, ids []string, strategy string) ([]string, error) {
+	ids = slices.DeleteFunc(slices.Clone(ids), func(id string) bool { return !uuidPattern.MatchString(id) })
+	var menus []string
+	if err := tx.Model(&models.NavigationItem{}).Where("id IN ?", ids).Distinct().Pluck("menu", &menus).Error; err != nil {
+		return nil, err
+	}
+	removed := []string{}
+	var trees []*navTree
+	for _, menu := range menus {
+		tree, err := loadNavTree(tx, menu, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if node, ok := tree.byID[id]; ok {
+				removed = append(removed, tree.remove(node, strategy)...)
+			}
+		}
+		trees = append(trees, tree)
+	}
+	if len(removed) == 0 {
+		return removed, nil
+	}
+
+	var items []models.NavigationItem
+	if err := tx.Preload("Tags").Find(&items, "id IN ?", removed).Error; err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if err := trashRecord(c, tx, services.TrashKindNavigationItem, item); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Delete(&models.NavigationItem{}, removed).Error; err != nil {
+		return nil, err
+	}
+	for _, tree := range trees {
+		if _, err := tree.save(tx); err != nil {
+			return nil, err
+		}
+	}
+	return removed, nil
+}
+
+// navDeleteStrategy reads ?strategy, cascade by default.
+func navDeleteStrategy(c *gin.Context) (string, bool) {
+	switch strategy := c.DefaultQuery("strategy", NavDeleteCascade); strategy {
+	case NavDeleteCascade, NavDeleteReparent:
+		return strategy, true
+	}
+	return "", false
+}
+
+// numberNavSiblings sets the order of siblings to their position.
+func numberNavSiblings(nodes []*navNode) {
+	for i, n := range nodes {
+		n.item.Order = i
+	}
+}
+
+// number recomputes lft, rgt and depth depth-first, from 1.
+func (t *navTree) number() {
+	next := 1
+	var walk func(nodes []*navNode, depth int)
+	walk = func(nodes []*navNode, depth int) {
+		for _, n := range nodes {
+			n.item.Lft, n.item.Depth = next, depth
+			next++
+			walk(n.children, depth+1)
+			n.item.Rgt = next
+			next++
+		}
+	}
+	walk(t.roots, 0)
+}
+
+// save numbers the tree and writes the items whose position changed. It
+// returns how many were written.
+func (t *navTree) save(tx *gorm.DB) (int, error) {
+	t.number()
+	written := 0
+	for id, node := range t.byID {
+		old, item := t.original[id], node.item
+		if old.Lft == item.Lft && old.Rgt == item.Rgt && old.Depth == item.Depth &&
+			old.Order == item.Order && samePtr(old.ParentID, item.ParentID) {
+			continue
+		}
+		if err := tx.Model(&models.NavigationItem{}).Where("id = ?", id).UpdateColumns(map[string]any{
+			"parent_id": item.ParentID,
+			"lft":       item.Lft,
+			"rgt":       item.Rgt,
+			"depth":     item.Depth,
+			"order":     item.Order,
+		}).Error; err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
+
+// rebuildNavMenus renumbers menus in tx, every menu holding items when
+// menus is empty, from the parent ids and orders. It returns how many items
+// were read and how many written.
+func rebuildNavMenus(tx *gorm.DB, menus []string) (int, int, error) {
+	if len(menus) == 0 {
+		if err := tx.Model(&models.NavigationItem{}).Distinct().Pluck("menu", &menus).Error; err != nil {
+			return 0, 0, err
+		}
+	}
+	total, updated := 0, 0
+	for _, menu := range menus {
+		tree, err := loadNavTree(tx, menu, true)
+		if err != nil {
+			return total, updated, err
+		}
+		saved, err := tree.save(tx)
+		if err != nil {
+			return total, updated, err
+		}
+		total += len(tree.byID)
+		updated += saved
+	}
+	return total, updated, nil
+}
+
+func samePtr(a, b *string) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+// respondNavItem sends a navigation item with its parent, page and tags.
+func respondNavItem(c *gin.Context, db *gorm.DB, id string, status int) {
+	var item models.NavigationItem
+	if err := db.Preload("Parent").
+		Preload("Page").
+		Preload("Tags.Category").
+		First(&item, "id = ?", id).Error; err != nil {
+		utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
+		return
+	}
+	c.JSON(status, gin.H{"data": item, "success": true})
+}
@@ -0,0 +1,285 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// navFixture builds the items of an outline such as "a(b,c),d", numbered
+// as a saved tree: lft, rgt, depth and order set, in lft order.
+func navFixture(t *testing.T, outline string) []models.NavigationItem {
+	t.Helper()
+	var items []models.NavigationItem
+	pos := 0
+	var parse func(parentID *string)
+	parse = func(parentID *string) {
+		for pos < len(outline) {
+			start := pos
+			for pos < len(outline) && !strings.ContainsRune("(),", rune(outline[pos])) {
+				pos++
+			}
+			id := outline[start:pos]
+			items = append(items, models.NavigationItem{ID: id, Title: strings.ToUpper(id), Menu: "sidebar", ParentID: parentID})
+			if pos < len(outline) && outline[pos] == '(' {
+				pos++
+				parse(&id)
+				pos++
+			}
+			if pos >= len(outline) || outline[pos] == ')' {
+				return
+			}
+			pos++
+		}
+	}
+	parse(nil)
+	tree := newNavTree("sidebar", items)
+	tree.number()
+	var walk func(nodes []*navNode)
+	walk = func(nodes []*navNode) {
+		numberNavSiblings(nodes)
+		for _, n := range nodes {
+			walk(n.children)
+		}
+	}
+	walk(tree.roots)
+	items = items[:0]
+	for _, node := range tree.byID {
+		items = append(items, node.item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Lft < items[j].Lft })
+	if got := navOutline(tree); got != outline {
+		t.Fatalf("fixture %q reads back as %q", outline, got)
+	}
+	return items
+}
+
+// navOutline writes the tree back in the outline syntax of navFixture.
+func navOutline(tree *navTree) string {
+	var write func(nodes []*navNode) string
+	write = func(nodes []*navNode) string {
+		parts := make([]string, len(nodes))
+		for i, n := range nodes {
+			parts[i] = n.item.ID
+			if len(n.children) > 0 {
+				parts[i] += "(" + write(n.children) + ")"
+			}
+		}
+		return strings.Join(parts, ",")
+	}
+	return write(tree.roots)
+}
+
+// checkNavTree numbers tree as save does and checks that the result is a
+// valid nested set matching the parent links and sibling orders.
+func checkNavTree(t *testing.T, tree *navTree) {
+	t.Helper()
+	tree.number()
+	var items []models.NavigationItem
+	var walk func(nodes []*navNode, parent *navNode)
+	walk = func(nodes []*navNode, parent *navNode) {
+		for i, n := range nodes {
+			if parent == nil && n.item.ParentID != nil || parent != nil && !samePtr(n.item.ParentID, &parent.item.ID) {
+				t.Errorf("%s sits under %v but points at parent %v", n.item.ID, parent, n.item.ParentID)
+			}
+			if n.item.Order != i {
+				t.Errorf("%s is sibling %d but has order %d", n.item.ID, i, n.item.Order)
+			}
+			if tree.byID[n.item.ID] != n {
+				t.Errorf("%s is not indexed", n.item.ID)
+			}
+			items = append(items, n.item)
+			walk(n.children, n)
+		}
+	}
+	walk(tree.roots, nil)
+	if len(items) != len(tree.byID) {
+		t.Errorf("tree reaches %d items, index holds %d", len(items), len(tree.byID))
+	}
+	highest := 0
+	for _, item := range items {
+		highest = max(highest, item.Rgt)
+	}
+	if highest != 2*len(items) {
+		t.Errorf("highest bound is %d, want %d", highest, 2*len(items))
+	}
+	for _, issue := range validateNavItems(items) {
+		t.Errorf("%s: %s: %s", issue.ID, issue.Problem, issue.Detail)
+	}
+}
+
+func TestNewNavTree(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	items := []models.NavigationItem{
+		{ID: "a", Lft: 1},
+		{ID: "b", Lft: 2, ParentID: ptr("a")},
+		{ID: "c", Lft: 3, ParentID: ptr("gone"), Order: 1},
+		{ID: "d", Lft: 4, ParentID: ptr("e"), Order: 3},
+		{ID: "e", Lft: 5, ParentID: ptr("d")},
+		{ID: "f", Lft: 6, ParentID: ptr("f"), Order: 2},
+	}
+	tree := newNavTree("sidebar", items)
+	if got, want := navOutline(tree), "a(b),c,f,d(e)"; got != want {
+		t.Errorf("outline = %q, want %q", got, want)
+	}
+	checkNavTree(t, tree)
+}
+
+func TestNavTreeMove(t *testing.T) {
+	const base = "a(b(c,d),e),f"
+	tests := []struct {
+		name     string
+		id       string
+		parent   string
+		position int
+		want     string
+		err      error
+	}{
+		{"into another parent", "c", "f", 0, "a(b(d),e),f(c)", nil},
+		{"to the root level", "b", "", 0, "b(c,d),a(e),f", nil},
+		{"within its siblings", "d", "b", 0, "a(b(d,c),e),f", nil},
+		{"to the end of its siblings", "c", "b", 1, "a(b(d,c),e),f", nil},
+		{"between new siblings", "e", "b", 1, "a(b(c,e,d)),f", nil},
+		{"out of range goes last", "a", "f", 99, "f(a(b(c,d),e))", nil},
+		{"negative goes last", "f", "a", -1, "a(b(c,d),e,f)", nil},
+		{"under itself", "b", "b", 0, base, ErrInvalidParent},
+		{"under a descendant", "a", "c", 0, base, ErrInvalidParent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := newNavTree("sidebar", navFixture(t, base))
+			var parent *navNode
+			if tt.parent != "" {
+				parent = tree.byID[tt.parent]
+			}
+			err := tree.move(tree.byID[tt.id], parent, tt.position)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("move error = %v, want %v", err, tt.err)
+			}
+			if got := navOutline(tree); got != tt.want {
+				t.Errorf("outline = %q, want %q", got, tt.want)
+			}
+			checkNavTree(t, tree)
+		})
+	}
+}
+
+func TestNavTreeMoveDepths(t *testing.T) {
+	tree := newNavTree("sidebar", navFixture(t, "a(b(c(d))),e"))
+	if err := tree.move(tree.byID["b"], tree.byID["e"], 0); err != nil {
+		t.Fatal(err)
+	}
+	checkNavTree(t, tree)
+	want := map[string][3]int{"a": {1, 2, 0}, "e": {3, 10, 0}, "b": {4, 9, 1}, "c": {5, 8, 2}, "d": {6, 7, 3}}
+	for id, w := range want {
+		item := tree.byID[id].item
+		if got := [3]int{item.Lft, item.Rgt, item.Depth}; got != w {
+			t.Errorf("%s lft, rgt, depth = %v, want %v", id, got, w)
+		}
+	}
+}
+
+func TestNavTreeRestore(t *testing.T) {
+	const base = "a(b(c),d),e"
+	tests := []struct {
+		name   string
+		parent string
+		want   string
+	}{
+		{"under its parent", "b", "a(b(c,x),d),e"},
+		{"under a root", "a", "a(b(c),d,x),e"},
+		{"at the root level", "", "a(b(c),d),e,x"},
+		{"parent in another menu", "elsewhere", "a(b(c),d),e,x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The restored row comes back without bounds, so it is read
+			// first, before the rest of the menu.
+			restored := models.NavigationItem{ID: "x", Title: "X", Menu: "sidebar", Order: 7}
+			if tt.parent != "" {
+				restored.ParentID = &tt.parent
+			}
+			items := append([]models.NavigationItem{restored}, navFixture(t, base)...)
+			tree := newNavTree("sidebar", items)
+			if err := tree.restore("x", restored.ParentID); err != nil {
+				t.Fatal(err)
+			}
+			if got := navOutline(tree); got != tt.want {
+				t.Errorf("outline = %q, want %q", got, tt.want)
+			}
+			checkNavTree(t, tree)
+		})
+	}
+
+	tree := newNavTree("sidebar", navFixture(t, base))
+	if err := tree.restore("x", nil); !errors.Is(err, errItemNotFound) {
+		t.Errorf("restore of a missing item: error = %v, want errItemNotFound", err)
+	}
+}
+
+func TestNavTreeRemove(t *testing.T) {
+	const base = "a(b(c,d(g)),e),f"
+	tests := []struct {
+		name     string
+		id       string
+		strategy string
+		want     string
+		removed  []string
+	}{
+		{"reparent lifts the children in place", "b", NavDeleteReparent, "a(c,d(g),e),f", []string{"b"}},
+		{"reparent a root", "a", NavDeleteReparent, "b(c,d(g)),e,f", []string{"a"}},
+		{"reparent a leaf", "f", NavDeleteReparent, "a(b(c,d(g)),e)", []string{"f"}},
+		{"reparent keeps grandchildren", "d", NavDeleteReparent, "a(b(c,g),e),f", []string{"d"}},
+		{"cascade takes the subtree", "b", NavDeleteCascade, "a(e),f", []string{"b", "c", "d", "g"}},
+		{"cascade a root", "a", NavDeleteCascade, "f", []string{"a", "b", "c", "d", "g", "e"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := newNavTree("sidebar", navFixture(t, base))
+			removed := tree.remove(tree.byID[tt.id], tt.strategy)
+			if strings.Join(removed, ",") != strings.Join(tt.removed, ",") {
+				t.Errorf("removed = %v, want %v", removed, tt.removed)
+			}
+			for _, id := range removed {
+				if _, ok := tree.byID[id]; ok {
+					t.Errorf("%s is still indexed", id)
+				}
+			}
+			if got := navOutline(tree); got != tt.want {
+				t.Errorf("outline = %q, want %q", got, tt.want)
+			}
+			checkNavTree(t, tree)
+		})
+	}
+}
+
+func TestNavTreeRemoveReparentDepths(t *testing.T) {
+	tree := newNavTree("sidebar", navFixture(t, "a(b(c(d),e)),f"))
+	tree.remove(tree.byID["b"], NavDeleteReparent)
+	checkNavTree(t, tree)
+	want := map[string][3]int{"a": {1, 8, 0}, "c": {2, 5, 1}, "d": {3, 4, 2}, "e": {6, 7, 1}, "f": {9, 10, 0}}
+	for id, w := range want {
+		item := tree.byID[id].item
+		if got := [3]int{item.Lft, item.Rgt, item.Depth}; got != w {
+			t.Errorf("%s lft, rgt, depth = %v, want %v", id, got, w)
+		}
+	}
+}
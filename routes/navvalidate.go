@@ -0,0 +1,117 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"fmt"
+	"sort"
+)
+
+// Problems reported by the navigation validation.
+const (
+	NavIssueBounds      = "bounds"
+	NavIssueDuplicate   = "duplicate_bound"
+	NavIssueOverlap     = "overlap"
+	NavIssueParent      = "missing_parent"
+	NavIssueContainment = "containment"
+	NavIssueDepth       = "depth"
+)
+
+// A NavIssue is a broken nested-set invariant on an item.
+type NavIssue struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Menu    string `json:"menu"`
+	Problem string `json:"problem"`
+	Detail  string `json:"detail"`
+}
+
+// validateNavItems checks the nested set of the items of one menu: lft
+// before rgt, bounds used once, intervals nested or disjoint, each item
+// directly inside its parent, and depths following the parents.
+func validateNavItems(items []models.NavigationItem) []NavIssue {
+	issues := []NavIssue{}
+	report := func(item models.NavigationItem, problem, format string, args ...any) {
+		issues = append(issues, NavIssue{
+			ID:      item.ID,
+			Title:   item.Title,
+			Menu:    item.Menu,
+			Problem: problem,
+			Detail:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	sorted := make([]models.NavigationItem, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Lft < sorted[j].Lft })
+	byID := make(map[string]models.NavigationItem, len(sorted))
+	bounds := map[int]string{}
+	for _, item := range sorted {
+		byID[item.ID] = item
+		if item.Lft < 1 || item.Lft >= item.Rgt {
+			report(item, NavIssueBounds, "lft %d is not below rgt %d", item.Lft, item.Rgt)
+		}
+		for _, bound := range []int{item.Lft, item.Rgt} {
+			if other, ok := bounds[bound]; ok && other != item.ID {
+				report(item, NavIssueDuplicate, "bound %d is also used by %s", bound, other)
+			}
+			bounds[bound] = item.ID
+		}
+	}
+
+	// The enclosing intervals of an item, innermost last.
+	var stack []models.NavigationItem
+	for _, item := range sorted {
+		for len(stack) > 0 && stack[len(stack)-1].Rgt < item.Lft {
+			stack = stack[:len(stack)-1]
+		}
+		var enclosing *models.NavigationItem
+		if len(stack) > 0 {
+			enclosing = &stack[len(stack)-1]
+			if item.Rgt > enclosing.Rgt {
+				report(item, NavIssueOverlap, "[%d, %d] overlaps [%d, %d] of %s",
+					item.Lft, item.Rgt, enclosing.Lft, enclosing.Rgt, enclosing.ID)
+			}
+		}
+		if item.Lft < item.Rgt {
+			stack = append(stack, item)
+		}
+
+		if item.ParentID == nil {
+			if item.Depth != 0 {
+				report(item, NavIssueDepth, "depth %d of a root is not 0", item.Depth)
+			}
+			if enclosing != nil {
+				report(item, NavIssueContainment, "root lies inside %s", enclosing.ID)
+			}
+			continue
+		}
+		parent, ok := byID[*item.ParentID]
+		if !ok {
+			report(item, NavIssueParent, "parent %s is not in the menu", *item.ParentID)
+			continue
+		}
+		if item.Depth != parent.Depth+1 {
+			report(item, NavIssueDepth, "depth %d does not follow depth %d of the parent", item.Depth, parent.Depth)
+		}
+		if enclosing == nil || enclosing.ID != parent.ID {
+			report(item, NavIssueContainment, "not directly inside its parent [%d, %d]", parent.Lft, parent.Rgt)
+		}
+	}
+	return issues
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"slices"
+	"testing"
+)
+
+func TestValidateNavItems(t *testing.T) {
+	item := func(id, parent string, lft, rgt, depth int) models.NavigationItem {
+		it := models.NavigationItem{ID: id, Menu: "sidebar", Lft: lft, Rgt: rgt, Depth: depth}
+		if parent != "" {
+			it.ParentID = &parent
+		}
+		return it
+	}
+	tests := []struct {
+		name  string
+		items []models.NavigationItem
+		want  []string
+	}{
+		{"valid", []models.NavigationItem{
+			item("a", "", 1, 6, 0), item("b", "a", 2, 3, 1), item("c", "a", 4, 5, 1), item("d", "", 7, 8, 0),
+		}, nil},
+		{"valid in any input order", []models.NavigationItem{
+			item("d", "", 7, 8, 0), item("c", "a", 4, 5, 1), item("a", "", 1, 6, 0), item("b", "a", 2, 3, 1),
+		}, nil},
+		{"empty bounds", []models.NavigationItem{
+			item("a", "", 3, 3, 0), item("b", "", 0, 1, 0),
+		}, []string{"b:" + NavIssueBounds, "a:" + NavIssueBounds}},
+		{"shared bound", []models.NavigationItem{
+			item("a", "", 1, 4, 0), item("b", "a", 2, 3, 1), item("c", "", 4, 5, 0),
+		}, []string{"c:" + NavIssueDuplicate, "c:" + NavIssueOverlap, "c:" + NavIssueContainment}},
+		{"overlapping intervals", []models.NavigationItem{
+			item("a", "", 1, 4, 0), item("b", "", 3, 6, 0),
+		}, []string{"b:" + NavIssueOverlap, "b:" + NavIssueContainment}},
+		{"missing parent", []models.NavigationItem{
+			item("a", "", 1, 2, 0), item("b", "gone", 3, 4, 1),
+		}, []string{"b:" + NavIssueParent}},
+		{"outside its parent", []models.NavigationItem{
+			item("a", "", 1, 2, 0), item("b", "a", 3, 4, 1),
+		}, []string{"b:" + NavIssueContainment}},
+		{"inside a sibling instead of its parent", []models.NavigationItem{
+			item("a", "", 1, 8, 0), item("b", "a", 2, 5, 1), item("c", "a", 3, 4, 1), item("d", "a", 6, 7, 1),
+		}, []string{"c:" + NavIssueContainment}},
+		{"wrong depths", []models.NavigationItem{
+			item("a", "", 1, 6, 1), item("b", "a", 2, 5, 1), item("c", "b", 3, 4, 3),
+		}, []string{"a:" + NavIssueDepth, "b:" + NavIssueDepth, "c:" + NavIssueDepth}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, issue := range validateNavItems(tt.items) {
+				if issue.Menu != "sidebar" {
+					t.Errorf("issue on %s has menu %q", issue.ID, issue.Menu)
+				}
+				got = append(got, issue.ID+":"+issue.Problem)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("issues = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateNavItemsAfterRebuild(t *testing.T) {
+	// A broken menu read back by the rebuild validates cleanly once
+	// renumbered.
+	ptr := func(s string) *string { return &s }
+	items := []models.NavigationItem{
+		{ID: "a", Lft: 5, Rgt: 5},
+		{ID: "b", Lft: 1, Rgt: 9, ParentID: ptr("a"), Depth: 4},
+		{ID: "c", Lft: 3, Rgt: 2, ParentID: ptr("gone")},
+		{ID: "d", Lft: 2, Rgt: 8, ParentID: ptr("b")},
+	}
+	if len(validateNavItems(items)) == 0 {
+		t.Fatal("broken fixture reports no issue")
+	}
+	slices.SortStableFunc(items, func(a, b models.NavigationItem) int { return a.Lft - b.Lft })
+	tree := newNavTree("sidebar", items)
+	tree.number()
+	var rebuilt []models.NavigationItem
+	for _, node := range tree.byID {
+		rebuilt = append(rebuilt, node.item)
+	}
+	if issues := validateNavItems(rebuilt); len(issues) != 0 {
+		t.Errorf("rebuilt menu reports %+v", issues)
+	}
+}
@@ -18,13 +18,22 @@ package routes
 
 import (
 	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"maps"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
 )
 
@@ -35,6 +44,55 @@ type RelationDefinition struct {
 	ToTable    string `json:"toTable"`
 	OnDelete   string `json:"onDelete"`
 	PivotTable string `json:"pivotTable,omitempty"`
+	// LabelColumns form the label sent instead of the related object, joined
+	// by LabelSeparator (a space by default), unless the relation is expanded.
+	LabelColumns   []string `json:"labelColumns,omitempty"`
+	LabelSeparator string   `json:"labelSeparator,omitempty"`
+	// Symmetric makes a many-to-many relation of a table to itself read in
+	// both directions: linking A to B also lists A among B's items.
+	Symmetric bool `json:"symmetric,omitempty"`
+	// DependencyLimit caps the rows of ToTable sent as dependencies,
+	// DependencyFilter is an expression over a ToTable row deciding whether
+	// it is sent, and DependencyColumns restricts the columns sent.
+	DependencyLimit   int      `json:"dependencyLimit,omitempty"`
+	DependencyFilter  string   `json:"dependencyFilter,omitempty"`
+	DependencyColumns []string `json:"dependencyColumns,omitempty"`
+}
+
+type ColumnDefinition struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	NaturalKey bool   `json:"naturalKey,omitempty"`
+	Default    any    `json:"default,omitempty"`
+	// VisibilityTags marks the column holding the tags a row is visible to.
+	VisibilityTags bool `json:"visibilityTags,omitempty"`
+	// Mask hides part of the values from the users it concerns.
+	Mask *MaskRule `json:"mask,omitempty"`
+	// Encrypted stores the values sealed with the app key; the column must
+	// be of a text type.
+	Encrypted bool `json:"encrypted,omitempty"`
+	// Index indexes the column on deploy. The columns sharing an IndexGroup
+	// get one composite index, in the order they are declared.
+	Index      bool   `json:"index,omitempty"`
+	IndexGroup string `json:"indexGroup,omitempty"`
+}
+
+func naturalKeyColumn(cols []ColumnDefinition) string {
+	for _, col := range cols {
+		if col.NaturalKey {
+			return col.Name
+		}
+	}
+	return ""
+}
+
+func visibilityColumn(cols []ColumnDefinition) string {
+	for _, col := range cols {
+		if col.VisibilityTags {
+			return col.Name
+		}
+	}
+	return ""
 }
 
 type schemaRaw struct {
@@ -42,11 +100,18 @@ type schemaRaw struct {
 	Relations []RelationDefinition `json:"relations"`
 }
 
-func RegisterPublicPageRoutes(r gin.IRoutes, db *gorm.DB) {
-	r.GET("/page/:id", func(c *gin.Context) {
-		id := c.Param("id")
+func RegisterPublicPageRoutes(r gin.IRoutes, db *gorm.DB, cache *services.Cache, hooks *services.Webhooks, events *services.PageEvents) {
+	r.GET("/page/by-slug/:slug", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		slug := c.Param("slug")
+
+		// Pages created before slugs existed may still be looked up by name.
 		var page models.Page
-		if err := db.Preload("Template").First(&page, "id = ?", id).Error; err != nil {
+		err := db.Select("id").Where("slug = ?", slug).First(&page).Error
+		if err == gorm.ErrRecordNotFound {
+			err = db.Select("id").Where("name = ?", slug).First(&page).Error
+		}
+		if err != nil {
 			if err == gorm.ErrRecordNotFound {
 				c.JSON(http.StatusNotFound, gin.H{"error": "❌ Page introuvable"})
 				return
@@ -55,262 +120,57 @@ func RegisterPublicPageRoutes(r gin.IRoutes, db *gorm.DB) {
 			return
 		}
 
-		var raw schemaRaw
-		if page.SchemaRelationsDeployed != nil {
-			_ = json.Unmarshal(page.SchemaRelationsDeployed, &raw.Relations)
-		}
-		if page.SchemaUiDeployed != nil {
-			_ = json.Unmarshal(page.SchemaUiDeployed, &raw.UI)
-		} else {
-			raw.UI = []map[string]any{}
-		}
+		servePage(c, db, cache, page.ID)
+	})
+	r.GET("/page/:id", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		servePage(c, db, cache, c.Param("id"))
+	})
+	r.GET("/page/:id/changelog", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		id := c.Param("id")
 
-		var menuDefs []map[string]any
-		if page.SchemaMenuUiDeployed != nil {
-			_ = json.Unmarshal(page.SchemaMenuUiDeployed, &menuDefs)
+		var page models.Page
+		err := db.Select("id", "status").First(&page, "id = ?", id).Error
+		if err == gorm.ErrRecordNotFound || err == nil && !pageVisible(c, page.Status) {
+			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+			return
 		}
-
-		menus := make([]map[string]any, 0, len(menuDefs))
-		for _, m := range menuDefs {
-			menus = append(menus, map[string]any{
-				"name":  m["name"],
-				"order": m["order"],
-				"refId": fmt.Sprintf("%v", m["refId"]),
-			})
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
 		}
 
-		data := []map[string]any{}
-		dependencies := make(map[string]any)
-
-		if Bool(page.Deploy) && page.TableName != "" {
-			sqlDB, _ := db.DB()
-			rows, err := sqlDB.Query(fmt.Sprintf(`SELECT * FROM %s`, quoteIdent(page.TableName)))
+		query := db.Preload("User", userSummary).Where("page_id = ?", id)
+		if since := c.Query("since"); since != "" {
+			t, err := time.Parse(time.RFC3339, since)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
-			defer rows.Close()
-
-			cols, _ := rows.Columns()
-			rawRows := make([]map[string]any, 0)
-			allIDs := make([]string, 0)
-
-			for rows.Next() {
-				values := make([]interface{}, len(cols))
-				ptrs := make([]interface{}, len(cols))
-				for i := range cols {
-					ptrs[i] = &values[i]
-				}
-				if err := rows.Scan(ptrs...); err != nil {
-					continue
-				}
-
-				entry := make(map[string]any, len(cols))
-				for i, col := range cols {
-					entry[col] = values[i]
-				}
-
-				if idv, ok := entry["id"]; ok && idv != nil {
-					allIDs = append(allIDs, fmt.Sprintf("%v", idv))
-				}
-
-				rawRows = append(rawRows, entry)
-			}
-
-			if len(rawRows) == 0 {
-				c.JSON(http.StatusOK, gin.H{
-					"id":           page.ID,
-					"name":         page.Name,
-					"template":     page.Template,
-					"schema":       raw.UI,
-					"menus":        menus,
-					"relations":    raw.Relations,
-					"data":         data,
-					"dependencies": dependencies,
-				})
+				utils.Error(c, http.StatusBadRequest, "INVALID_SINCE", "since must be an RFC3339 timestamp")
 				return
 			}
-
-
-			pivotData := make(map[string]map[string][]string)
-
-			for _, rel := range raw.Relations {
-				if rel.Type != "many-to-many" || len(allIDs) == 0 {
-					continue
-				}
-				pivot := pivotTableName(page.TableName, rel)
-				in := "'" + strings.Join(allIDs, "','") + "'"
-				query := fmt.Sprintf(
-					`SELECT left_id, right_id FROM %s WHERE left_id IN (%s)`,
-					quoteIdent(pivot), in,
-				)
-
-				rs, err := sqlDB.Query(query)
-				if err != nil {
-					continue
-				}
-
-				m := make(map[string][]string)
-				for rs.Next() {
-					var left, right string
-					if err := rs.Scan(&left, &right); err == nil {
-						m[left] = append(m[left], right)
-					}
-				}
-				rs.Close()
-
-				pivotData[pivot] = m
-			}
-
-			fkByTable := make(map[string]map[string]struct{})
-
-			for _, rel := range raw.Relations {
-				if rel.Type != "one-to-one" && rel.Type != "one-to-many" {
-					continue
-				}
-
-				for _, entry := range rawRows {
-					if fk, ok := entry[rel.FromColumn]; ok && fk != nil {
-						idStr := fmt.Sprintf("%v", fk)
-						if idStr == "" {
-							continue
-						}
-						if fkByTable[rel.ToTable] == nil {
-							fkByTable[rel.ToTable] = make(map[string]struct{})
-						}
-						fkByTable[rel.ToTable][idStr] = struct{}{}
-					}
-				}
-			}
-
-			for _, rel := range raw.Relations {
-				if rel.Type != "many-to-many" {
-					continue
-				}
-				pivot := pivotTableName(page.TableName, rel)
-				pairs := pivotData[pivot]
-				if pairs == nil {
-					continue
-				}
-
-				for _, rights := range pairs {
-					for _, rid := range rights {
-						if fkByTable[rel.ToTable] == nil {
-							fkByTable[rel.ToTable] = make(map[string]struct{})
-						}
-						fkByTable[rel.ToTable][rid] = struct{}{}
-					}
-				}
-			}
-
-			objCache := batchLoadRelated(sqlDB, fkByTable)
-
-			for _, entry := range rawRows {
-				for _, rel := range raw.Relations {
-
-					switch rel.Type {
-
-					case "one-to-one", "one-to-many":
-						if fk, ok := entry[rel.FromColumn]; ok && fk != nil {
-							idStr := fmt.Sprintf("%v", fk)
-							if idStr == "" {
-								continue
-							}
-							key := rel.ToTable + ":" + idStr
-
-							if obj, ok := objCache[key]; ok {
-								entry[rel.FromColumn] = obj
-							}
-						}
-
-					case "many-to-many":
-						pivot := pivotTableName(page.TableName, rel)
-						entryID := fmt.Sprintf("%v", entry["id"])
-
-						if pairs, ok := pivotData[pivot]; ok {
-							rightIDs := pairs[entryID]
-							if len(rightIDs) == 0 {
-								entry[rel.FromColumn] = []any{}
-								continue
-							}
-
-							list := make([]any, 0, len(rightIDs))
-							for _, rid := range rightIDs {
-								key := rel.ToTable + ":" + rid
-								if obj, ok := objCache[key]; ok {
-									list = append(list, obj)
-								} else {
-									list = append(list, rid)
-								}
-							}
-							entry[rel.FromColumn] = list
-						} else {
-							entry[rel.FromColumn] = []any{}
-						}
-					}
-				}
-
-				data = append(data, entry)
-			}
-
-			loaded := make(map[string]bool)
-			for _, rel := range raw.Relations {
-				if loaded[rel.ToTable] {
-					continue
-				}
-				loaded[rel.ToTable] = true
-
-				q := fmt.Sprintf(`SELECT * FROM %s`, quoteIdent(rel.ToTable))
-				rs, err := sqlDB.Query(q)
-				if err != nil {
-					continue
-				}
-
-				cols, _ := rs.Columns()
-				var arr []map[string]any
-
-				for rs.Next() {
-					vals := make([]interface{}, len(cols))
-					ptrs := make([]interface{}, len(cols))
-					for i := range cols {
-						ptrs[i] = &vals[i]
-					}
-					if err := rs.Scan(ptrs...); err == nil {
-						row := make(map[string]any, len(cols))
-						for i, c := range cols {
-							row[c] = vals[i]
-						}
-						arr = append(arr, row)
-					}
-				}
-
-				rs.Close()
-				dependencies[rel.FromColumn] = arr
-			}
+			query = query.Where("created_at > ?", t)
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"id":           page.ID,
-			"name":         page.Name,
-			"template":     page.Template,
-			"schema":       raw.UI,
-			"menus":        menus,
-			"functions":	page.SchemaFunctionsDeployed,
-			"conditions":	page.SchemaConditionsDeployed,
-			"relations":    raw.Relations,
-			"data":         data,
-			"dependencies": dependencies,
-		})
+		var entries []models.PageChangelog
+		if err := query.Order("created_at DESC").Find(&entries).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_CHANGELOG_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": entries, "success": true})
 	})
 	r.POST("/page/:id", func(c *gin.Context) {
+		db := utils.DB(c, db)
 		id := c.Param("id")
 
 		var page models.Page
-		if err := db.First(&page, "id = ?", id).Error; err != nil {
+		if err := db.First(&page, "id = ?", id).Error; err != nil || !pageVisible(c, page.Status) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Page introuvable"})
 			return
 		}
 
+		if rejectReadOnlyPage(c, page) {
+			return
+		}
 		if page.TableName == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "TableName manquant"})
 			return
@@ -321,128 +181,1267 @@ func RegisterPublicPageRoutes(r gin.IRoutes, db *gorm.DB) {
 			_ = json.Unmarshal(page.SchemaRelationsDeployed, &raw.Relations)
 		}
 
+		if err := checkPageTables(db, page, raw.Relations); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		var payload map[string]any
 		if err := c.BindJSON(&payload); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		sqlDB, _ := db.DB()
-
-
-		simpleFields := map[string]any{}
-		m2mFields := map[string][]string{} 
-
-		for _, rel := range raw.Relations {
-			if rel.Type == "many-to-many" {
-				if v, ok := payload[rel.FromColumn]; ok && v != nil {
-
-					arr, ok := v.([]interface{})
-					if !ok {
-						fmt.Println("⚠️ Format M2M invalide pour", rel.FromColumn)
-						delete(payload, rel.FromColumn)
-						continue
-					}
-
-					ids := []string{}
-
-					for _, a := range arr {
-						switch val := a.(type) {
+		simpleFields, m2mFields := splitM2MFields(payload, raw.Relations)
 
-						case string:
-							ids = append(ids, val)
-						case map[string]interface{}:
-							if idv, ok := val["id"]; ok {
-								ids = append(ids, fmt.Sprintf("%v", idv))
-							}
-
-						default:
-							fmt.Println("⚠️ Valeur M2M inconnue:", a)
-						}
-					}
+		columns := parseColumns(page.SchemaColumnsDeployed)
+		defaults := columnDefaults(c, columns, simpleFields)
 
-					m2mFields[rel.FromColumn] = ids
-				}
+		upsert := c.Query("upsert") == "true"
+		naturalKey := ""
+		if upsert {
+			naturalKey = naturalKeyColumn(columns)
+			if naturalKey == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Aucune colonne naturalKey déclarée pour cette page"})
+				return
+			}
+			if _, ok := simpleFields[naturalKey]; !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("La clé naturelle %q est requise en mode upsert", naturalKey)})
+				return
+			}
+			// Each encryption uses a new nonce: sealed values never match.
+			if isEncryptedColumn(columns, naturalKey) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("La clé naturelle %q est chiffrée et ne permet pas l'upsert", naturalKey)})
+				return
+			}
+		}
 
-				delete(payload, rel.FromColumn)
+		if !upsert {
+			for col, val := range defaults {
+				simpleFields[col] = val
+			}
+		}
+		insertOnly := simpleFields
+		if upsert {
+			insertOnly = defaults
+		}
+		if err := pageIDStrategy(page).assign(simpleFields, insertOnly); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		dropStamps(simpleFields, insertOnly)
+		if sqlDB, err := PageSQL(db, page); err == nil {
+			if upsert {
+				stampUpsert(c, sqlDB, page.TableName, simpleFields, insertOnly)
+			} else {
+				stampInsert(c, sqlDB, page.TableName, insertOnly)
+			}
+		}
+		if err := applyWriteFunctions(parseFunctions(page.SchemaFunctionsDeployed), simpleFields); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := encryptFields(columns, simpleFields); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if upsert {
+			if err := encryptFields(columns, defaults); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
 			}
 		}
 
-		for k, v := range payload {
-			simpleFields[k] = v
+		// The upsert overwrites the row holding the natural key: the user
+		// must be allowed to see it, as for a PUT.
+		if upsert {
+			sqlDB, err := PageSQL(db, page)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			existing, err := loadItemBy(sqlDB, page, raw, naturalKey, simpleFields[naturalKey])
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if err == nil && !applyItemConditions(c, db, page, existing) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Item introuvable"})
+				return
+			}
 		}
 
-		newID, err := InsertDynamic(sqlDB, page.TableName, simpleFields)
+		tx, err := beginPageSQL(c, db, page)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		defer tx.Rollback()
+
+		var newID string
+		inserted := true
+		if upsert {
+			newID, inserted, err = UpsertDynamic(tx, page.TableName, naturalKey, simpleFields, defaults)
+		} else {
+			newID, err = InsertDynamic(tx, page.TableName, simpleFields)
+		}
+		if err != nil {
+			status := http.StatusInternalServerError
+			if isIdentifierError(err) {
+				status = http.StatusBadRequest
+			}
+			// 42P10: no unique constraint matches the ON CONFLICT target.
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "42P10" {
+				status = http.StatusBadRequest
+				err = fmt.Errorf("la colonne %q n'a pas de contrainte d'unicité: %w", naturalKey, err)
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
 
 		for _, rel := range raw.Relations {
 			if rel.Type != "many-to-many" {
 				continue
 			}
 
-			rightIDs := m2mFields[rel.FromColumn]
+			rightIDs, provided := m2mFields[rel.FromColumn]
 			pivotTable := pivotTableName(page.TableName, rel)
 
+			if !inserted {
+				// Re-pushing an existing record: the payload is authoritative
+				// for the relations it carries, the others are left untouched.
+				if !provided {
+					continue
+				}
+				if rel.Symmetric && rel.selfReferencing(page.TableName) {
+					if err := ClearPivotReverse(tx, pivotTable, newID); err != nil {
+						c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("relation %s: %v", rel.FromColumn, err)})
+						return
+					}
+				}
+				if err := ReplacePivotM2M(tx, pivotTable, newID, rightIDs); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("relation %s: %v", rel.FromColumn, err)})
+					return
+				}
+				continue
+			}
+
 			if len(rightIDs) == 0 {
 				continue
 			}
 
-			if err := InsertPivotM2M(sqlDB, pivotTable, newID, rightIDs); err != nil {
-				fmt.Println("❌ Erreur pivot:", err)
+			if err := InsertPivotM2M(tx, pivotTable, newID, rightIDs); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("relation %s: %v", rel.FromColumn, err)})
+				return
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		invalidateTableCache(c, db, cache, page.TableName)
+
+		event := services.WebhookRowCreated
+		if !inserted {
+			event = services.WebhookRowUpdated
+		}
+		notifyRowChange(c, hooks, events, event, page, newID, func() map[string]any {
+			sqlDB, err := PageSQL(db, page)
+			if err != nil {
+				return nil
 			}
+			item, _ := loadItem(sqlDB, page, raw, newID)
+			return item
+		})
+
+		if !inserted {
+			recordRowAudit(c, db, services.AuditActionUpdate, page, newID)
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Mise à jour OK",
+				"id":      newID,
+			})
+			return
 		}
 
+		recordRowAudit(c, db, services.AuditActionCreate, page, newID)
+
 		c.JSON(http.StatusCreated, gin.H{
 			"message": "Création OK",
 			"id":      newID,
 		})
 	})
+	r.DELETE("/page/:id/:itemId", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		itemID := c.Param("itemId")
 
+		page, raw, ok := loadDeployedPage(c, db, c.Param("id"))
+		if !ok {
+			return
+		}
+		if rejectReadOnlyPage(c, page) {
+			return
+		}
 
-}
+		sqlDB, err := PageSQL(db, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		item, err := loadItem(sqlDB, page, raw, itemID)
+		if err != nil || !applyItemConditions(c, db, page, item) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item introuvable"})
+			return
+		}
+		snap, err := snapshotRow(sqlDB, page, raw, itemID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 
+		tx, err := beginPageSQL(c, db, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback()
 
-func quoteIdent(ident string) string {
-	safe := strings.ReplaceAll(ident, `"`, `""`)
-	return `"` + safe + `"`
-}
+		if err := clearRowPivots(tx, page, raw, itemID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 
-func pivotTableName(pageTable string, rel RelationDefinition) string {
-	if rel.PivotTable != "" {
-		return rel.PivotTable
-	}
-	return strings.ToLower(fmt.Sprintf("%s_%s_%s", pageTable, rel.FromColumn, rel.ToTable))
-}
-func batchLoadRelated(db *sql.DB, fkByTable map[string]map[string]struct{}) map[string]map[string]any {
-	cache := make(map[string]map[string]any)
+		q := newQuery("DELETE FROM ").Ident(page.TableName).Write(" WHERE id = ").Arg(itemID)
+		if _, err := tx.Exec(q.SQL(), q.Args()...); err != nil {
+			respondDeleteError(c, err)
+			return
+		}
 
-	for table, idSet := range fkByTable {
-		if len(idSet) == 0 {
-			continue
+		if err := tx.Commit(); err != nil {
+			respondDeleteError(c, err)
+			return
 		}
 
-		ids := make([]string, 0, len(idSet))
-		for id := range idSet {
-			ids = append(ids, id)
+		if err := services.RecordTombstones(db, page.ID, itemID); err != nil {
+			log.Printf("⚠️  Suppression de %s/%s non enregistrée pour la synchronisation: %v", page.TableName, itemID, err)
+		}
+		trashRow(c, db, page, itemID, snap)
+		invalidateTableCache(c, db, cache, page.TableName)
+		recordRowAudit(c, db, services.AuditActionDelete, page, itemID)
+		notifyRowChange(c, hooks, events, services.WebhookRowDeleted, page, itemID, func() map[string]any { return item })
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Suppression OK",
+			"id":      itemID,
+		})
+	})
+
+	// POST /page/:id/deleteMany deletes the rows of the ids in the body, in
+	// one transaction. A row still referenced through a RESTRICT foreign key
+	// is kept and reported blocked without failing the others; results gives
+	// the outcome of each id. Deleted rows go to the trash, as with DELETE.
+	r.POST("/page/:id/deleteMany", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		page, raw, ok := loadDeployedPage(c, db, c.Param("id"))
+		if !ok {
+			return
+		}
+		if rejectReadOnlyPage(c, page) {
+			return
+		}
+
+		var ids []string
+		if err := c.ShouldBindJSON(&ids); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(ids) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Aucun identifiant fourni"})
+			return
 		}
 
-		placeholders := make([]string, len(ids))
-		args := make([]interface{}, len(ids))
-		for i, id := range ids {
-			placeholders[i] = fmt.Sprintf("$%d", i+1)
-			args[i] = id
+		sqlDB, err := PageSQL(db, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		tx, err := beginPageSQL(c, db, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback()
+
+		results := make([]DeleteResult, 0, len(ids))
+		deleted := map[string]map[string]any{}
+		snaps := map[string]services.TrashedRow{}
+		for _, itemID := range ids {
+			if _, done := deleted[itemID]; done {
+				continue
+			}
+			item, err := loadItem(sqlDB, page, raw, itemID)
+			if err != nil || !applyItemConditions(c, db, page, item) {
+				results = append(results, DeleteResult{ID: itemID, Status: DeleteNotFound})
+				continue
+			}
+			if snaps[itemID], err = snapshotRow(sqlDB, page, raw, itemID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Each row is deleted under a savepoint, so that a refused
+			// delete leaves the transaction usable for the next ones.
+			if _, err := tx.Exec("SAVEPOINT delete_row"); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			err = clearRowPivots(tx, page, raw, itemID)
+			if err == nil {
+				q := newQuery("DELETE FROM ").Ident(page.TableName).Write(" WHERE id = ").Arg(itemID)
+				_, err = tx.Exec(q.SQL(), q.Args()...)
+			}
+			if table, restricted := isRestrictViolation(err); restricted {
+				if _, err := tx.Exec("ROLLBACK TO SAVEPOINT delete_row"); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				results = append(results, DeleteResult{ID: itemID, Status: DeleteBlocked, Table: table})
+				continue
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if _, err := tx.Exec("RELEASE SAVEPOINT delete_row"); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			deleted[itemID] = item
+			results = append(results, DeleteResult{ID: itemID, Status: DeleteDone})
+		}
+
+		if err := tx.Commit(); err != nil {
+			respondDeleteError(c, err)
+			return
+		}
+
+		if len(deleted) > 0 {
+			deletedIDs := make([]string, 0, len(deleted))
+			for _, res := range results {
+				if res.Status == DeleteDone {
+					deletedIDs = append(deletedIDs, res.ID)
+				}
+			}
+			if err := services.RecordTombstones(db, page.ID, deletedIDs...); err != nil {
+				log.Printf("⚠️  Suppressions de %s non enregistrées pour la synchronisation: %v", page.TableName, err)
+			}
+			invalidateTableCache(c, db, cache, page.TableName)
+			for _, itemID := range deletedIDs {
+				item := deleted[itemID]
+				trashRow(c, db, page, itemID, snaps[itemID])
+				recordRowAudit(c, db, services.AuditActionDelete, page, itemID)
+				notifyRowChange(c, hooks, events, services.WebhookRowDeleted, page, itemID, func() map[string]any { return item })
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Suppression OK",
+			"count":   len(deleted),
+			"results": results,
+		})
+	})
+
+	// PUT and PATCH /page/:id/:itemId update the columns they carry. PATCH
+	// leaves the many-to-many relations it omits untouched, PUT empties them.
+	// With If-Match, the row must still be at the version (ETag) the client
+	// read, or the answer is 409 with the current row.
+	updateItem := func(c *gin.Context, replace bool) {
+		db := utils.DB(c, db)
+		itemID := c.Param("itemId")
+
+		page, raw, ok := loadDeployedPage(c, db, c.Param("id"))
+		if !ok {
+			return
+		}
+		if rejectReadOnlyPage(c, page) {
+			return
+		}
+
+		var payload map[string]any
+		if err := c.BindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		delete(payload, "id")
+
+		sqlDB, err := PageSQL(db, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		current, err := loadItem(sqlDB, page, raw, itemID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item introuvable"})
+			return
+		}
+		// The version is read before the conditions strip the row.
+		etag := rowVersion(current)
+		version, versioned := current[rowVersionColumn]
+		if !applyItemConditions(c, db, page, current) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item introuvable"})
+			return
+		}
+
+		conflict := func() {
+			item := current
+			if latest, err := loadItem(sqlDB, page, raw, itemID); err == nil {
+				etag, item = rowVersion(latest), latest
+				if !applyItemConditions(c, db, page, item) {
+					item = nil
+				}
+			}
+			c.Header("ETag", etag)
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "L'élément a été modifié entre-temps",
+				"code":  "VERSION_CONFLICT",
+				"item":  item,
+			})
+		}
+		if !utils.IfMatch(c, etag) {
+			conflict()
+			return
+		}
+
+		simpleFields, m2mFields := splitM2MFields(payload, raw.Relations)
+		if err := checkItemWrite(c, db, page, current, simpleFields); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err := applyWriteFunctions(parseFunctions(page.SchemaFunctionsDeployed), simpleFields); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := encryptFields(parseColumns(page.SchemaColumnsDeployed), simpleFields); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		stampUpdate(c, current, simpleFields)
+
+		tx, err := beginPageSQL(c, db, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback()
+
+		// The version is checked again by the update itself, so a write
+		// landing between the read above and this one is not overwritten.
+		if versioned {
+			updated, err := UpdateDynamicIf(tx, page.TableName, itemID, simpleFields, rowVersionColumn, version)
+			if err != nil {
+				c.JSON(updateErrorStatus(err), gin.H{"error": err.Error()})
+				return
+			}
+			if !updated {
+				conflict()
+				return
+			}
+		} else if err := UpdateDynamic(tx, page.TableName, itemID, simpleFields); err != nil {
+			c.JSON(updateErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		for _, rel := range raw.Relations {
+			if rel.Type != "many-to-many" {
+				continue
+			}
+			rightIDs, provided := m2mFields[rel.FromColumn]
+			if !provided && !replace {
+				continue
+			}
+			pivotTable := pivotTableName(page.TableName, rel)
+			if rel.Symmetric && rel.selfReferencing(page.TableName) {
+				if err := ClearPivotReverse(tx, pivotTable, itemID); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("relation %s: %v", rel.FromColumn, err)})
+					return
+				}
+			}
+			if err := ReplacePivotM2M(tx, pivotTable, itemID, rightIDs); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("relation %s: %v", rel.FromColumn, err)})
+				return
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		invalidateTableCache(c, db, cache, page.TableName)
+		recordRowAudit(c, db, services.AuditActionUpdate, page, itemID)
+		notifyRowChange(c, hooks, events, services.WebhookRowUpdated, page, itemID, func() map[string]any {
+			item, _ := loadItem(sqlDB, page, raw, itemID)
+			return item
+		})
+
+		body := gin.H{"message": "Mise à jour OK", "id": itemID}
+		if item, err := loadItem(sqlDB, page, raw, itemID); err == nil {
+			c.Header("ETag", rowVersion(item))
+			if applyItemConditions(c, db, page, item) {
+				body["item"] = item
+			}
+		}
+		c.JSON(http.StatusOK, body)
+	}
+	r.PUT("/page/:id/:itemId", func(c *gin.Context) { updateItem(c, true) })
+	r.PATCH("/page/:id/:itemId", func(c *gin.Context) { updateItem(c, false) })
+
+	// PATCH /page/:id/patchMany applies the same partial update to every row
+	// of ids, in one transaction: either all rows are updated or none. The
+	// many-to-many relations present in updates are replaced on each row.
+	r.PATCH("/page/:id/patchMany", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		page, raw, ok := loadDeployedPage(c, db, c.Param("id"))
+		if !ok {
+			return
+		}
+		if rejectReadOnlyPage(c, page) {
+			return
+		}
+
+		var payload struct {
+			IDs     []string       `json:"ids"`
+			Updates map[string]any `json:"updates"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		delete(payload.Updates, "id")
+		if len(payload.IDs) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Aucun identifiant fourni"})
+			return
+		}
+		if len(payload.Updates) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Aucune modification fournie"})
+			return
+		}
+
+		sqlDB, err := PageSQL(db, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		items := make(map[string]map[string]any, len(payload.IDs))
+		for _, itemID := range payload.IDs {
+			item, err := loadItem(sqlDB, page, raw, itemID)
+			if err != nil || !applyItemConditions(c, db, page, item) {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Item %s introuvable", itemID), "id": itemID})
+				return
+			}
+			items[itemID] = item
+		}
+
+		simpleFields, m2mFields := splitM2MFields(payload.Updates, raw.Relations)
+		if err := checkItemWrite(c, db, page, nil, simpleFields); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err := applyWriteFunctions(parseFunctions(page.SchemaFunctionsDeployed), simpleFields); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := encryptFields(parseColumns(page.SchemaColumnsDeployed), simpleFields); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tx, err := beginPageSQL(c, db, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback()
+
+		for _, itemID := range payload.IDs {
+			fields := maps.Clone(simpleFields)
+			stampUpdate(c, items[itemID], fields)
+			if err := UpdateDynamic(tx, page.TableName, itemID, fields); err != nil {
+				c.JSON(updateErrorStatus(err), gin.H{"error": err.Error()})
+				return
+			}
+
+			for _, rel := range raw.Relations {
+				rightIDs, provided := m2mFields[rel.FromColumn]
+				if rel.Type != "many-to-many" || !provided {
+					continue
+				}
+				pivotTable := pivotTableName(page.TableName, rel)
+				if rel.Symmetric && rel.selfReferencing(page.TableName) {
+					if err := ClearPivotReverse(tx, pivotTable, itemID); err != nil {
+						c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("relation %s: %v", rel.FromColumn, err)})
+						return
+					}
+				}
+				if err := ReplacePivotM2M(tx, pivotTable, itemID, rightIDs); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("relation %s: %v", rel.FromColumn, err)})
+					return
+				}
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		invalidateTableCache(c, db, cache, page.TableName)
+		for _, itemID := range payload.IDs {
+			recordRowAudit(c, db, services.AuditActionUpdate, page, itemID)
+			notifyRowChange(c, hooks, events, services.WebhookRowUpdated, page, itemID, func() map[string]any {
+				item, _ := loadItem(sqlDB, page, raw, itemID)
+				return item
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Mise à jour OK",
+			"count":   len(payload.IDs),
+			"ids":     payload.IDs,
+		})
+	})
+
+	// POST /page/:id/:itemId/duplicate copies a row under a new id. The body,
+	// optional, overrides columns of the copy (e.g. a unique code), and
+	// ?links=true also copies its many-to-many links.
+	r.POST("/page/:id/:itemId/duplicate", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		itemID := c.Param("itemId")
+
+		page, raw, ok := loadDeployedPage(c, db, c.Param("id"))
+		if !ok {
+			return
+		}
+		if rejectReadOnlyPage(c, page) {
+			return
+		}
+
+		overrides := map[string]any{}
+		if c.Request.ContentLength > 0 {
+			if err := c.BindJSON(&overrides); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		delete(overrides, "id")
+
+		sqlDB, err := PageSQL(db, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		item, err := loadItem(sqlDB, page, raw, itemID)
+		if err != nil || !applyItemConditions(c, db, page, item) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item introuvable"})
+			return
+		}
+
+		tx, err := beginPageSQL(c, db, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback()
+
+		fields, err := loadRow(tx, page.TableName, itemID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		// The copy gets its own id (from the page strategy or the column
+		// default) and stamps.
+		for _, col := range []string{"id", stampCreatedAt, stampUpdatedAt, stampCreatedBy, stampUpdatedBy} {
+			delete(fields, col)
+		}
+		// Files are not shared: deleting the source would delete the copy's.
+		for _, col := range fileColumns(parseColumns(page.SchemaColumnsDeployed)) {
+			delete(fields, col)
+		}
+		if err := pageIDStrategy(page).assign(fields, fields); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		stampInsert(c, sqlDB, page.TableName, fields)
+
+		simpleOverrides, m2mOverrides := splitM2MFields(overrides, raw.Relations)
+		dropStamps(simpleOverrides)
+		if err := applyWriteFunctions(parseFunctions(page.SchemaFunctionsDeployed), simpleOverrides); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := encryptFields(parseColumns(page.SchemaColumnsDeployed), simpleOverrides); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for col, val := range simpleOverrides {
+			fields[col] = val
+		}
+
+		newID, err := InsertDynamic(tx, page.TableName, fields)
+		if err != nil {
+			status := updateErrorStatus(err)
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+				status = http.StatusConflict
+				err = fmt.Errorf("la copie viole la contrainte %s, fournissez une nouvelle valeur: %w", pgErr.ConstraintName, err)
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		copyLinks := c.Query("links") == "true"
+		for _, rel := range raw.Relations {
+			if rel.Type != "many-to-many" {
+				continue
+			}
+			pivotTable := pivotTableName(page.TableName, rel)
+			rightIDs, provided := m2mOverrides[rel.FromColumn]
+			if !provided {
+				if !copyLinks {
+					continue
+				}
+				pairs, err := loadPivotPairs(sqlDB, pivotTable, rel.Symmetric && rel.selfReferencing(page.TableName), []string{itemID})
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("relation %s: %v", rel.FromColumn, err)})
+					return
+				}
+				rightIDs = pairs[itemID]
+			}
+			if len(rightIDs) == 0 {
+				continue
+			}
+			if err := InsertPivotM2M(tx, pivotTable, newID, rightIDs); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("relation %s: %v", rel.FromColumn, err)})
+				return
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		invalidateTableCache(c, db, cache, page.TableName)
+		recordRowAudit(c, db, services.AuditActionCreate, page, newID)
+		notifyRowChange(c, hooks, events, services.WebhookRowCreated, page, newID, func() map[string]any {
+			item, _ := loadItem(sqlDB, page, raw, newID)
+			return item
+		})
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":  "Duplication OK",
+			"id":       newID,
+			"sourceId": itemID,
+		})
+	})
+
+
+}
+
+
+// 23505: unique_violation.
+const pgUniqueViolation = "23505"
+
+// updateErrorStatus is 400 for a payload naming an invalid column, 500
+// otherwise.
+func updateErrorStatus(err error) int {
+	if isIdentifierError(err) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// Outcomes of a row in a bulk delete.
+const (
+	DeleteDone     = "deleted"
+	DeleteBlocked  = "blocked"
+	DeleteNotFound = "notFound"
+)
+
+// DeleteResult is the outcome of one id of a bulk delete. Table names the
+// table still referencing a blocked row.
+type DeleteResult struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Table  string `json:"table,omitempty"`
+}
+
+// clearRowPivots removes the pivot rows of a row about to be deleted, for
+// the pivots deployed before their foreign keys existed.
+func clearRowPivots(tx sqlExecutor, page models.Page, raw schemaRaw, itemID string) error {
+	for _, rel := range raw.Relations {
+		if rel.Type != "many-to-many" {
+			continue
+		}
+		pivot := pivotTableName(page.TableName, rel)
+		if err := ClearPivot(tx, pivot, itemID); err != nil {
+			return fmt.Errorf("relation %s: %w", rel.FromColumn, err)
+		}
+		if rel.selfReferencing(page.TableName) {
+			if err := ClearPivotReverse(tx, pivot, itemID); err != nil {
+				return fmt.Errorf("relation %s: %w", rel.FromColumn, err)
+			}
+		}
+	}
+	return nil
+}
+
+// respondDeleteError answers 409 when a RESTRICT foreign key refused the
+// delete.
+func respondDeleteError(c *gin.Context, err error) {
+	if table, restricted := isRestrictViolation(err); restricted {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("Suppression impossible: l'élément est encore référencé par %s", table),
+			"code":  "DELETE_RESTRICTED",
+			"table": table,
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// pageFlights coalesces the concurrent builds of a cacheable page payload,
+// keyed by its cache key.
+var pageFlights singleflight.Group
+
+// servePage writes the payload of page id, from the page cache when
+// possible.
+func servePage(c *gin.Context, db *gorm.DB, cache *services.Cache, id string) {
+	if c.Query("preview") == "draft" {
+		serveDraftPreview(c, db, id)
+		return
+	}
+	// Pages that are not published are only served to admins, before the
+	// cache is looked at.
+	var head models.Page
+	if err := db.Select("status", "schema_columns_deployed", "schema_conditions_deployed").Where("id = ?", id).Limit(1).Find(&head).Error; err == nil &&
+		head.Status != "" && !pageVisible(c, head.Status) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Page introuvable"})
+		return
+	}
+	// ?sort may not reveal the order of the values the user cannot read.
+	if v, ok := c.GetQuery("sort"); ok {
+		cols := parseColumns(head.SchemaColumnsDeployed)
+		policy := newRowPolicy(c, db, parseConditions(head.SchemaConditionsDeployed), visibilityColumn(cols))
+		if err := checkQueryColumns(head, policy, newColumnMasks(c, db, pageMasks(cols)), parseSortParam(v), nil); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	deps, err := parseDependencyOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Only the dependency modes are cached; a custom limit, sort, filter,
+	// expand or page is always rebuilt. Pages with encrypted columns are
+	// never cached: their payload holds the decrypted values.
+	cacheKey := pageCacheKey(id, deps.Mode)
+	expand := parseExpand(c)
+	pagination := utils.ParsePagination(c, 0, listMaxPageSize)
+	encrypted := len(encryptedColumns(parseColumns(head.SchemaColumnsDeployed))) > 0
+	if _, overridden := pageQueryOverrides(c, QueryDefinition{}); deps.Limit > 0 || overridden || !expand.empty() || pagination.Paged() || encrypted {
+		cacheKey = ""
+	}
+
+	if body, ok := cache.Get(c.Request.Context(), cacheKey); cacheKey != "" && ok {
+		sendPagePayload(c, db, body)
+		return
+	}
+
+	build := func(ctx context.Context) ([]byte, error) {
+		var page models.Page
+		if err := db.Preload("Template").First(&page, "id = ?", id).Error; err != nil {
+			return nil, err
+		}
+
+		opts := payloadOptions{Dependencies: deps, Expand: expand, Page: pagination, Context: ctx}
+		if query, overridden := pageQueryOverrides(c, parseQueryDefinition(page.SchemaQueryDeployed)); overridden {
+			opts.Query = &query
+		}
+		payload, err := buildPagePayload(db, page, opts)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(payload)
+	}
+
+	var body []byte
+	if cacheKey == "" {
+		body, err = build(c.Request.Context())
+	} else {
+		// Concurrent loads of the same cacheable payload share one build,
+		// which outlives a caller that goes away.
+		var shared any
+		shared, err, _ = pageFlights.Do(cacheKey, func() (any, error) {
+			ctx := context.WithoutCancel(c.Request.Context())
+			body, err := build(ctx)
+			if err == nil {
+				cache.Set(ctx, cacheKey, body)
+			}
+			return body, err
+		})
+		body, _ = shared.([]byte)
+	}
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "❌ Page introuvable"})
+			return
+		}
+		c.JSON(queryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	sendPagePayload(c, db, body)
+}
+
+// sendPagePayload applies the per-user server conditions to a page payload
+// shared by every user (and possibly cached) and signs its file URLs before
+// writing it, in JSON:API format when asked.
+func sendPagePayload(c *gin.Context, db *gorm.DB, body []byte) {
+	body, err := applyUIRollout(c, db, body)
+	if err == nil {
+		body, err = applyLocale(c, body)
+	}
+	if err == nil {
+		body, err = applyPayloadConditions(c, db, body)
+	}
+	if err == nil {
+		body, err = applyFileURLs(body)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Vary", "Accept, Accept-Language")
+	if wantsJSONAPI(c) {
+		if body, err = pageJSONAPIDocument(body); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		utils.BytesWithETag(c, http.StatusOK, JSONAPIMediaType, body)
+		return
+	}
+	utils.JSONBytesWithETag(c, http.StatusOK, body)
+}
+
+// payloadOptions tunes how buildPagePayload reads the page table and its
+// dependencies.
+type payloadOptions struct {
+	Limit        int
+	Dependencies dependencyOptions
+	// Query replaces the page default sort and filters when set.
+	Query *QueryDefinition
+	// Expand lists the labelled relations sent as full objects.
+	Expand expandSet
+	// Page reads one page of rows (?page, ?pageSize) instead of all of them.
+	Page utils.Pagination
+	// Context cancels the queries, usually with the request; they are also
+	// bounded by services.QueryTimeout.
+	Context context.Context
+}
+
+func buildPagePayload(db *gorm.DB, page models.Page, opts payloadOptions) (gin.H, error) {
+	var raw schemaRaw
+	if page.SchemaRelationsDeployed != nil {
+		_ = json.Unmarshal(page.SchemaRelationsDeployed, &raw.Relations)
+	}
+	if page.SchemaUiDeployed != nil {
+		_ = json.Unmarshal(page.SchemaUiDeployed, &raw.UI)
+	} else {
+		raw.UI = []map[string]any{}
+	}
+
+	var menuDefs []map[string]any
+	if page.SchemaMenuUiDeployed != nil {
+		_ = json.Unmarshal(page.SchemaMenuUiDeployed, &menuDefs)
+	}
+
+	menus := make([]map[string]any, 0, len(menuDefs))
+	for _, m := range menuDefs {
+		menu := map[string]any{
+			"name":  m["name"],
+			"order": m["order"],
+			"refId": fmt.Sprintf("%v", m["refId"]),
+		}
+		// Resolved per request by applyLocale.
+		if variants, ok := m[i18nKey]; ok {
+			menu[i18nKey] = variants
+		}
+		menus = append(menus, menu)
+	}
+
+	data := []map[string]any{}
+	dependencies := make(map[string]any)
+	meta := opts.Page.Meta(0, 0)
+
+	if Bool(page.Deploy) && isProxyPage(page) {
+		rows, total, err := loadSourceRows(page, opts)
+		if err != nil {
+			return nil, err
+		}
+		applyReadFunctions(parseFunctions(page.SchemaFunctionsDeployed), rows...)
+		return pagePayload(page, raw, menus, rows, dependencies, opts.Page.Meta(total, len(rows))), nil
+	}
+
+	if Bool(page.Deploy) && page.TableName != "" {
+		if err := checkPageTables(db, page, raw.Relations); err != nil {
+			return nil, err
+		}
+
+		pageDB, err := PageSQL(db, page)
+		if err != nil {
+			return nil, err
+		}
+		sqlDB, cancel := withQueryTimeout(opts.Context, pageDB)
+		defer cancel()
+		query := parseQueryDefinition(page.SchemaQueryDeployed)
+		if opts.Query != nil {
+			query = *opts.Query
+		}
+		limit := opts.Limit
+		if opts.Page.Paged() {
+			limit = opts.Page.PageSize
+		}
+		var total int64
+		if opts.Page.Paged() {
+			if total, err = countRows(sqlDB, page.TableName, query); err != nil {
+				return nil, err
+			}
+		}
+		rows, err := loadRelatedRows(sqlDB, page.TableName, raw.Relations, func(q *dynamicQuery) error {
+			if err := query.apply(sqlDB, page.TableName, q, limit); err != nil {
+				return err
+			}
+			if offset := opts.Page.Offset(); opts.Page.Paged() && offset > 0 {
+				q.Write(" OFFSET ").Arg(offset)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		meta = opts.Page.Meta(total, len(rows))
+		if len(rows) == 0 {
+			return pagePayload(page, raw, menus, data, dependencies, meta), nil
+		}
+		data = rows
+
+		// The relations to guarded tables are filtered and labelled per
+		// user by applyPayloadConditions.
+		guarded, err := guardedTables(db, raw.Relations)
+		if err != nil {
+			return nil, err
+		}
+		decryptRows(parseColumns(page.SchemaColumnsDeployed), data...)
+		applyReadFunctions(parseFunctions(page.SchemaFunctionsDeployed), data...)
+		applyRelationLabels(unguardedRelations(raw.Relations, guarded), opts.Expand, data...)
+
+		dependencies = loadDependencies(sqlDB, raw.Relations, opts.Dependencies, opts.Expand, guarded)
+	}
+
+	return pagePayload(page, raw, menus, data, dependencies, meta), nil
+}
+
+func pagePayload(page models.Page, raw schemaRaw, menus []map[string]any, data []map[string]any, dependencies map[string]any, meta utils.PageMeta) gin.H {
+	payload := gin.H{
+		"id":               page.ID,
+		"name":             page.Name,
+		"slug":             page.Slug,
+		"tableName":        page.TableName,
+		"template":         page.Template,
+		"schema":           raw.UI,
+		"menus":            menus,
+		"functions":        page.SchemaFunctionsDeployed,
+		"conditions":       page.SchemaConditionsDeployed,
+		"query":            page.SchemaQueryDeployed,
+		"visibilityColumn": visibilityColumn(parseColumns(page.SchemaColumnsDeployed)),
+		"fileColumns":      fileColumns(parseColumns(page.SchemaColumnsDeployed)),
+		"relations":        raw.Relations,
+		"data":             data,
+		"meta":             meta,
+		"dependencies":     dependencies,
+	}
+	// Resolved per user by applyPayloadConditions.
+	if masks := pageMasks(parseColumns(page.SchemaColumnsDeployed)); masks != nil {
+		payload["masks"] = masks
+	}
+	if isReadOnlyPage(page) {
+		payload["readOnly"] = true
+	}
+	// Resolved per user by applyUIRollout.
+	if len(page.SchemaUiRollout) > 0 {
+		payload["schemaRollout"] = page.SchemaUiRollout
+		payload["uiRollout"] = page.UiRollout
+	}
+	return payload
+}
+
+func quoteIdent(ident string) string {
+	safe := strings.ReplaceAll(ident, `"`, `""`)
+	return `"` + safe + `"`
+}
+
+// selfReferencing reports whether rel points back at the page's own table.
+func (rel RelationDefinition) selfReferencing(table string) bool {
+	return rel.ToTable == table
+}
+
+// pivotTableName names the pivot of a many-to-many relation. The relation
+// column is part of the name, so a table can hold several relations to
+// itself ("items_parents_items", "items_related_items").
+func pivotTableName(pageTable string, rel RelationDefinition) string {
+	if rel.PivotTable != "" {
+		return rel.PivotTable
+	}
+	return strings.ToLower(fmt.Sprintf("%s_%s_%s", pageTable, rel.FromColumn, rel.ToTable))
+}
+func splitM2MFields(payload map[string]any, relations []RelationDefinition) (map[string]any, map[string][]string) {
+	simpleFields := map[string]any{}
+	m2mFields := map[string][]string{}
+
+	for _, rel := range relations {
+		if rel.Type != "many-to-many" {
+			continue
+		}
+		if v, ok := payload[rel.FromColumn]; ok && v != nil {
+			arr, ok := v.([]interface{})
+			if !ok {
+				fmt.Println("⚠️ Format M2M invalide pour", rel.FromColumn)
+			} else {
+				ids := []string{}
+				for _, a := range arr {
+					switch val := a.(type) {
+					case string:
+						ids = append(ids, val)
+					case map[string]interface{}:
+						if idv, ok := val["id"]; ok {
+							ids = append(ids, fmt.Sprintf("%v", idv))
+						}
+					default:
+						fmt.Println("⚠️ Valeur M2M inconnue:", a)
+					}
+				}
+				m2mFields[rel.FromColumn] = ids
+			}
+		}
+	}
+
+	for k, v := range payload {
+		if _, isM2M := m2mFields[k]; isM2M {
+			continue
+		}
+		if rel := findRelation(relations, k); rel != nil && rel.Type == "many-to-many" {
+			continue
+		}
+		simpleFields[k] = v
+	}
+	return simpleFields, m2mFields
+}
+
+func findRelation(relations []RelationDefinition, column string) *RelationDefinition {
+	for i := range relations {
+		if relations[i].FromColumn == column {
+			return &relations[i]
+		}
+	}
+	return nil
+}
+
+// loadPivotPairs maps each of ids to the right ids of its pivot rows. A
+// symmetric pivot is also read from the right side.
+func loadPivotPairs(db sqlExecutor, pivot string, symmetric bool, ids []string) (map[string][]string, error) {
+	q := newQuery("SELECT left_id, right_id FROM ").Ident(pivot).
+		Write(" WHERE left_id IN (").ArgList(stringArgs(ids)...).Write(")")
+	if symmetric {
+		q.Write(" OR right_id IN (").ArgList(stringArgs(ids)...).Write(")")
+	}
+
+	rs, err := db.Query(q.SQL(), q.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	m := make(map[string][]string)
+	seen := make(map[[2]string]bool)
+	link := func(from, to string) {
+		if !wanted[from] || seen[[2]string{from, to}] {
+			return
+		}
+		seen[[2]string{from, to}] = true
+		m[from] = append(m[from], to)
+	}
+
+	for rs.Next() {
+		var left, right string
+		if err := rs.Scan(&left, &right); err != nil {
+			continue
+		}
+		link(left, right)
+		if symmetric {
+			link(right, left)
+		}
+	}
+	return m, nil
+}
+
+// snapshotRows copies rows of table, keyed like batchLoadRelated, before
+// their relations are resolved.
+func snapshotRows(table string, rows ...map[string]any) map[string]map[string]any {
+	known := make(map[string]map[string]any, len(rows))
+	for _, row := range rows {
+		if id, ok := row["id"]; ok && id != nil {
+			known[table+":"+fmt.Sprintf("%v", id)] = maps.Clone(row)
+		}
+	}
+	return known
+}
+
+// batchLoadRelated loads the rows referenced by fkByTable, keyed "table:id".
+// Rows found in known are reused instead of queried: for a relation of a
+// table to itself they are plain copies, so a row is never nested into
+// itself and resolution cannot loop.
+func batchLoadRelated(db sqlExecutor, fkByTable map[string]map[string]struct{}, known map[string]map[string]any) map[string]map[string]any {
+	cache := make(map[string]map[string]any)
+
+	for table, idSet := range fkByTable {
+		ids := make([]string, 0, len(idSet))
+		for id := range idSet {
+			if row, ok := known[table+":"+id]; ok {
+				cache[table+":"+id] = row
+				continue
+			}
+			ids = append(ids, id)
+		}
+		if len(ids) == 0 {
+			continue
 		}
 
-		q := fmt.Sprintf(
-			`SELECT * FROM %s WHERE id IN (%s)`,
-			quoteIdent(table),
-			strings.Join(placeholders, ","),
-		)
+		q := newQuery("SELECT * FROM ").Ident(table).
+			Write(" WHERE id IN (").ArgList(stringArgs(ids)...).Write(")")
 
-		rs, err := db.Query(q, args...)
+		rs, err := db.Query(q.SQL(), q.Args()...)
 		if err != nil {
 			continue
 		}
@@ -0,0 +1,186 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+)
+
+type SortDefinition struct {
+	Column    string `json:"column"`
+	Direction string `json:"direction,omitempty"`
+}
+
+// FilterDefinition compares Column to Value. Op is one of eq (default), ne,
+// lt, lte, gt, gte, in, null and notNull.
+type FilterDefinition struct {
+	Column string `json:"column"`
+	Op     string `json:"op,omitempty"`
+	Value  any    `json:"value"`
+}
+
+// QueryDefinition is the default sort and the baseline filters of a page,
+// applied when GET /page/:id reads its table.
+type QueryDefinition struct {
+	Sort    []SortDefinition   `json:"sort,omitempty"`
+	Filters []FilterDefinition `json:"filters,omitempty"`
+}
+
+func parseQueryDefinition(raw datatypes.JSON) QueryDefinition {
+	var def QueryDefinition
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &def)
+	}
+	return def
+}
+
+var filterOperators = map[string]string{
+	"":    "=",
+	"eq":  "=",
+	"ne":  "<>",
+	"lt":  "<",
+	"lte": "<=",
+	"gt":  ">",
+	"gte": ">=",
+}
+
+// parseSortParam reads ?sort=name,-created_at.
+func parseSortParam(v string) []SortDefinition {
+	var sorts []SortDefinition
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		sort := SortDefinition{Column: part, Direction: "asc"}
+		if strings.HasPrefix(part, "-") {
+			sort = SortDefinition{Column: part[1:], Direction: "desc"}
+		}
+		sorts = append(sorts, sort)
+	}
+	return sorts
+}
+
+// pageQueryOverrides reads the query parameters replacing the page defaults:
+// ?sort replaces the default sort, ?defaultFilters=off drops the baseline
+// filters. overridden is false when the request keeps the defaults.
+func pageQueryOverrides(c *gin.Context, def QueryDefinition) (query QueryDefinition, overridden bool) {
+	query = def
+	if v, ok := c.GetQuery("sort"); ok {
+		query.Sort = parseSortParam(v)
+		overridden = true
+	}
+	if c.Query("defaultFilters") == "off" {
+		query.Filters = nil
+		overridden = true
+	}
+	return query, overridden
+}
+
+// apply writes the WHERE and ORDER BY clauses of def. Columns must exist in
+// the table; limit > 0 adds a LIMIT, ordering by id when no sort is set.
+func (def QueryDefinition) apply(sqlDB sqlExecutor, table string, q *dynamicQuery, limit int) error {
+	if len(def.Sort) > 0 || len(def.Filters) > 0 {
+		cols, err := getColumns(sqlDB, table)
+		if err != nil {
+			return err
+		}
+		known := make(map[string]bool, len(cols))
+		for _, col := range cols {
+			known[col] = true
+		}
+		for _, f := range def.Filters {
+			if !known[f.Column] {
+				return fmt.Errorf("%w: unknown filter column %q", ErrInvalidIdentifier, f.Column)
+			}
+		}
+		for _, s := range def.Sort {
+			if !known[s.Column] {
+				return fmt.Errorf("%w: unknown sort column %q", ErrInvalidIdentifier, s.Column)
+			}
+		}
+	}
+
+	for i, f := range def.Filters {
+		if i == 0 {
+			q.Write(" WHERE ")
+		} else {
+			q.Write(" AND ")
+		}
+		q.Ident(f.Column)
+
+		switch f.Op {
+		case "null":
+			q.Write(" IS NULL")
+		case "notNull":
+			q.Write(" IS NOT NULL")
+		case "in":
+			values, _ := f.Value.([]any)
+			if len(values) == 0 {
+				q.Write(" IN (NULL)")
+				continue
+			}
+			q.Write(" IN (").ArgList(values...).Write(")")
+		default:
+			op, ok := filterOperators[f.Op]
+			if !ok {
+				return fmt.Errorf("%w: unknown filter operator %q", ErrInvalidIdentifier, f.Op)
+			}
+			q.Write(" ", op, " ").Arg(f.Value)
+		}
+	}
+
+	sorts := def.Sort
+	if len(sorts) == 0 && limit > 0 {
+		sorts = []SortDefinition{{Column: "id"}}
+	}
+	for i, s := range sorts {
+		if i == 0 {
+			q.Write(" ORDER BY ")
+		} else {
+			q.Write(", ")
+		}
+		q.Ident(s.Column)
+		if strings.EqualFold(s.Direction, "desc") {
+			q.Write(" DESC")
+		} else {
+			q.Write(" ASC")
+		}
+	}
+
+	if limit > 0 {
+		q.Write(" LIMIT ").Arg(limit)
+	}
+	return nil
+}
+
+// countRows counts the rows of table matching the filters of def.
+func countRows(sqlDB sqlExecutor, table string, def QueryDefinition) (int64, error) {
+	def.Sort = nil
+	q := newQuery("SELECT COUNT(*) FROM ").Ident(table)
+	if err := def.apply(sqlDB, table, q, 0); err != nil {
+		return 0, err
+	}
+	var total int64
+	err := sqlDB.QueryRow(q.SQL(), q.Args()...).Scan(&total)
+	return total, err
+}
@@ -0,0 +1,199 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	previewDefaultLimit = 10
+	previewMaxLimit     = 100
+)
+
+// draftPage returns a copy of page whose deployed schemas are replaced by the
+// draft ones, so the regular payload builder renders what a deploy would.
+func draftPage(page models.Page) models.Page {
+	draft := page
+	draft.SchemaColumnsDeployed = page.SchemaColumns
+	draft.SchemaRelationsDeployed = page.SchemaRelations
+	draft.SchemaUiDeployed = page.SchemaUi
+	draft.SchemaMenuUiDeployed = page.SchemaMenuUi
+	draft.SchemaConditionsDeployed = page.SchemaConditions
+	draft.SchemaFunctionsDeployed = page.SchemaFunctions
+	draft.SchemaQueryDeployed = page.SchemaQuery
+	return draft
+}
+
+// mockValue fills a column that has no data yet, based on its declared type.
+func mockValue(col ColumnDefinition, i int) any {
+	switch strings.ToLower(col.Type) {
+	case "int", "integer", "bigint", "smallint", "number", "numeric", "decimal", "float", "double", "real":
+		return i + 1
+	case "bool", "boolean":
+		return i%2 == 0
+	case "date", "datetime", "timestamp", "timestamptz", "time":
+		return time.Now().AddDate(0, 0, -i)
+	case "json", "jsonb":
+		return map[string]any{}
+	case "uuid":
+		return fmt.Sprintf("00000000-0000-0000-0000-%012d", i+1)
+	}
+	return fmt.Sprintf("%s %d", col.Name, i+1)
+}
+
+// buildPagePreview renders the GET /page/:id shape from the draft schema.
+// Deployed pages use a sample of their real rows, projected onto the draft
+// columns; pages without a table get mock rows. Draft relations towards
+// tables that do not exist yet are dropped and reported as warnings.
+func buildPagePreview(db *gorm.DB, page models.Page, limit int) (gin.H, error) {
+	draft := draftPage(page)
+	warnings := []string{}
+
+	registry, err := loadTableRegistry(db)
+	if err != nil {
+		return nil, err
+	}
+
+	hasTable := Bool(page.Deploy) && registry.check(page.Storage, page.TableName) == nil
+
+	relations := []RelationDefinition{}
+	for _, rel := range parseRelations(draft.SchemaRelationsDeployed) {
+		if err := registry.check(page.Storage, rel.ToTable); err != nil {
+			warnings = append(warnings, fmt.Sprintf("relation %s ignorée: %v", rel.FromColumn, err))
+			continue
+		}
+		if rel.Type == "many-to-many" && (!hasTable || registry.check(page.Storage, pivotTableName(page.TableName, rel)) != nil) {
+			warnings = append(warnings, fmt.Sprintf("relation %s ignorée: table pivot non déployée", rel.FromColumn))
+			continue
+		}
+		relations = append(relations, rel)
+	}
+	draft.SchemaRelationsDeployed, _ = json.Marshal(relations)
+
+	if !hasTable {
+		draft.Deploy = new(bool)
+	}
+
+	payload, err := buildPagePayload(db, draft, payloadOptions{Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+
+	columns := parseColumns(draft.SchemaColumnsDeployed)
+	data, _ := payload["data"].([]map[string]any)
+
+	mock := !hasTable
+	if mock {
+		data = make([]map[string]any, 0, limit)
+		for i := 0; i < limit && len(columns) > 0; i++ {
+			data = append(data, map[string]any{"id": fmt.Sprintf("preview-%d", i+1)})
+		}
+	}
+
+	if len(columns) > 0 {
+		keep := map[string]bool{"id": true}
+		for _, col := range columns {
+			keep[col.Name] = true
+		}
+		for _, rel := range relations {
+			keep[rel.FromColumn] = true
+		}
+		for _, fn := range parseFunctions(draft.SchemaFunctionsDeployed) {
+			keep[fn.target()] = true
+		}
+
+		for i, row := range data {
+			for key := range row {
+				if !keep[key] {
+					delete(row, key)
+				}
+			}
+			for _, col := range columns {
+				if _, ok := row[col.Name]; !ok {
+					row[col.Name] = mockValue(col, i)
+				}
+			}
+		}
+	}
+
+	if mock {
+		decryptRows(parseColumns(draft.SchemaColumnsDeployed), data...)
+		applyReadFunctions(parseFunctions(draft.SchemaFunctionsDeployed), data...)
+	}
+
+	payload["data"] = data
+	payload["preview"] = gin.H{
+		"draft":    true,
+		"mock":     mock,
+		"limit":    limit,
+		"warnings": warnings,
+	}
+	return payload, nil
+}
+
+// serveDraftPreview answers GET /page/:id?preview=draft: the page rendered
+// from its draft schemas by buildPagePreview, then sent like the deployed
+// payload so that the draft conditions, masks and file URLs apply. The rows
+// are a copy read from the table, never written back. Admins only.
+func serveDraftPreview(c *gin.Context, db *gorm.DB, id string) {
+	if user := utils.CurrentUser(c); user == nil || !Bool(user.IsAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Aperçu réservé aux administrateurs"})
+		return
+	}
+
+	var page models.Page
+	if err := db.Preload("Template").First(&page, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "❌ Page introuvable"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := previewDefaultLimit
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+		limit = min(v, previewMaxLimit)
+	}
+	payload, err := buildPagePreview(db, page, limit)
+	if err != nil {
+		c.JSON(queryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	// The draft UI is the one previewed, whatever rollout is running.
+	delete(payload, "schemaRollout")
+	delete(payload, "uiRollout")
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	sendPagePayload(c, db, body)
+}
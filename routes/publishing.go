@@ -0,0 +1,135 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// pageTransition moves a page from one of from to to. Review transitions
+// are for reviewers only; the others are open to every builder user.
+type pageTransition struct {
+	from   []string
+	to     string
+	review bool
+}
+
+var pageTransitions = map[string]pageTransition{
+	"submit":    {from: []string{models.PageStatusDraft}, to: models.PageStatusInReview},
+	"withdraw":  {from: []string{models.PageStatusInReview}, to: models.PageStatusDraft},
+	"approve":   {from: []string{models.PageStatusInReview}, to: models.PageStatusPublished, review: true},
+	"reject":    {from: []string{models.PageStatusInReview}, to: models.PageStatusDraft, review: true},
+	"unpublish": {from: []string{models.PageStatusPublished}, to: models.PageStatusDraft, review: true},
+	"archive":   {from: []string{models.PageStatusDraft, models.PageStatusPublished}, to: models.PageStatusArchived, review: true},
+	"reopen":    {from: []string{models.PageStatusArchived}, to: models.PageStatusDraft, review: true},
+}
+
+// isPageReviewer reports whether user may publish pages: admins, and the
+// members of the groups listed in PAGE_REVIEWER_GROUPS.
+func isPageReviewer(user *models.User) bool {
+	if user == nil {
+		return false
+	}
+	if Bool(user.IsAdmin) {
+		return true
+	}
+	var groups []string
+	_ = json.Unmarshal(user.Groups, &groups)
+	for _, g := range strings.Split(os.Getenv("PAGE_REVIEWER_GROUPS"), ",") {
+		if g = strings.TrimSpace(g); g != "" && slices.Contains(groups, g) {
+			return true
+		}
+	}
+	return false
+}
+
+// pageVisible reports whether the current user may read a page in status:
+// published pages are served to everyone, the others to admins only.
+func pageVisible(c *gin.Context, status string) bool {
+	if status == models.PageStatusPublished {
+		return true
+	}
+	user := utils.CurrentUser(c)
+	return user != nil && Bool(user.IsAdmin)
+}
+
+func RegisterBuilderPublishingRoutes(group *gin.RouterGroup, db *gorm.DB, cache *services.Cache) {
+	// POST /builder/:id/<transition> moves the page through the publishing
+	// workflow. The body may carry a comment, kept in the audit log.
+	for name, transition := range pageTransitions {
+		group.POST("/builder/:id/"+name, func(c *gin.Context) {
+			db := utils.DB(c, db)
+			id := c.Param("id")
+			var payload struct {
+				Comment string `json:"comment"`
+			}
+			if c.Request.ContentLength > 0 {
+				if err := c.ShouldBindJSON(&payload); err != nil {
+					utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+					return
+				}
+			}
+			if transition.review && !isPageReviewer(utils.CurrentUser(c)) {
+				utils.Error(c, http.StatusForbidden, "FORBIDDEN", "Only reviewers may "+name+" a page")
+				return
+			}
+
+			var before models.Page
+			if err := db.First(&before, "id = ?", id).Error; err != nil {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+				return
+			}
+			res := db.Model(&models.Page{}).Where("id = ? AND status IN ?", id, transition.from).
+				Update("status", transition.to)
+			if res.Error != nil {
+				utils.Error(c, http.StatusInternalServerError, "DB_UPDATE_ERROR", res.Error.Error())
+				return
+			}
+			if res.RowsAffected == 0 {
+				utils.Error(c, http.StatusConflict, "INVALID_TRANSITION",
+					fmt.Sprintf("Cannot %s a page in status %s", name, before.Status))
+				return
+			}
+			invalidatePageCache(c, cache, id)
+			// The menu hides the items of unpublished pages.
+			invalidateNavigationCache(c, cache)
+			recordAudit(c, db, name, services.AuditResourcePage, &id, services.AuditStatusSuccess, gin.H{
+				"from":    before.Status,
+				"to":      transition.to,
+				"comment": payload.Comment,
+			})
+
+			var updated models.Page
+			if err := db.Preload("Template").Preload("Tags.Category").First(&updated, "id = ?", id).Error; err != nil {
+				utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"data": updated, "success": true})
+		})
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"fmt"
+	"strings"
+)
+
+type dynamicQuery struct {
+	sql  strings.Builder
+	args []any
+}
+
+func newQuery(parts ...string) *dynamicQuery {
+	q := &dynamicQuery{}
+	for _, p := range parts {
+		q.sql.WriteString(p)
+	}
+	return q
+}
+
+func (q *dynamicQuery) Write(parts ...string) *dynamicQuery {
+	for _, p := range parts {
+		q.sql.WriteString(p)
+	}
+	return q
+}
+
+func (q *dynamicQuery) Ident(name string) *dynamicQuery {
+	q.sql.WriteString(quoteIdent(name))
+	return q
+}
+
+func (q *dynamicQuery) Idents(names []string) *dynamicQuery {
+	for i, name := range names {
+		if i > 0 {
+			q.sql.WriteString(", ")
+		}
+		q.Ident(name)
+	}
+	return q
+}
+
+func (q *dynamicQuery) Arg(value any) *dynamicQuery {
+	q.args = append(q.args, value)
+	fmt.Fprintf(&q.sql, "$%d", len(q.args))
+	return q
+}
+
+func (q *dynamicQuery) ArgList(values ...any) *dynamicQuery {
+	for i, v := range values {
+		if i > 0 {
+			q.sql.WriteString(", ")
+		}
+		q.Arg(v)
+	}
+	return q
+}
+
+func (q *dynamicQuery) SQL() string {
+	return q.sql.String()
+}
+
+func (q *dynamicQuery) Args() []any {
+	return q.args
+}
+
+func stringArgs(values []string) []any {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
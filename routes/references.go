@@ -0,0 +1,195 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/utils"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// referenceIDLimit caps the ids listed per referencing relation; Count
+// always covers every row.
+const referenceIDLimit = 50
+
+// Reference is a relation of a deployed page pointing at a row, with the
+// rows of that page that point at it. Restricts reports whether those rows
+// keep the row from being deleted. IDs is empty when a row policy guards
+// the page: only the count is given.
+type Reference struct {
+	PageID    string   `json:"pageId"`
+	PageName  string   `json:"pageName"`
+	Table     string   `json:"table"`
+	Column    string   `json:"column"`
+	Type      string   `json:"type"`
+	OnDelete  string   `json:"onDelete,omitempty"`
+	Restricts bool     `json:"restricts"`
+	Count     int64    `json:"count"`
+	IDs       []string `json:"ids"`
+}
+
+func tableExists(sqlDB sqlExecutor, table string) (bool, error) {
+	var exists bool
+	err := sqlDB.QueryRow("SELECT to_regclass($1) IS NOT NULL", quoteIdent(table)).Scan(&exists)
+	return exists, err
+}
+
+// referencingRows counts the rows of table whose column holds itemID and
+// lists the ids of the first ones.
+func referencingRows(sqlDB *sql.DB, table, idColumn, column, itemID string) (int64, []string, error) {
+	var count int64
+	q := newQuery("SELECT COUNT(*) FROM ").Ident(table).Write(" WHERE ").Ident(column).Write("::text = ").Arg(itemID)
+	if err := sqlDB.QueryRow(q.SQL(), q.Args()...).Scan(&count); err != nil {
+		return 0, nil, err
+	}
+
+	q = newQuery("SELECT ").Ident(idColumn).Write("::text FROM ").Ident(table).
+		Write(" WHERE ").Ident(column).Write("::text = ").Arg(itemID).
+		Write(" ORDER BY 1 LIMIT ").Arg(referenceIDLimit)
+	rows, err := sqlDB.Query(q.SQL(), q.Args()...)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return 0, nil, err
+		}
+		ids = append(ids, id)
+	}
+	return count, ids, rows.Err()
+}
+
+// findReferences lists the relations of the deployed pages of page's
+// storage that point at itemID, skipping those no row uses and the pages
+// the user may not see.
+func findReferences(c *gin.Context, db *gorm.DB, sqlDB *sql.DB, page models.Page, itemID string) ([]Reference, error) {
+	var pages []models.Page
+	if err := db.Select("id", "name", "status", "table_name", "storage", "schema_relations_deployed", "schema_columns_deployed", "schema_conditions_deployed").
+		Where("deploy = ? AND table_name <> '' AND COALESCE(storage, '') = ?", true, page.Storage).
+		Order("name").Find(&pages).Error; err != nil {
+		return nil, err
+	}
+
+	refs := []Reference{}
+	seen := map[string]bool{}
+	for _, p := range pages {
+		if !pageVisible(c, p.Status) {
+			continue
+		}
+		policy := newRowPolicy(c, db, parseConditions(p.SchemaConditionsDeployed), visibilityColumn(parseColumns(p.SchemaColumnsDeployed)))
+		for _, rel := range parseRelations(p.SchemaRelationsDeployed) {
+			if rel.ToTable != page.TableName || validateIdent(rel.FromColumn) != nil || policy != nil && policy.hidden[rel.FromColumn] {
+				continue
+			}
+			ref := Reference{PageID: p.ID, PageName: p.Name, Column: rel.FromColumn, Type: rel.Type, OnDelete: rel.OnDelete}
+
+			// One-to-* relations hold the id in a column of the page
+			// table; many-to-many ones in the right_id of the pivot.
+			table, idColumn, column := p.TableName, "id", rel.FromColumn
+			switch rel.Type {
+			case "one-to-one", "one-to-many":
+				action, _ := onDeleteAction(rel.OnDelete, "")
+				ref.Restricts = action == "RESTRICT" || action == "NO ACTION"
+			case "many-to-many":
+				table, idColumn, column = pivotTableName(p.TableName, rel), "left_id", "right_id"
+				action, _ := onDeleteAction(rel.OnDelete, "CASCADE")
+				ref.Restricts = action == "RESTRICT" || action == "NO ACTION"
+			default:
+				continue
+			}
+			key := table + "." + column
+			if seen[key] || validateIdent(table) != nil || isCoreTable(db, table) {
+				continue
+			}
+			seen[key] = true
+
+			if exists, err := tableExists(sqlDB, table); err != nil {
+				return nil, err
+			} else if !exists {
+				continue
+			}
+			count, ids, err := referencingRows(sqlDB, table, idColumn, column, itemID)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", table, column, err)
+			}
+			if count == 0 {
+				continue
+			}
+			if policy != nil {
+				ids = []string{}
+			}
+			ref.Table, ref.Count, ref.IDs = p.TableName, count, ids
+			refs = append(refs, ref)
+		}
+	}
+	return refs, nil
+}
+
+func RegisterPageReferenceRoutes(r gin.IRoutes, db *gorm.DB) {
+	// GET /page/:id/:itemId/referenced-by lists the rows of every deployed
+	// page whose relations point at the item, e.g. to warn before deleting a
+	// shared reference record. restricted is true when one of them will
+	// refuse the delete.
+	r.GET("/page/:id/:itemId/referenced-by", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		itemID := c.Param("itemId")
+
+		page, raw, ok := loadDeployedPage(c, db, c.Param("id"))
+		if !ok {
+			return
+		}
+
+		sqlDB, err := PageSQL(db, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		item, err := loadItem(sqlDB, page, raw, itemID)
+		if err != nil || !applyItemConditions(c, db, page, item) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item introuvable"})
+			return
+		}
+
+		refs, err := findReferences(c, db, sqlDB, page, itemID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var total int64
+		restricted := false
+		for _, ref := range refs {
+			total += ref.Count
+			restricted = restricted || ref.Restricts
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"id":         itemID,
+			"data":       refs,
+			"total":      total,
+			"restricted": restricted,
+		})
+	})
+}
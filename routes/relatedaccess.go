@@ -0,0 +1,199 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// guardedTables lists, of the tables relations point at, those deployed by
+// a page that restricts its rows or columns: server conditions, a
+// visibility column or masks. Their rows, embedded or in the dependencies,
+// are filtered for each user before being sent.
+func guardedTables(db *gorm.DB, relations []RelationDefinition) (map[string]models.Page, error) {
+	guarded := map[string]models.Page{}
+	var tables []string
+	for _, rel := range relations {
+		if rel.ToTable != "" {
+			tables = append(tables, rel.ToTable)
+		}
+	}
+	if len(tables) == 0 {
+		return guarded, nil
+	}
+
+	var pages []models.Page
+	if err := db.Select("id", "table_name", "schema_columns_deployed", "schema_conditions_deployed").
+		Where("table_name IN ? AND deploy = ?", tables, true).
+		Order("created_at").Find(&pages).Error; err != nil {
+		return nil, err
+	}
+	for _, page := range pages {
+		if _, ok := guarded[page.TableName]; ok {
+			continue
+		}
+		cols := parseColumns(page.SchemaColumnsDeployed)
+		if hasServerConditions(parseConditions(page.SchemaConditionsDeployed)) ||
+			visibilityColumn(cols) != "" || pageMasks(cols) != nil {
+			guarded[page.TableName] = page
+		}
+	}
+	return guarded, nil
+}
+
+// unguardedRelations keeps the relations whose rows are the same for every
+// user, which may be labelled before the payload is shared.
+func unguardedRelations(relations []RelationDefinition, guarded map[string]models.Page) []RelationDefinition {
+	out := make([]RelationDefinition, 0, len(relations))
+	for _, rel := range relations {
+		if _, ok := guarded[rel.ToTable]; !ok {
+			out = append(out, rel)
+		}
+	}
+	return out
+}
+
+// relatedPolicy is what the current user may see of a guarded table.
+type relatedPolicy struct {
+	policy *rowPolicy
+	masks  columnMasks
+}
+
+// admit reports whether the user may see row, stripping its hidden columns
+// and masking the masked ones in place.
+func (p relatedPolicy) admit(row map[string]any) bool {
+	if p.policy != nil {
+		if !p.policy.allows(row) {
+			return false
+		}
+		p.policy.strip(row)
+	}
+	p.masks.apply(row)
+	return true
+}
+
+// relatedAccess is the access of the current user to the guarded tables of
+// a page. A guarded table none of whose rules concerns the user has no
+// policy.
+type relatedAccess struct {
+	guarded  map[string]models.Page
+	policies map[string]relatedPolicy
+}
+
+func loadRelatedAccess(c *gin.Context, db *gorm.DB, relations []RelationDefinition) (relatedAccess, error) {
+	guarded, err := guardedTables(db, relations)
+	if err != nil {
+		return relatedAccess{}, err
+	}
+	access := relatedAccess{guarded: guarded, policies: map[string]relatedPolicy{}}
+	for table, page := range guarded {
+		cols := parseColumns(page.SchemaColumnsDeployed)
+		p := relatedPolicy{
+			policy: newRowPolicy(c, db, parseConditions(page.SchemaConditionsDeployed), visibilityColumn(cols)),
+			masks:  newColumnMasks(c, db, pageMasks(cols)),
+		}
+		if p.policy != nil || p.masks != nil {
+			access.policies[table] = p
+		}
+	}
+	return access, nil
+}
+
+// rows filters the objects of the guarded relations embedded in rows: a
+// hidden one-to-* object is replaced by its id, a hidden many-to-many one
+// is left out of the list. The guarded relations are then labelled, the
+// others having been when the rows were read.
+func (a relatedAccess) rows(relations []RelationDefinition, expand expandSet, rows ...map[string]any) {
+	var guarded []RelationDefinition
+	for _, rel := range relations {
+		if _, ok := a.guarded[rel.ToTable]; ok {
+			guarded = append(guarded, rel)
+		}
+	}
+	for _, rel := range guarded {
+		p, restricted := a.policies[rel.ToTable]
+		if !restricted {
+			continue
+		}
+		for _, row := range rows {
+			switch v := row[rel.FromColumn].(type) {
+			case map[string]any:
+				if !p.admit(v) {
+					row[rel.FromColumn] = v["id"]
+				}
+			case []any:
+				list := make([]any, 0, len(v))
+				for _, item := range v {
+					if obj, ok := item.(map[string]any); ok && !p.admit(obj) {
+						continue
+					}
+					list = append(list, item)
+				}
+				row[rel.FromColumn] = list
+			}
+		}
+	}
+	applyRelationLabels(guarded, expand, rows...)
+}
+
+// dependencies trims the guarded tables of a dependencies block, read whole
+// by loadDependencies: the rows the user may not see are dropped, the
+// others stripped and masked, then projected and limited as the other
+// tables were.
+func (a relatedAccess) dependencies(dependencies map[string]any, relations []RelationDefinition, opts dependencyOptions, expand expandSet) {
+	for _, spec := range dependencySpecs(relations, opts, expand) {
+		if _, ok := a.guarded[spec.table]; !ok {
+			continue
+		}
+		value, ok := dependencies[spec.table]
+		if !ok {
+			continue
+		}
+		p, restricted := a.policies[spec.table]
+		kept := []map[string]any{}
+		for _, row := range relatedRows(value) {
+			if spec.limit > 0 && len(kept) >= spec.limit {
+				break
+			}
+			if restricted && !p.admit(row) {
+				continue
+			}
+			kept = append(kept, spec.project(row))
+		}
+		dependencies[spec.table] = dependencyRows(opts, kept)
+	}
+}
+
+// relatedRows reads a list of rows, in memory or decoded from JSON.
+func relatedRows(value any) []map[string]any {
+	switch v := value.(type) {
+	case []map[string]any:
+		return v
+	case []any:
+		rows := make([]map[string]any, 0, len(v))
+		for _, item := range v {
+			if row, ok := item.(map[string]any); ok {
+				rows = append(rows, row)
+			}
+		}
+		return rows
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Aliases of the relation query. The page table is aliased so the
+// subqueries can reach its row whatever the names of the related tables.
+const (
+	joinRowAlias     = "__row"
+	joinRelatedAlias = "__related"
+	joinPivotAlias   = "__pivot"
+)
+
+// relationColumn names the column carrying relations[i] in the rows read by
+// selectWithRelations.
+func relationColumn(i int) string {
+	return fmt.Sprintf("__relation_%d", i)
+}
+
+// selectWithRelations starts the SELECT of the rows of table with each
+// relation resolved in the same query: a correlated subquery per relation
+// returns the related row (one-to-*) or the array of related rows
+// (many-to-many) as JSON. The subqueries sit in the select list, so the
+// WHERE and ORDER BY written after it by QueryDefinition.apply only see the
+// page table.
+func selectWithRelations(table string, relations []RelationDefinition) *dynamicQuery {
+	q := newQuery("SELECT ").Ident(joinRowAlias).Write(".*")
+	for i, rel := range relations {
+		switch rel.Type {
+		case "one-to-one", "one-to-many":
+			q.Write(", (SELECT to_jsonb(").Ident(joinRelatedAlias).Write(") FROM ").Ident(rel.ToTable).Write(" AS ").Ident(joinRelatedAlias).
+				Write(" WHERE ").Ident(joinRelatedAlias).Write(".id = ").Ident(joinRowAlias).Write(".").Ident(rel.FromColumn).Write(")")
+
+		case "many-to-many":
+			// The other end of a pivot row: its right id, or for a symmetric
+			// relation whichever side is not the row. A pivot row whose
+			// target is gone yields its id, like the batched loader.
+			other := newQuery("").Ident(joinPivotAlias).Write(".right_id")
+			where := newQuery("").Ident(joinPivotAlias).Write(".left_id = ").Ident(joinRowAlias).Write(".id")
+			if rel.Symmetric && rel.selfReferencing(table) {
+				other = newQuery("CASE WHEN ").Ident(joinPivotAlias).Write(".left_id = ").Ident(joinRowAlias).Write(".id THEN ").
+					Ident(joinPivotAlias).Write(".right_id ELSE ").Ident(joinPivotAlias).Write(".left_id END")
+				where.Write(" OR ").Ident(joinPivotAlias).Write(".right_id = ").Ident(joinRowAlias).Write(".id")
+			}
+			q.Write(", (SELECT jsonb_agg(DISTINCT COALESCE(to_jsonb(").Ident(joinRelatedAlias).Write("), to_jsonb((").Write(other.SQL()).Write(")::text))) FROM ").
+				Ident(pivotTableName(table, rel)).Write(" AS ").Ident(joinPivotAlias).
+				Write(" LEFT JOIN ").Ident(rel.ToTable).Write(" AS ").Ident(joinRelatedAlias).
+				Write(" ON ").Ident(joinRelatedAlias).Write(".id = ").Write(other.SQL()).
+				Write(" WHERE ").Write(where.SQL()).Write(")")
+
+		default:
+			continue
+		}
+		q.Write(" AS ").Ident(relationColumn(i))
+	}
+	return q.Write(" FROM ").Ident(table).Write(" AS ").Ident(joinRowAlias)
+}
+
+// isJoinError reports whether the relation query was refused for its shape,
+// e.g. a foreign key whose type differs from the id it points at or a
+// missing pivot. The rows are then read again with the batched loader.
+func isJoinError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && strings.HasPrefix(pgErr.Code, "42")
+}
+
+// queryRows reads every row of q as a map.
+func queryRows(db sqlExecutor, q *dynamicQuery) ([]map[string]any, error) {
+	rows, err := db.Query(q.SQL(), q.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, _ := rows.Columns()
+	out := make([]map[string]any, 0)
+	for rows.Next() {
+		values := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range cols {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			continue
+		}
+
+		entry := make(map[string]any, len(cols))
+		for i, col := range cols {
+			entry[col] = values[i]
+		}
+		out = append(out, entry)
+	}
+	return out, rows.Err()
+}
+
+// resolveJoinedRelations moves the relation columns of rows read by
+// selectWithRelations onto the relation columns: a one-to-* column gets the
+// related row (its id is kept when the row is gone), a many-to-many column
+// the list of related rows.
+func resolveJoinedRelations(relations []RelationDefinition, rows ...map[string]any) {
+	for _, entry := range rows {
+		for i, rel := range relations {
+			col := relationColumn(i)
+			value, ok := entry[col]
+			if !ok {
+				continue
+			}
+			delete(entry, col)
+
+			var related any
+			if b, ok := value.([]byte); ok {
+				dec := json.NewDecoder(bytes.NewReader(b))
+				dec.UseNumber()
+				_ = dec.Decode(&related)
+			}
+			switch rel.Type {
+			case "one-to-one", "one-to-many":
+				if related != nil {
+					entry[rel.FromColumn] = related
+				}
+			case "many-to-many":
+				list, _ := related.([]any)
+				if list == nil {
+					list = []any{}
+				}
+				entry[rel.FromColumn] = list
+			}
+		}
+	}
+}
+
+// resolveBatchedRelations resolves the relations of rows with one query per
+// pivot and per related table. It is the loader of tables the relation
+// query cannot read.
+func resolveBatchedRelations(db sqlExecutor, table string, relations []RelationDefinition, rows ...map[string]any) {
+	allIDs := make([]string, 0, len(rows))
+	for _, entry := range rows {
+		if idv, ok := entry["id"]; ok && idv != nil {
+			allIDs = append(allIDs, fmt.Sprintf("%v", idv))
+		}
+	}
+
+	pivotData := make(map[string]map[string][]string)
+	for _, rel := range relations {
+		if rel.Type != "many-to-many" || len(allIDs) == 0 {
+			continue
+		}
+		pivot := pivotTableName(table, rel)
+		m, err := loadPivotPairs(db, pivot, rel.Symmetric && rel.selfReferencing(table), allIDs)
+		if err != nil {
+			continue
+		}
+		pivotData[pivot] = m
+	}
+
+	fkByTable := make(map[string]map[string]struct{})
+	for _, rel := range relations {
+		switch rel.Type {
+		case "one-to-one", "one-to-many":
+			for _, entry := range rows {
+				if fk, ok := entry[rel.FromColumn]; ok && fk != nil {
+					if idStr := fmt.Sprintf("%v", fk); idStr != "" {
+						addFK(fkByTable, rel.ToTable, idStr)
+					}
+				}
+			}
+		case "many-to-many":
+			for _, rights := range pivotData[pivotTableName(table, rel)] {
+				for _, rid := range rights {
+					addFK(fkByTable, rel.ToTable, rid)
+				}
+			}
+		}
+	}
+
+	objCache := batchLoadRelated(db, fkByTable, snapshotRows(table, rows...))
+
+	for _, entry := range rows {
+		for _, rel := range relations {
+			switch rel.Type {
+			case "one-to-one", "one-to-many":
+				if fk, ok := entry[rel.FromColumn]; ok && fk != nil {
+					idStr := fmt.Sprintf("%v", fk)
+					if idStr == "" {
+						continue
+					}
+					if obj, ok := objCache[rel.ToTable+":"+idStr]; ok {
+						entry[rel.FromColumn] = obj
+					}
+				}
+
+			case "many-to-many":
+				rightIDs := pivotData[pivotTableName(table, rel)][fmt.Sprintf("%v", entry["id"])]
+				list := make([]any, 0, len(rightIDs))
+				for _, rid := range rightIDs {
+					if obj, ok := objCache[rel.ToTable+":"+rid]; ok {
+						list = append(list, obj)
+					} else {
+						list = append(list, rid)
+					}
+				}
+				entry[rel.FromColumn] = list
+			}
+		}
+	}
+}
+
+// loadRelatedRows reads the rows selected by where (a WHERE, ORDER BY and
+// LIMIT written on the query) with their relations resolved, in one query
+// when possible.
+func loadRelatedRows(db sqlExecutor, table string, relations []RelationDefinition, where func(q *dynamicQuery) error) ([]map[string]any, error) {
+	q := selectWithRelations(table, relations)
+	if err := where(q); err != nil {
+		return nil, err
+	}
+	rows, err := queryRows(db, q)
+	if err == nil {
+		resolveJoinedRelations(relations, rows...)
+		return rows, nil
+	}
+	if !isJoinError(err) {
+		return nil, err
+	}
+
+	q = newQuery("SELECT * FROM ").Ident(table)
+	if err := where(q); err != nil {
+		return nil, err
+	}
+	if rows, err = queryRows(db, q); err != nil {
+		return nil, err
+	}
+	resolveBatchedRelations(db, table, relations, rows...)
+	return rows, nil
+}
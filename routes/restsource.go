@@ -0,0 +1,347 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+var (
+	ErrInvalidSource     = errors.New("invalid page source")
+	ErrSourceUnavailable = errors.New("page source unavailable")
+)
+
+// Auth schemes of a REST source. The secret is never stored on the page:
+// SecretEnv names the environment variable holding it, which must start
+// with SourceSecretPrefix so that a page cannot send out the other secrets
+// of the API.
+const (
+	SourceAuthBearer = "bearer"
+	SourceAuthBasic  = "basic"
+	SourceAuthHeader = "header"
+
+	SourceSecretPrefix = "PAGE_SOURCE_SECRET_"
+)
+
+const (
+	defaultSourceTimeout = 10 * time.Second
+	maxSourceTimeout     = 60 * time.Second
+	// maxSourceBody bounds the upstream responses read by a proxy page.
+	maxSourceBody = 16 << 20
+)
+
+// sourceAuthKey marks the context of a source request carrying a secret.
+type sourceAuthKey struct{}
+
+// sourceClient only follows redirects to the allowed hosts, and never to
+// another host when the request carries a secret: a custom auth header
+// would be sent along.
+var sourceClient = &http.Client{
+	Timeout: maxSourceTimeout,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if !slices.Contains(sourceHosts(), strings.ToLower(req.URL.Hostname())) {
+			return fmt.Errorf("redirect to %q, which is not in PAGE_SOURCE_HOSTS", req.URL.Hostname())
+		}
+		if via[0].Context().Value(sourceAuthKey{}) != nil && !strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+			return fmt.Errorf("redirect of an authenticated request to %q", req.URL.Hostname())
+		}
+		return nil
+	},
+}
+
+// RESTSource is the upstream endpoint of a proxy page. GET /page/:id reads
+// it instead of a table and maps its rows onto the deployed columns.
+type RESTSource struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Query   map[string]string `json:"query,omitempty"`
+	Auth    *RESTSourceAuth   `json:"auth,omitempty"`
+	// DataPath is the dotted path of the rows in the response, the response
+	// itself when empty. TotalPath points at the row count of a paged
+	// endpoint.
+	DataPath  string `json:"dataPath,omitempty"`
+	TotalPath string `json:"totalPath,omitempty"`
+	// IDField is the path of the row id, "id" by default.
+	IDField string `json:"idField,omitempty"`
+	// Fields maps the page columns to paths in an upstream row. A column
+	// without a mapping is read under its own name.
+	Fields map[string]string `json:"fields,omitempty"`
+	// PageParam and PageSizeParam forward ?page and ?pageSize upstream; the
+	// rows are paged locally without them.
+	PageParam     string `json:"pageParam,omitempty"`
+	PageSizeParam string `json:"pageSizeParam,omitempty"`
+	// Timeout is in seconds, capped at a minute.
+	Timeout int `json:"timeout,omitempty"`
+}
+
+type RESTSourceAuth struct {
+	Type string `json:"type"`
+	// Header is the header carrying the secret of the "header" scheme.
+	Header    string `json:"header,omitempty"`
+	Username  string `json:"username,omitempty"`
+	SecretEnv string `json:"secretEnv"`
+}
+
+// isProxyPage tells whether page reads a REST source instead of a table.
+func isProxyPage(page models.Page) bool {
+	return len(page.Source) > 0 && string(page.Source) != "null"
+}
+
+func parseRESTSource(raw datatypes.JSON) (RESTSource, error) {
+	var src RESTSource
+	if err := json.Unmarshal(raw, &src); err != nil {
+		return src, fmt.Errorf("%w: %v", ErrInvalidSource, err)
+	}
+	return src, nil
+}
+
+// sourceHosts lists the hosts proxy pages may read, from PAGE_SOURCE_HOSTS
+// (comma separated). Without it, no page can read a REST source.
+func sourceHosts() []string {
+	var hosts []string
+	for _, h := range strings.Split(os.Getenv("PAGE_SOURCE_HOSTS"), ",") {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// checkRESTSource validates the source of a proxy page: an http(s) URL on
+// an allowed host, and a known auth scheme whose secret is set.
+func checkRESTSource(page models.Page) error {
+	if !isProxyPage(page) {
+		return nil
+	}
+	if page.TableName != "" {
+		return fmt.Errorf("%w: a page reads either a table or a source", ErrInvalidSource)
+	}
+	src, err := parseRESTSource(page.Source)
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(src.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalidSource)
+	}
+	if !slices.Contains(sourceHosts(), strings.ToLower(u.Hostname())) {
+		return fmt.Errorf("%w: host %q is not in PAGE_SOURCE_HOSTS", ErrInvalidSource, u.Hostname())
+	}
+	if src.Timeout < 0 {
+		return fmt.Errorf("%w: negative timeout", ErrInvalidSource)
+	}
+	if auth := src.Auth; auth != nil {
+		switch auth.Type {
+		case SourceAuthBearer, SourceAuthBasic:
+		case SourceAuthHeader:
+			if auth.Header == "" {
+				return fmt.Errorf("%w: header auth needs a header", ErrInvalidSource)
+			}
+		default:
+			return fmt.Errorf("%w: unknown auth type %q", ErrInvalidSource, auth.Type)
+		}
+		if !validSourceSecret(auth.SecretEnv) {
+			return fmt.Errorf("%w: secretEnv must be a variable starting with %s", ErrInvalidSource, SourceSecretPrefix)
+		}
+	}
+	return nil
+}
+
+func validSourceSecret(name string) bool {
+	return len(name) > len(SourceSecretPrefix) && strings.HasPrefix(name, SourceSecretPrefix)
+}
+
+// sourcePath reads the dotted path in v; numeric segments index arrays.
+func sourcePath(v any, path string) (any, bool) {
+	if path == "" {
+		return v, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			var ok bool
+			if v, ok = node[key]; !ok {
+				return nil, false
+			}
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func (src RESTSource) request(ctx context.Context, pagination utils.Pagination) (*http.Request, error) {
+	u, err := url.Parse(src.URL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSource, err)
+	}
+	query := u.Query()
+	for k, v := range src.Query {
+		query.Set(k, v)
+	}
+	if pagination.Paged() && src.PageParam != "" {
+		query.Set(src.PageParam, strconv.Itoa(pagination.Page))
+		if src.PageSizeParam != "" {
+			query.Set(src.PageSizeParam, strconv.Itoa(pagination.PageSize))
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	if src.Auth != nil {
+		ctx = context.WithValue(ctx, sourceAuthKey{}, true)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range src.Headers {
+		req.Header.Set(k, v)
+	}
+	if auth := src.Auth; auth != nil {
+		if !validSourceSecret(auth.SecretEnv) {
+			return nil, fmt.Errorf("%w: secretEnv must be a variable starting with %s", ErrInvalidSource, SourceSecretPrefix)
+		}
+		secret := os.Getenv(auth.SecretEnv)
+		if secret == "" {
+			return nil, fmt.Errorf("%w: %s is not set", ErrInvalidSource, auth.SecretEnv)
+		}
+		switch auth.Type {
+		case SourceAuthBearer:
+			req.Header.Set("Authorization", "Bearer "+secret)
+		case SourceAuthBasic:
+			req.SetBasicAuth(auth.Username, secret)
+		case SourceAuthHeader:
+			req.Header.Set(auth.Header, secret)
+		}
+	}
+	return req, nil
+}
+
+// loadSourceRows reads the rows of a proxy page and maps them onto its
+// deployed columns. It returns the rows of the requested page and the
+// total, from TotalPath when the endpoint pages itself.
+func loadSourceRows(page models.Page, opts payloadOptions) ([]map[string]any, int64, error) {
+	src, err := parseRESTSource(page.Source)
+	if err != nil {
+		return nil, 0, err
+	}
+	timeout := defaultSourceTimeout
+	if src.Timeout > 0 {
+		timeout = min(time.Duration(src.Timeout)*time.Second, maxSourceTimeout)
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := src.request(ctx, opts.Page)
+	if err != nil {
+		return nil, 0, err
+	}
+	res, err := sourceClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", ErrSourceUnavailable, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, 0, fmt.Errorf("%w: %s answered %d", ErrSourceUnavailable, req.URL.Host, res.StatusCode)
+	}
+	var body any
+	dec := json.NewDecoder(io.LimitReader(res.Body, maxSourceBody))
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", ErrSourceUnavailable, err)
+	}
+
+	list, ok := sourcePath(body, src.DataPath)
+	items, isList := list.([]any)
+	if !ok || !isList {
+		return nil, 0, fmt.Errorf("%w: no rows at %q", ErrSourceUnavailable, src.DataPath)
+	}
+	total := int64(len(items))
+	upstreamPaged := opts.Page.Paged() && src.PageParam != ""
+	if upstreamPaged && src.TotalPath != "" {
+		if v, ok := sourcePath(body, src.TotalPath); ok {
+			if n, err := strconv.ParseInt(fmt.Sprint(v), 10, 64); err == nil {
+				total = n
+			}
+		}
+	}
+	if opts.Page.Paged() && !upstreamPaged {
+		start := min(opts.Page.Offset(), len(items))
+		items = items[start:min(start+opts.Page.PageSize, len(items))]
+	} else if !opts.Page.Paged() && opts.Limit > 0 && len(items) > opts.Limit {
+		items = items[:opts.Limit]
+	}
+
+	idField := src.IDField
+	if idField == "" {
+		idField = "id"
+	}
+	cols := parseColumns(page.SchemaColumnsDeployed)
+	rows := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		row := map[string]any{}
+		if id, ok := sourcePath(item, idField); ok {
+			row["id"] = id
+		}
+		if len(cols) == 0 && len(src.Fields) == 0 {
+			if obj, ok := item.(map[string]any); ok {
+				for k, v := range obj {
+					row[k] = v
+				}
+			}
+		}
+		for col, path := range src.Fields {
+			row[col], _ = sourcePath(item, path)
+		}
+		for _, col := range cols {
+			if _, mapped := src.Fields[col.Name]; !mapped {
+				row[col.Name], _ = sourcePath(item, col.Name)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, total, nil
+}
@@ -0,0 +1,212 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// revisionListColumns are the columns of the revision list; the snapshots
+// are only read by GET /builder/:id/revisions/:number.
+var revisionListColumns = []string{"id", "page_id", "number", "diff", "user_id", "created_at"}
+
+// draftSchemaChanged reports whether a draft schema of the page differs
+// between before and after.
+func draftSchemaChanged(before, after models.Page) bool {
+	pairs := [][2]datatypes.JSON{
+		{before.SchemaColumns, after.SchemaColumns},
+		{before.SchemaRelations, after.SchemaRelations},
+		{before.SchemaUi, after.SchemaUi},
+		{before.SchemaMenuUi, after.SchemaMenuUi},
+		{before.SchemaConditions, after.SchemaConditions},
+		{before.SchemaFunctions, after.SchemaFunctions},
+		{before.SchemaQuery, after.SchemaQuery},
+	}
+	for _, p := range pairs {
+		if !bytes.Equal(p[0], p[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// revisionDiff is the diff between the draft schemas of before and after,
+// computed by diffSchemas with the ones of before on the deployed side.
+func revisionDiff(before, after models.Page) SchemaDiff {
+	return diffSchemas(models.Page{
+		SchemaColumns:            after.SchemaColumns,
+		SchemaRelations:          after.SchemaRelations,
+		SchemaUi:                 after.SchemaUi,
+		SchemaMenuUi:             after.SchemaMenuUi,
+		SchemaConditions:         after.SchemaConditions,
+		SchemaFunctions:          after.SchemaFunctions,
+		SchemaQuery:              after.SchemaQuery,
+		SchemaColumnsDeployed:    before.SchemaColumns,
+		SchemaRelationsDeployed:  before.SchemaRelations,
+		SchemaUiDeployed:         before.SchemaUi,
+		SchemaMenuUiDeployed:     before.SchemaMenuUi,
+		SchemaConditionsDeployed: before.SchemaConditions,
+		SchemaFunctionsDeployed:  before.SchemaFunctions,
+		SchemaQueryDeployed:      before.SchemaQuery,
+	})
+}
+
+// recordRevision stores the draft schemas of after as the next revision of
+// the page when they differ from the ones of before.
+func recordRevision(c *gin.Context, db *gorm.DB, before, after models.Page) {
+	if !draftSchemaChanged(before, after) {
+		return
+	}
+
+	var number int
+	if err := db.Model(&models.PageRevision{}).Where("page_id = ?", after.ID).
+		Select("COALESCE(MAX(number), 0)").Scan(&number).Error; err != nil {
+		log.Println("⚠️  Unable to read page revisions:", err)
+		return
+	}
+	diff, _ := json.Marshal(revisionDiff(before, after))
+	revision := models.PageRevision{
+		PageID:           after.ID,
+		Number:           number + 1,
+		SchemaColumns:    after.SchemaColumns,
+		SchemaRelations:  after.SchemaRelations,
+		SchemaUi:         after.SchemaUi,
+		SchemaMenuUi:     after.SchemaMenuUi,
+		SchemaConditions: after.SchemaConditions,
+		SchemaFunctions:  after.SchemaFunctions,
+		SchemaQuery:      after.SchemaQuery,
+		Diff:             diff,
+		UserID:           utils.CurrentUserID(c),
+	}
+	if err := db.Create(&revision).Error; err != nil {
+		log.Println("⚠️  Unable to write page revision:", err)
+	}
+}
+
+// loadRevision reads revision :number of page :id, answering 400 or 404 when
+// it cannot.
+func loadRevision(c *gin.Context, db *gorm.DB) (models.PageRevision, bool) {
+	var revision models.PageRevision
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil || number < 1 {
+		utils.Error(c, http.StatusBadRequest, "INVALID_REVISION", "number must be a positive integer")
+		return revision, false
+	}
+	if err := db.Preload("User", userSummary).First(&revision, "page_id = ? AND number = ?", c.Param("id"), number).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			utils.Error(c, http.StatusNotFound, "REVISION_NOT_FOUND", "Revision not found")
+			return revision, false
+		}
+		utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+		return revision, false
+	}
+	return revision, true
+}
+
+func RegisterBuilderRevisionRoutes(group *gin.RouterGroup, db *gorm.DB, cache *services.Cache) {
+	builder := group.Group("/builder")
+
+	// GET /builder/:id/revisions lists the revisions of the draft schemas of
+	// the page, newest first, with their author and diff.
+	builder.GET("/:id/revisions", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		id := c.Param("id")
+
+		var page models.Page
+		if err := db.Select("id").First(&page, "id = ?", id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+				return
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+
+		var revisions []models.PageRevision
+		if err := db.Select(revisionListColumns).Preload("User", userSummary).Where("page_id = ?", id).
+			Order("number DESC").Find(&revisions).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_REVISIONS_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": revisions, "success": true})
+	})
+
+	// GET /builder/:id/revisions/:number returns a revision with its schemas.
+	builder.GET("/:id/revisions/:number", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		revision, ok := loadRevision(c, db)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": revision, "success": true})
+	})
+
+	// POST /builder/:id/revisions/:number/restore makes the schemas of a
+	// revision the draft of the page again. The deployed schemas are left as
+	// they are, and the restore is recorded as a new revision.
+	builder.POST("/:id/revisions/:number/restore", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		id := c.Param("id")
+
+		var before models.Page
+		if err := db.First(&before, "id = ?", id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+				return
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+		revision, ok := loadRevision(c, db)
+		if !ok {
+			return
+		}
+
+		if err := db.Model(&models.Page{}).Where("id = ?", id).Updates(map[string]any{
+			"schema_columns":    revision.SchemaColumns,
+			"schema_relations":  revision.SchemaRelations,
+			"schema_ui":         revision.SchemaUi,
+			"schema_menu_ui":    revision.SchemaMenuUi,
+			"schema_conditions": revision.SchemaConditions,
+			"schema_functions":  revision.SchemaFunctions,
+			"schema_query":      revision.SchemaQuery,
+		}).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_UPDATE_ERROR", err.Error())
+			return
+		}
+		invalidatePageCache(c, cache, id)
+
+		var updated models.Page
+		if err := db.Preload("Template").Preload("Tags.Category").First(&updated, "id = ?", id).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
+			return
+		}
+		recordSchemaChangelog(c, db, before, updated)
+		c.JSON(http.StatusOK, gin.H{"data": updated, "revision": revision.Number, "success": true})
+	})
+}
@@ -0,0 +1,275 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// UI variants reported by the page payload.
+const (
+	UIVariantDeployed = "deployed"
+	UIVariantRollout  = "rollout"
+)
+
+// uiRollout targets the users served the rollout UI of a page: the listed
+// users (id or email), the members of Audience, and Percent of everyone
+// else. A user always falls in the same percentage bucket for a page.
+type uiRollout struct {
+	Percent   int                `json:"percent"`
+	Users     []string           `json:"users,omitempty"`
+	Audience  *ConditionSubjects `json:"audience,omitempty"`
+	StartedAt time.Time          `json:"startedAt"`
+}
+
+func parseRollout(raw datatypes.JSON) *uiRollout {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	var r uiRollout
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return nil
+	}
+	return &r
+}
+
+// rolloutBucket places a user in [0, 100) for a page.
+func rolloutBucket(pageID, userID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(pageID + ":" + userID))
+	return int(h.Sum32() % 100)
+}
+
+// targets reports whether the requesting user is served the rollout UI.
+func (r *uiRollout) targets(c *gin.Context, db *gorm.DB, pageID string) bool {
+	user := utils.CurrentUser(c)
+	if r == nil || user == nil {
+		return false
+	}
+	for _, u := range r.Users {
+		if u == user.ID || strings.EqualFold(u, user.Email) {
+			return true
+		}
+	}
+	if r.Audience != nil && (len(r.Audience.Groups) > 0 || len(r.Audience.Tags) > 0) &&
+		loadConditionSubject(c, db).matches(r.Audience) {
+		return true
+	}
+	return rolloutBucket(pageID, user.ID) < r.Percent
+}
+
+// rolloutUI returns the UI to serve instead of the deployed one, or nil.
+func rolloutUI(c *gin.Context, db *gorm.DB, page models.Page) datatypes.JSON {
+	if len(page.SchemaUiRollout) == 0 {
+		return nil
+	}
+	if !parseRollout(page.UiRollout).targets(c, db, page.ID) {
+		return nil
+	}
+	return page.SchemaUiRollout
+}
+
+// applyUIRollout picks the UI of a page payload shared by every user: the
+// rollout UI it carries replaces the deployed one for its audience. Both
+// rollout keys are removed before the payload is sent.
+func applyUIRollout(c *gin.Context, db *gorm.DB, body []byte) ([]byte, error) {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, err
+	}
+	candidate, ok := envelope["schemaRollout"]
+	if !ok {
+		return body, nil
+	}
+
+	var rollout *uiRollout
+	_ = json.Unmarshal(envelope["uiRollout"], &rollout)
+	var pageID string
+	_ = json.Unmarshal(envelope["id"], &pageID)
+
+	variant := UIVariantDeployed
+	if rollout.targets(c, db, pageID) {
+		envelope["schema"] = candidate
+		variant = UIVariantRollout
+	}
+	envelope["uiVariant"], _ = json.Marshal(variant)
+	delete(envelope, "schemaRollout")
+	delete(envelope, "uiRollout")
+	return json.Marshal(envelope)
+}
+
+// RegisterPageRolloutRoutes manages the gradual rollout of a new UI on a
+// deployed page: start (or retarget), promote to everyone, or abandon.
+func RegisterPageRolloutRoutes(group *gin.RouterGroup, db *gorm.DB, cache *services.Cache) {
+	rollouts := group.Group("/builder/:id/rollout")
+
+	load := func(c *gin.Context, db *gorm.DB) (models.Page, bool) {
+		var page models.Page
+		if err := db.First(&page, "id = ?", c.Param("id")).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+				return page, false
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return page, false
+		}
+		return page, true
+	}
+
+	respond := func(c *gin.Context, status int, page models.Page) {
+		c.JSON(status, gin.H{
+			"data": gin.H{
+				"rollout":  parseRollout(page.UiRollout),
+				"schemaUi": page.SchemaUiRollout,
+			},
+			"success": true,
+		})
+	}
+
+	rollouts.GET("", func(c *gin.Context) {
+		page, ok := load(c, db)
+		if !ok {
+			return
+		}
+		respond(c, http.StatusOK, page)
+	})
+
+	// PUT starts the rollout of schemaUi (the draft UI by default) or changes
+	// its audience.
+	rollouts.PUT("", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		var payload struct {
+			SchemaUi datatypes.JSON     `json:"schemaUi"`
+			Percent  int                `json:"percent"`
+			Users    []string           `json:"users"`
+			Audience *ConditionSubjects `json:"audience"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		if payload.Percent < 0 || payload.Percent > 100 {
+			utils.Error(c, http.StatusBadRequest, "INVALID_ROLLOUT", "percent must be between 0 and 100")
+			return
+		}
+
+		page, ok := load(c, db)
+		if !ok {
+			return
+		}
+		if !Bool(page.Deploy) {
+			utils.Error(c, http.StatusBadRequest, "NOT_DEPLOYED", "Only a deployed page can roll out a UI")
+			return
+		}
+
+		ui := payload.SchemaUi
+		switch {
+		case len(ui) > 0 && string(ui) != "null":
+		case len(page.SchemaUiRollout) > 0:
+			ui = page.SchemaUiRollout
+		default:
+			ui = page.SchemaUi
+		}
+		if len(ui) == 0 {
+			utils.Error(c, http.StatusBadRequest, "INVALID_ROLLOUT", "No UI to roll out")
+			return
+		}
+		if err := checkMenuRefs(ui, page.SchemaMenuUiDeployed); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_MENU", err.Error())
+			return
+		}
+
+		rollout := uiRollout{Percent: payload.Percent, Users: payload.Users, Audience: payload.Audience, StartedAt: time.Now()}
+		if current := parseRollout(page.UiRollout); current != nil {
+			rollout.StartedAt = current.StartedAt
+		}
+		raw, _ := json.Marshal(rollout)
+
+		if err := db.Model(&page).Updates(map[string]any{
+			"schema_ui_rollout": ui,
+			"ui_rollout":        datatypes.JSON(raw),
+		}).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_UPDATE_ERROR", err.Error())
+			return
+		}
+		before := page
+		page.SchemaUiRollout, page.UiRollout = ui, raw
+		invalidatePageCache(c, cache, page.ID)
+		recordBuilderAudit(c, db, before, page)
+		respond(c, http.StatusOK, page)
+	})
+
+	// POST /promote deploys the rollout UI to everyone.
+	rollouts.POST("/promote", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		before, ok := load(c, db)
+		if !ok {
+			return
+		}
+		if len(before.SchemaUiRollout) == 0 {
+			utils.Error(c, http.StatusConflict, "NO_ROLLOUT", "No UI is being rolled out")
+			return
+		}
+
+		after := before
+		after.SchemaUiDeployed = before.SchemaUiRollout
+		after.SchemaUiRollout, after.UiRollout = nil, nil
+		if err := db.Model(&before).Updates(map[string]any{
+			"schema_ui_deployed": after.SchemaUiDeployed,
+			"schema_ui_rollout":  nil,
+			"ui_rollout":         nil,
+		}).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_UPDATE_ERROR", err.Error())
+			return
+		}
+		recordSchemaChangelog(c, db, before, after)
+		invalidatePageCache(c, cache, after.ID)
+		c.JSON(http.StatusOK, gin.H{"data": after, "success": true})
+	})
+
+	// DELETE abandons the rollout: everyone gets the deployed UI again.
+	rollouts.DELETE("", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		page, ok := load(c, db)
+		if !ok {
+			return
+		}
+		if err := db.Model(&page).Updates(map[string]any{
+			"schema_ui_rollout": nil,
+			"ui_rollout":        nil,
+		}).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_UPDATE_ERROR", err.Error())
+			return
+		}
+		invalidatePageCache(c, cache, page.ID)
+		after := page
+		after.SchemaUiRollout, after.UiRollout = nil, nil
+		recordBuilderAudit(c, db, page, after)
+		c.JSON(http.StatusOK, gin.H{"message": "Rollout abandoned", "id": page.ID, "success": true})
+	})
+}
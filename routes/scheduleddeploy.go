@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// scheduleDeploy plans the deploy of page with migration at at, and answers
+// 202 with the scheduled deploy.
+func scheduleDeploy(c *gin.Context, db *gorm.DB, page models.Page, migration DeployMigration, at time.Time) {
+	if !at.After(time.Now()) {
+		utils.Error(c, http.StatusBadRequest, "INVALID_SCHEDULE", "scheduledAt must be in the future")
+		return
+	}
+	if page.TableName == "" {
+		utils.Error(c, http.StatusBadRequest, "DEPLOY_ERROR", "the page has no table")
+		return
+	}
+	if err := checkPageOwnership(db, deployedPage(page)); err != nil {
+		utils.Error(c, deployErrorStatus(err), "DEPLOY_ERROR", err.Error())
+		return
+	}
+	if err := checkViewAdmin(c, page.ViewQuery); err != nil {
+		utils.Error(c, deployErrorStatus(err), "DEPLOY_ERROR", err.Error())
+		return
+	}
+
+	raw, _ := json.Marshal(migration)
+	deploy := models.ScheduledDeploy{
+		PageID:      page.ID,
+		ScheduledAt: at.UTC(),
+		Status:      models.ScheduledDeployPending,
+		Migration:   raw,
+		UserID:      utils.CurrentUserID(c),
+	}
+	if err := db.Create(&deploy).Error; err != nil {
+		utils.Error(c, http.StatusInternalServerError, "DB_CREATE_ERROR", err.Error())
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"data": deploy, "success": true})
+}
+
+// ScheduledDeployRunner runs a scheduled deploy like POST
+// /builder/:id/deploy would have, on behalf of the user who scheduled it.
+func ScheduledDeployRunner(db *gorm.DB, cache *services.Cache) func(context.Context, models.ScheduledDeploy) (any, error) {
+	return func(ctx context.Context, deploy models.ScheduledDeploy) (any, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/builder/"+deploy.PageID+"/deploy", nil)
+		if err != nil {
+			return nil, err
+		}
+		c := &gin.Context{Request: req}
+		// The user is read again, so that one who lost the admin flag since
+		// cannot deploy a view query.
+		if deploy.UserID != nil {
+			user := models.User{ID: *deploy.UserID}
+			if err := db.Where("id = ?", user.ID).Limit(1).Find(&user).Error; err != nil {
+				return nil, err
+			}
+			c.Set(utils.UserKey, &user)
+		}
+
+		var before models.Page
+		if err := db.First(&before, "id = ?", deploy.PageID).Error; err != nil {
+			return nil, err
+		}
+		var migration DeployMigration
+		if len(deploy.Migration) > 0 {
+			if err := json.Unmarshal(deploy.Migration, &migration); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidMigration, err)
+			}
+		}
+		plan, err := deployPage(c, db, before, migration)
+		if err != nil {
+			return nil, err
+		}
+		invalidatePageCache(c, cache, before.ID)
+
+		var updated models.Page
+		if err := db.Preload("Template").Preload("Tags.Category").First(&updated, "id = ?", before.ID).Error; err != nil {
+			return plan, err
+		}
+		recordSchemaChangelog(c, db, before, updated)
+		return plan, nil
+	}
+}
+
+func RegisterBuilderScheduledDeployRoutes(group *gin.RouterGroup, db *gorm.DB) {
+	builder := group.Group("/builder/:id/scheduled-deploys")
+
+	// GET lists the scheduled deploys of the page with their outcome, the
+	// latest first.
+	builder.GET("", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		deploys := []models.ScheduledDeploy{}
+		if err := db.Preload("User", userSummary).Where("page_id = ?", c.Param("id")).Order("scheduled_at DESC").
+			Find(&deploys).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": deploys, "success": true})
+	})
+
+	// DELETE cancels a deploy that has not started yet.
+	builder.DELETE("/:deployId", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		res := db.Model(&models.ScheduledDeploy{}).
+			Where("id = ? AND page_id = ? AND status = ?", c.Param("deployId"), c.Param("id"), models.ScheduledDeployPending).
+			Update("status", models.ScheduledDeployCancelled)
+		if res.Error != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_UPDATE_ERROR", res.Error.Error())
+			return
+		}
+		if res.RowsAffected == 0 {
+			utils.Error(c, http.StatusConflict, "NOT_PENDING", "No pending scheduled deploy with this id")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": c.Param("deployId"), "success": true})
+	})
+}
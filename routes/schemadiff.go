@@ -0,0 +1,148 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"gorm.io/datatypes"
+)
+
+type ColumnChange struct {
+	Name string           `json:"name"`
+	From ColumnDefinition `json:"from"`
+	To   ColumnDefinition `json:"to"`
+}
+
+type RelationChange struct {
+	FromColumn string             `json:"fromColumn"`
+	From       RelationDefinition `json:"from"`
+	To         RelationDefinition `json:"to"`
+}
+
+// SchemaDiff is what deploying the draft schemas of a page would change:
+// its columns and relations against the deployed ones, the other schemas
+// that differ, and the DDL the deploy would run on the live table.
+type SchemaDiff struct {
+	ColumnsAdded     []ColumnDefinition   `json:"columnsAdded"`
+	ColumnsRemoved   []ColumnDefinition   `json:"columnsRemoved"`
+	ColumnsChanged   []ColumnChange       `json:"columnsChanged"`
+	RelationsAdded   []RelationDefinition `json:"relationsAdded"`
+	RelationsRemoved []RelationDefinition `json:"relationsRemoved"`
+	RelationsChanged []RelationChange     `json:"relationsChanged"`
+	// Schemas lists the other draft schemas that differ from their deployed
+	// version: ui, menus, conditions, functions, query.
+	Schemas []string    `json:"schemas"`
+	Live    *deployPlan `json:"live,omitempty"`
+}
+
+// Empty reports whether a deploy would change nothing.
+func (d SchemaDiff) Empty() bool {
+	return len(d.ColumnsAdded)+len(d.ColumnsRemoved)+len(d.ColumnsChanged)+
+		len(d.RelationsAdded)+len(d.RelationsRemoved)+len(d.RelationsChanged)+len(d.Schemas) == 0 &&
+		(d.Live == nil || len(d.Live.Statements) == 0)
+}
+
+// diffSchemas compares the draft schemas of page with the deployed ones.
+// Columns are matched by name, relations by their column.
+func diffSchemas(page models.Page) SchemaDiff {
+	diff := SchemaDiff{
+		ColumnsAdded:     []ColumnDefinition{},
+		ColumnsRemoved:   []ColumnDefinition{},
+		ColumnsChanged:   []ColumnChange{},
+		RelationsAdded:   []RelationDefinition{},
+		RelationsRemoved: []RelationDefinition{},
+		RelationsChanged: []RelationChange{},
+		Schemas:          []string{},
+	}
+
+	oldCols := map[string]ColumnDefinition{}
+	for _, col := range parseColumns(page.SchemaColumnsDeployed) {
+		oldCols[col.Name] = col
+	}
+	for _, col := range parseColumns(page.SchemaColumns) {
+		old, ok := oldCols[col.Name]
+		switch {
+		case !ok:
+			diff.ColumnsAdded = append(diff.ColumnsAdded, col)
+		case !reflect.DeepEqual(old, col):
+			diff.ColumnsChanged = append(diff.ColumnsChanged, ColumnChange{Name: col.Name, From: old, To: col})
+		}
+		delete(oldCols, col.Name)
+	}
+	for _, col := range oldCols {
+		diff.ColumnsRemoved = append(diff.ColumnsRemoved, col)
+	}
+	sort.Slice(diff.ColumnsRemoved, func(i, j int) bool { return diff.ColumnsRemoved[i].Name < diff.ColumnsRemoved[j].Name })
+
+	oldRels := map[string]RelationDefinition{}
+	for _, rel := range parseRelations(page.SchemaRelationsDeployed) {
+		oldRels[rel.FromColumn] = rel
+	}
+	for _, rel := range parseRelations(page.SchemaRelations) {
+		old, ok := oldRels[rel.FromColumn]
+		switch {
+		case !ok:
+			diff.RelationsAdded = append(diff.RelationsAdded, rel)
+		case !reflect.DeepEqual(old, rel):
+			diff.RelationsChanged = append(diff.RelationsChanged, RelationChange{FromColumn: rel.FromColumn, From: old, To: rel})
+		}
+		delete(oldRels, rel.FromColumn)
+	}
+	for _, rel := range oldRels {
+		diff.RelationsRemoved = append(diff.RelationsRemoved, rel)
+	}
+	sort.Slice(diff.RelationsRemoved, func(i, j int) bool {
+		return diff.RelationsRemoved[i].FromColumn < diff.RelationsRemoved[j].FromColumn
+	})
+
+	for _, s := range []struct {
+		name         string
+		draft, deployed datatypes.JSON
+	}{
+		{"ui", page.SchemaUi, page.SchemaUiDeployed},
+		{"menus", page.SchemaMenuUi, page.SchemaMenuUiDeployed},
+		{"conditions", page.SchemaConditions, page.SchemaConditionsDeployed},
+		{"functions", page.SchemaFunctions, page.SchemaFunctionsDeployed},
+		{"query", page.SchemaQuery, page.SchemaQueryDeployed},
+	} {
+		if !jsonEqual(s.draft, s.deployed) {
+			diff.Schemas = append(diff.Schemas, s.name)
+		}
+	}
+	return diff
+}
+
+// jsonEqual compares two JSON documents by value, so that formatting and key
+// order do not count as changes.
+func jsonEqual(a, b datatypes.JSON) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var va, vb any
+	if len(a) > 0 {
+		_ = json.Unmarshal(a, &va)
+	}
+	if len(b) > 0 {
+		_ = json.Unmarshal(b, &vb)
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MergePatchMediaType is the media type of RFC 7386 merge patches.
+const MergePatchMediaType = "application/merge-patch+json"
+
+// draftSchema is a draft schema of a page the builder saves on its own.
+type draftSchema struct {
+	column string
+	field  func(*models.Page) *datatypes.JSON
+}
+
+var draftSchemas = map[string]draftSchema{
+	"columns":    {"schema_columns", func(p *models.Page) *datatypes.JSON { return &p.SchemaColumns }},
+	"relations":  {"schema_relations", func(p *models.Page) *datatypes.JSON { return &p.SchemaRelations }},
+	"ui":         {"schema_ui", func(p *models.Page) *datatypes.JSON { return &p.SchemaUi }},
+	"menus":      {"schema_menu_ui", func(p *models.Page) *datatypes.JSON { return &p.SchemaMenuUi }},
+	"conditions": {"schema_conditions", func(p *models.Page) *datatypes.JSON { return &p.SchemaConditions }},
+	"functions":  {"schema_functions", func(p *models.Page) *datatypes.JSON { return &p.SchemaFunctions }},
+}
+
+// mergePatch applies the RFC 7386 merge patch to target: objects are merged
+// key by key, a null removes the key, and anything else replaces the value.
+func mergePatch(target, patch any) any {
+	fields, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	doc, ok := target.(map[string]any)
+	if !ok {
+		doc = map[string]any{}
+	}
+	for key, value := range fields {
+		if value == nil {
+			delete(doc, key)
+			continue
+		}
+		doc[key] = mergePatch(doc[key], value)
+	}
+	return doc
+}
+
+// decodeJSON decodes a JSON document keeping its numbers as written. An
+// empty document decodes to nil.
+func decodeJSON(body []byte) (any, error) {
+	var value any
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// errSchemaRejected aborts the patch transaction once the response is sent.
+var errSchemaRejected = errors.New("schema rejected")
+
+func RegisterBuilderSchemaRoutes(group *gin.RouterGroup, db *gorm.DB, cache *services.Cache) {
+	// PATCH /builder/:id/schema/:schema merges the body into one draft schema
+	// of the page (columns, relations, ui, menus, conditions or functions).
+	// The row is locked while the patch is applied, so panels saved at the
+	// same time do not overwrite each other.
+	group.PATCH("/builder/:id/schema/:schema", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		id := c.Param("id")
+		schema, ok := draftSchemas[c.Param("schema")]
+		if !ok {
+			utils.Error(c, http.StatusNotFound, "UNKNOWN_SCHEMA", "Unknown schema "+c.Param("schema"))
+			return
+		}
+		if ct := c.ContentType(); ct != "" && ct != MergePatchMediaType && ct != gin.MIMEJSON {
+			utils.Error(c, http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE", "Expected "+MergePatchMediaType)
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		patch, err := decodeJSON(body)
+		if err != nil || len(bytes.TrimSpace(body)) == 0 {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", "The body must be a JSON merge patch")
+			return
+		}
+
+		var before models.Page
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&before, "id = ?", id).Error; err != nil {
+				return err
+			}
+			current, err := decodeJSON(*schema.field(&before))
+			if err != nil {
+				current = nil
+			}
+			var value datatypes.JSON
+			if merged := mergePatch(current, patch); merged != nil {
+				value, _ = json.Marshal(merged)
+			}
+
+			after := before
+			*schema.field(&after) = value
+			if c.Param("schema") == "menus" || c.Param("schema") == "ui" {
+				if err := checkPageMenus(after); err != nil {
+					utils.Error(c, http.StatusBadRequest, "INVALID_MENU", err.Error())
+					return errSchemaRejected
+				}
+			}
+			if !checkPageSchemas(c, tx, after) {
+				return errSchemaRejected
+			}
+			return tx.Model(&models.Page{}).Where("id = ?", id).Update(schema.column, value).Error
+		})
+		switch {
+		case errors.Is(err, errSchemaRejected):
+			return
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+			return
+		case err != nil:
+			utils.Error(c, http.StatusInternalServerError, "DB_PATCH_ERROR", err.Error())
+			return
+		}
+		invalidatePageCache(c, cache, id)
+
+		var updated models.Page
+		if err := db.Preload("Template").Preload("Tags.Category").First(&updated, "id = ?", id).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
+			return
+		}
+		recordSchemaChangelog(c, db, before, updated)
+		c.JSON(http.StatusOK, gin.H{"data": updated, "success": true})
+	})
+}
@@ -0,0 +1,192 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/utils"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func newShareToken() (string, error) {
+	b := make([]byte, 9)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func shareLinkURL(c *gin.Context, token string) string {
+	base := strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/")
+	if base == "" {
+		scheme := "http"
+		if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + c.Request.Host
+	}
+	return base + "/s/" + token
+}
+
+func RegisterShareLinkRoutes(r gin.IRoutes, db *gorm.DB) {
+	r.POST("/page/:id/:itemId/link", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		itemID := c.Param("itemId")
+
+		var payload struct {
+			ExpiresIn int        `json:"expiresIn"`
+			ExpiresAt *time.Time `json:"expiresAt"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&payload); err != nil {
+				utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+				return
+			}
+		}
+
+		page, raw, ok := loadDeployedPage(c, db, c.Param("id"))
+		if !ok {
+			return
+		}
+
+		sqlDB, err := PageSQL(db, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		item, err := loadItem(sqlDB, page, raw, itemID)
+		if err != nil || !applyItemConditions(c, db, page, item) {
+			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Item not found")
+			return
+		}
+
+		token, err := newShareToken()
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "TOKEN_ERROR", err.Error())
+			return
+		}
+
+		link := models.ShareLink{
+			Token:       token,
+			PageID:      page.ID,
+			ItemID:      itemID,
+			ExpiresAt:   payload.ExpiresAt,
+			CreatedByID: utils.CurrentUserID(c),
+		}
+		if payload.ExpiresIn > 0 {
+			expires := time.Now().Add(time.Duration(payload.ExpiresIn) * time.Second)
+			link.ExpiresAt = &expires
+		}
+
+		if err := db.Create(&link).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_CREATE_ERROR", err.Error())
+			return
+		}
+
+		url := shareLinkURL(c, token)
+		qr, err := utils.QRCodePNG(url, 8)
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "QR_CODE_ERROR", err.Error())
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"data": gin.H{
+				"link":   link,
+				"url":    url,
+				"qrCode": "data:image/png;base64," + base64.StdEncoding.EncodeToString(qr),
+			},
+			"success": true,
+		})
+	})
+}
+
+func RegisterPublicShareRoutes(group *gin.RouterGroup, db *gorm.DB) {
+	group.GET("/:token", func(c *gin.Context) {
+		var link models.ShareLink
+		if err := db.First(&link, "token = ?", c.Param("token")).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Link not found")
+				return
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+		if link.ExpiresAt != nil && time.Now().After(*link.ExpiresAt) {
+			utils.Error(c, http.StatusGone, "LINK_EXPIRED", "Link has expired")
+			return
+		}
+
+		page, raw, ok := loadDeployedPage(c, db, link.PageID)
+		if !ok {
+			return
+		}
+
+		sqlDB, err := PageSQL(db, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		item, err := loadItem(sqlDB, page, raw, link.ItemID)
+		if err != nil || !applyItemConditions(c, db, page, item) {
+			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Item not found")
+			return
+		}
+		access, err := loadRelatedAccess(c, db, raw.Relations)
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+		expand := parseExpand(c)
+		applyRelationLabels(unguardedRelations(raw.Relations, access.guarded), expand, item)
+		access.rows(raw.Relations, expand, item)
+
+		c.JSON(http.StatusOK, gin.H{
+			"name":      page.Name,
+			"template":  page.Template,
+			"fiche":     page.FicheTemplate,
+			"schema":    raw.UI,
+			"relations": raw.Relations,
+			"item":      item,
+			"readOnly":  true,
+			"expiresAt": link.ExpiresAt,
+		})
+	})
+
+	group.GET("/:token/qr.png", func(c *gin.Context) {
+		var link models.ShareLink
+		if err := db.First(&link, "token = ?", c.Param("token")).Error; err != nil {
+			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Link not found")
+			return
+		}
+
+		qr, err := utils.QRCodePNG(shareLinkURL(c, link.Token), 8)
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "QR_CODE_ERROR", err.Error())
+			return
+		}
+		c.Data(http.StatusOK, "image/png", qr)
+	})
+}
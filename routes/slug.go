@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+)
+
+// slugify lowercases s, drops accents and joins the remaining words with
+// dashes: "Équipes & Sites" becomes "equipes-sites".
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range norm.NFD.String(strings.ToLower(s)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		default:
+			dash = true
+		}
+	}
+	if b.Len() == 0 {
+		return "page"
+	}
+	return b.String()
+}
+
+// uniquePageSlug returns slugify(base), suffixed with -2, -3... when another
+// page than excludeID already uses it.
+func uniquePageSlug(db *gorm.DB, base, excludeID string) (string, error) {
+	root := slugify(base)
+	slug := root
+	for n := 2; ; n++ {
+		var count int64
+		q := db.Model(&models.Page{}).Where("slug = ?", slug)
+		if excludeID != "" {
+			q = q.Where("id <> ?", excludeID)
+		}
+		if err := q.Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", root, n)
+	}
+}
+
+// BackfillPageSlugs gives a slug to the pages created before slugs existed.
+func BackfillPageSlugs(db *gorm.DB) {
+	var pages []models.Page
+	if err := db.Select("id", "name").Where("slug IS NULL OR slug = ''").Find(&pages).Error; err != nil {
+		log.Printf("❌ Slugs de pages: %v", err)
+		return
+	}
+	for _, page := range pages {
+		slug, err := uniquePageSlug(db, page.Name, page.ID)
+		if err == nil {
+			err = db.Model(&models.Page{}).Where("id = ?", page.ID).Update("slug", slug).Error
+		}
+		if err != nil {
+			log.Printf("❌ Slug de la page %s: %v", page.ID, err)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/utils"
+	"database/sql"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Stamp columns added to every deployed table. The timestamps default to
+// now() on insert; the API keeps updated_at and the user stamps current,
+// and a trigger also touches updated_at when the page sets StampTrigger.
+const (
+	stampCreatedAt = "created_at"
+	stampUpdatedAt = rowVersionColumn
+	stampCreatedBy = "created_by"
+	stampUpdatedBy = "updated_by"
+)
+
+// stampTriggerFunction is shared by the stamp triggers of a database.
+const stampTriggerFunction = "api_core_touch_updated_at"
+
+func stampTriggerName(table string) string {
+	name := "touch_" + table
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return name
+}
+
+// syncRowStamps adds the stamp columns a table lacks, and creates or drops
+// its updated_at trigger, within tx.
+func syncRowStamps(tx sqlExecutor, table string, trigger bool) error {
+	if err := validateIdent(table); err != nil {
+		return err
+	}
+
+	q := newQuery("ALTER TABLE ").Ident(table).
+		Write(" ADD COLUMN IF NOT EXISTS ").Ident(stampCreatedAt).Write(" timestamptz NOT NULL DEFAULT now(),").
+		Write(" ADD COLUMN IF NOT EXISTS ").Ident(stampUpdatedAt).Write(" timestamptz NOT NULL DEFAULT now(),").
+		Write(" ADD COLUMN IF NOT EXISTS ").Ident(stampCreatedBy).Write(" uuid,").
+		Write(" ADD COLUMN IF NOT EXISTS ").Ident(stampUpdatedBy).Write(" uuid")
+	if _, err := tx.Exec(q.SQL()); err != nil {
+		return err
+	}
+
+	q = newQuery("DROP TRIGGER IF EXISTS ").Ident(stampTriggerName(table)).Write(" ON ").Ident(table)
+	if _, err := tx.Exec(q.SQL()); err != nil {
+		return err
+	}
+	if trigger {
+		if _, err := tx.Exec(`CREATE OR REPLACE FUNCTION ` + stampTriggerFunction + `() RETURNS trigger AS $$
+			BEGIN
+				NEW.updated_at = now();
+				RETURN NEW;
+			END
+			$$ LANGUAGE plpgsql`); err != nil {
+			return err
+		}
+		q = newQuery("CREATE TRIGGER ").Ident(stampTriggerName(table)).
+			Write(" BEFORE UPDATE ON ").Ident(table).
+			Write(" FOR EACH ROW EXECUTE FUNCTION " + stampTriggerFunction + "()")
+		if _, err := tx.Exec(q.SQL()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deployRowStamps applies syncRowStamps after a builder save that deployed
+// the page, moved it to another table or toggled its trigger.
+func deployRowStamps(db *gorm.DB, before, after models.Page) error {
+	if !Bool(after.Deploy) || after.TableName == "" {
+		return nil
+	}
+	if Bool(before.Deploy) && before.TableName == after.TableName &&
+		Bool(before.StampTrigger) == Bool(after.StampTrigger) {
+		return nil
+	}
+	sqlDB, err := PageSQL(db, after)
+	if err != nil {
+		return err
+	}
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// Views have no stamp columns.
+	if kind, err := relationKind(tx, after.TableName); err != nil || isViewKind(kind) {
+		return err
+	}
+	if err := syncRowStamps(tx, after.TableName, Bool(after.StampTrigger)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// dropStamps removes the row stamps sent by a client: the API maintains
+// them, and updated_at is the row version and the sync cursor.
+func dropStamps(fields ...map[string]any) {
+	for _, f := range fields {
+		for _, col := range []string{stampCreatedAt, stampUpdatedAt, stampCreatedBy, stampUpdatedBy} {
+			delete(f, col)
+		}
+	}
+}
+
+// stampInsert sets the user stamps of a new row, when its table has them,
+// over whatever the client sent.
+func stampInsert(c *gin.Context, sqlDB *sql.DB, table string, fields map[string]any) {
+	dropStamps(fields)
+	userID := utils.CurrentUserID(c)
+	if userID == nil {
+		return
+	}
+	cols, err := getColumns(sqlDB, table)
+	if err != nil {
+		return
+	}
+	for _, col := range cols {
+		if col == stampCreatedBy || col == stampUpdatedBy {
+			fields[col] = *userID
+		}
+	}
+}
+
+// stampUpsert stamps an upsert: created_by only goes in insertOnly, while
+// updated_at and updated_by go in fields, written whether the row is new
+// or not.
+func stampUpsert(c *gin.Context, sqlDB *sql.DB, table string, fields, insertOnly map[string]any) {
+	dropStamps(fields, insertOnly)
+	cols, err := getColumns(sqlDB, table)
+	if err != nil {
+		return
+	}
+	userID := utils.CurrentUserID(c)
+	for _, col := range cols {
+		switch {
+		case col == stampUpdatedAt:
+			fields[col] = time.Now()
+		case col == stampCreatedBy && userID != nil:
+			insertOnly[col] = *userID
+		case col == stampUpdatedBy && userID != nil:
+			fields[col] = *userID
+		}
+	}
+}
+
+// stampUpdate sets updated_at and updated_by on the fields updating current,
+// for the stamp columns current has, over whatever the client sent.
+func stampUpdate(c *gin.Context, current, fields map[string]any) {
+	dropStamps(fields)
+	if _, ok := current[stampUpdatedAt]; ok {
+		fields[stampUpdatedAt] = time.Now()
+	}
+	if _, ok := current[stampUpdatedBy]; ok {
+		if userID := utils.CurrentUserID(c); userID != nil {
+			fields[stampUpdatedBy] = *userID
+		}
+	}
+}
@@ -0,0 +1,215 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/utils"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Kinds of columns with statistics.
+const (
+	StatsKindNumber = "number"
+	StatsKindDate   = "date"
+)
+
+// ColumnStats summarizes a numeric or date column. Min, Max, Avg and Median
+// are nil when every value is null.
+type ColumnStats struct {
+	Count     int64 `json:"count"`
+	NullCount int64 `json:"nullCount"`
+	Min       any   `json:"min"`
+	Max       any   `json:"max"`
+	Avg       any   `json:"avg"`
+	Median    any   `json:"median"`
+}
+
+// statsKind reads the kind of a table column from its SQL type, or "" when
+// it has no statistics.
+func statsKind(sqlDB *sql.DB, table, column string) (string, error) {
+	var dataType string
+	err := sqlDB.QueryRow(`
+		SELECT data_type FROM information_schema.columns
+		WHERE table_name = $1 AND column_name = $2 AND table_schema = ANY(current_schemas(false))`,
+		table, column).Scan(&dataType)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+	switch dataType {
+	case "smallint", "integer", "bigint", "numeric", "real", "double precision":
+		return StatsKindNumber, nil
+	case "date", "timestamp without time zone", "timestamp with time zone":
+		return StatsKindDate, nil
+	}
+	return "", nil
+}
+
+// statsSQL aggregates the rows matching the page filters in the database.
+func statsSQL(agg columnAggregate, kind string) (ColumnStats, error) {
+	col := quoteIdent(agg.column)
+	q := newQuery("SELECT COUNT(*), COUNT(*) - COUNT(", col, "), ")
+	if kind == StatsKindNumber {
+		q.Write("MIN(", col, ")::float8, MAX(", col, ")::float8, AVG(", col, ")::float8, ",
+			"percentile_cont(0.5) WITHIN GROUP (ORDER BY ", col, ")")
+	} else {
+		epoch := "EXTRACT(EPOCH FROM " + col + ")"
+		q.Write("MIN(", col, ")::timestamptz, MAX(", col, ")::timestamptz, to_timestamp(AVG(", epoch, ")), ",
+			"to_timestamp(percentile_cont(0.5) WITHIN GROUP (ORDER BY ", epoch, "))")
+	}
+	q.Write(" FROM ").Ident(agg.page.TableName)
+
+	query := agg.query
+	query.Sort = nil
+	if err := query.apply(agg.sqlDB, agg.page.TableName, q, 0); err != nil {
+		return ColumnStats{}, err
+	}
+
+	var stats ColumnStats
+	err := agg.sqlDB.QueryRow(q.SQL(), q.Args()...).
+		Scan(&stats.Count, &stats.NullCount, &stats.Min, &stats.Max, &stats.Avg, &stats.Median)
+	return stats, err
+}
+
+// statsValue reads a value of a numeric column as a float, or a value of a
+// date column as seconds since the epoch.
+func statsValue(kind string, v any) (float64, bool) {
+	if kind == StatsKindDate {
+		switch t := v.(type) {
+		case time.Time:
+			return float64(t.UnixNano()) / 1e9, true
+		case string:
+			if parsed, err := time.Parse(time.RFC3339Nano, t); err == nil {
+				return float64(parsed.UnixNano()) / 1e9, true
+			}
+		}
+		return 0, false
+	}
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case []byte:
+		f, err := strconv.ParseFloat(string(n), 64)
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// statsRows computes the statistics over the rows the user may see.
+func statsRows(db *gorm.DB, agg columnAggregate, kind string) (ColumnStats, error) {
+	visible, err := visibleValues(db, agg.page, agg.policy, agg.column, agg.query)
+	if err != nil {
+		return ColumnStats{}, err
+	}
+
+	stats := ColumnStats{Count: int64(len(visible))}
+	values := make([]float64, 0, len(visible))
+	for _, v := range visible {
+		if v == nil {
+			stats.NullCount++
+			continue
+		}
+		if f, ok := statsValue(kind, v); ok {
+			values = append(values, f)
+		}
+	}
+	if len(values) == 0 {
+		return stats, nil
+	}
+
+	sort.Float64s(values)
+	sum := 0.0
+	for _, f := range values {
+		sum += f
+	}
+	median := values[len(values)/2]
+	if len(values)%2 == 0 {
+		median = (values[len(values)/2-1] + median) / 2
+	}
+
+	out := func(f float64) any {
+		if kind == StatsKindDate {
+			return time.Unix(0, int64(f*1e9)).UTC()
+		}
+		return f
+	}
+	stats.Min, stats.Max = out(values[0]), out(values[len(values)-1])
+	stats.Avg, stats.Median = out(sum/float64(len(values))), out(median)
+	return stats, nil
+}
+
+func RegisterPageStatsRoutes(r gin.IRoutes, db *gorm.DB) {
+	// GET /page/:id/stats/:column returns the count, null count, min, max,
+	// average and median of a numeric or date column, for dashboard widgets
+	// and data-quality checks.
+	r.GET("/page/:id/stats/:column", func(c *gin.Context) {
+		db := utils.ReadDB(c, db)
+		agg, ok := loadColumnAggregate(c, db)
+		if !ok {
+			return
+		}
+
+		kind, err := statsKind(agg.sqlDB, agg.page.TableName, agg.column)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if kind == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("La colonne %q n'est ni numérique ni une date", agg.column)})
+			return
+		}
+
+		var stats ColumnStats
+		if agg.policy == nil {
+			stats, err = statsSQL(agg, kind)
+		} else {
+			stats, err = statsRows(db, agg, kind)
+		}
+		if err != nil {
+			c.JSON(aggregateErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"column": agg.column,
+			"kind":   kind,
+			"data":   stats,
+		})
+	})
+}
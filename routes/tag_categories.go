@@ -19,184 +19,243 @@ package routes
 import (
 	"api-core-v2/models"
 	"api-core-v2/utils"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/mitchellh/mapstructure"
 	"gorm.io/gorm"
 )
 
-func RegisterTagCategoryRoutes(group *gin.RouterGroup, db *gorm.DB) {
-	categories := group.Group("/tag-categories")
-	categories.GET("", func(c *gin.Context) {
-		var cats []models.TagCategory
-		var tags []models.Tag
+// taxonomyCategory is the exchange format of a tag category: categories and
+// tags are matched by name, ids never leave the environment.
+type taxonomyCategory struct {
+	Name string        `json:"name"`
+	Tags []taxonomyTag `json:"tags"`
+}
 
-		if err := db.Preload("Tags.Category").Find(&cats).Error; err != nil {
-			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_CATEGORIES_ERROR", err.Error())
-			return
-		}
-		if err := db.Preload("Category").Find(&tags).Error; err != nil {
-			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_TAGS_ERROR", err.Error())
-			return
+type taxonomyTag struct {
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+}
+
+var taxonomyCSVHeader = []string{"category", "tag", "color"}
+
+type taxonomyImportResult struct {
+	CategoriesCreated int `json:"categoriesCreated"`
+	TagsCreated       int `json:"tagsCreated"`
+	TagsUpdated       int `json:"tagsUpdated"`
+	TagsUnchanged     int `json:"tagsUnchanged"`
+}
+
+func exportTaxonomy(db *gorm.DB) ([]taxonomyCategory, error) {
+	var categories []models.TagCategory
+	if err := db.Preload("Tags", func(db *gorm.DB) *gorm.DB { return db.Order("name") }).
+		Order("name").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	out := make([]taxonomyCategory, 0, len(categories))
+	for _, cat := range categories {
+		entry := taxonomyCategory{Name: cat.Name, Tags: make([]taxonomyTag, 0, len(cat.Tags))}
+		for _, tag := range cat.Tags {
+			entry.Tags = append(entry.Tags, taxonomyTag{Name: tag.Name, Color: tag.Color})
 		}
+		out = append(out, entry)
+	}
+	return out, nil
+}
 
-		c.JSON(http.StatusOK, gin.H{
-			"data": cats,
-			"dependencies": gin.H{
-				"tags": tags,
-			},
-			"success": true,
-		})
-	})
-	categories.POST("", func(c *gin.Context) {
-		var payload models.TagCategory
-		if err := c.ShouldBindJSON(&payload); err != nil {
-			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
-			return
+func writeTaxonomyCSV(w io.Writer, categories []taxonomyCategory) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(taxonomyCSVHeader); err != nil {
+		return err
+	}
+	for _, cat := range categories {
+		if len(cat.Tags) == 0 {
+			if err := cw.Write([]string{cat.Name, "", ""}); err != nil {
+				return err
+			}
 		}
-		if err := db.Create(&payload).Error; err != nil {
-			utils.Error(c, http.StatusInternalServerError, "DB_CREATE_ERROR", err.Error())
-			return
+		for _, tag := range cat.Tags {
+			if err := cw.Write([]string{cat.Name, tag.Name, tag.Color}); err != nil {
+				return err
+			}
 		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
 
-		var created models.TagCategory
-		if err := db.Preload("Tags.Category").First(&created, "id = ?", payload.ID).Error; err != nil {
-			utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
-			return
-		}
-		c.JSON(http.StatusCreated, gin.H{"data": created, "success": true})
-	})
-	categories.PUT("/:id", func(c *gin.Context) {
-		id := c.Param("id")
-		var payload models.TagCategory
+// readTaxonomyCSV reads the category,tag,color rows written by the export.
+// A row with an empty tag only declares its category.
+func readTaxonomyCSV(r io.Reader) ([]taxonomyCategory, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
 
-		if err := c.ShouldBindJSON(&payload); err != nil {
-			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
-			return
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["category"]; !ok {
+		return nil, fmt.Errorf("missing %q column", "category")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
 		}
+		return ""
+	}
 
-		var existing models.TagCategory
-		if err := db.Preload("Tags").First(&existing, "id = ?", id).Error; err != nil {
-			utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Category not found")
-			return
+	var out []taxonomyCategory
+	index := map[string]int{}
+	for _, record := range records[1:] {
+		name := field(record, "category")
+		if name == "" {
+			continue
 		}
-
-		payload.ID = id
-		if err := db.Model(&existing).Updates(&payload).Error; err != nil {
-			utils.Error(c, http.StatusInternalServerError, "DB_UPDATE_ERROR", err.Error())
-			return
+		i, ok := index[name]
+		if !ok {
+			i = len(out)
+			index[name] = i
+			out = append(out, taxonomyCategory{Name: name})
 		}
-
-		var updated models.TagCategory
-		if err := db.Preload("Tags.Category").First(&updated, "id = ?", id).Error; err != nil {
-			utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
-			return
+		if tag := field(record, "tag"); tag != "" {
+			out[i].Tags = append(out[i].Tags, taxonomyTag{Name: tag, Color: field(record, "color")})
 		}
-		c.JSON(http.StatusOK, gin.H{"data": updated, "success": true})
-	})
-	categories.PATCH("/:id", func(c *gin.Context) {
-		id := c.Param("id")
-		var updates map[string]interface{}
+	}
+	return out, nil
+}
 
-		if err := c.ShouldBindJSON(&updates); err != nil {
-			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
-			return
-		}
+// importTaxonomy creates the missing categories and tags and updates the
+// colors that differ. Nothing is deleted, so importing twice is a no-op.
+func importTaxonomy(db *gorm.DB, categories []taxonomyCategory) (taxonomyImportResult, error) {
+	var result taxonomyImportResult
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, entry := range categories {
+			name := strings.TrimSpace(entry.Name)
+			if name == "" {
+				return fmt.Errorf("category without a name")
+			}
 
-		if len(updates) == 0 {
-			utils.Error(c, http.StatusBadRequest, "NO_UPDATES_PROVIDED", "No updates provided")
-			return
-		}
+			var cat models.TagCategory
+			err := tx.Where("name = ?", name).First(&cat).Error
+			if err == gorm.ErrRecordNotFound {
+				cat = models.TagCategory{Name: name}
+				if err := tx.Create(&cat).Error; err != nil {
+					return err
+				}
+				result.CategoriesCreated++
+			} else if err != nil {
+				return err
+			}
 
-		if err := db.Model(&models.TagCategory{}).Where("id = ?", id).Updates(updates).Error; err != nil {
-			utils.Error(c, http.StatusInternalServerError, "DB_PATCH_ERROR", err.Error())
-			return
-		}
+			for _, t := range entry.Tags {
+				tagName := strings.TrimSpace(t.Name)
+				if tagName == "" {
+					return fmt.Errorf("tag without a name in category %q", name)
+				}
 
-		var updated models.TagCategory
-		if err := db.Preload("Tags.Category").First(&updated, "id = ?", id).Error; err != nil {
-			utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
-			return
+				var tag models.Tag
+				err := tx.Where("category_id = ? AND name = ?", cat.ID, tagName).First(&tag).Error
+				switch {
+				case err == gorm.ErrRecordNotFound:
+					tag = models.Tag{Name: tagName, Color: t.Color, CategoryID: &cat.ID}
+					if err := tx.Create(&tag).Error; err != nil {
+						return err
+					}
+					result.TagsCreated++
+				case err != nil:
+					return err
+				case t.Color != "" && t.Color != tag.Color:
+					if err := tx.Model(&tag).Update("color", t.Color).Error; err != nil {
+						return err
+					}
+					result.TagsUpdated++
+				default:
+					result.TagsUnchanged++
+				}
+			}
 		}
-		c.JSON(http.StatusOK, gin.H{"data": updated, "success": true})
+		return nil
 	})
-	categories.PATCH("/patchMany", func(c *gin.Context) {
-		var payload struct {
-			IDs     []string               `json:"ids"`
-			Updates map[string]interface{} `json:"updates"`
-		}
-		if err := c.ShouldBindJSON(&payload); err != nil {
-			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
-			return
-		}
-		if len(payload.IDs) == 0 {
-			utils.Error(c, http.StatusBadRequest, "NO_IDS_PROVIDED", "No IDs provided")
-			return
-		}
-		if len(payload.Updates) == 0 {
-			utils.Error(c, http.StatusBadRequest, "NO_UPDATES_PROVIDED", "No updates provided")
-			return
-		}
+	return result, err
+}
 
-		var updates models.TagCategory
-		if err := mapstructure.Decode(payload.Updates, &updates); err != nil {
-			utils.Error(c, http.StatusBadRequest, "DECODE_ERROR", err.Error())
+func RegisterTagCategoryRoutes(group *gin.RouterGroup, db *gorm.DB) {
+	// GET /tag-categories/export?format=csv exports the categories with their
+	// tags; JSON is the default.
+	group.GET("/tag-categories/export", func(c *gin.Context) {
+		categories, err := exportTaxonomy(db)
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
 			return
 		}
 
-		if err := db.Model(&models.TagCategory{}).Where("id IN ?", payload.IDs).Updates(&updates).Error; err != nil {
-			utils.Error(c, http.StatusInternalServerError, "DB_PATCH_MANY_ERROR", err.Error())
-			return
+		switch c.DefaultQuery("format", "json") {
+		case "json":
+			c.Header("Content-Disposition", `attachment; filename="tag-categories.json"`)
+			c.JSON(http.StatusOK, gin.H{"data": categories, "success": true})
+		case "csv":
+			var buf bytes.Buffer
+			if err := writeTaxonomyCSV(&buf, categories); err != nil {
+				utils.Error(c, http.StatusInternalServerError, "EXPORT_ERROR", err.Error())
+				return
+			}
+			c.Header("Content-Disposition", `attachment; filename="tag-categories.csv"`)
+			c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+		default:
+			utils.Error(c, http.StatusBadRequest, "INVALID_FORMAT", "format must be json or csv")
 		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Categories updated successfully",
-			"count":   len(payload.IDs),
-			"success": true,
-		})
 	})
-	categories.POST("/deleteMany", func(c *gin.Context) {
-		var ids []string
-		if err := c.ShouldBindJSON(&ids); err != nil {
+
+	// POST /tag-categories/import takes a CSV body (text/csv or ?format=csv)
+	// or the JSON of the export, with or without its envelope.
+	group.POST("/tag-categories/import", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
 			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
 			return
 		}
-		if len(ids) == 0 {
-			utils.Error(c, http.StatusBadRequest, "NO_IDS_PROVIDED", "No IDs provided")
-			return
-		}
-		if err := db.Delete(&models.TagCategory{}, ids).Error; err != nil {
-			utils.Error(c, http.StatusInternalServerError, "DB_DELETE_MANY_ERROR", err.Error())
-			return
-		}
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Categories deleted successfully",
-			"count":   len(ids),
-			"success": true,
-		})
-	})
-	categories.DELETE("/:id", func(c *gin.Context) {
-		id := c.Param("id")
-		var category models.TagCategory
 
-		if err := db.First(&category, "id = ?", id).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Category not found")
-				return
+		var categories []taxonomyCategory
+		if c.Query("format") == "csv" || strings.HasPrefix(c.ContentType(), "text/csv") {
+			categories, err = readTaxonomyCSV(bytes.NewReader(body))
+		} else if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+			var envelope struct {
+				Data []taxonomyCategory `json:"data"`
 			}
-			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			err = json.Unmarshal(trimmed, &envelope)
+			categories = envelope.Data
+		} else {
+			err = json.Unmarshal(body, &categories)
+		}
+		if err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
 			return
 		}
 
-		if err := db.Delete(&category).Error; err != nil {
-			utils.Error(c, http.StatusInternalServerError, "DB_DELETE_ERROR", err.Error())
+		result, err := importTaxonomy(db, categories)
+		if err != nil {
+			utils.Error(c, http.StatusBadRequest, "IMPORT_ERROR", err.Error())
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Category deleted successfully",
-			"id":      id,
-			"success": true,
-		})
+		c.JSON(http.StatusOK, gin.H{"data": result, "success": true})
+	})
+
+	RegisterCrudRoutes[models.TagCategory](group, db, CrudResource{
+		Path:     "/tag-categories",
+		Singular: "Category",
+		Plural:   "Categories",
 	})
 }
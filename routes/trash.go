@@ -0,0 +1,463 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTrashConflict    = errors.New("a record with this id exists again")
+	ErrTrashPageMissing = errors.New("the page of the row is no longer deployed")
+)
+
+// trashRecord keeps a copy of a core record about to be deleted, labelled
+// with its name or title.
+func trashRecord(c *gin.Context, db *gorm.DB, kind string, record any) error {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return err
+	}
+
+	item := models.TrashItem{
+		Kind:       kind,
+		ResourceID: fmt.Sprint(fields["id"]),
+		DeletedBy:  utils.CurrentUserID(c),
+	}
+	for _, key := range []string{"name", "title"} {
+		if label, ok := fields[key].(string); ok && label != "" {
+			item.Label = label
+			break
+		}
+	}
+	return services.MoveToTrash(db, item, json.RawMessage(raw))
+}
+
+// snapshotRow reads what the trash keeps of a row about to be deleted: its
+// columns, the links of its many-to-many relations and its files.
+func snapshotRow(sqlDB *sql.DB, page models.Page, raw schemaRaw, itemID string) (services.TrashedRow, error) {
+	row, err := loadRow(sqlDB, page.TableName, itemID)
+	if err != nil {
+		return services.TrashedRow{}, err
+	}
+	for col, v := range row {
+		if b, ok := v.([]byte); ok {
+			row[col] = string(b)
+		}
+	}
+
+	snap := services.TrashedRow{Row: row, Links: map[string][]string{}}
+	for _, rel := range raw.Relations {
+		if rel.Type != "many-to-many" {
+			continue
+		}
+		pairs, err := loadPivotPairs(sqlDB, pivotTableName(page.TableName, rel), rel.Symmetric && rel.selfReferencing(page.TableName), []string{itemID})
+		if err != nil {
+			return services.TrashedRow{}, fmt.Errorf("relation %s: %w", rel.FromColumn, err)
+		}
+		if len(pairs[itemID]) > 0 {
+			snap.Links[rel.FromColumn] = pairs[itemID]
+		}
+	}
+	snap.Files = rowFileKeys(row, fileColumns(parseColumns(page.SchemaColumnsDeployed)))
+	return snap, nil
+}
+
+// trashRow moves a deleted row to the trash once the delete is committed.
+// When it cannot be kept, its files are deleted right away.
+func trashRow(c *gin.Context, db *gorm.DB, page models.Page, itemID string, snap services.TrashedRow) {
+	item := models.TrashItem{
+		Kind:       services.TrashKindRow,
+		ResourceID: itemID,
+		PageID:     &page.ID,
+		Label:      page.Name,
+		DeletedBy:  utils.CurrentUserID(c),
+	}
+	if key := naturalKeyColumn(parseColumns(page.SchemaColumnsDeployed)); key != "" && snap.Row[key] != nil {
+		item.Label = fmt.Sprintf("%s: %v", page.Name, snap.Row[key])
+	}
+	if err := services.MoveToTrash(db, item, snap); err != nil {
+		log.Printf("⚠️  %s/%s non placé dans la corbeille: %v", page.TableName, itemID, err)
+		deleteFilesAfterCommit(c, snap.Files...)
+	}
+}
+
+// existingIDs keeps the ids still present in table.
+func existingIDs(sqlDB *sql.DB, table string, ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	q := newQuery("SELECT id::text FROM ").Ident(table).Write(" WHERE id::text IN (").ArgList(stringArgs(ids)...).Write(")")
+	rows, err := sqlDB.Query(q.SQL(), q.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		found[id] = true
+	}
+	return slices.DeleteFunc(slices.Clone(ids), func(id string) bool { return !found[id] }), rows.Err()
+}
+
+// restoreRow inserts a row of the trash back into the table of its page,
+// with the columns the table still has and the links whose rows still
+// exist. updated_at is set to now, so that synced clients fetch it again.
+func restoreRow(c *gin.Context, db *gorm.DB, item models.TrashItem) (models.Page, error) {
+	var page models.Page
+	if item.PageID == nil {
+		return page, ErrTrashPageMissing
+	}
+	if err := db.First(&page, "id = ?", *item.PageID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return page, ErrTrashPageMissing
+		}
+		return page, err
+	}
+	if !Bool(page.Deploy) || page.TableName == "" {
+		return page, ErrTrashPageMissing
+	}
+	relations := parseRelations(page.SchemaRelationsDeployed)
+	if err := checkPageTables(db, page, relations); err != nil {
+		return page, err
+	}
+
+	var snap services.TrashedRow
+	if err := json.Unmarshal(item.Data, &snap); err != nil {
+		return page, err
+	}
+	sqlDB, err := PageSQL(db, page)
+	if err != nil {
+		return page, err
+	}
+	if _, err := loadRow(sqlDB, page.TableName, item.ResourceID); err == nil {
+		return page, ErrTrashConflict
+	}
+	cols, err := getColumns(sqlDB, page.TableName)
+	if err != nil {
+		return page, err
+	}
+	fields := map[string]any{}
+	for _, col := range cols {
+		if v, ok := snap.Row[col]; ok {
+			fields[col] = v
+		}
+	}
+	fields["id"] = item.ResourceID
+	if slices.Contains(cols, stampUpdatedAt) {
+		fields[stampUpdatedAt] = time.Now()
+	}
+
+	tx, err := beginPageSQL(c, db, page)
+	if err != nil {
+		return page, err
+	}
+	defer tx.Rollback()
+
+	if _, err := InsertDynamic(tx, page.TableName, fields); err != nil {
+		return page, err
+	}
+	for _, rel := range relations {
+		if rel.Type != "many-to-many" || len(snap.Links[rel.FromColumn]) == 0 {
+			continue
+		}
+		rightIDs, err := existingIDs(sqlDB, rel.ToTable, snap.Links[rel.FromColumn])
+		if err != nil {
+			return page, fmt.Errorf("relation %s: %w", rel.FromColumn, err)
+		}
+		if err := InsertPivotM2M(tx, pivotTableName(page.TableName, rel), item.ResourceID, rightIDs); err != nil {
+			return page, fmt.Errorf("relation %s: %w", rel.FromColumn, err)
+		}
+	}
+	return page, tx.Commit()
+}
+
+// existingTags keeps the tags that were not deleted since.
+func existingTags(db *gorm.DB, tags []models.Tag) ([]models.Tag, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, len(tags))
+	for i, tag := range tags {
+		ids[i] = tag.ID
+	}
+	var found []models.Tag
+	err := db.Find(&found, "id IN ?", ids).Error
+	return found, err
+}
+
+// restoreRecord recreates a core record of the trash. References to records
+// deleted since are cleared.
+func restoreRecord(db *gorm.DB, item models.TrashItem) error {
+	exists := func(model any, id *string) bool {
+		var n int64
+		return id != nil && db.Model(model).Where("id = ?", *id).Count(&n).Error == nil && n > 0
+	}
+
+	var record any
+	// place numbers a restored navigation item in the tree.
+	var place func(tx *gorm.DB) error
+	switch item.Kind {
+	case services.TrashKindPage:
+		var page models.Page
+		if err := json.Unmarshal(item.Data, &page); err != nil {
+			return err
+		}
+		page.Template, page.FicheTemplate = nil, nil
+		if !exists(&models.Template{}, page.TemplateID) {
+			page.TemplateID = nil
+		}
+		if !exists(&models.Template{}, page.FicheTemplateID) {
+			page.FicheTemplateID = nil
+		}
+		tags, err := existingTags(db, page.Tags)
+		if err != nil {
+			return err
+		}
+		page.Tags = tags
+		record = &page
+	case services.TrashKindNavigationItem:
+		var nav models.NavigationItem
+		if err := json.Unmarshal(item.Data, &nav); err != nil {
+			return err
+		}
+		nav.Parent, nav.Page = nil, nil
+		nav.Lft, nav.Rgt, nav.Depth = 0, 0, 0
+		if !exists(&models.NavigationItem{}, nav.ParentID) {
+			nav.ParentID = nil
+		}
+		if !exists(&models.Page{}, nav.PageID) {
+			nav.PageID = nil
+		}
+		tags, err := existingTags(db, nav.Tags)
+		if err != nil {
+			return err
+		}
+		nav.Tags = tags
+		record = &nav
+		// The item goes last under its parent, or last among the roots of
+		// its menu when the parent is gone or was moved to another menu.
+		place = func(tx *gorm.DB) error {
+			tree, err := loadNavTree(tx, nav.Menu, false)
+			if err != nil {
+				return err
+			}
+			if err := tree.restore(nav.ID, nav.ParentID); err != nil {
+				return err
+			}
+			_, err = tree.save(tx)
+			return err
+		}
+	case services.TrashKindTag:
+		var tag models.Tag
+		if err := json.Unmarshal(item.Data, &tag); err != nil {
+			return err
+		}
+		tag.Category = nil
+		if !exists(&models.TagCategory{}, tag.CategoryID) {
+			tag.CategoryID = nil
+		}
+		record = &tag
+	default:
+		return fmt.Errorf("unknown trash kind %q", item.Kind)
+	}
+
+	if exists(record, &item.ResourceID) {
+		return ErrTrashConflict
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if place != nil {
+			if err := lockNavigation(tx); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		if place != nil {
+			return place(tx)
+		}
+		return nil
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		return fmt.Errorf("%w: %s", ErrTrashConflict, pgErr.Detail)
+	}
+	return err
+}
+
+func RegisterTrashRoutes(group *gin.RouterGroup, db *gorm.DB, cache *services.Cache, hooks *services.Webhooks, events *services.PageEvents) {
+	trash := group.Group("/trash")
+
+	load := func(c *gin.Context, db *gorm.DB) (*models.TrashItem, bool) {
+		var item models.TrashItem
+		if err := db.First(&item, "id = ?", c.Param("id")).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Trash item not found")
+				return nil, false
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return nil, false
+		}
+		return &item, true
+	}
+
+	filter := func(c *gin.Context, query *gorm.DB) *gorm.DB {
+		if kind := c.Query("kind"); kind != "" {
+			query = query.Where("kind = ?", kind)
+		}
+		if pageID := c.Query("pageId"); pageID != "" {
+			query = query.Where("page_id = ?", pageID)
+		}
+		return query
+	}
+
+	// GET /trash lists the deleted records still restorable, most recent
+	// first, optionally of one kind (?kind=page|navigation_item|tag|row) or
+	// one page (?pageId=).
+	trash.GET("", func(c *gin.Context) {
+		query := filter(c, db.Order("deleted_at DESC"))
+
+		list := []models.TrashItem{}
+		meta, err := utils.FindPage(query, utils.ParsePagination(c, 0, listMaxPageSize), &list, nil)
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"data":      list,
+			"meta":      meta,
+			"retention": services.TrashRetention().String(),
+			"success":   true,
+		})
+	})
+
+	trash.GET("/:id", func(c *gin.Context) {
+		item, ok := load(c, db)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": item, "success": true})
+	})
+
+	// POST /trash/:id/restore recreates the record and takes it out of the
+	// trash. It is 409 when a record with the same id (or unique name) was
+	// created since, or when the page of a row is gone.
+	trash.POST("/:id/restore", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		item, ok := load(c, db)
+		if !ok {
+			return
+		}
+
+		var err error
+		var page models.Page
+		if item.Kind == services.TrashKindRow {
+			page, err = restoreRow(c, db, *item)
+		} else {
+			err = restoreRecord(db, *item)
+		}
+		if errors.Is(err, ErrTrashConflict) || errors.Is(err, ErrTrashPageMissing) {
+			utils.Error(c, http.StatusConflict, "RESTORE_CONFLICT", err.Error())
+			return
+		}
+		if err != nil {
+			utils.Error(c, updateErrorStatus(err), "RESTORE_ERROR", err.Error())
+			return
+		}
+		if err := db.Delete(item).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_DELETE_ERROR", err.Error())
+			return
+		}
+
+		switch item.Kind {
+		case services.TrashKindRow:
+			sqlDB, _ := PageSQL(db, page)
+			invalidateTableCache(c, db, cache, page.TableName)
+			recordRowAudit(c, db, services.AuditActionCreate, page, item.ResourceID)
+			notifyRowChange(c, hooks, events, services.WebhookRowCreated, page, item.ResourceID, func() map[string]any {
+				var raw schemaRaw
+				raw.Relations = parseRelations(page.SchemaRelationsDeployed)
+				row, _ := loadItem(sqlDB, page, raw, item.ResourceID)
+				return row
+			})
+		case services.TrashKindPage:
+			invalidatePageCache(c, cache, item.ResourceID)
+		case services.TrashKindNavigationItem:
+			invalidateNavigationCache(c, cache)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Trash item restored successfully",
+			"kind":    item.Kind,
+			"id":      item.ResourceID,
+			"success": true,
+		})
+	})
+
+	// DELETE /trash/:id deletes a record for good.
+	trash.DELETE("/:id", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		item, ok := load(c, db)
+		if !ok {
+			return
+		}
+		if err := services.PurgeTrashItems(db, []models.TrashItem{*item}); err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_DELETE_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Trash item purged successfully", "id": item.ID, "success": true})
+	})
+
+	// DELETE /trash empties the trash, or the part of it matching ?kind= and
+	// ?pageId=.
+	trash.DELETE("", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		var items []models.TrashItem
+		if err := filter(c, db).Find(&items).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+		if err := services.PurgeTrashItems(db, items); err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_DELETE_ERROR", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Trash purged successfully", "count": len(items), "success": true})
+	})
+}
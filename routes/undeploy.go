@@ -0,0 +1,297 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// What POST /builder/:id/undeploy does with the tables of the page.
+const (
+	// UndeployKeep leaves them in place.
+	UndeployKeep = "keep"
+	// UndeploySnapshot uploads their rows to the object storage, then drops
+	// them.
+	UndeploySnapshot = "snapshot"
+	// UndeployGraveyard renames them into services.GraveyardSchema.
+	UndeployGraveyard = "graveyard"
+)
+
+// A TableSnapshot is the content of the tables of an undeployed page: its
+// table and the pivots of its many-to-many relations.
+type TableSnapshot struct {
+	PageID    string                      `json:"pageId"`
+	Table     string                      `json:"table"`
+	TakenAt   time.Time                   `json:"takenAt"`
+	Columns   datatypes.JSON              `json:"columns,omitempty"`
+	Relations datatypes.JSON              `json:"relations,omitempty"`
+	Rows      []map[string]any            `json:"rows"`
+	Pivots    map[string][]map[string]any `json:"pivots,omitempty"`
+}
+
+type undeployResult struct {
+	Mode string `json:"mode"`
+	// Tables lists the tables dropped or moved, pivots first.
+	Tables []string `json:"tables"`
+	// Moved maps each table to its qualified name in the graveyard.
+	Moved       map[string]string `json:"moved,omitempty"`
+	SnapshotKey string            `json:"snapshotKey,omitempty"`
+	// Snapshot is a download URL of the snapshot, valid for S3_URL_TTL.
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+// undeployTables lists the existing tables of page, pivots first so that
+// they go before the table they point at.
+func undeployTables(db sqlExecutor, page models.Page) ([]string, error) {
+	tables := []string{}
+	seen := map[string]bool{}
+	for _, rel := range parseRelations(page.SchemaRelationsDeployed) {
+		if rel.Type != "many-to-many" {
+			continue
+		}
+		pivot := pivotTableName(page.TableName, rel)
+		if seen[pivot] || validateIdent(pivot) != nil {
+			continue
+		}
+		seen[pivot] = true
+		exists, err := tableExists(db, pivot)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			tables = append(tables, pivot)
+		}
+	}
+	exists, err := tableExists(db, page.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		tables = append(tables, page.TableName)
+	}
+	return tables, nil
+}
+
+// snapshotValue makes a scanned value JSON friendly: raw bytes are kept as
+// JSON when they are some, as text otherwise.
+func snapshotValue(v any) any {
+	b, ok := v.([]byte)
+	if !ok {
+		return v
+	}
+	if json.Valid(b) {
+		return json.RawMessage(b)
+	}
+	return string(b)
+}
+
+func readSnapshotRows(db sqlExecutor, table string) ([]map[string]any, error) {
+	rows, err := queryRows(db, newQuery("SELECT * FROM ").Ident(table))
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		for col, v := range row {
+			row[col] = snapshotValue(v)
+		}
+	}
+	return rows, nil
+}
+
+// snapshotTables uploads the rows of tables to the object storage and
+// returns the key of the snapshot.
+func snapshotTables(c *gin.Context, db sqlExecutor, page models.Page, tables []string) (string, error) {
+	store := services.Objects()
+	if store == nil {
+		return "", services.ErrNoObjectStore
+	}
+
+	snapshot := TableSnapshot{
+		PageID:    page.ID,
+		Table:     page.TableName,
+		TakenAt:   time.Now().UTC(),
+		Columns:   page.SchemaColumnsDeployed,
+		Relations: page.SchemaRelationsDeployed,
+		Rows:      []map[string]any{},
+		Pivots:    map[string][]map[string]any{},
+	}
+	for _, table := range tables {
+		rows, err := readSnapshotRows(db, table)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", table, err)
+		}
+		if table == page.TableName {
+			snapshot.Rows = rows
+		} else {
+			snapshot.Pivots[table] = rows
+		}
+	}
+
+	body, err := json.Marshal(snapshot)
+	if err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("snapshots/%s/%s-%s.json", page.ID, page.TableName, snapshot.TakenAt.Format("20060102T150405Z"))
+	if err := store.Put(c.Request.Context(), key, "application/json", body); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// graveyardName is the name of table once moved to the graveyard, stamped
+// so that a page undeployed twice does not collide with itself.
+func graveyardName(table string, at time.Time) string {
+	suffix := "_" + at.UTC().Format("20060102150405")
+	if len(table)+len(suffix) > 63 {
+		table = table[:63-len(suffix)]
+	}
+	return table + suffix
+}
+
+func RegisterBuilderUndeployRoutes(group *gin.RouterGroup, db *gorm.DB, cache *services.Cache) {
+	// POST /builder/:id/undeploy takes the page offline. Its deployed schemas
+	// are kept for a later deploy. ?table=snapshot also uploads the rows of
+	// its tables to the object storage and drops them; ?table=graveyard moves
+	// them to the graveyard schema instead. The default, keep, leaves them.
+	group.POST("/builder/:id/undeploy", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		id := c.Param("id")
+
+		mode := c.DefaultQuery("table", UndeployKeep)
+		switch mode {
+		case UndeployKeep, UndeploySnapshot, UndeployGraveyard:
+		default:
+			utils.Error(c, http.StatusBadRequest, "INVALID_MODE", "table must be keep, snapshot or graveyard")
+			return
+		}
+
+		var before models.Page
+		if err := db.First(&before, "id = ?", id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Page not found")
+				return
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+		if mode == UndeployKeep && !Bool(before.Deploy) {
+			utils.Error(c, http.StatusConflict, "NOT_DEPLOYED", "Page is not deployed")
+			return
+		}
+		if mode != UndeployKeep && before.TableName == "" {
+			utils.Error(c, http.StatusBadRequest, "NO_TABLE", "Page has no table")
+			return
+		}
+		if mode == UndeploySnapshot && services.Objects() == nil {
+			utils.Error(c, http.StatusBadRequest, "NO_OBJECT_STORE", services.ErrNoObjectStore.Error())
+			return
+		}
+
+		result := undeployResult{Mode: mode, Tables: []string{}}
+		var tx sqlTx
+		if mode != UndeployKeep {
+			var err error
+			if tx, err = beginPageSQL(c, db, before); err != nil {
+				utils.Error(c, http.StatusBadRequest, "INVALID_STORAGE", err.Error())
+				return
+			}
+			defer tx.Rollback()
+			if kind, err := relationKind(tx, before.TableName); err == nil && isViewKind(kind) {
+				utils.Error(c, http.StatusBadRequest, "VIEW_PAGE", "A page over a view only undeploys with table=keep")
+				return
+			}
+			if result.Tables, err = undeployTables(tx, before); err != nil {
+				utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+				return
+			}
+		}
+
+		switch mode {
+		case UndeploySnapshot:
+			key, err := snapshotTables(c, tx, before, result.Tables)
+			if err != nil {
+				utils.Error(c, http.StatusInternalServerError, "SNAPSHOT_ERROR", err.Error())
+				return
+			}
+			result.SnapshotKey = key
+			result.Snapshot = services.Objects().PresignGet(key, safeFilename(before.TableName, "snapshot")+".json")
+			for _, table := range result.Tables {
+				q := newQuery("DROP TABLE ").Ident(table).Write(" CASCADE")
+				if _, err := tx.Exec(q.SQL()); err != nil {
+					utils.Error(c, http.StatusInternalServerError, "DROP_TABLE_ERROR", fmt.Sprintf("%s: %v", table, err))
+					return
+				}
+			}
+
+		case UndeployGraveyard:
+			schema := services.GraveyardSchema()
+			if err := validateIdent(schema); err != nil {
+				utils.Error(c, http.StatusInternalServerError, "INVALID_GRAVEYARD", err.Error())
+				return
+			}
+			if _, err := tx.Exec(newQuery("CREATE SCHEMA IF NOT EXISTS ").Ident(schema).SQL()); err != nil {
+				utils.Error(c, http.StatusInternalServerError, "GRAVEYARD_ERROR", err.Error())
+				return
+			}
+			now := time.Now()
+			result.Moved = map[string]string{}
+			for _, table := range result.Tables {
+				moved := graveyardName(table, now)
+				for _, q := range []*dynamicQuery{
+					newQuery("ALTER TABLE ").Ident(table).Write(" RENAME TO ").Ident(moved),
+					newQuery("ALTER TABLE ").Ident(moved).Write(" SET SCHEMA ").Ident(schema),
+				} {
+					if _, err := tx.Exec(q.SQL()); err != nil {
+						utils.Error(c, http.StatusInternalServerError, "GRAVEYARD_ERROR", fmt.Sprintf("%s: %v", table, err))
+						return
+					}
+				}
+				result.Moved[table] = schema + "." + moved
+			}
+		}
+
+		if err := db.Model(&models.Page{}).Where("id = ?", id).Update("deploy", false).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_UPDATE_ERROR", err.Error())
+			return
+		}
+		if tx != nil {
+			if err := tx.Commit(); err != nil {
+				utils.Error(c, http.StatusInternalServerError, "DB_COMMIT_ERROR", err.Error())
+				return
+			}
+		}
+		invalidatePageCache(c, cache, id)
+
+		var updated models.Page
+		if err := db.Preload("Template").Preload("Tags.Category").First(&updated, "id = ?", id).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_RELOAD_ERROR", err.Error())
+			return
+		}
+		recordSchemaChangelog(c, db, before, updated)
+		c.JSON(http.StatusOK, gin.H{"data": updated, "undeploy": result, "success": true})
+	})
+}
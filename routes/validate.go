@@ -0,0 +1,336 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// A SchemaIssue is a problem found in a draft schema of a page. Path points
+// at the offending entry, e.g. "[2].type".
+type SchemaIssue struct {
+	Schema  string `json:"schema"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+type schemaValidator struct {
+	issues []SchemaIssue
+}
+
+func (v *schemaValidator) add(schema, path, format string, args ...any) {
+	v.issues = append(v.issues, SchemaIssue{Schema: schema, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// decode unmarshals raw into out, noting an issue when its shape is not the
+// expected one. An absent schema is valid.
+func (v *schemaValidator) decode(schema string, raw datatypes.JSON, out any) bool {
+	if len(raw) == 0 || string(raw) == "null" {
+		return false
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		v.add(schema, "", "malformed schema: %v", err)
+		return false
+	}
+	return true
+}
+
+var maskModes = map[string]bool{MaskNull: true, MaskRedact: true, MaskLast: true, MaskFirst: true, MaskEmail: true}
+
+func (v *schemaValidator) columns(cols []ColumnDefinition) {
+	seen := map[string]bool{}
+	naturalKeys, visibility := 0, 0
+	for i, col := range cols {
+		path := fmt.Sprintf("[%d]", i)
+		switch {
+		case col.Name == "":
+			v.add("columns", path+".name", "a column needs a name")
+		case validateIdent(col.Name) != nil:
+			v.add("columns", path+".name", "%q is not a valid column name", col.Name)
+		case seen[col.Name]:
+			v.add("columns", path+".name", "column %q is declared twice", col.Name)
+		}
+		seen[col.Name] = true
+
+		if _, err := columnSQLType(col); err != nil {
+			v.add("columns", path+".type", "unknown column type %q", col.Type)
+		}
+		if col.NaturalKey {
+			naturalKeys++
+			if col.Encrypted {
+				v.add("columns", path+".encrypted", "the natural key %q cannot be encrypted", col.Name)
+			}
+		}
+		if col.VisibilityTags {
+			visibility++
+		}
+		if col.Mask != nil && !maskModes[col.Mask.Mode] {
+			v.add("columns", path+".mask.mode", "unknown mask mode %q", col.Mask.Mode)
+		}
+	}
+	if naturalKeys > 1 {
+		v.add("columns", "", "only one column can be the natural key")
+	}
+	if visibility > 1 {
+		v.add("columns", "", "only one column can hold visibility tags")
+	}
+}
+
+// tableColumns returns the known columns of a table, nil when it is unknown:
+// the columns declared by the pages stored in it and the ones of the live
+// table.
+type tableColumns func(table string) (map[string]bool, error)
+
+func (v *schemaValidator) relations(page models.Page, rels []RelationDefinition, columnsOf tableColumns) error {
+	seen := map[string]bool{}
+	for i, rel := range rels {
+		path := fmt.Sprintf("[%d]", i)
+		switch rel.Type {
+		case "one-to-one", "one-to-many", "many-to-many":
+		default:
+			v.add("relations", path+".type", "unknown relation type %q", rel.Type)
+		}
+		switch {
+		case rel.FromColumn == "":
+			v.add("relations", path+".fromColumn", "a relation needs a fromColumn")
+		case validateIdent(rel.FromColumn) != nil:
+			v.add("relations", path+".fromColumn", "%q is not a valid column name", rel.FromColumn)
+		case seen[rel.FromColumn]:
+			v.add("relations", path+".fromColumn", "column %q holds two relations", rel.FromColumn)
+		}
+		seen[rel.FromColumn] = true
+		if _, err := onDeleteAction(rel.OnDelete, ""); err != nil {
+			v.add("relations", path+".onDelete", "unknown onDelete %q", rel.OnDelete)
+		}
+		if rel.Symmetric && (rel.Type != "many-to-many" || !rel.selfReferencing(page.TableName)) {
+			v.add("relations", path+".symmetric", "only a many-to-many relation of the table to itself can be symmetric")
+		}
+		if rel.Type == "many-to-many" && page.TableName != "" {
+			if pivot := pivotTableName(page.TableName, rel); validateIdent(pivot) != nil {
+				v.add("relations", path+".pivotTable", "%q is not a valid pivot table name", pivot)
+			}
+		}
+
+		if rel.ToTable == "" {
+			v.add("relations", path+".toTable", "a relation needs a toTable")
+			continue
+		}
+		if validateIdent(rel.ToTable) != nil {
+			v.add("relations", path+".toTable", "%q is not a valid table name", rel.ToTable)
+			continue
+		}
+		cols, err := columnsOf(rel.ToTable)
+		if err != nil {
+			return err
+		}
+		if cols == nil {
+			v.add("relations", path+".toTable", "table %q does not exist and no page stores its rows in it", rel.ToTable)
+			continue
+		}
+		for _, ref := range []struct {
+			key   string
+			names []string
+		}{{"labelColumns", rel.LabelColumns}, {"dependencyColumns", rel.DependencyColumns}} {
+			for j, name := range ref.names {
+				if !cols[name] {
+					v.add("relations", fmt.Sprintf("%s.%s[%d]", path, ref.key, j), "table %q has no column %q", rel.ToTable, name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// pageColumnsOf resolves the columns of the tables the relations of page
+// point at, in the storage of page.
+func pageColumnsOf(db *gorm.DB, page models.Page) tableColumns {
+	return func(table string) (map[string]bool, error) {
+		cols := map[string]bool{}
+		known := false
+		add := func(names ...string) {
+			for _, name := range names {
+				cols[name] = true
+			}
+		}
+		addSchema := func(raw ...datatypes.JSON) {
+			for _, r := range raw {
+				for _, col := range parseColumns(r) {
+					add(col.Name)
+				}
+			}
+		}
+
+		if table == page.TableName {
+			known = true
+			addSchema(page.SchemaColumns, page.SchemaColumnsDeployed)
+		}
+		var owners []models.Page
+		q := db.Select("id", "schema_columns", "schema_columns_deployed").
+			Where("table_name = ? AND storage = ?", table, page.Storage)
+		if page.ID != "" {
+			q = q.Where("id <> ?", page.ID)
+		}
+		if err := q.Find(&owners).Error; err != nil {
+			return nil, err
+		}
+		for _, owner := range owners {
+			known = true
+			addSchema(owner.SchemaColumns, owner.SchemaColumnsDeployed)
+		}
+
+		if sqlDB, err := PageSQL(db, page); err == nil {
+			live, err := getColumns(sqlDB, table)
+			if err != nil {
+				return nil, err
+			}
+			if len(live) > 0 {
+				known = true
+				add(live...)
+			}
+		}
+		if !known {
+			return nil, nil
+		}
+		add("id", stampCreatedAt, stampUpdatedAt, stampCreatedBy, stampUpdatedBy)
+		return cols, nil
+	}
+}
+
+// validatePageSchemas checks the draft columns, relations, UI and menus of
+// page. Only a database failure is returned as an error.
+func validatePageSchemas(db *gorm.DB, page models.Page) ([]SchemaIssue, error) {
+	v := &schemaValidator{issues: []SchemaIssue{}}
+
+	var cols []ColumnDefinition
+	if v.decode("columns", page.SchemaColumns, &cols) {
+		v.columns(cols)
+	}
+	var rels []RelationDefinition
+	if v.decode("relations", page.SchemaRelations, &rels) {
+		if err := v.relations(page, rels, pageColumnsOf(db, page)); err != nil {
+			return nil, err
+		}
+	}
+	var ui []map[string]any
+	v.decode("ui", page.SchemaUi, &ui)
+	var menu []menuEntry
+	if v.decode("menus", page.SchemaMenuUi, &menu) {
+		if err := checkMenuRefs(page.SchemaUi, page.SchemaMenuUi); err != nil {
+			v.add("menus", "", "%s", strings.TrimPrefix(err.Error(), ErrInvalidMenu.Error()+": "))
+		}
+	}
+	return v.issues, nil
+}
+
+// schemaFields are the keys of the builder PATCH body that change what
+// validatePageSchemas checks.
+var schemaFields = map[string]string{
+	"schemaColumns": "SchemaColumns", "schema_columns": "SchemaColumns", "SchemaColumns": "SchemaColumns",
+	"schemaRelations": "SchemaRelations", "schema_relations": "SchemaRelations", "SchemaRelations": "SchemaRelations",
+	"schemaUi": "SchemaUi", "schema_ui": "SchemaUi", "SchemaUi": "SchemaUi",
+	"schemaMenuUi": "SchemaMenuUi", "schema_menu_ui": "SchemaMenuUi", "SchemaMenuUi": "SchemaMenuUi",
+	"tableName": "TableName", "table_name": "TableName", "TableName": "TableName",
+	"storage": "Storage", "Storage": "Storage",
+}
+
+// touchesSchemas is touchesMenus for the schemas checked on save.
+func touchesSchemas(before models.Page, updates map[string]any) (models.Page, bool) {
+	after := before
+	touched := false
+	for key, value := range updates {
+		field, ok := schemaFields[key]
+		if !ok {
+			continue
+		}
+		touched = true
+		switch field {
+		case "TableName":
+			after.TableName, _ = value.(string)
+		case "Storage":
+			after.Storage, _ = value.(string)
+		case "SchemaColumns":
+			after.SchemaColumns, _ = json.Marshal(value)
+		case "SchemaRelations":
+			after.SchemaRelations, _ = json.Marshal(value)
+		case "SchemaUi":
+			after.SchemaUi, _ = json.Marshal(value)
+		case "SchemaMenuUi":
+			after.SchemaMenuUi, _ = json.Marshal(value)
+		}
+	}
+	return after, touched
+}
+
+// mergePageSchemas is touchesSchemas for the struct payload of the builder
+// PUT, where a nil or empty field is left unchanged.
+func mergePageSchemas(before, payload models.Page) (models.Page, bool) {
+	after := before
+	touched := false
+	for _, f := range []struct{ from, to *datatypes.JSON }{
+		{&payload.SchemaColumns, &after.SchemaColumns},
+		{&payload.SchemaRelations, &after.SchemaRelations},
+		{&payload.SchemaUi, &after.SchemaUi},
+		{&payload.SchemaMenuUi, &after.SchemaMenuUi},
+	} {
+		if *f.from != nil {
+			*f.to = *f.from
+			touched = true
+		}
+	}
+	for _, f := range []struct{ from, to *string }{
+		{&payload.TableName, &after.TableName},
+		{&payload.Storage, &after.Storage},
+	} {
+		if *f.from != "" {
+			*f.to = *f.from
+			touched = true
+		}
+	}
+	return after, touched
+}
+
+// checkPageSchemas validates page on save, answering 422 with the issues
+// found. It reports whether the save can go on.
+func checkPageSchemas(c *gin.Context, db *gorm.DB, page models.Page) bool {
+	issues, err := validatePageSchemas(db, page)
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+		return false
+	}
+	if len(issues) == 0 {
+		return true
+	}
+	details := fmt.Sprintf("%d schema issue(s)", len(issues))
+	if page.ID != "" {
+		details = fmt.Sprintf("page %s: %s", page.ID, details)
+	}
+	c.JSON(http.StatusUnprocessableEntity, utils.APIResponse{
+		Success: false,
+		Data:    issues,
+		Error:   &utils.APIError{Code: "INVALID_SCHEMA", Details: details},
+	})
+	return false
+}
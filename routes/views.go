@@ -0,0 +1,167 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidView   = errors.New("invalid view")
+	ErrViewAdminOnly = errors.New("only admins may define or deploy a view query")
+)
+
+// checkViewAdmin refuses a view query from anyone but an admin: the query
+// runs as the application role on every read of the page, so whoever
+// writes it can run any SQL that role may.
+func checkViewAdmin(c *gin.Context, query string) error {
+	if strings.TrimSpace(query) == "" {
+		return nil
+	}
+	if user := utils.CurrentUser(c); user != nil && Bool(user.IsAdmin) {
+		return nil
+	}
+	return ErrViewAdminOnly
+}
+
+// relationKind reads the pg_class relkind of table: "r" for a table, "v" for
+// a view, "m" for a materialized view, "" when it does not exist.
+func relationKind(db sqlExecutor, table string) (string, error) {
+	var kind string
+	err := db.QueryRow(`SELECT COALESCE((SELECT relkind::text FROM pg_class WHERE oid = to_regclass($1)), '')`,
+		quoteIdent(table)).Scan(&kind)
+	return kind, err
+}
+
+func isViewKind(kind string) bool {
+	return kind == "v" || kind == "m"
+}
+
+// viewQuery is the stored SELECT of page, without its trailing semicolon.
+func viewQuery(page models.Page) string {
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(page.ViewQuery), ";"))
+}
+
+// checkViewQuery validates the stored SELECT of a page: one SELECT or WITH
+// statement.
+func checkViewQuery(page models.Page) error {
+	query := viewQuery(page)
+	if query == "" {
+		return nil
+	}
+	if isProxyPage(page) {
+		return fmt.Errorf("%w: a page reads either a view or a source", ErrInvalidView)
+	}
+	if head := strings.ToLower(strings.Fields(query)[0]); head != "select" && head != "with" {
+		return fmt.Errorf("%w: the query must be a SELECT", ErrInvalidView)
+	}
+	if strings.Contains(query, ";") {
+		return fmt.Errorf("%w: the query must be a single statement", ErrInvalidView)
+	}
+	return nil
+}
+
+// planView plans the deploy of a page over a view: the view is created or
+// replaced from the stored query, or used as is without one. Views get no
+// columns, stamps nor foreign keys.
+func planView(db sqlExecutor, page models.Page, kind string) (*deployPlan, error) {
+	plan := &deployPlan{Table: page.TableName, View: true, Statements: []string{}}
+	query := viewQuery(page)
+	if query == "" {
+		return plan, nil
+	}
+	if err := checkViewQuery(page); err != nil {
+		return nil, err
+	}
+	if kind != "" && kind != "v" {
+		return nil, fmt.Errorf("%w: %q exists and is not a view", ErrInvalidView, page.TableName)
+	}
+	plan.Created = kind == ""
+	plan.add(newQuery("CREATE OR REPLACE VIEW ").Ident(page.TableName).Write(" AS ", query))
+	return plan, nil
+}
+
+// checkViewTables refuses a view without an id column or reading a core
+// table. The tables are taken from its plan, which sees through the views
+// it is built on.
+func checkViewTables(db *gorm.DB, tx sqlExecutor, table string) error {
+	live, err := tableColumnTypes(tx, table)
+	if err != nil {
+		return err
+	}
+	if live["id"] == "" {
+		return fmt.Errorf("%w: %q has no id column", ErrInvalidView, table)
+	}
+
+	var raw []byte
+	if err := tx.QueryRow(newQuery("EXPLAIN (FORMAT JSON) SELECT * FROM ").Ident(table).SQL()).Scan(&raw); err != nil {
+		return err
+	}
+	var plan any
+	if err := json.Unmarshal(raw, &plan); err != nil {
+		return err
+	}
+	var check func(node any) error
+	check = func(node any) error {
+		switch n := node.(type) {
+		case []any:
+			for _, v := range n {
+				if err := check(v); err != nil {
+					return err
+				}
+			}
+		case map[string]any:
+			if name, ok := n["Relation Name"].(string); ok {
+				schema, _ := n["Schema"].(string)
+				if isCoreTable(db, name) || schema == "pg_catalog" || schema == "information_schema" {
+					return fmt.Errorf("%w: %q", ErrReservedTable, name)
+				}
+			}
+			for _, v := range n {
+				if err := check(v); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return check(plan)
+}
+
+// isReadOnlyPage tells whether the rows of page cannot be written through
+// the API: proxy pages, pages over a view and pages marked read-only.
+func isReadOnlyPage(page models.Page) bool {
+	return Bool(page.ReadOnly) || page.ViewQuery != "" || isProxyPage(page)
+}
+
+// rejectReadOnlyPage answers 405 to a row write on a read-only page.
+func rejectReadOnlyPage(c *gin.Context, page models.Page) bool {
+	if !isReadOnlyPage(page) {
+		return false
+	}
+	c.JSON(http.StatusMethodNotAllowed, gin.H{"error": "Cette page est en lecture seule"})
+	return true
+}
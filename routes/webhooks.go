@@ -0,0 +1,214 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"api-core-v2/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+type webhookPayload struct {
+	URL    *string   `json:"url"`
+	Secret *string   `json:"secret"`
+	Events *[]string `json:"events"`
+	PageID *string   `json:"pageId"`
+	Active *bool     `json:"active"`
+}
+
+// apply validates the payload and copies it onto hook.
+func (p webhookPayload) apply(db *gorm.DB, hook *models.Webhook) error {
+	if p.URL != nil {
+		u, err := url.Parse(*p.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("url must be an absolute http(s) URL")
+		}
+		hook.URL = *p.URL
+	}
+	if p.Secret != nil {
+		hook.Secret = *p.Secret
+	}
+	if p.Events != nil {
+		for _, event := range *p.Events {
+			known := false
+			for _, e := range services.WebhookEvents {
+				known = known || e == event
+			}
+			if !known {
+				return fmt.Errorf("unknown event %q", event)
+			}
+		}
+		hook.Events, _ = json.Marshal(*p.Events)
+	}
+	if p.PageID != nil {
+		hook.PageID = nil
+		if *p.PageID != "" {
+			var count int64
+			if err := db.Model(&models.Page{}).Where("id = ?", *p.PageID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return fmt.Errorf("page %s not found", *p.PageID)
+			}
+			hook.PageID = p.PageID
+		}
+	}
+	if p.Active != nil {
+		hook.Active = p.Active
+	}
+	return nil
+}
+
+// RegisterWebhookRoutes manages the webhook subscriptions. Secrets are only
+// returned when the webhook is created.
+func RegisterWebhookRoutes(group *gin.RouterGroup, db *gorm.DB) {
+	webhooks := group.Group("/webhooks")
+
+	load := func(c *gin.Context, db *gorm.DB) (*models.Webhook, bool) {
+		var hook models.Webhook
+		if err := db.First(&hook, "id = ?", c.Param("id")).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				utils.Error(c, http.StatusNotFound, "NOT_FOUND", "Webhook not found")
+				return nil, false
+			}
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return nil, false
+		}
+		return &hook, true
+	}
+
+	webhooks.GET("", func(c *gin.Context) {
+		query := db.Order("created_at DESC")
+		if pageID := c.Query("pageId"); pageID != "" {
+			query = query.Where("page_id = ?", pageID)
+		}
+
+		list := []models.Webhook{}
+		meta, err := utils.FindPage(query, utils.ParsePagination(c, 0, listMaxPageSize), &list, nil)
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_FETCH_ERROR", err.Error())
+			return
+		}
+		for i := range list {
+			list[i].Secret = ""
+		}
+		c.JSON(http.StatusOK, gin.H{"data": list, "meta": meta, "success": true})
+	})
+
+	webhooks.GET("/:id", func(c *gin.Context) {
+		hook, ok := load(c, db)
+		if !ok {
+			return
+		}
+		hook.Secret = ""
+		c.JSON(http.StatusOK, gin.H{"data": hook, "success": true})
+	})
+
+	webhooks.POST("", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		var payload webhookPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		if payload.URL == nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_WEBHOOK", "url is required")
+			return
+		}
+
+		hook := models.Webhook{Events: datatypes.JSON("[]"), CreatedByID: utils.CurrentUserID(c)}
+		if err := payload.apply(db, &hook); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_WEBHOOK", err.Error())
+			return
+		}
+		if hook.Secret == "" {
+			hook.Secret = services.NewWebhookSecret()
+		}
+
+		if err := db.Create(&hook).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_CREATE_ERROR", err.Error())
+			return
+		}
+		recordAudit(c, db, services.AuditActionCreate, "webhook", &hook.ID, services.AuditStatusSuccess, gin.H{"url": hook.URL})
+		c.JSON(http.StatusCreated, gin.H{"data": hook, "success": true})
+	})
+
+	webhooks.PUT("/:id", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		var payload webhookPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+		hook, ok := load(c, db)
+		if !ok {
+			return
+		}
+		if err := payload.apply(db, hook); err != nil {
+			utils.Error(c, http.StatusBadRequest, "INVALID_WEBHOOK", err.Error())
+			return
+		}
+
+		if err := db.Select("url", "secret", "events", "page_id", "active").Save(hook).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_UPDATE_ERROR", err.Error())
+			return
+		}
+		recordAudit(c, db, services.AuditActionUpdate, "webhook", &hook.ID, services.AuditStatusSuccess, gin.H{"url": hook.URL})
+		hook.Secret = ""
+		c.JSON(http.StatusOK, gin.H{"data": hook, "success": true})
+	})
+
+	webhooks.DELETE("/:id", func(c *gin.Context) {
+		db := utils.DB(c, db)
+		hook, ok := load(c, db)
+		if !ok {
+			return
+		}
+		if err := db.Delete(hook).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "DB_DELETE_ERROR", err.Error())
+			return
+		}
+		recordAudit(c, db, services.AuditActionDelete, "webhook", &hook.ID, services.AuditStatusSuccess, gin.H{"url": hook.URL})
+		c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted", "id": hook.ID, "success": true})
+	})
+
+	// POST /:id/ping delivers a ping event right away, without retries, and
+	// reports the outcome.
+	webhooks.POST("/:id/ping", func(c *gin.Context) {
+		hook, ok := load(c, db)
+		if !ok {
+			return
+		}
+		event := services.NewWebhookEvent(services.WebhookPing)
+		if hook.PageID != nil {
+			event.PageID = *hook.PageID
+		}
+		if err := services.DeliverWebhook(*hook, event); err != nil {
+			utils.Error(c, http.StatusBadGateway, "DELIVERY_FAILED", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Ping delivered", "id": event.ID, "success": true})
+	})
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"api-core-v2/models"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AuditResourcePageRow = "page_row"
+	// AuditResourcePage covers the builder mutations of a page and its
+	// publishing transitions.
+	AuditResourcePage = "page"
+
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+
+	AuditStatusSuccess = "success"
+	AuditStatusFailure = "failure"
+)
+
+func RecordAudit(db *gorm.DB, entry models.AuditLog) {
+	if err := db.Create(&entry).Error; err != nil {
+		log.Println("⚠️  Unable to write audit log:", err)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const CacheKeyPrefix = "cache:"
+
+const NavigationCacheKey = CacheKeyPrefix + "navigation"
+
+type Cache struct {
+	rdb    *redis.Client
+	ttl    time.Duration
+	health *Health
+}
+
+func NewCache(rdb *redis.Client, ttl time.Duration) *Cache {
+	return &Cache{rdb: rdb, ttl: ttl}
+}
+
+func NewCacheFromEnv(rdb *redis.Client) *Cache {
+	ttlSec := 0
+	if v := os.Getenv("PAGE_CACHE_TTL"); v != "" {
+		fmt.Sscanf(v, "%d", &ttlSec)
+	}
+	if ttlSec <= 0 || rdb == nil {
+		return nil
+	}
+	return NewCache(rdb, time.Duration(ttlSec)*time.Second)
+}
+
+func NavigationMenuCacheKey(menu string) string {
+	return NavigationCacheKey + ":" + menu
+}
+
+func PageCacheKey(pageID string) string {
+	return CacheKeyPrefix + "page:" + pageID
+}
+
+func (c *Cache) Enabled() bool {
+	return c != nil
+}
+
+// WithHealth skips the cache while Redis is down. Invalidations are lost
+// meanwhile, so every cached entry is dropped once Redis is back.
+func (c *Cache) WithHealth(h *Health) *Cache {
+	if c == nil {
+		return nil
+	}
+	c.health = h
+	h.OnChange(DependencyRedis, func(status string) {
+		if status != HealthDown {
+			c.flush(context.Background())
+		}
+	})
+	return c
+}
+
+func (c *Cache) available() bool {
+	return c != nil && c.health.Available(DependencyRedis)
+}
+
+func (c *Cache) flush(ctx context.Context) {
+	iter := c.rdb.Scan(ctx, 0, CacheKeyPrefix+"*", 500).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		log.Println("⚠️  Cache flush failed:", err)
+		return
+	}
+	c.Delete(ctx, keys...)
+}
+
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool) {
+	if !c.available() {
+		return nil, false
+	}
+	body, err := c.rdb.Get(ctx, key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			log.Println("⚠️  Cache read failed:", err)
+		}
+		return nil, false
+	}
+	return body, true
+}
+
+func (c *Cache) Set(ctx context.Context, key string, body []byte) {
+	if !c.available() {
+		return
+	}
+	if err := c.rdb.Set(ctx, key, body, c.ttl).Err(); err != nil {
+		log.Println("⚠️  Cache write failed:", err)
+	}
+}
+
+func (c *Cache) Delete(ctx context.Context, keys ...string) {
+	if !c.available() || len(keys) == 0 {
+		return
+	}
+	if err := c.rdb.Del(ctx, keys...).Err(); err != nil {
+		log.Println("⚠️  Cache invalidation failed:", err)
+	}
+}
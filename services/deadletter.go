@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"api-core-v2/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DeadLetterPending   = "pending"
+	DeadLetterReplayed  = "replayed"
+	DeadLetterDiscarded = "discarded"
+)
+
+// JobHandler runs one delivery of a job from its stored payload. Handlers are
+// registered per kind so dead letters can be replayed after a restart.
+type JobHandler func(db *gorm.DB, payload []byte) error
+
+var (
+	jobHandlersMu sync.RWMutex
+	jobHandlers   = map[string]JobHandler{}
+)
+
+func RegisterJobHandler(kind string, handler JobHandler) {
+	jobHandlersMu.Lock()
+	defer jobHandlersMu.Unlock()
+	jobHandlers[kind] = handler
+}
+
+func jobHandler(kind string) (JobHandler, bool) {
+	jobHandlersMu.RLock()
+	defer jobHandlersMu.RUnlock()
+	h, ok := jobHandlers[kind]
+	return h, ok
+}
+
+func jobMaxAttempts() int {
+	if n, err := strconv.Atoi(os.Getenv("JOB_MAX_ATTEMPTS")); err == nil && n > 0 {
+		return n
+	}
+	return 3
+}
+
+// RunJob delivers payload through the handler registered for kind, retrying
+// with exponential backoff. Once the attempts are exhausted the payload is
+// kept as a dead letter for inspection and replay.
+func RunJob(db *gorm.DB, kind, target string, payload any) error {
+	handler, ok := jobHandler(kind)
+	if !ok {
+		return fmt.Errorf("no handler registered for job %q", kind)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	attempts := jobMaxAttempts()
+	backoff := time.Second
+
+	for attempt := 1; ; attempt++ {
+		err = handler(db, body)
+		if err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	letter := models.DeadLetter{
+		Kind:     kind,
+		Target:   target,
+		Payload:  body,
+		Error:    err.Error(),
+		Attempts: attempts,
+		Status:   DeadLetterPending,
+	}
+	if dbErr := db.Create(&letter).Error; dbErr != nil {
+		log.Printf("❌ [DEADLETTER] Impossible d'enregistrer le job %s: %v", kind, dbErr)
+	}
+	return err
+}
+
+// ReplayDeadLetter runs a dead letter once more. It is marked replayed on
+// success; on failure the attempt count and last error are updated.
+func ReplayDeadLetter(db *gorm.DB, letter *models.DeadLetter) error {
+	handler, ok := jobHandler(letter.Kind)
+	if !ok {
+		return fmt.Errorf("no handler registered for job %q", letter.Kind)
+	}
+
+	runErr := handler(db, letter.Payload)
+
+	letter.Attempts++
+	if runErr != nil {
+		letter.Error = runErr.Error()
+	} else {
+		now := time.Now()
+		letter.Status = DeadLetterReplayed
+		letter.ReplayedAt = &now
+	}
+
+	if err := db.Save(letter).Error; err != nil {
+		return err
+	}
+	return runErr
+}
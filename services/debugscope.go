@@ -0,0 +1,213 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const DebugScopeKeyPrefix = "debug-scope:"
+
+// Kinds of debug scope. A user scope matches the user id or email, a client
+// scope the OAuth client of the token (azp or client_id), a route scope
+// "[METHOD ]/api/path", where a trailing * matches any suffix.
+const (
+	DebugScopeUser   = "user"
+	DebugScopeClient = "client"
+	DebugScopeRoute  = "route"
+)
+
+// MaxDebugScopeDuration bounds how long verbose logging may stay on.
+const MaxDebugScopeDuration = 24 * time.Hour
+
+// debugScopesRefresh bounds how long an instance keeps using a stale list
+// after another instance changed it.
+const debugScopesRefresh = 5 * time.Second
+
+var ErrInvalidDebugScope = errors.New("invalid debug scope")
+
+type DebugScope struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	Value     string    `json:"value"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+}
+
+// DebugRequest is what a debug scope is matched against.
+type DebugRequest struct {
+	UserID    string
+	UserEmail string
+	Client    string
+	Method    string
+	Route     string
+}
+
+func (s DebugScope) Matches(r DebugRequest) bool {
+	switch s.Kind {
+	case DebugScopeUser:
+		return s.Value != "" && (s.Value == r.UserID || strings.EqualFold(s.Value, r.UserEmail))
+	case DebugScopeClient:
+		return s.Value != "" && s.Value == r.Client
+	case DebugScopeRoute:
+		pattern := s.Value
+		if method, rest, ok := strings.Cut(pattern, " "); ok {
+			if !strings.EqualFold(method, r.Method) {
+				return false
+			}
+			pattern = strings.TrimSpace(rest)
+		}
+		if prefix, wildcard := strings.CutSuffix(pattern, "*"); wildcard {
+			return strings.HasPrefix(r.Route, prefix)
+		}
+		return pattern == r.Route
+	}
+	return false
+}
+
+// DebugScopes turns verbose request logging on for some users, clients or
+// routes for a limited time. Scopes live in Redis, expiring with their
+// TTL, so every instance applies them.
+type DebugScopes struct {
+	rdb    *redis.Client
+	health *Health
+
+	mu        sync.Mutex
+	scopes    []DebugScope
+	checkedAt time.Time
+}
+
+func NewDebugScopes(rdb *redis.Client) *DebugScopes {
+	return &DebugScopes{rdb: rdb}
+}
+
+func (d *DebugScopes) WithHealth(h *Health) *DebugScopes {
+	d.health = h
+	return d
+}
+
+func (d *DebugScopes) List(ctx context.Context) ([]DebugScope, error) {
+	if d.rdb == nil {
+		return nil, nil
+	}
+	var scopes []DebugScope
+	iter := d.rdb.Scan(ctx, 0, DebugScopeKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		body, err := d.rdb.Get(ctx, iter.Val()).Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var scope DebugScope
+		if err := json.Unmarshal(body, &scope); err != nil {
+			log.Println("⚠️  Debug scope unreadable:", err)
+			continue
+		}
+		scopes = append(scopes, scope)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(scopes, func(i, j int) bool { return scopes[i].ExpiresAt.Before(scopes[j].ExpiresAt) })
+	return scopes, nil
+}
+
+// Add stores scope for duration, at most MaxDebugScopeDuration.
+func (d *DebugScopes) Add(ctx context.Context, scope DebugScope, duration time.Duration) (DebugScope, error) {
+	switch scope.Kind {
+	case DebugScopeUser, DebugScopeClient, DebugScopeRoute:
+	default:
+		return scope, fmt.Errorf("%w: kind must be user, client or route", ErrInvalidDebugScope)
+	}
+	if strings.TrimSpace(scope.Value) == "" {
+		return scope, fmt.Errorf("%w: value is required", ErrInvalidDebugScope)
+	}
+	if duration <= 0 || duration > MaxDebugScopeDuration {
+		return scope, fmt.Errorf("%w: duration must be between 1s and %s", ErrInvalidDebugScope, MaxDebugScopeDuration)
+	}
+	if d.rdb == nil {
+		return scope, errors.New("debug scopes need Redis")
+	}
+
+	scope.ID = randomHex(8)
+	scope.ExpiresAt = time.Now().Add(duration).UTC()
+	body, err := json.Marshal(scope)
+	if err != nil {
+		return scope, err
+	}
+	if err := d.rdb.Set(ctx, DebugScopeKeyPrefix+scope.ID, body, duration).Err(); err != nil {
+		return scope, err
+	}
+	d.forget()
+	return scope, nil
+}
+
+// Remove deletes a scope and reports whether it existed.
+func (d *DebugScopes) Remove(ctx context.Context, id string) (bool, error) {
+	if d.rdb == nil {
+		return false, nil
+	}
+	n, err := d.rdb.Del(ctx, DebugScopeKeyPrefix+id).Result()
+	d.forget()
+	return n > 0, err
+}
+
+func (d *DebugScopes) forget() {
+	d.mu.Lock()
+	d.checkedAt = time.Time{}
+	d.mu.Unlock()
+}
+
+// Match returns the first active scope matching r.
+func (d *DebugScopes) Match(ctx context.Context, r DebugRequest) (DebugScope, bool) {
+	if d == nil {
+		return DebugScope{}, false
+	}
+
+	d.mu.Lock()
+	if time.Since(d.checkedAt) >= debugScopesRefresh && d.health.Available(DependencyRedis) {
+		// Keep the last known scopes while Redis is unreachable.
+		if scopes, err := d.List(ctx); err == nil {
+			d.scopes = scopes
+		} else {
+			log.Println("⚠️  Debug scopes read failed:", err)
+		}
+		d.checkedAt = time.Now()
+	}
+	scopes := d.scopes
+	d.mu.Unlock()
+
+	now := time.Now()
+	for _, scope := range scopes {
+		if now.Before(scope.ExpiresAt) && scope.Matches(r) {
+			return scope, true
+		}
+	}
+	return DebugScope{}, false
+}
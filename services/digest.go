@@ -0,0 +1,253 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"api-core-v2/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+
+	DigestChannelEmail   = "email"
+	DigestChannelWebhook = "webhook"
+)
+
+// DigestEditor only names a contributor: digests may leave the API through
+// webhooks, so they carry no email address.
+type DigestEditor struct {
+	Name    string `json:"name"`
+	Changes int64  `json:"changes"`
+}
+
+type PageDigest struct {
+	PageID     string         `json:"pageId"`
+	PageName   string         `json:"pageName"`
+	Frequency  string         `json:"frequency"`
+	From       time.Time      `json:"from"`
+	To         time.Time      `json:"to"`
+	Added      int64          `json:"added"`
+	Changed    int64          `json:"changed"`
+	Deleted    int64          `json:"deleted"`
+	TopEditors []DigestEditor `json:"topEditors"`
+}
+
+// webhookClient does not follow redirects: a target is only checked when
+// the subscription is created.
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// DigestWebhookHosts lists the hosts digest webhooks may target, from
+// DIGEST_WEBHOOK_HOSTS (comma-separated).
+func DigestWebhookHosts() []string {
+	var hosts []string
+	for _, h := range strings.Split(os.Getenv("DIGEST_WEBHOOK_HOSTS"), ",") {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// CheckDigestWebhook validates the target of a webhook digest: an https URL
+// on one of DigestWebhookHosts.
+func CheckDigestWebhook(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q", target)
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("webhook URL must use https")
+	}
+	if !slices.Contains(DigestWebhookHosts(), strings.ToLower(u.Hostname())) {
+		return fmt.Errorf("host %q is not in DIGEST_WEBHOOK_HOSTS", u.Hostname())
+	}
+	return nil
+}
+
+func DigestPeriod(frequency string) time.Duration {
+	if frequency == DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+func BuildPageDigest(db *gorm.DB, page models.Page, frequency string, from, to time.Time) (*PageDigest, error) {
+	digest := &PageDigest{
+		PageID:     page.ID,
+		PageName:   page.Name,
+		Frequency:  frequency,
+		From:       from,
+		To:         to,
+		TopEditors: []DigestEditor{},
+	}
+
+	scope := db.Model(&models.AuditLog{}).
+		Where("resource = ? AND resource_id = ? AND status = ?", AuditResourcePageRow, page.ID, AuditStatusSuccess).
+		Where("created_at >= ? AND created_at < ?", from, to)
+
+	var counts []struct {
+		Action string
+		Count  int64
+	}
+	if err := scope.Session(&gorm.Session{}).
+		Select("action, COUNT(*) AS count").
+		Group("action").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range counts {
+		switch row.Action {
+		case AuditActionCreate:
+			digest.Added = row.Count
+		case AuditActionUpdate:
+			digest.Changed = row.Count
+		case AuditActionDelete:
+			digest.Deleted = row.Count
+		}
+	}
+
+	if err := scope.Session(&gorm.Session{}).
+		Select("users.name, COUNT(*) AS changes").
+		Joins("JOIN users ON users.id = audit_logs.user_id").
+		Group("users.id, users.name").
+		Order("changes DESC").
+		Limit(5).
+		Scan(&digest.TopEditors).Error; err != nil {
+		return nil, err
+	}
+
+	return digest, nil
+}
+
+const JobDigest = "digest"
+
+// DigestJob is the replayable payload of a digest delivery.
+type DigestJob struct {
+	SubscriptionID string      `json:"subscriptionId"`
+	Digest         *PageDigest `json:"digest"`
+}
+
+func init() {
+	RegisterJobHandler(JobDigest, func(db *gorm.DB, payload []byte) error {
+		var job DigestJob
+		if err := json.Unmarshal(payload, &job); err != nil {
+			return err
+		}
+		var sub models.DigestSubscription
+		if err := db.Preload("User").First(&sub, "id = ?", job.SubscriptionID).Error; err != nil {
+			return err
+		}
+		return DeliverDigest(sub, job.Digest)
+	})
+}
+
+func DeliverDigest(sub models.DigestSubscription, digest *PageDigest) error {
+	switch sub.Channel {
+	case DigestChannelWebhook:
+		return deliverDigestWebhook(sub.Target, digest)
+	case DigestChannelEmail:
+		target := sub.Target
+		if target == "" && sub.User != nil {
+			target = sub.User.Email
+		}
+		return deliverDigestEmail(target, digest)
+	}
+	return fmt.Errorf("unknown digest channel %q", sub.Channel)
+}
+
+func deliverDigestWebhook(target string, digest *PageDigest) error {
+	if target == "" {
+		return fmt.Errorf("missing webhook target")
+	}
+
+	body, err := json.Marshal(digest)
+	if err != nil {
+		return err
+	}
+
+	resp, err := webhookClient.Post(target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func deliverDigestEmail(target string, digest *PageDigest) error {
+	host := os.Getenv("SMTP_HOST")
+	from := os.Getenv("SMTP_FROM")
+	if host == "" || from == "" {
+		return fmt.Errorf("missing SMTP_* env vars")
+	}
+	if target == "" {
+		return fmt.Errorf("missing email target")
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	var auth smtp.Auth
+	if user := os.Getenv("SMTP_USERNAME"); user != "" {
+		auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", target)
+	// The page name is free text: a line break would inject headers.
+	subjectName := strings.NewReplacer("\r", " ", "\n", " ").Replace(digest.PageName)
+	fmt.Fprintf(&b, "Subject: [%s] Digest %s\r\n", subjectName, digest.Frequency)
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&b, "Page: %s\r\n", digest.PageName)
+	fmt.Fprintf(&b, "Période: %s → %s\r\n\r\n", digest.From.Format(time.RFC3339), digest.To.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Lignes ajoutées: %d\r\n", digest.Added)
+	fmt.Fprintf(&b, "Lignes modifiées: %d\r\n", digest.Changed)
+	fmt.Fprintf(&b, "Lignes supprimées: %d\r\n", digest.Deleted)
+
+	if len(digest.TopEditors) > 0 {
+		b.WriteString("\r\nTop contributeurs:\r\n")
+		for _, editor := range digest.TopEditors {
+			fmt.Fprintf(&b, " - %s: %d\r\n", editor.Name, editor.Changes)
+		}
+	}
+
+	return smtp.SendMail(host+":"+port, auth, from, []string{target}, []byte(b.String()))
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const EditLockKeyPrefix = "editlock:"
+
+// EditLock is held by the user editing a page in the builder.
+type EditLock struct {
+	PageID     string    `json:"pageId"`
+	UserID     string    `json:"userId"`
+	UserName   string    `json:"userName"`
+	AcquiredAt time.Time `json:"acquiredAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// acquireLockScript sets the lock unless another user holds it, and returns
+// the lock in place.
+var acquireLockScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if current then
+	local lock = cjson.decode(current)
+	if lock.userId ~= ARGV[2] then
+		return current
+	end
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[3])
+return ARGV[1]
+`)
+
+// releaseLockScript deletes the lock when the user holds it.
+var releaseLockScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if current and cjson.decode(current).userId == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// EditLocks keeps one user at a time editing a page. A lock expires after
+// its TTL unless its holder extends it, so a closed builder tab frees the
+// page. Locks are shared through Redis when it is configured and up, and
+// kept in memory otherwise.
+type EditLocks struct {
+	rdb    *redis.Client
+	ttl    time.Duration
+	health *Health
+
+	mu    sync.Mutex
+	local map[string]EditLock
+}
+
+// NewEditLocksFromEnv reads BUILDER_LOCK_TTL, in seconds (120 by default, 0
+// disables the locks).
+func NewEditLocksFromEnv(rdb *redis.Client) *EditLocks {
+	ttl := 120 * time.Second
+	if n, err := strconv.Atoi(os.Getenv("BUILDER_LOCK_TTL")); err == nil && n >= 0 {
+		ttl = time.Duration(n) * time.Second
+	}
+	return &EditLocks{rdb: rdb, ttl: ttl, local: map[string]EditLock{}}
+}
+
+func (l *EditLocks) WithHealth(h *Health) *EditLocks {
+	l.health = h
+	return l
+}
+
+func (l *EditLocks) Enabled() bool {
+	return l != nil && l.ttl > 0
+}
+
+func (l *EditLocks) TTL() time.Duration {
+	return l.ttl
+}
+
+func (l *EditLocks) shared() bool {
+	return l.rdb != nil && l.health.Available(DependencyRedis)
+}
+
+// Acquire takes the lock of pageID for the user, or extends it when the
+// user already holds it. When another user holds it, that lock is returned
+// with false.
+func (l *EditLocks) Acquire(ctx context.Context, pageID, userID, userName string) (EditLock, bool) {
+	now := time.Now()
+	lock := EditLock{PageID: pageID, UserID: userID, UserName: userName, AcquiredAt: now, ExpiresAt: now.Add(l.ttl)}
+	if current, ok := l.Get(ctx, pageID); ok && current.UserID == userID {
+		lock.AcquiredAt = current.AcquiredAt
+	}
+
+	if l.shared() {
+		body, _ := json.Marshal(lock)
+		res, err := acquireLockScript.Run(ctx, l.rdb, []string{EditLockKeyPrefix + pageID},
+			string(body), userID, l.ttl.Milliseconds()).Text()
+		if err == nil {
+			var held EditLock
+			if err := json.Unmarshal([]byte(res), &held); err == nil {
+				return held, held.UserID == userID
+			}
+		}
+		log.Println("⚠️  Edit lock acquire failed, using local memory:", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if current, ok := l.local[pageID]; ok && current.UserID != userID && now.Before(current.ExpiresAt) {
+		return current, false
+	}
+	l.local[pageID] = lock
+	return lock, true
+}
+
+// Get returns the lock of pageID, if any.
+func (l *EditLocks) Get(ctx context.Context, pageID string) (EditLock, bool) {
+	if l.shared() {
+		body, err := l.rdb.Get(ctx, EditLockKeyPrefix+pageID).Bytes()
+		if err == redis.Nil {
+			return EditLock{}, false
+		}
+		if err == nil {
+			var lock EditLock
+			if err := json.Unmarshal(body, &lock); err == nil {
+				return lock, true
+			}
+		}
+		log.Println("⚠️  Edit lock read failed, using local memory:", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lock, ok := l.local[pageID]
+	if ok && time.Now().After(lock.ExpiresAt) {
+		delete(l.local, pageID)
+		return EditLock{}, false
+	}
+	return lock, ok
+}
+
+// Release frees the lock of pageID when the user holds it.
+func (l *EditLocks) Release(ctx context.Context, pageID, userID string) {
+	if l.shared() {
+		if err := releaseLockScript.Run(ctx, l.rdb, []string{EditLockKeyPrefix + pageID}, userID).Err(); err != nil {
+			log.Println("⚠️  Edit lock release failed:", err)
+		}
+	}
+	l.mu.Lock()
+	if l.local[pageID].UserID == userID {
+		delete(l.local, pageID)
+	}
+	l.mu.Unlock()
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// encryptedPrefix starts every value sealed by ColumnCipher, so values
+// written before a column was encrypted are still read as they are.
+const encryptedPrefix = "enc:v1:"
+
+var ErrNoColumnCipher = errors.New("column encryption is not configured")
+
+// ColumnCipher seals the values of encrypted page columns with AES-256-GCM.
+// Values are JSON-encoded before sealing so they come back with their type.
+type ColumnCipher struct {
+	aead cipher.AEAD
+}
+
+var (
+	columnCipherMu sync.RWMutex
+	columnCipher   *ColumnCipher
+)
+
+// LoadColumnCipherFromEnv configures column encryption when
+// COLUMN_ENCRYPTION_KEY is set, to 32 bytes encoded in base64.
+func LoadColumnCipherFromEnv() error {
+	encoded := os.Getenv("COLUMN_ENCRYPTION_KEY")
+	if encoded == "" {
+		return nil
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != 32 {
+		return fmt.Errorf("COLUMN_ENCRYPTION_KEY: expected 32 bytes encoded in base64")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	columnCipherMu.Lock()
+	columnCipher = &ColumnCipher{aead: aead}
+	columnCipherMu.Unlock()
+	log.Println("🔵 Column encryption enabled")
+	return nil
+}
+
+// Cipher returns the configured column cipher, or nil.
+func Cipher() *ColumnCipher {
+	columnCipherMu.RLock()
+	defer columnCipherMu.RUnlock()
+	return columnCipher
+}
+
+// IsEncrypted reports whether v was sealed by a ColumnCipher.
+func IsEncrypted(v any) bool {
+	s, ok := v.(string)
+	return ok && strings.HasPrefix(s, encryptedPrefix)
+}
+
+// Encrypt seals v. nil and values that are already sealed are returned as
+// they are.
+func (c *ColumnCipher) Encrypt(v any) (any, error) {
+	if v == nil || IsEncrypted(v) {
+		return v, nil
+	}
+	plain, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := c.aead.Seal(nonce, nonce, plain, nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value sealed by Encrypt. Values that are not sealed are
+// returned as they are.
+func (c *ColumnCipher) Decrypt(v any) (any, error) {
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+	if !IsEncrypted(v) {
+		return v, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(v.(string), encryptedPrefix))
+	if err != nil {
+		return nil, err
+	}
+	size := c.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, errors.New("encrypted value is too short")
+	}
+	plain, err := c.aead.Open(nil, sealed[:size], sealed[size:], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var out any
+	dec := json.NewDecoder(bytes.NewReader(plain))
+	dec.UseNumber()
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const PageEventsChannel = "page-events"
+
+// PageEvent tells the subscribers of a page that one of its rows changed.
+// It carries no row data: clients refetch the row through the API, which
+// applies their conditions and row policy.
+type PageEvent struct {
+	ID         string    `json:"id"`
+	Event      string    `json:"event"`
+	PageID     string    `json:"pageId"`
+	ItemID     string    `json:"itemId,omitempty"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
+// PageEvents broadcasts row changes to the streams open on their page.
+// Events go through Redis pub/sub when it is configured and up, so that
+// every instance sees the writes of the others, and stay local otherwise.
+type PageEvents struct {
+	rdb    *redis.Client
+	health *Health
+
+	mu          sync.Mutex
+	subscribers map[string]map[chan PageEvent]struct{}
+}
+
+func NewPageEvents(rdb *redis.Client) *PageEvents {
+	return &PageEvents{rdb: rdb, subscribers: map[string]map[chan PageEvent]struct{}{}}
+}
+
+func (e *PageEvents) WithHealth(h *Health) *PageEvents {
+	e.health = h
+	return e
+}
+
+// Start relays the events published by every instance to the local
+// subscribers.
+func (e *PageEvents) Start(ctx context.Context) {
+	if e.rdb == nil {
+		return
+	}
+	go func() {
+		for {
+			sub := e.rdb.Subscribe(ctx, PageEventsChannel)
+			for msg := range sub.Channel() {
+				var event PageEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					log.Println("⚠️  Page event unreadable:", err)
+					continue
+				}
+				e.deliver(event)
+			}
+			sub.Close()
+			if ctx.Err() != nil {
+				return
+			}
+			time.Sleep(time.Second)
+		}
+	}()
+}
+
+// Publish sends event to the subscribers of its page, on every instance.
+func (e *PageEvents) Publish(event PageEvent) {
+	if e == nil {
+		return
+	}
+	if e.rdb != nil && e.health.Available(DependencyRedis) {
+		body, err := json.Marshal(event)
+		if err == nil {
+			err = e.rdb.Publish(context.Background(), PageEventsChannel, body).Err()
+		}
+		if err == nil {
+			return
+		}
+		log.Println("⚠️  Page event publish failed, delivering locally:", err)
+	}
+	e.deliver(event)
+}
+
+// Subscribe returns the events of pageID until cancel is called. A
+// subscriber too slow to keep up loses events rather than blocking writers.
+func (e *PageEvents) Subscribe(pageID string) (<-chan PageEvent, func()) {
+	ch := make(chan PageEvent, 64)
+	e.mu.Lock()
+	if e.subscribers[pageID] == nil {
+		e.subscribers[pageID] = map[chan PageEvent]struct{}{}
+	}
+	e.subscribers[pageID][ch] = struct{}{}
+	e.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			e.mu.Lock()
+			delete(e.subscribers[pageID], ch)
+			if len(e.subscribers[pageID]) == 0 {
+				delete(e.subscribers, pageID)
+			}
+			e.mu.Unlock()
+		})
+	}
+}
+
+func (e *PageEvents) deliver(event PageEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for ch := range e.subscribers[event.PageID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const FingerprintKeyPrefix = "fingerprint:"
+
+// Fingerprints remembers the submissions seen during the window, so that the
+// same import sent twice (a double click, a retried upload) is applied once.
+// They are shared through Redis when it is configured and up, and kept in
+// memory otherwise.
+type Fingerprints struct {
+	rdb    *redis.Client
+	window time.Duration
+	health *Health
+
+	mu    sync.Mutex
+	local map[string]time.Time
+}
+
+// NewFingerprintsFromEnv reads IMPORT_DEDUP_WINDOW, in seconds (300 by
+// default, 0 disables the check).
+func NewFingerprintsFromEnv(rdb *redis.Client) *Fingerprints {
+	window := 300 * time.Second
+	if n, err := strconv.Atoi(os.Getenv("IMPORT_DEDUP_WINDOW")); err == nil && n >= 0 {
+		window = time.Duration(n) * time.Second
+	}
+	return &Fingerprints{rdb: rdb, window: window, local: map[string]time.Time{}}
+}
+
+func (f *Fingerprints) WithHealth(h *Health) *Fingerprints {
+	f.health = h
+	return f
+}
+
+func (f *Fingerprints) Enabled() bool {
+	return f != nil && f.window > 0
+}
+
+func (f *Fingerprints) Window() time.Duration {
+	return f.window
+}
+
+// Fingerprint hashes the parts identifying a submission.
+func Fingerprint(parts ...[]byte) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (f *Fingerprints) shared() bool {
+	return f.rdb != nil && f.health.Available(DependencyRedis)
+}
+
+// Claim records fingerprint and reports whether it was new. A duplicate gets
+// the time left before it may be submitted again.
+func (f *Fingerprints) Claim(ctx context.Context, fingerprint string) (bool, time.Duration) {
+	if f.shared() {
+		key := FingerprintKeyPrefix + fingerprint
+		ok, err := f.rdb.SetNX(ctx, key, time.Now().Unix(), f.window).Result()
+		if err == nil {
+			if ok {
+				return true, 0
+			}
+			ttl, _ := f.rdb.TTL(ctx, key).Result()
+			return false, ttl
+		}
+		log.Println("⚠️  Fingerprint claim failed, using local memory:", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	for key, expires := range f.local {
+		if now.After(expires) {
+			delete(f.local, key)
+		}
+	}
+	if expires, seen := f.local[fingerprint]; seen {
+		return false, expires.Sub(now)
+	}
+	f.local[fingerprint] = now.Add(f.window)
+	return true, 0
+}
+
+// Release forgets fingerprint, so that a failed submission may be retried
+// right away.
+func (f *Fingerprints) Release(ctx context.Context, fingerprint string) {
+	if f.shared() {
+		if err := f.rdb.Del(ctx, FingerprintKeyPrefix+fingerprint).Err(); err != nil {
+			log.Println("⚠️  Fingerprint release failed:", err)
+		}
+	}
+	f.mu.Lock()
+	delete(f.local, fingerprint)
+	f.mu.Unlock()
+}
@@ -0,0 +1,255 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Dependency states. A dependency answering slower than its threshold is
+// degraded; one failing its check is down.
+const (
+	HealthUp       = "up"
+	HealthDegraded = "degraded"
+	HealthDown     = "down"
+)
+
+// Names of the dependencies checked by the API. Page storages are checked
+// as "storage:<name>".
+const (
+	DependencyPostgres = "postgres"
+	DependencyReplica  = "replica"
+	DependencyRedis    = "redis"
+	DependencyOIDC     = "oidc"
+)
+
+type HealthCheckFunc func(ctx context.Context) error
+
+type DependencyHealth struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Critical  bool      `json:"critical"`
+	LatencyMs int64     `json:"latencyMs"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+	Since     time.Time `json:"since"`
+}
+
+type healthCheck struct {
+	name     string
+	critical bool
+	slow     time.Duration
+	fn       HealthCheckFunc
+}
+
+// Health checks the dependencies in the background. Features backed by an
+// optional dependency ask Available before using it, so an outage degrades
+// the API instead of failing requests. Only a critical dependency down
+// makes the API itself down.
+type Health struct {
+	interval time.Duration
+	timeout  time.Duration
+
+	mu        sync.RWMutex
+	checks    []healthCheck
+	results   map[string]DependencyHealth
+	listeners map[string][]func(status string)
+}
+
+// NewHealthFromEnv reads HEALTH_CHECK_INTERVAL and HEALTH_CHECK_TIMEOUT, in
+// seconds (15 and 3 by default).
+func NewHealthFromEnv() *Health {
+	h := &Health{
+		interval:  15 * time.Second,
+		timeout:   3 * time.Second,
+		results:   map[string]DependencyHealth{},
+		listeners: map[string][]func(string){},
+	}
+	if n, err := strconv.Atoi(os.Getenv("HEALTH_CHECK_INTERVAL")); err == nil && n > 0 {
+		h.interval = time.Duration(n) * time.Second
+	}
+	if n, err := strconv.Atoi(os.Getenv("HEALTH_CHECK_TIMEOUT")); err == nil && n > 0 {
+		h.timeout = time.Duration(n) * time.Second
+	}
+	return h
+}
+
+// Register adds a check. slow is the latency above which the dependency is
+// degraded; zero disables it.
+func (h *Health) Register(name string, critical bool, slow time.Duration, fn HealthCheckFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, healthCheck{name: name, critical: critical, slow: slow, fn: fn})
+}
+
+// OnChange calls fn, from the checking goroutine, each time the status of
+// name changes after its first check.
+func (h *Health) OnChange(name string, fn func(status string)) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.listeners[name] = append(h.listeners[name], fn)
+}
+
+// Start runs every check once, then keeps checking in the background.
+func (h *Health) Start() {
+	h.CheckNow(context.Background())
+	go func() {
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			h.CheckNow(context.Background())
+		}
+	}()
+}
+
+func (h *Health) CheckNow(ctx context.Context) {
+	h.mu.RLock()
+	checks := append([]healthCheck(nil), h.checks...)
+	h.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check healthCheck) {
+			defer wg.Done()
+			h.record(check, h.run(ctx, check))
+		}(check)
+	}
+	wg.Wait()
+}
+
+func (h *Health) run(ctx context.Context, check healthCheck) DependencyHealth {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.fn(ctx)
+	result := DependencyHealth{
+		Name:      check.name,
+		Status:    HealthUp,
+		Critical:  check.critical,
+		LatencyMs: time.Since(start).Milliseconds(),
+		CheckedAt: time.Now(),
+	}
+	switch {
+	case err != nil:
+		result.Status = HealthDown
+		result.Error = err.Error()
+	case check.slow > 0 && time.Since(start) > check.slow:
+		result.Status = HealthDegraded
+	}
+	return result
+}
+
+func (h *Health) record(check healthCheck, result DependencyHealth) {
+	h.mu.Lock()
+	prev, seen := h.results[check.name]
+	result.Since = result.CheckedAt
+	if seen && prev.Status == result.Status {
+		result.Since = prev.Since
+	}
+	h.results[check.name] = result
+	listeners := h.listeners[check.name]
+	h.mu.Unlock()
+
+	if !seen || prev.Status == result.Status {
+		return
+	}
+	log.Printf("🩺 %s: %s → %s %s", check.name, prev.Status, result.Status, result.Error)
+	for _, fn := range listeners {
+		fn(result.Status)
+	}
+}
+
+// Available reports whether name may be used: it is not down. Unknown
+// dependencies and a nil Health are available.
+func (h *Health) Available(name string) bool {
+	if h == nil {
+		return true
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	result, ok := h.results[name]
+	return !ok || result.Status != HealthDown
+}
+
+// Report returns the overall status with the status of each dependency.
+func (h *Health) Report() (string, []DependencyHealth) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	overall := HealthUp
+	deps := make([]DependencyHealth, 0, len(h.results))
+	for _, result := range h.results {
+		deps = append(deps, result)
+		switch {
+		case result.Status == HealthDown && result.Critical:
+			overall = HealthDown
+		case result.Status != HealthUp && overall == HealthUp:
+			overall = HealthDegraded
+		}
+	}
+	sort.Slice(deps, func(i, j int) bool { return deps[i].Name < deps[j].Name })
+	return overall, deps
+}
+
+func PingSQL(db *sql.DB) HealthCheckFunc {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+func PingRedis(rdb *redis.Client) HealthCheckFunc {
+	return func(ctx context.Context) error {
+		return rdb.Ping(ctx).Err()
+	}
+}
+
+// CheckOIDC fetches the discovery document of issuer.
+func CheckOIDC(issuer string) HealthCheckFunc {
+	url := strings.TrimRight(issuer, "/") + "/.well-known/openid-configuration"
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("discovery returned %d", res.StatusCode)
+		}
+		return nil
+	}
+}
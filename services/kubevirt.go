@@ -0,0 +1,260 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// KubeVirtVM is the flattened inventory entry written to the synced table.
+type KubeVirtVM struct {
+	UID       string
+	Name      string
+	Namespace string
+	Status    string
+	Ready     bool
+	Node      string
+	IP        string
+	OS        string
+	CPU       int
+	Memory    string
+	CreatedAt *time.Time
+}
+
+type KubeVirtClient struct {
+	baseURL   string
+	token     string
+	namespace string
+	http      *http.Client
+}
+
+// NewKubeVirtClientFromEnv returns nil when KUBEVIRT_API_URL is not set and
+// the process is not running inside a cluster.
+func NewKubeVirtClientFromEnv() (*KubeVirtClient, error) {
+	baseURL := os.Getenv("KUBEVIRT_API_URL")
+	if baseURL == "" {
+		if host := os.Getenv("KUBERNETES_SERVICE_HOST"); host != "" {
+			baseURL = "https://" + host + ":" + os.Getenv("KUBERNETES_SERVICE_PORT")
+		}
+	}
+	if baseURL == "" {
+		return nil, nil
+	}
+
+	token := os.Getenv("KUBEVIRT_TOKEN")
+	if token == "" {
+		if b, err := os.ReadFile(serviceAccountDir + "/token"); err == nil {
+			token = strings.TrimSpace(string(b))
+		}
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: os.Getenv("KUBEVIRT_INSECURE") == "true"}
+
+	caFile := os.Getenv("KUBEVIRT_CA_FILE")
+	if caFile == "" {
+		if _, err := os.Stat(serviceAccountDir + "/ca.crt"); err == nil {
+			caFile = serviceAccountDir + "/ca.crt"
+		}
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("invalid CA bundle %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &KubeVirtClient{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		token:     token,
+		namespace: os.Getenv("KUBEVIRT_NAMESPACE"),
+		http: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+type kvMetadata struct {
+	Name              string     `json:"name"`
+	Namespace         string     `json:"namespace"`
+	UID               string     `json:"uid"`
+	CreationTimestamp *time.Time `json:"creationTimestamp"`
+}
+
+type kvVirtualMachineList struct {
+	Items []struct {
+		Metadata kvMetadata `json:"metadata"`
+		Spec     struct {
+			Template struct {
+				Spec struct {
+					Domain struct {
+						CPU struct {
+							Cores   int `json:"cores"`
+							Sockets int `json:"sockets"`
+							Threads int `json:"threads"`
+						} `json:"cpu"`
+						Memory struct {
+							Guest string `json:"guest"`
+						} `json:"memory"`
+						Resources struct {
+							Requests map[string]string `json:"requests"`
+						} `json:"resources"`
+					} `json:"domain"`
+				} `json:"spec"`
+			} `json:"template"`
+		} `json:"spec"`
+		Status struct {
+			PrintableStatus string `json:"printableStatus"`
+			Ready           bool   `json:"ready"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type kvVirtualMachineInstanceList struct {
+	Items []struct {
+		Metadata kvMetadata `json:"metadata"`
+		Status   struct {
+			NodeName   string `json:"nodeName"`
+			Interfaces []struct {
+				IPAddress string `json:"ipAddress"`
+			} `json:"interfaces"`
+			GuestOSInfo struct {
+				PrettyName string `json:"prettyName"`
+			} `json:"guestOSInfo"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+func (k *KubeVirtClient) resourcePath(resource string) string {
+	if k.namespace != "" {
+		return "/apis/kubevirt.io/v1/namespaces/" + k.namespace + "/" + resource
+	}
+	return "/apis/kubevirt.io/v1/" + resource
+}
+
+func (k *KubeVirtClient) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if k.token != "" {
+		req.Header.Set("Authorization", "Bearer "+k.token)
+	}
+
+	resp, err := k.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ListVirtualMachines merges VirtualMachine specs with the runtime state of
+// their VirtualMachineInstance (node, IP, guest OS) when one is running.
+func (k *KubeVirtClient) ListVirtualMachines(ctx context.Context) ([]KubeVirtVM, error) {
+	var vms kvVirtualMachineList
+	if err := k.get(ctx, k.resourcePath("virtualmachines"), &vms); err != nil {
+		return nil, err
+	}
+
+	var vmis kvVirtualMachineInstanceList
+	if err := k.get(ctx, k.resourcePath("virtualmachineinstances"), &vmis); err != nil {
+		return nil, err
+	}
+
+	type runtime struct{ node, ip, os string }
+	running := make(map[string]runtime, len(vmis.Items))
+	for _, vmi := range vmis.Items {
+		rt := runtime{node: vmi.Status.NodeName, os: vmi.Status.GuestOSInfo.PrettyName}
+		for _, iface := range vmi.Status.Interfaces {
+			if iface.IPAddress != "" {
+				rt.ip = iface.IPAddress
+				break
+			}
+		}
+		running[vmi.Metadata.Namespace+"/"+vmi.Metadata.Name] = rt
+	}
+
+	out := make([]KubeVirtVM, 0, len(vms.Items))
+	for _, vm := range vms.Items {
+		domain := vm.Spec.Template.Spec.Domain
+
+		cpu := max(domain.CPU.Cores, 1) * max(domain.CPU.Sockets, 1) * max(domain.CPU.Threads, 1)
+		memory := domain.Memory.Guest
+		if memory == "" {
+			memory = domain.Resources.Requests["memory"]
+		}
+
+		entry := KubeVirtVM{
+			UID:       vm.Metadata.UID,
+			Name:      vm.Metadata.Name,
+			Namespace: vm.Metadata.Namespace,
+			Status:    vm.Status.PrintableStatus,
+			Ready:     vm.Status.Ready,
+			CPU:       cpu,
+			Memory:    memory,
+			CreatedAt: vm.Metadata.CreationTimestamp,
+		}
+		if rt, ok := running[vm.Metadata.Namespace+"/"+vm.Metadata.Name]; ok {
+			entry.Node = rt.node
+			entry.IP = rt.ip
+			entry.OS = rt.os
+		}
+		out = append(out, entry)
+	}
+
+	return out, nil
+}
+
+// Row maps the inventory entry onto the column names expected in the synced
+// table. Columns missing from the table are dropped by the worker.
+func (vm KubeVirtVM) Row(syncedAt time.Time) map[string]any {
+	return map[string]any{
+		"uid":        vm.UID,
+		"name":       vm.Name,
+		"namespace":  vm.Namespace,
+		"status":     vm.Status,
+		"ready":      vm.Ready,
+		"node":       vm.Node,
+		"ip":         vm.IP,
+		"os":         vm.OS,
+		"cpu":        vm.CPU,
+		"memory":     vm.Memory,
+		"vm_created": vm.CreatedAt,
+		"synced_at":  syncedAt,
+	}
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const MaintenanceKey = "maintenance"
+
+// maintenanceRefresh bounds how long an instance keeps serving a stale state
+// after another instance changed it.
+const maintenanceRefresh = 5 * time.Second
+
+type MaintenanceState struct {
+	ReadOnly   bool       `json:"readOnly"`
+	Message    string     `json:"message,omitempty"`
+	RetryAfter int        `json:"retryAfter"`
+	Since      *time.Time `json:"since,omitempty"`
+}
+
+// Maintenance shares the maintenance state between instances through Redis.
+// MAINTENANCE_READ_ONLY and MAINTENANCE_RETRY_AFTER give the state used
+// until an admin sets one.
+type Maintenance struct {
+	rdb      *redis.Client
+	fallback MaintenanceState
+	health   *Health
+
+	mu        sync.Mutex
+	state     MaintenanceState
+	checkedAt time.Time
+}
+
+func NewMaintenanceFromEnv(rdb *redis.Client) *Maintenance {
+	fallback := MaintenanceState{
+		ReadOnly:   os.Getenv("MAINTENANCE_READ_ONLY") == "true",
+		Message:    os.Getenv("MAINTENANCE_MESSAGE"),
+		RetryAfter: 300,
+	}
+	if n, err := strconv.Atoi(os.Getenv("MAINTENANCE_RETRY_AFTER")); err == nil && n > 0 {
+		fallback.RetryAfter = n
+	}
+	return &Maintenance{rdb: rdb, fallback: fallback}
+}
+
+// WithHealth keeps the last known state without querying Redis while it is
+// down.
+func (m *Maintenance) WithHealth(h *Health) *Maintenance {
+	m.health = h
+	return m
+}
+
+func (m *Maintenance) State(ctx context.Context) MaintenanceState {
+	if m == nil {
+		return MaintenanceState{}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if time.Since(m.checkedAt) < maintenanceRefresh {
+		return m.state
+	}
+
+	state := m.fallback
+	if !m.health.Available(DependencyRedis) {
+		if !m.checkedAt.IsZero() {
+			state = m.state
+		}
+		m.state = state
+		m.checkedAt = time.Now()
+		return state
+	}
+	body, err := m.rdb.Get(ctx, MaintenanceKey).Bytes()
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(body, &state); err != nil {
+			log.Println("⚠️  Maintenance state unreadable:", err)
+			state = m.fallback
+		}
+	case err != redis.Nil:
+		// Keep the last known state while Redis is unreachable.
+		log.Println("⚠️  Maintenance state read failed:", err)
+		if !m.checkedAt.IsZero() {
+			state = m.state
+		}
+	}
+
+	m.state = state
+	m.checkedAt = time.Now()
+	return state
+}
+
+func (m *Maintenance) Set(ctx context.Context, state MaintenanceState) error {
+	if state.RetryAfter <= 0 {
+		state.RetryAfter = m.fallback.RetryAfter
+	}
+	if state.ReadOnly && state.Since == nil {
+		now := time.Now()
+		state.Since = &now
+	}
+	if !state.ReadOnly {
+		state.Since = nil
+	}
+
+	body, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := m.rdb.Set(ctx, MaintenanceKey, body, 0).Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.state = state
+	m.checkedAt = time.Now()
+	m.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,316 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DependencyObjectStore = "object-store"
+
+var ErrNoObjectStore = errors.New("object storage is not configured")
+
+// ObjectStore keeps the files of `file` columns in an S3-compatible bucket
+// (AWS S3, MinIO, ...). Requests are signed with AWS Signature V4, and
+// downloads go through presigned URLs so files never transit the API.
+type ObjectStore struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+	urlTTL    time.Duration
+	maxSize   int64
+	client    *http.Client
+}
+
+var (
+	objectStoreMu sync.RWMutex
+	objectStore   *ObjectStore
+)
+
+// LoadObjectStoreFromEnv configures the object storage when S3_BUCKET is
+// set. S3_ENDPOINT defaults to AWS for S3_REGION (us-east-1); MinIO needs
+// S3_ENDPOINT and S3_PATH_STYLE=true. S3_URL_TTL is the lifetime of download
+// URLs in seconds (900), S3_MAX_UPLOAD_MB the upload size limit (25).
+func LoadObjectStoreFromEnv() error {
+	bucket := os.Getenv("S3_BUCKET")
+	if bucket == "" {
+		return nil
+	}
+
+	region := os.Getenv("S3_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	u, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("S3_ENDPOINT: invalid URL %q", endpoint)
+	}
+
+	store := &ObjectStore{
+		endpoint:  u,
+		region:    region,
+		bucket:    bucket,
+		accessKey: os.Getenv("S3_ACCESS_KEY_ID"),
+		secretKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		pathStyle: os.Getenv("S3_PATH_STYLE") == "true",
+		urlTTL:    15 * time.Minute,
+		maxSize:   25 << 20,
+		client:    &http.Client{Timeout: 60 * time.Second},
+	}
+	if n, err := strconv.Atoi(os.Getenv("S3_URL_TTL")); err == nil && n > 0 {
+		store.urlTTL = time.Duration(n) * time.Second
+	}
+	if n, err := strconv.Atoi(os.Getenv("S3_MAX_UPLOAD_MB")); err == nil && n > 0 {
+		store.maxSize = int64(n) << 20
+	}
+
+	objectStoreMu.Lock()
+	objectStore = store
+	objectStoreMu.Unlock()
+	log.Printf("🔵 Object storage: %s/%s", u.Host, bucket)
+	return nil
+}
+
+// Objects returns the configured object storage, or nil.
+func Objects() *ObjectStore {
+	objectStoreMu.RLock()
+	defer objectStoreMu.RUnlock()
+	return objectStore
+}
+
+func (s *ObjectStore) MaxSize() int64 {
+	return s.maxSize
+}
+
+func (s *ObjectStore) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	path := u.Path
+	if s.pathStyle {
+		path += "/" + s.bucket
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+	if key != "" {
+		path += "/" + key
+	} else if !s.pathStyle {
+		path += "/"
+	}
+	u.Path = path
+	u.RawPath = awsEscapePath(path)
+	return &u
+}
+
+// Put stores body under key.
+func (s *ObjectStore) Put(ctx context.Context, key, contentType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key).String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return s.do(req, body)
+}
+
+func (s *ObjectStore) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	return s.do(req, nil)
+}
+
+// Check reaches the bucket, for the health checks.
+func (s *ObjectStore) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.objectURL("").String(), nil)
+	if err != nil {
+		return err
+	}
+	return s.do(req, nil)
+}
+
+func (s *ObjectStore) do(req *http.Request, body []byte) error {
+	sum := sha256.Sum256(body)
+	s.sign(req, hex.EncodeToString(sum[:]), time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("object storage: %s %s: %d %s", req.Method, req.URL.Path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// PresignGet returns a download URL for key valid for S3_URL_TTL. filename,
+// when set, names the downloaded file.
+func (s *ObjectStore) PresignGet(key, filename string) string {
+	return s.presignGet(key, filename, time.Now().UTC())
+}
+
+func (s *ObjectStore) presignGet(key, filename string, now time.Time) string {
+	u := s.objectURL(key)
+
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.accessKey+"/"+s.scope(now))
+	query.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(s.urlTTL.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if filename != "" {
+		query.Set("response-content-disposition", fmt.Sprintf(`attachment; filename="%s"`, strings.ReplaceAll(filename, `"`, "")))
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	canonical := strings.Join([]string{
+		http.MethodGet,
+		awsEscapePath(u.Path),
+		u.RawQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	u.RawQuery += "&X-Amz-Signature=" + s.signature(now, canonical)
+	return u.String()
+}
+
+func (s *ObjectStore) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.region + "/s3/aws4_request"
+}
+
+// sign adds the Signature V4 Authorization header to req.
+func (s *ObjectStore) sign(req *http.Request, payloadHash string, now time.Time) {
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var headers strings.Builder
+	for _, name := range names {
+		headers.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		awsEscapePath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		headers.String(),
+		signed,
+		payloadHash,
+	}, "\n")
+
+	req.Header.Del("Host")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, s.scope(now), signed, s.signature(now, canonical)))
+}
+
+func (s *ObjectStore) signature(t time.Time, canonical string) string {
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		t.Format("20060102T150405Z"),
+		s.scope(t),
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsEscape percent-encodes everything but the RFC 3986 unreserved
+// characters, as Signature V4 requires.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if 'A' <= ch && ch <= 'Z' || 'a' <= ch && ch <= 'z' || '0' <= ch && ch <= '9' ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func awsEscapePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = awsEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		vs := append([]string(nil), values[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
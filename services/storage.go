@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// StorageDriver opens the database holding the tables of deployed pages. The
+// dynamic layer speaks Postgres SQL, so drivers must accept it.
+type StorageDriver interface {
+	Open(dsn string) (*sql.DB, error)
+}
+
+type postgresDriver struct{}
+
+// Open connects with pgx. A schema is selected through the DSN, e.g.
+// "postgres://.../app?search_path=business".
+func (postgresDriver) Open(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// QueryTimeout bounds the dynamic SQL reading page tables
+// (PAGE_QUERY_TIMEOUT in seconds, 30 by default; 0 disables it).
+func QueryTimeout() time.Duration {
+	if n, err := strconv.Atoi(os.Getenv("PAGE_QUERY_TIMEOUT")); err == nil && n >= 0 {
+		return time.Duration(n) * time.Second
+	}
+	return 30 * time.Second
+}
+
+// GraveyardSchema is the schema the tables of undeployed pages are moved to
+// when they are kept aside rather than dropped (PAGE_GRAVEYARD_SCHEMA,
+// "graveyard" by default).
+func GraveyardSchema() string {
+	if schema := os.Getenv("PAGE_GRAVEYARD_SCHEMA"); schema != "" {
+		return schema
+	}
+	return "graveyard"
+}
+
+var (
+	storageMu      sync.RWMutex
+	storageDrivers = map[string]StorageDriver{"postgres": postgresDriver{}}
+	storages       = map[string]*sql.DB{}
+)
+
+func RegisterStorageDriver(name string, driver StorageDriver) {
+	storageMu.Lock()
+	defer storageMu.Unlock()
+	storageDrivers[name] = driver
+}
+
+// LoadStoragesFromEnv opens every storage declared as
+// PAGE_STORAGE_<NAME>_DSN, with PAGE_STORAGE_<NAME>_DRIVER defaulting to
+// postgres. Pages select one by its lower-cased name.
+func LoadStoragesFromEnv() error {
+	for _, kv := range os.Environ() {
+		key, dsn, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(key, "PAGE_STORAGE_") || !strings.HasSuffix(key, "_DSN") {
+			continue
+		}
+		prefix := strings.TrimSuffix(key, "_DSN")
+		name := strings.ToLower(strings.TrimPrefix(prefix, "PAGE_STORAGE_"))
+		if name == "" || dsn == "" {
+			continue
+		}
+
+		driverName := os.Getenv(prefix + "_DRIVER")
+		if driverName == "" {
+			driverName = "postgres"
+		}
+
+		storageMu.RLock()
+		driver, ok := storageDrivers[driverName]
+		storageMu.RUnlock()
+		if !ok {
+			return fmt.Errorf("storage %s: unknown driver %q", name, driverName)
+		}
+
+		db, err := driver.Open(dsn)
+		if err != nil {
+			return fmt.Errorf("storage %s: %w", name, err)
+		}
+
+		storageMu.Lock()
+		storages[name] = db
+		storageMu.Unlock()
+		log.Printf("🔵 Page storage %q: %s", name, driverName)
+	}
+	return nil
+}
+
+func HasStorage(name string) bool {
+	storageMu.RLock()
+	defer storageMu.RUnlock()
+	_, ok := storages[name]
+	return ok
+}
+
+// StorageNames lists the configured storages.
+func StorageNames() []string {
+	storageMu.RLock()
+	defer storageMu.RUnlock()
+	names := make([]string, 0, len(storages))
+	for name := range storages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StorageDB returns the connection of a named storage.
+func StorageDB(name string) (*sql.DB, error) {
+	storageMu.RLock()
+	defer storageMu.RUnlock()
+	db, ok := storages[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown page storage %q", name)
+	}
+	return db, nil
+}
@@ -19,19 +19,32 @@ package services
 import (
 	"api-core-v2/models"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
 )
 
-func SyncUserFromClaims(db *gorm.DB, claims map[string]interface{}) error {
+// ErrNoUserClaims is returned for tokens that do not describe a person, such
+// as client-credentials tokens issued to machines.
+var ErrNoUserClaims = errors.New("token carries no user identity")
 
-	sub := claims["sub"].(string)
-	email := claims["email"].(string)
-	name := claims["name"].(string)
-	given := claims["given_name"].(string)
-	family := claims["family_name"].(string)
-	preferred := claims["preferred_username"].(string)
+func claimString(claims map[string]interface{}, key string) string {
+	s, _ := claims[key].(string)
+	return s
+}
+
+func SyncUserFromClaims(db *gorm.DB, claims map[string]interface{}) (*models.User, error) {
+
+	sub := claimString(claims, "sub")
+	email := claimString(claims, "email")
+	if sub == "" || email == "" {
+		return nil, ErrNoUserClaims
+	}
+	name := claimString(claims, "name")
+	given := claimString(claims, "given_name")
+	family := claimString(claims, "family_name")
+	preferred := claimString(claims, "preferred_username")
 	groupsJson, _ := json.Marshal(claims["groups"])
 
 	var user models.User
@@ -51,9 +64,12 @@ func SyncUserFromClaims(db *gorm.DB, claims map[string]interface{}) error {
 			FirstLogin:        now,
 			LastLogin:         &now,
 			LoginCount:        1,
-			Iss:               claims["iss"].(string),
+			Iss:               claimString(claims, "iss"),
+		}
+		if err := db.Create(&user).Error; err != nil {
+			return nil, err
 		}
-		return db.Create(&user).Error
+		return &user, nil
 	}
 
 	user.Email = email
@@ -62,10 +78,13 @@ func SyncUserFromClaims(db *gorm.DB, claims map[string]interface{}) error {
 	user.FamilyName = family
 	user.PreferredUsername = preferred
 	user.Groups = groupsJson
-	user.Iss = claims["iss"].(string)
+	user.Iss = claimString(claims, "iss")
 
 	user.LastLogin = &now
 	user.LoginCount++
 
-	return db.Save(&user).Error
+	if err := db.Save(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
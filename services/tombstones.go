@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"api-core-v2/models"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TombstoneRetention is how long deleted rows are remembered for
+// incremental sync (SYNC_TOMBSTONE_RETENTION_DAYS, 30 days by default). A
+// client whose cursor is older must download the page again.
+func TombstoneRetention() time.Duration {
+	if n, err := strconv.Atoi(os.Getenv("SYNC_TOMBSTONE_RETENTION_DAYS")); err == nil && n > 0 {
+		return time.Duration(n) * 24 * time.Hour
+	}
+	return 30 * 24 * time.Hour
+}
+
+func RecordTombstones(db *gorm.DB, pageID string, rowIDs ...string) error {
+	if len(rowIDs) == 0 {
+		return nil
+	}
+	now := time.Now()
+	tombstones := make([]models.RowTombstone, 0, len(rowIDs))
+	for _, id := range rowIDs {
+		tombstones = append(tombstones, models.RowTombstone{PageID: pageID, RowID: id, DeletedAt: now})
+	}
+	return db.Create(&tombstones).Error
+}
+
+// PurgeTombstones drops the tombstones older than the retention.
+func PurgeTombstones(db *gorm.DB) (int64, error) {
+	res := db.Where("deleted_at < ?", time.Now().Add(-TombstoneRetention())).Delete(&models.RowTombstone{})
+	return res.RowsAffected, res.Error
+}
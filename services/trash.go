@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"api-core-v2/models"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of records kept in the trash.
+const (
+	TrashKindPage           = "page"
+	TrashKindNavigationItem = "navigation_item"
+	TrashKindTag            = "tag"
+	TrashKindRow            = "row"
+)
+
+// TrashRetention is how long deleted records stay in the trash
+// (TRASH_RETENTION_DAYS, 30 days by default).
+func TrashRetention() time.Duration {
+	if n, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS")); err == nil && n > 0 {
+		return time.Duration(n) * 24 * time.Hour
+	}
+	return 30 * 24 * time.Hour
+}
+
+// TrashedRow is the Data of a page row in the trash. Files are the objects
+// of its file columns, deleted only when the row leaves the trash for good.
+type TrashedRow struct {
+	Row   map[string]any      `json:"row"`
+	Links map[string][]string `json:"links,omitempty"`
+	Files []string            `json:"files,omitempty"`
+}
+
+// MoveToTrash stores item with data as its Data.
+func MoveToTrash(db *gorm.DB, item models.TrashItem, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	item.Data = raw
+	if item.DeletedAt.IsZero() {
+		item.DeletedAt = time.Now()
+	}
+	return db.Create(&item).Error
+}
+
+// PurgeTrashItems deletes items from the trash for good, with the files of
+// the rows among them.
+func PurgeTrashItems(db *gorm.DB, items []models.TrashItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	if err := db.Delete(&models.TrashItem{}, "id IN ?", ids).Error; err != nil {
+		return err
+	}
+
+	store := Objects()
+	if store == nil {
+		return nil
+	}
+	for _, item := range items {
+		if item.Kind != TrashKindRow {
+			continue
+		}
+		var row TrashedRow
+		if err := json.Unmarshal(item.Data, &row); err != nil {
+			continue
+		}
+		for _, key := range row.Files {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if err := store.Delete(ctx, key); err != nil {
+				log.Printf("⚠️  Fichier %s non supprimé: %v", key, err)
+			}
+			cancel()
+		}
+	}
+	return nil
+}
+
+// PurgeExpiredTrash empties the trash of the records older than the
+// retention.
+func PurgeExpiredTrash(db *gorm.DB) (int64, error) {
+	var items []models.TrashItem
+	if err := db.Where("deleted_at < ?", time.Now().Add(-TrashRetention())).Find(&items).Error; err != nil {
+		return 0, err
+	}
+	return int64(len(items)), PurgeTrashItems(db, items)
+}
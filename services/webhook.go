@@ -0,0 +1,202 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"api-core-v2/models"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Row events sent to webhooks. WebhookPing is only sent on demand, to test
+// a subscription.
+const (
+	WebhookRowCreated = "row.created"
+	WebhookRowUpdated = "row.updated"
+	WebhookRowDeleted = "row.deleted"
+	WebhookPing       = "ping"
+)
+
+var WebhookEvents = []string{WebhookRowCreated, WebhookRowUpdated, WebhookRowDeleted}
+
+const JobWebhook = "webhook"
+
+// Headers of a webhook delivery. The signature is the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the webhook secret, prefixed "sha256=".
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+type WebhookEvent struct {
+	ID         string         `json:"id"`
+	Event      string         `json:"event"`
+	PageID     string         `json:"pageId,omitempty"`
+	Table      string         `json:"table,omitempty"`
+	ItemID     string         `json:"itemId,omitempty"`
+	Data       map[string]any `json:"data,omitempty"`
+	OccurredAt time.Time      `json:"occurredAt"`
+}
+
+// WebhookJob is the replayable payload of a webhook delivery.
+type WebhookJob struct {
+	WebhookID string       `json:"webhookId"`
+	Event     WebhookEvent `json:"event"`
+}
+
+func init() {
+	RegisterJobHandler(JobWebhook, func(db *gorm.DB, payload []byte) error {
+		var job WebhookJob
+		if err := json.Unmarshal(payload, &job); err != nil {
+			return err
+		}
+		var hook models.Webhook
+		if err := db.First(&hook, "id = ?", job.WebhookID).Error; err != nil {
+			return err
+		}
+		return DeliverWebhook(hook, job.Event)
+	})
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func NewWebhookSecret() string {
+	return randomHex(32)
+}
+
+func NewWebhookEvent(event string) WebhookEvent {
+	return WebhookEvent{ID: randomHex(16), Event: event, OccurredAt: time.Now().UTC()}
+}
+
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookEventList decodes the events of a webhook; empty means all.
+func WebhookEventList(hook models.Webhook) []string {
+	var events []string
+	if hook.Events != nil {
+		_ = json.Unmarshal(hook.Events, &events)
+	}
+	return events
+}
+
+func webhookWants(hook models.Webhook, event string) bool {
+	events := WebhookEventList(hook)
+	if len(events) == 0 {
+		return true
+	}
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliverWebhook posts event to hook once. Each attempt is signed anew.
+func DeliverWebhook(hook models.Webhook, event WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, event.Event)
+	req.Header.Set(WebhookDeliveryHeader, event.ID)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(hook.Secret, timestamp, body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Webhooks fans row changes out to the matching subscriptions. Deliveries
+// run in the background through RunJob, so failures end up as dead letters.
+type Webhooks struct {
+	db *gorm.DB
+}
+
+func NewWebhooks(db *gorm.DB) *Webhooks {
+	return &Webhooks{db: db}
+}
+
+// Dispatch sends event to the active webhooks of its page. data is only
+// called when at least one webhook wants the event.
+func (w *Webhooks) Dispatch(event WebhookEvent, data func() map[string]any) {
+	if w == nil {
+		return
+	}
+
+	var hooks []models.Webhook
+	if err := w.db.Where("active = ? AND (page_id IS NULL OR page_id = ?)", true, event.PageID).
+		Find(&hooks).Error; err != nil {
+		log.Println("⚠️  Webhooks lookup failed:", err)
+		return
+	}
+
+	loaded := false
+	for _, hook := range hooks {
+		if !webhookWants(hook, event.Event) {
+			continue
+		}
+		if !loaded && data != nil {
+			event.Data = data()
+			loaded = true
+		}
+		go func(hook models.Webhook) {
+			if err := RunJob(w.db, JobWebhook, hook.URL, WebhookJob{WebhookID: hook.ID, Event: event}); err != nil {
+				log.Printf("❌ [WEBHOOK] %s %s: %v", event.Event, hook.URL, err)
+			}
+		}(hook)
+	}
+}
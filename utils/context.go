@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"api-core-v2/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	UserKey   = "currentUser"
+	ClaimsKey = "tokenClaims"
+)
+
+func CurrentUser(c *gin.Context) *models.User {
+	if v, ok := c.Get(UserKey); ok {
+		if user, ok := v.(*models.User); ok {
+			return user
+		}
+	}
+	return nil
+}
+
+func CurrentUserID(c *gin.Context) *string {
+	if user := CurrentUser(c); user != nil {
+		return &user.ID
+	}
+	return nil
+}
+
+func CurrentClaims(c *gin.Context) jwt.MapClaims {
+	if v, ok := c.Get(ClaimsKey); ok {
+		if claims, ok := v.(jwt.MapClaims); ok {
+			return claims
+		}
+	}
+	return nil
+}
+
+const (
+	TxKey          = "requestTx"
+	ReadDBKey      = "readDB"
+	afterCommitKey = "afterCommit"
+)
+
+// DB returns the transaction opened for this request by the transaction
+// middleware, or db when the request is not wrapped in one.
+func DB(c *gin.Context, db *gorm.DB) *gorm.DB {
+	if v, ok := c.Get(TxKey); ok {
+		if tx, ok := v.(*gorm.DB); ok {
+			return tx
+		}
+	}
+	return db
+}
+
+// ReadDB returns the replica chosen for this request by the maintenance
+// middleware, or db outside of maintenance.
+func ReadDB(c *gin.Context, db *gorm.DB) *gorm.DB {
+	if v, ok := c.Get(ReadDBKey); ok {
+		if replica, ok := v.(*gorm.DB); ok {
+			return replica
+		}
+	}
+	return db
+}
+
+// AfterCommit defers fn until the request transaction commits, or runs it
+// right away when there is none. Deferred hooks are dropped on rollback.
+func AfterCommit(c *gin.Context, fn func()) {
+	if _, ok := c.Get(TxKey); !ok {
+		fn()
+		return
+	}
+	hooks, _ := c.Get(afterCommitKey)
+	list, _ := hooks.([]func())
+	c.Set(afterCommitKey, append(list, fn))
+}
+
+func RunAfterCommit(c *gin.Context) {
+	hooks, _ := c.Get(afterCommitKey)
+	list, _ := hooks.([]func())
+	for _, fn := range list {
+		fn()
+	}
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func ETagMatches(c *gin.Context, etag string) bool {
+	return etagListMatches(c.GetHeader("If-None-Match"), etag)
+}
+
+// IfMatch reports whether the If-Match precondition holds for etag. A
+// request without the header always passes.
+func IfMatch(c *gin.Context, etag string) bool {
+	header := c.GetHeader("If-Match")
+	return header == "" || etagListMatches(header, etag)
+}
+
+func etagListMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		candidate = strings.TrimPrefix(candidate, "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func JSONBytesWithETag(c *gin.Context, status int, body []byte) {
+	BytesWithETag(c, status, "application/json; charset=utf-8", body)
+}
+
+// BytesWithETag is JSONBytesWithETag for another content type.
+func BytesWithETag(c *gin.Context, status int, contentType string, body []byte) {
+	etag := ETag(body)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+
+	if status == http.StatusOK && ETagMatches(c, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(status, contentType, body)
+}
+
+func JSONWithETag(c *gin.Context, status int, obj any) {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		Error(c, http.StatusInternalServerError, "ENCODE_ERROR", err.Error())
+		return
+	}
+	JSONBytesWithETag(c, status, body)
+}
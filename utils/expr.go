@@ -0,0 +1,999 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// A small, side-effect free expression language used to evaluate
+// schemaFunctions server-side. Expressions only see the row they are given
+// and a fixed set of pure builtins; size, nesting and evaluation steps are
+// bounded so a stored expression cannot hang a request.
+//
+//	price * quantity
+//	status == "done" ? 100 : round(progress * 100, 1)
+//	concat(upper(lastName), " ", firstName)
+//	category.name ?? "—"
+const (
+	maxExprLength = 2000
+	maxExprDepth  = 64
+	maxExprSteps  = 10000
+)
+
+var ErrExpr = errors.New("expression error")
+
+func exprErrorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrExpr, fmt.Sprintf(format, args...))
+}
+
+type Expr struct {
+	src  string
+	root exprNode
+}
+
+var exprCache sync.Map
+
+// CompileExpr parses src once; compiled expressions are cached by source.
+func CompileExpr(src string) (*Expr, error) {
+	if cached, ok := exprCache.Load(src); ok {
+		return cached.(*Expr), nil
+	}
+	if len(src) > maxExprLength {
+		return nil, exprErrorf("expression longer than %d characters", maxExprLength)
+	}
+
+	tokens, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseTernary(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, exprErrorf("unexpected %q at %d", tok.text, tok.pos)
+	}
+
+	e := &Expr{src: src, root: root}
+	exprCache.Store(src, e)
+	return e, nil
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval runs the expression against env. Integer, byte and pointer values in
+// env are normalized so that row maps read from database/sql can be passed
+// as-is.
+func (e *Expr) Eval(env map[string]any) (any, error) {
+	budget := maxExprSteps
+	return e.root.eval(&exprState{env: env, budget: &budget})
+}
+
+// ─── Lexer ──────────────────────────────────────────────────────────────
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type exprToken struct {
+	kind tokKind
+	text string
+	num  float64
+	pos  int
+}
+
+var exprOperators = []string{
+	"??", "==", "!=", "<=", ">=", "&&", "||",
+	"+", "-", "*", "/", "%", "<", ">", "!", "?", ":", "(", ")", "[", "]", ",", ".",
+}
+
+func lexExpr(src string) ([]exprToken, error) {
+	var tokens []exprToken
+	r := []rune(src)
+	i := 0
+
+	for i < len(r) {
+		ch := r[i]
+
+		switch {
+		case unicode.IsSpace(ch):
+			i++
+
+		case ch >= '0' && ch <= '9':
+			start := i
+			for i < len(r) && (r[i] >= '0' && r[i] <= '9' || r[i] == '.') {
+				i++
+			}
+			text := string(r[start:i])
+			n, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, exprErrorf("invalid number %q at %d", text, start)
+			}
+			tokens = append(tokens, exprToken{kind: tokNumber, text: text, num: n, pos: start})
+
+		case ch == '"' || ch == '\'':
+			start := i
+			i++
+			var sb strings.Builder
+			for i < len(r) && r[i] != ch {
+				if r[i] == '\\' && i+1 < len(r) {
+					i++
+					switch r[i] {
+					case 'n':
+						sb.WriteRune('\n')
+					case 't':
+						sb.WriteRune('\t')
+					default:
+						sb.WriteRune(r[i])
+					}
+				} else {
+					sb.WriteRune(r[i])
+				}
+				i++
+			}
+			if i >= len(r) {
+				return nil, exprErrorf("unterminated string at %d", start)
+			}
+			i++
+			tokens = append(tokens, exprToken{kind: tokString, text: sb.String(), pos: start})
+
+		case ch == '_' || unicode.IsLetter(ch):
+			start := i
+			for i < len(r) && (r[i] == '_' || unicode.IsLetter(r[i]) || unicode.IsDigit(r[i])) {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokIdent, text: string(r[start:i]), pos: start})
+
+		default:
+			rest := string(r[i:])
+			matched := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(rest, op) {
+					tokens = append(tokens, exprToken{kind: tokOp, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, exprErrorf("unexpected character %q at %d", ch, i)
+			}
+		}
+	}
+
+	return append(tokens, exprToken{kind: tokEOF, pos: len(r)}), nil
+}
+
+// ─── Parser ─────────────────────────────────────────────────────────────
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *exprParser) isOp(ops ...string) bool {
+	tok := p.peek()
+	if tok.kind == tokOp {
+		for _, op := range ops {
+			if tok.text == op {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *exprParser) isKeyword(word string) bool {
+	tok := p.peek()
+	return tok.kind == tokIdent && tok.text == word
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.isOp(op) {
+		tok := p.peek()
+		return exprErrorf("expected %q at %d", op, tok.pos)
+	}
+	p.next()
+	return nil
+}
+
+func (p *exprParser) parseTernary(depth int) (exprNode, error) {
+	if depth > maxExprDepth {
+		return nil, exprErrorf("expression nested too deeply")
+	}
+	cond, err := p.parseBinary(0, depth)
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("?") {
+		return cond, nil
+	}
+	p.next()
+	then, err := p.parseTernary(depth + 1)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseTernary(depth + 1)
+	if err != nil {
+		return nil, err
+	}
+	return &ternaryNode{cond: cond, then: then, otherwise: otherwise}, nil
+}
+
+// Binary precedence levels, loosest first.
+var exprLevels = [][]string{
+	{"??"},
+	{"||", "or"},
+	{"&&", "and"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *exprParser) levelOp(level int) (string, bool) {
+	for _, op := range exprLevels[level] {
+		if p.isOp(op) || (op == "or" || op == "and") && p.isKeyword(op) {
+			switch op {
+			case "or":
+				return "||", true
+			case "and":
+				return "&&", true
+			}
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) parseBinary(level, depth int) (exprNode, error) {
+	if level == len(exprLevels) {
+		return p.parseUnary(depth)
+	}
+	left, err := p.parseBinary(level+1, depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.levelOp(level)
+		if !ok {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseBinary(level+1, depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary(depth int) (exprNode, error) {
+	if depth > maxExprDepth {
+		return nil, exprErrorf("expression nested too deeply")
+	}
+	if p.isOp("!", "-") || p.isKeyword("not") {
+		op := p.next().text
+		if op == "not" {
+			op = "!"
+		}
+		operand, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: op, operand: operand}, nil
+	}
+	return p.parsePostfix(depth)
+}
+
+func (p *exprParser) parsePostfix(depth int) (exprNode, error) {
+	node, err := p.parsePrimary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.isOp("."):
+			p.next()
+			tok := p.next()
+			if tok.kind != tokIdent {
+				return nil, exprErrorf("expected field name at %d", tok.pos)
+			}
+			node = &memberNode{target: node, key: &literalNode{value: tok.text}}
+		case p.isOp("["):
+			p.next()
+			key, err := p.parseTernary(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			node = &memberNode{target: node, key: key}
+		default:
+			return node, nil
+		}
+	}
+}
+
+func (p *exprParser) parsePrimary(depth int) (exprNode, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokNumber:
+		return &literalNode{value: tok.num}, nil
+	case tokString:
+		return &literalNode{value: tok.text}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null", "nil":
+			return &literalNode{value: nil}, nil
+		}
+		if !p.isOp("(") {
+			return &identNode{name: tok.text}, nil
+		}
+		fn, ok := exprBuiltins[tok.text]
+		if !ok {
+			return nil, exprErrorf("unknown function %q", tok.text)
+		}
+		p.next()
+		var args []exprNode
+		for !p.isOp(")") {
+			if len(args) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			arg, err := p.parseTernary(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+		p.next()
+		if tok.text == "if" {
+			if len(args) != 3 {
+				return nil, exprErrorf("if() expects 3 arguments")
+			}
+			return &ternaryNode{cond: args[0], then: args[1], otherwise: args[2]}, nil
+		}
+		return &callNode{name: tok.text, fn: fn, args: args}, nil
+	case tokOp:
+		switch tok.text {
+		case "(":
+			node, err := p.parseTernary(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			return node, p.expect(")")
+		case "[":
+			var items []exprNode
+			for !p.isOp("]") {
+				if len(items) > 0 {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+				item, err := p.parseTernary(depth + 1)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+			p.next()
+			return &listNode{items: items}, nil
+		}
+	case tokEOF:
+		return nil, exprErrorf("unexpected end of expression")
+	}
+	return nil, exprErrorf("unexpected %q at %d", tok.text, tok.pos)
+}
+
+// ─── Evaluation ─────────────────────────────────────────────────────────
+
+type exprState struct {
+	env    map[string]any
+	budget *int
+}
+
+func (s *exprState) step() error {
+	*s.budget--
+	if *s.budget < 0 {
+		return exprErrorf("evaluation step limit exceeded")
+	}
+	return nil
+}
+
+type exprNode interface {
+	eval(s *exprState) (any, error)
+}
+
+type literalNode struct{ value any }
+
+func (n *literalNode) eval(s *exprState) (any, error) {
+	return n.value, s.step()
+}
+
+type identNode struct{ name string }
+
+func (n *identNode) eval(s *exprState) (any, error) {
+	if err := s.step(); err != nil {
+		return nil, err
+	}
+	return normalizeExprValue(s.env[n.name]), nil
+}
+
+type listNode struct{ items []exprNode }
+
+func (n *listNode) eval(s *exprState) (any, error) {
+	out := make([]any, 0, len(n.items))
+	for _, item := range n.items {
+		v, err := item.eval(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, s.step()
+}
+
+type memberNode struct{ target, key exprNode }
+
+func (n *memberNode) eval(s *exprState) (any, error) {
+	target, err := n.target.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	key, err := n.key.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := target.(type) {
+	case map[string]any:
+		return normalizeExprValue(t[exprString(key)]), nil
+	case []any:
+		idx, ok := key.(float64)
+		if !ok || idx < 0 || int(idx) >= len(t) {
+			return nil, nil
+		}
+		return normalizeExprValue(t[int(idx)]), nil
+	}
+	return nil, nil
+}
+
+type unaryNode struct {
+	op      string
+	operand exprNode
+}
+
+func (n *unaryNode) eval(s *exprState) (any, error) {
+	v, err := n.operand.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "!" {
+		return !exprTruthy(v), nil
+	}
+	f, ok := exprNumber(v)
+	if !ok {
+		return nil, exprErrorf("cannot negate %T", v)
+	}
+	return -f, nil
+}
+
+type ternaryNode struct{ cond, then, otherwise exprNode }
+
+func (n *ternaryNode) eval(s *exprState) (any, error) {
+	c, err := n.cond.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	if exprTruthy(c) {
+		return n.then.eval(s)
+	}
+	return n.otherwise.eval(s)
+}
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *binaryNode) eval(s *exprState) (any, error) {
+	left, err := n.left.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuiting operators.
+	switch n.op {
+	case "&&":
+		if !exprTruthy(left) {
+			return false, nil
+		}
+		right, err := n.right.eval(s)
+		return exprTruthy(right), err
+	case "||":
+		if exprTruthy(left) {
+			return true, nil
+		}
+		right, err := n.right.eval(s)
+		return exprTruthy(right), err
+	case "??":
+		if left != nil {
+			return left, nil
+		}
+		return n.right.eval(s)
+	}
+
+	right, err := n.right.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return exprEqual(left, right), nil
+	case "!=":
+		return !exprEqual(left, right), nil
+	case "<", "<=", ">", ">=":
+		cmp, ok := exprCompare(left, right)
+		if !ok {
+			return false, nil
+		}
+		switch n.op {
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
+		}
+		return cmp >= 0, nil
+	case "+":
+		if ls, ok := left.(string); ok {
+			return ls + exprString(right), nil
+		}
+		if rs, ok := right.(string); ok {
+			return exprString(left) + rs, nil
+		}
+	}
+
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	l, lok := exprNumber(left)
+	r, rok := exprNumber(right)
+	if !lok || !rok {
+		return nil, exprErrorf("operator %s expects numbers, got %T and %T", n.op, left, right)
+	}
+
+	switch n.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, exprErrorf("division by zero")
+		}
+		return l / r, nil
+	case "%":
+		if r == 0 {
+			return nil, exprErrorf("division by zero")
+		}
+		return math.Mod(l, r), nil
+	}
+	return nil, exprErrorf("unknown operator %s", n.op)
+}
+
+type exprFunc func(args []any) (any, error)
+
+type callNode struct {
+	name string
+	fn   exprFunc
+	args []exprNode
+}
+
+func (n *callNode) eval(s *exprState) (any, error) {
+	args := make([]any, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(s)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	if err := s.step(); err != nil {
+		return nil, err
+	}
+	out, err := n.fn(args)
+	if err != nil {
+		return nil, exprErrorf("%s(): %v", n.name, err)
+	}
+	return out, nil
+}
+
+// ─── Values ─────────────────────────────────────────────────────────────
+
+func normalizeExprValue(v any) any {
+	switch t := v.(type) {
+	case nil, string, bool, float64, time.Time, map[string]any, []any:
+		return v
+	case []byte:
+		return string(t)
+	case json.Number:
+		if f, err := t.Float64(); err == nil {
+			return f
+		}
+		return t.String()
+	case float32:
+		return float64(t)
+	case int:
+		return float64(t)
+	case int8:
+		return float64(t)
+	case int16:
+		return float64(t)
+	case int32:
+		return float64(t)
+	case int64:
+		return float64(t)
+	case uint:
+		return float64(t)
+	case uint8:
+		return float64(t)
+	case uint16:
+		return float64(t)
+	case uint32:
+		return float64(t)
+	case uint64:
+		return float64(t)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		return normalizeExprValue(rv.Elem().Interface())
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func exprTruthy(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case float64:
+		return t != 0
+	case string:
+		return t != ""
+	case []any:
+		return len(t) > 0
+	}
+	return true
+}
+
+func exprNumber(v any) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case bool:
+		if t {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func exprString(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case time.Time:
+		return t.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func exprEqual(a, b any) bool {
+	if af, ok := a.(float64); ok {
+		if bf, ok := exprNumber(b); ok {
+			return af == bf
+		}
+	}
+	if at, ok := a.(time.Time); ok {
+		if bt, ok := b.(time.Time); ok {
+			return at.Equal(bt)
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func exprCompare(a, b any) (int, bool) {
+	if at, ok := a.(time.Time); ok {
+		if bt, ok := b.(time.Time); ok {
+			return at.Compare(bt), true
+		}
+	}
+	if as, ok := a.(string); ok {
+		if bs, ok := b.(string); ok {
+			return strings.Compare(as, bs), true
+		}
+	}
+	af, aok := exprNumber(a)
+	bf, bok := exprNumber(b)
+	if !aok || !bok {
+		return 0, false
+	}
+	switch {
+	case af < bf:
+		return -1, true
+	case af > bf:
+		return 1, true
+	}
+	return 0, true
+}
+
+// ─── Builtins ───────────────────────────────────────────────────────────
+
+func argCount(args []any, min, max int) error {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		return fmt.Errorf("unexpected argument count %d", len(args))
+	}
+	return nil
+}
+
+func numericFn(f func(float64) float64) exprFunc {
+	return func(args []any) (any, error) {
+		if err := argCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		if args[0] == nil {
+			return nil, nil
+		}
+		n, ok := exprNumber(args[0])
+		if !ok {
+			return nil, fmt.Errorf("expects a number")
+		}
+		return f(n), nil
+	}
+}
+
+func stringFn(f func(string) string) exprFunc {
+	return func(args []any) (any, error) {
+		if err := argCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		if args[0] == nil {
+			return nil, nil
+		}
+		return f(exprString(args[0])), nil
+	}
+}
+
+func extremumFn(wantLess bool) exprFunc {
+	return func(args []any) (any, error) {
+		if len(args) == 1 {
+			if list, ok := args[0].([]any); ok {
+				args = list
+			}
+		}
+		var best any
+		for _, a := range args {
+			if a == nil {
+				continue
+			}
+			if best == nil {
+				best = a
+				continue
+			}
+			if cmp, ok := exprCompare(a, best); ok && (cmp < 0) == wantLess && cmp != 0 {
+				best = a
+			}
+		}
+		return best, nil
+	}
+}
+
+var exprBuiltins map[string]exprFunc
+
+func init() {
+	exprBuiltins = map[string]exprFunc{
+		"if": nil, // rewritten into a lazy ternary by the parser
+
+		"len": func(args []any) (any, error) {
+			if err := argCount(args, 1, 1); err != nil {
+				return nil, err
+			}
+			switch t := args[0].(type) {
+			case string:
+				return float64(len([]rune(t))), nil
+			case []any:
+				return float64(len(t)), nil
+			case map[string]any:
+				return float64(len(t)), nil
+			}
+			return float64(0), nil
+		},
+		"upper": stringFn(strings.ToUpper),
+		"lower": stringFn(strings.ToLower),
+		"trim":  stringFn(strings.TrimSpace),
+		"concat": func(args []any) (any, error) {
+			var sb strings.Builder
+			for _, a := range args {
+				sb.WriteString(exprString(a))
+			}
+			return sb.String(), nil
+		},
+		"contains": func(args []any) (any, error) {
+			if err := argCount(args, 2, 2); err != nil {
+				return nil, err
+			}
+			if list, ok := args[0].([]any); ok {
+				for _, item := range list {
+					if exprEqual(normalizeExprValue(item), args[1]) {
+						return true, nil
+					}
+				}
+				return false, nil
+			}
+			return strings.Contains(exprString(args[0]), exprString(args[1])), nil
+		},
+		"startsWith": func(args []any) (any, error) {
+			if err := argCount(args, 2, 2); err != nil {
+				return nil, err
+			}
+			return strings.HasPrefix(exprString(args[0]), exprString(args[1])), nil
+		},
+		"endsWith": func(args []any) (any, error) {
+			if err := argCount(args, 2, 2); err != nil {
+				return nil, err
+			}
+			return strings.HasSuffix(exprString(args[0]), exprString(args[1])), nil
+		},
+		"round": func(args []any) (any, error) {
+			if err := argCount(args, 1, 2); err != nil {
+				return nil, err
+			}
+			if args[0] == nil {
+				return nil, nil
+			}
+			n, ok := exprNumber(args[0])
+			if !ok {
+				return nil, fmt.Errorf("expects a number")
+			}
+			places := 0.0
+			if len(args) == 2 {
+				places, _ = exprNumber(args[1])
+			}
+			pow := math.Pow(10, places)
+			return math.Round(n*pow) / pow, nil
+		},
+		"floor": numericFn(math.Floor),
+		"ceil":  numericFn(math.Ceil),
+		"abs":   numericFn(math.Abs),
+		"min":   extremumFn(true),
+		"max":   extremumFn(false),
+		"coalesce": func(args []any) (any, error) {
+			for _, a := range args {
+				if a != nil {
+					return a, nil
+				}
+			}
+			return nil, nil
+		},
+		"now": func(args []any) (any, error) {
+			if err := argCount(args, 0, 0); err != nil {
+				return nil, err
+			}
+			return time.Now(), nil
+		},
+		"number": func(args []any) (any, error) {
+			if err := argCount(args, 1, 1); err != nil {
+				return nil, err
+			}
+			if n, ok := exprNumber(args[0]); ok {
+				return n, nil
+			}
+			return nil, nil
+		},
+		"string": func(args []any) (any, error) {
+			if err := argCount(args, 1, 1); err != nil {
+				return nil, err
+			}
+			return exprString(args[0]), nil
+		},
+		"daysBetween": func(args []any) (any, error) {
+			if err := argCount(args, 2, 2); err != nil {
+				return nil, err
+			}
+			from, ok1 := exprTime(args[0])
+			to, ok2 := exprTime(args[1])
+			if !ok1 || !ok2 {
+				return nil, nil
+			}
+			return math.Floor(to.Sub(from).Hours() / 24), nil
+		},
+	}
+}
+
+func exprTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+			if parsed, err := time.Parse(layout, t); err == nil {
+				return parsed, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,219 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExprEval(t *testing.T) {
+	price := 2.5
+	var missing *int
+	env := map[string]any{
+		"price":     &price,
+		"quantity":  int64(4),
+		"status":    "done",
+		"progress":  0.4567,
+		"firstName": "Ada",
+		"lastName":  []byte("Lovelace"),
+		"empty":     missing,
+		"category":  map[string]any{"name": "Books", "tags": []any{"a", int32(2)}},
+		"createdAt": time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		src  string
+		want any
+	}{
+		// literals and arithmetic
+		{`42`, 42.0},
+		{`'it\'s'`, "it's"},
+		{`"a\tb"`, "a\tb"},
+		{`null`, nil},
+		{`price * quantity`, 10.0},
+		{`1 + 2 * 3 - 4 / 2`, 5.0},
+		{`(1 + 2) * 3`, 9.0},
+		{`7 % 4`, 3.0},
+		{`-price`, -2.5},
+		{`--1`, 1.0},
+		{`"3" * 2`, 6.0},
+		{`empty + 1`, nil},
+
+		// strings
+		{`"n=" + quantity`, "n=4"},
+		{`firstName + " " + lastName`, "Ada Lovelace"},
+		{`concat(upper(lastName), " ", firstName)`, "LOVELACE Ada"},
+		{`lower(" X ") + trim("  y ")`, " x y"},
+		{`len("héllo")`, 5.0},
+		{`startsWith(firstName, "A") && endsWith(lastName, "ce")`, true},
+		{`contains(firstName, "d")`, true},
+		{`contains(category.tags, 2)`, true},
+		{`string(1.5)`, "1.5"},
+		{`number(" 12 ")`, 12.0},
+		{`number("x")`, nil},
+
+		// comparison and logic
+		{`status == "done"`, true},
+		{`quantity == "4"`, true},
+		{`status != "done"`, false},
+		{`quantity >= 4 and price < 3`, true},
+		{`"b" > "a"`, true},
+		{`status < 1`, false},
+		{`!status or not true`, false},
+		{`0 || ""`, false},
+		{`1 && "x"`, true},
+
+		// conditionals and nulls
+		{`status == "done" ? 100 : round(progress * 100, 1)`, 100.0},
+		{`status == "todo" ? 100 : round(progress * 100, 1)`, 45.7},
+		{`if(quantity > 10, "bulk", "unit")`, "unit"},
+		{`empty ?? "—"`, "—"},
+		{`status ?? "—"`, "done"},
+		{`coalesce(empty, null, 3)`, 3.0},
+
+		// members
+		{`category.name`, "Books"},
+		{`category["name"]`, "Books"},
+		{`category.tags[1]`, 2.0},
+		{`category.tags[5]`, nil},
+		{`category.missing.deeper`, nil},
+		{`[1, "a", true][1]`, "a"},
+		{`len([1, 2, 3])`, 3.0},
+
+		// numeric builtins
+		{`floor(-1.5) + ceil(1.2) + abs(-3)`, 3.0},
+		{`round(2.5)`, 3.0},
+		{`min(3, 1, 2)`, 1.0},
+		{`max([3, null, 7])`, 7.0},
+		{`round(empty)`, nil},
+		{`daysBetween(createdAt, "2025-01-31")`, 30.0},
+		{`daysBetween("nope", createdAt)`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			e, err := CompileExpr(tt.src)
+			if err != nil {
+				t.Fatalf("CompileExpr(%q): %v", tt.src, err)
+			}
+			got, err := e.Eval(env)
+			if err != nil {
+				t.Fatalf("Eval(%q): %v", tt.src, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Eval(%q) = %#v, want %#v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExprShortCircuit(t *testing.T) {
+	// The right-hand side would fail if it were evaluated.
+	for _, src := range []string{
+		`false && 1 / 0`,
+		`true || 1 / 0`,
+		`"x" ?? 1 / 0`,
+		`true ? 1 : 1 / 0`,
+		`if(false, 1 / 0, 1)`,
+	} {
+		e, err := CompileExpr(src)
+		if err != nil {
+			t.Fatalf("CompileExpr(%q): %v", src, err)
+		}
+		if _, err := e.Eval(nil); err != nil {
+			t.Errorf("Eval(%q): %v", src, err)
+		}
+	}
+}
+
+func TestExprCompileErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{``, "unexpected end of expression"},
+		{`1 +`, "unexpected end of expression"},
+		{`1..2`, "invalid number"},
+		{`"abc`, "unterminated string"},
+		{`a # b`, "unexpected character"},
+		{`a b`, `unexpected "b"`},
+		{`(1 + 2`, `expected ")"`},
+		{`a ? 1`, `expected ":"`},
+		{`a[1`, `expected "]"`},
+		{`a.1`, "expected field name"},
+		{`f(1, 2 3)`, "unknown function"},
+		{`round(1, 2 3)`, `expected ","`},
+		{`if(1, 2)`, "if() expects 3 arguments"},
+		{`)`, `unexpected ")"`},
+		{strings.Repeat("(", maxExprDepth+2) + "1" + strings.Repeat(")", maxExprDepth+2), "nested too deeply"},
+		{strings.Repeat("-", maxExprDepth+2) + "1", "nested too deeply"},
+		{strings.Repeat("1+", maxExprLength/2) + "1", "longer than"},
+	}
+	for _, tt := range tests {
+		_, err := CompileExpr(tt.src)
+		if !errors.Is(err, ErrExpr) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("CompileExpr(%q) error = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestExprEvalErrors(t *testing.T) {
+	env := map[string]any{"name": "x", "tags": []any{"a"}}
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`1 / 0`, "division by zero"},
+		{`5 % (2 - 2)`, "division by zero"},
+		{`-name`, "cannot negate string"},
+		{`tags * 2`, "expects numbers"},
+		{`tags - name`, "expects numbers"},
+		{`abs("x")`, "abs(): expects a number"},
+		{`round("x")`, "round(): expects a number"},
+		{`upper(1, 2)`, "upper(): unexpected argument count 2"},
+		{`now(1)`, "now(): unexpected argument count 1"},
+		{`len()`, "len(): unexpected argument count 0"},
+		{`1 + (2 / 0) * 3`, "division by zero"},
+	}
+	for _, tt := range tests {
+		e, err := CompileExpr(tt.src)
+		if err != nil {
+			t.Fatalf("CompileExpr(%q): %v", tt.src, err)
+		}
+		_, err = e.Eval(env)
+		if !errors.Is(err, ErrExpr) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Eval(%q) error = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestCompileExprCache(t *testing.T) {
+	a, err := CompileExpr("price * 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := CompileExpr("price * 2")
+	if a != b {
+		t.Error("CompileExpr did not reuse the cached expression")
+	}
+	if a.String() != "price * 2" {
+		t.Errorf("String() = %q", a.String())
+	}
+}
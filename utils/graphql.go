@@ -0,0 +1,667 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A parser for the executable subset of GraphQL: operations with variables,
+// fields with aliases and arguments, fragments (named and inline) and
+// directives. Type system definitions are not accepted.
+//
+//	query Clients($limit: Int = 10) {
+//	  clients(limit: $limit, sort: "-createdAt") { id name owner { label: name } }
+//	}
+const (
+	maxGraphQLLength = 20000
+	maxGraphQLDepth  = 32
+)
+
+var ErrGraphQL = errors.New("graphql error")
+
+func graphQLErrorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrGraphQL, fmt.Sprintf(format, args...))
+}
+
+type GraphQLDocument struct {
+	Operations []*GraphQLOperation
+	Fragments  map[string]*GraphQLFragment
+}
+
+type GraphQLOperation struct {
+	Type         string // query, mutation or subscription
+	Name         string
+	Variables    []GraphQLVariable
+	SelectionSet []*GraphQLSelection
+}
+
+type GraphQLVariable struct {
+	Name       string
+	Type       string
+	Default    any
+	HasDefault bool
+}
+
+type GraphQLFragment struct {
+	Name         string
+	On           string
+	SelectionSet []*GraphQLSelection
+}
+
+// GraphQLSelection is a field, a fragment spread (Fragment set) or an inline
+// fragment (Inline set, On optional).
+type GraphQLSelection struct {
+	Alias        string
+	Name         string
+	Arguments    map[string]any
+	Directives   []GraphQLDirective
+	SelectionSet []*GraphQLSelection
+	Fragment     string
+	Inline       bool
+	On           string
+}
+
+type GraphQLDirective struct {
+	Name      string
+	Arguments map[string]any
+}
+
+// Argument values are Go values (int64, float64, string, bool, nil, []any,
+// map[string]any) except variables and enums, which keep their own types
+// until resolved.
+type (
+	GraphQLVariableRef string
+	GraphQLEnum        string
+)
+
+// ResponseKey is the alias of the field, or its name.
+func (s *GraphQLSelection) ResponseKey() string {
+	if s.Alias != "" {
+		return s.Alias
+	}
+	return s.Name
+}
+
+// Included applies the @skip and @include directives.
+func (s *GraphQLSelection) Included(vars map[string]any) bool {
+	for _, d := range s.Directives {
+		cond, _ := ResolveGraphQLValue(d.Arguments["if"], vars).(bool)
+		switch d.Name {
+		case "skip":
+			if cond {
+				return false
+			}
+		case "include":
+			if !cond {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ResolveGraphQLValue replaces variables by their value and enums by their
+// name.
+func ResolveGraphQLValue(v any, vars map[string]any) any {
+	switch v := v.(type) {
+	case GraphQLVariableRef:
+		return vars[string(v)]
+	case GraphQLEnum:
+		return string(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = ResolveGraphQLValue(item, vars)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[k] = ResolveGraphQLValue(item, vars)
+		}
+		return out
+	}
+	return v
+}
+
+// Operation picks the operation to run: the named one, or the only one of
+// the document.
+func (d *GraphQLDocument) Operation(name string) (*GraphQLOperation, error) {
+	if name == "" {
+		if len(d.Operations) != 1 {
+			return nil, graphQLErrorf("operationName is required when the document has several operations")
+		}
+		return d.Operations[0], nil
+	}
+	for _, op := range d.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, graphQLErrorf("unknown operation %q", name)
+}
+
+// VariableValues merges the request variables with the declared defaults.
+func (op *GraphQLOperation) VariableValues(vars map[string]any) (map[string]any, error) {
+	out := make(map[string]any, len(op.Variables))
+	for _, v := range op.Variables {
+		if value, ok := vars[v.Name]; ok {
+			out[v.Name] = value
+			continue
+		}
+		if v.HasDefault {
+			out[v.Name] = ResolveGraphQLValue(v.Default, nil)
+			continue
+		}
+		if strings.HasSuffix(v.Type, "!") {
+			return nil, graphQLErrorf("variable $%s of type %s is required", v.Name, v.Type)
+		}
+	}
+	return out, nil
+}
+
+func ParseGraphQL(src string) (*GraphQLDocument, error) {
+	if len(src) > maxGraphQLLength {
+		return nil, graphQLErrorf("document longer than %d characters", maxGraphQLLength)
+	}
+	tokens, err := lexGraphQL(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &graphQLParser{tokens: tokens}
+	doc := &GraphQLDocument{Fragments: map[string]*GraphQLFragment{}}
+	for p.peek().kind != gqlEOF {
+		tok := p.peek()
+		switch {
+		case tok.is(gqlPunct, "{"):
+			set, err := p.parseSelectionSet(0)
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, &GraphQLOperation{Type: "query", SelectionSet: set})
+		case tok.is(gqlName, "query"), tok.is(gqlName, "mutation"), tok.is(gqlName, "subscription"):
+			op, err := p.parseOperation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		case tok.is(gqlName, "fragment"):
+			frag, err := p.parseFragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := doc.Fragments[frag.Name]; dup {
+				return nil, graphQLErrorf("fragment %q defined twice", frag.Name)
+			}
+			doc.Fragments[frag.Name] = frag
+		default:
+			return nil, graphQLErrorf("unexpected %q at %d", tok.text, tok.pos)
+		}
+	}
+	if len(doc.Operations) == 0 {
+		return nil, graphQLErrorf("document has no operation")
+	}
+	return doc, nil
+}
+
+// ─── Lexer ──────────────────────────────────────────────────────────────
+
+type gqlKind int
+
+const (
+	gqlEOF gqlKind = iota
+	gqlName
+	gqlInt
+	gqlFloat
+	gqlString
+	gqlPunct
+)
+
+type gqlToken struct {
+	kind gqlKind
+	text string
+	pos  int
+}
+
+func (t gqlToken) is(kind gqlKind, text string) bool {
+	return t.kind == kind && t.text == text
+}
+
+func isGraphQLNameStart(ch byte) bool {
+	return ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+}
+
+func isGraphQLDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func lexGraphQL(src string) ([]gqlToken, error) {
+	var tokens []gqlToken
+	i := 0
+
+	for i < len(src) {
+		ch := src[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == ',':
+			i++
+
+		case ch == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+
+		case strings.HasPrefix(src[i:], "..."):
+			tokens = append(tokens, gqlToken{kind: gqlPunct, text: "...", pos: i})
+			i += 3
+
+		case strings.ContainsRune("!$()[]{}:=@|&", rune(ch)):
+			tokens = append(tokens, gqlToken{kind: gqlPunct, text: string(ch), pos: i})
+			i++
+
+		case isGraphQLNameStart(ch):
+			start := i
+			for i < len(src) && (isGraphQLNameStart(src[i]) || isGraphQLDigit(src[i])) {
+				i++
+			}
+			tokens = append(tokens, gqlToken{kind: gqlName, text: src[start:i], pos: start})
+
+		case ch == '-' || isGraphQLDigit(ch):
+			start := i
+			kind := gqlInt
+			if ch == '-' {
+				i++
+			}
+			for i < len(src) && isGraphQLDigit(src[i]) {
+				i++
+			}
+			if i < len(src) && src[i] == '.' {
+				kind = gqlFloat
+				i++
+				for i < len(src) && isGraphQLDigit(src[i]) {
+					i++
+				}
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				kind = gqlFloat
+				i++
+				if i < len(src) && (src[i] == '+' || src[i] == '-') {
+					i++
+				}
+				for i < len(src) && isGraphQLDigit(src[i]) {
+					i++
+				}
+			}
+			if i == start+1 && ch == '-' {
+				return nil, graphQLErrorf("invalid number at %d", start)
+			}
+			tokens = append(tokens, gqlToken{kind: kind, text: src[start:i], pos: start})
+
+		case strings.HasPrefix(src[i:], `"""`):
+			end := strings.Index(src[i+3:], `"""`)
+			if end < 0 {
+				return nil, graphQLErrorf("unterminated block string at %d", i)
+			}
+			tokens = append(tokens, gqlToken{kind: gqlString, text: strings.TrimSpace(src[i+3 : i+3+end]), pos: i})
+			i += end + 6
+
+		case ch == '"':
+			start := i
+			i++
+			for i < len(src) && src[i] != '"' {
+				if src[i] == '\\' {
+					i++
+				}
+				if i < len(src) && src[i] == '\n' {
+					return nil, graphQLErrorf("unterminated string at %d", start)
+				}
+				i++
+			}
+			if i >= len(src) {
+				return nil, graphQLErrorf("unterminated string at %d", start)
+			}
+			i++
+			text, err := strconv.Unquote(src[start:i])
+			if err != nil {
+				return nil, graphQLErrorf("invalid string at %d", start)
+			}
+			tokens = append(tokens, gqlToken{kind: gqlString, text: text, pos: start})
+
+		default:
+			return nil, graphQLErrorf("unexpected character %q at %d", ch, i)
+		}
+	}
+
+	return append(tokens, gqlToken{kind: gqlEOF, pos: len(src)}), nil
+}
+
+// ─── Parser ─────────────────────────────────────────────────────────────
+
+type graphQLParser struct {
+	tokens []gqlToken
+	pos    int
+}
+
+func (p *graphQLParser) peek() gqlToken {
+	return p.tokens[p.pos]
+}
+
+func (p *graphQLParser) next() gqlToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != gqlEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *graphQLParser) accept(text string) bool {
+	if p.peek().is(gqlPunct, text) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *graphQLParser) expect(text string) error {
+	if tok := p.next(); !tok.is(gqlPunct, text) {
+		return graphQLErrorf("expected %q at %d, got %q", text, tok.pos, tok.text)
+	}
+	return nil
+}
+
+func (p *graphQLParser) name() (string, error) {
+	tok := p.next()
+	if tok.kind != gqlName {
+		return "", graphQLErrorf("expected a name at %d, got %q", tok.pos, tok.text)
+	}
+	return tok.text, nil
+}
+
+func (p *graphQLParser) parseOperation() (*GraphQLOperation, error) {
+	op := &GraphQLOperation{Type: p.next().text}
+	if p.peek().kind == gqlName {
+		op.Name = p.next().text
+	}
+
+	if p.accept("(") {
+		for !p.accept(")") {
+			if err := p.expect("$"); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			typ, err := p.parseType()
+			if err != nil {
+				return nil, err
+			}
+			v := GraphQLVariable{Name: name, Type: typ}
+			if p.accept("=") {
+				if v.Default, err = p.parseValue(true, 0); err != nil {
+					return nil, err
+				}
+				v.HasDefault = true
+			}
+			op.Variables = append(op.Variables, v)
+		}
+	}
+
+	if _, err := p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	set, err := p.parseSelectionSet(0)
+	if err != nil {
+		return nil, err
+	}
+	op.SelectionSet = set
+	return op, nil
+}
+
+func (p *graphQLParser) parseType() (string, error) {
+	var typ string
+	if p.accept("[") {
+		inner, err := p.parseType()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect("]"); err != nil {
+			return "", err
+		}
+		typ = "[" + inner + "]"
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return "", err
+		}
+		typ = name
+	}
+	if p.accept("!") {
+		typ += "!"
+	}
+	return typ, nil
+}
+
+func (p *graphQLParser) parseFragment() (*GraphQLFragment, error) {
+	p.next()
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if on := p.next(); !on.is(gqlName, "on") {
+		return nil, graphQLErrorf("expected \"on\" at %d", on.pos)
+	}
+	typ, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	set, err := p.parseSelectionSet(0)
+	if err != nil {
+		return nil, err
+	}
+	return &GraphQLFragment{Name: name, On: typ, SelectionSet: set}, nil
+}
+
+func (p *graphQLParser) parseSelectionSet(depth int) ([]*GraphQLSelection, error) {
+	if depth > maxGraphQLDepth {
+		return nil, graphQLErrorf("document nested deeper than %d levels", maxGraphQLDepth)
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	var set []*GraphQLSelection
+	for !p.accept("}") {
+		if p.peek().kind == gqlEOF {
+			return nil, graphQLErrorf("unterminated selection set")
+		}
+		sel, err := p.parseSelection(depth)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, sel)
+	}
+	if len(set) == 0 {
+		return nil, graphQLErrorf("empty selection set")
+	}
+	return set, nil
+}
+
+func (p *graphQLParser) parseSelection(depth int) (*GraphQLSelection, error) {
+	var err error
+	sel := &GraphQLSelection{}
+
+	if p.accept("...") {
+		if tok := p.peek(); tok.kind == gqlName && tok.text != "on" {
+			sel.Fragment = p.next().text
+			sel.Directives, err = p.parseDirectives()
+			return sel, err
+		}
+		sel.Inline = true
+		if p.peek().is(gqlName, "on") {
+			p.next()
+			if sel.On, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		if sel.Directives, err = p.parseDirectives(); err != nil {
+			return nil, err
+		}
+		sel.SelectionSet, err = p.parseSelectionSet(depth + 1)
+		return sel, err
+	}
+
+	if sel.Name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if p.accept(":") {
+		sel.Alias = sel.Name
+		if sel.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if sel.Arguments, err = p.parseArguments(); err != nil {
+		return nil, err
+	}
+	if sel.Directives, err = p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	if p.peek().is(gqlPunct, "{") {
+		if sel.SelectionSet, err = p.parseSelectionSet(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return sel, nil
+}
+
+func (p *graphQLParser) parseArguments() (map[string]any, error) {
+	if !p.accept("(") {
+		return nil, nil
+	}
+	args := map[string]any{}
+	for !p.accept(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if args[name], err = p.parseValue(false, 0); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+func (p *graphQLParser) parseDirectives() ([]GraphQLDirective, error) {
+	var directives []GraphQLDirective
+	for p.accept("@") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.parseArguments()
+		if err != nil {
+			return nil, err
+		}
+		directives = append(directives, GraphQLDirective{Name: name, Arguments: args})
+	}
+	return directives, nil
+}
+
+func (p *graphQLParser) parseValue(constant bool, depth int) (any, error) {
+	if depth > maxGraphQLDepth {
+		return nil, graphQLErrorf("value nested deeper than %d levels", maxGraphQLDepth)
+	}
+
+	tok := p.next()
+	switch tok.kind {
+	case gqlInt:
+		n, err := strconv.ParseInt(tok.text, 10, 64)
+		if err != nil {
+			return nil, graphQLErrorf("invalid integer %q", tok.text)
+		}
+		return n, nil
+	case gqlFloat:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, graphQLErrorf("invalid float %q", tok.text)
+		}
+		return f, nil
+	case gqlString:
+		return tok.text, nil
+	case gqlName:
+		switch tok.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return GraphQLEnum(tok.text), nil
+	}
+
+	switch tok.text {
+	case "$":
+		if constant {
+			return nil, graphQLErrorf("variable not allowed at %d", tok.pos)
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		return GraphQLVariableRef(name), nil
+	case "[":
+		list := []any{}
+		for !p.accept("]") {
+			v, err := p.parseValue(constant, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case "{":
+		obj := map[string]any{}
+		for !p.accept("}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if obj[name], err = p.parseValue(constant, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	}
+	return nil, graphQLErrorf("unexpected %q at %d", tok.text, tok.pos)
+}
@@ -0,0 +1,264 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLexGraphQL(t *testing.T) {
+	type tok struct {
+		kind gqlKind
+		text string
+	}
+	tests := []struct {
+		name string
+		src  string
+		want []tok
+	}{
+		{"names and punctuation", "query { a }", []tok{
+			{gqlName, "query"}, {gqlPunct, "{"}, {gqlName, "a"}, {gqlPunct, "}"},
+		}},
+		{"commas and comments are ignored", "a, b # c d\n e", []tok{
+			{gqlName, "a"}, {gqlName, "b"}, {gqlName, "e"},
+		}},
+		{"spread", "...F", []tok{{gqlPunct, "..."}, {gqlName, "F"}}},
+		{"numbers", "1 -2 3.5 -4e3 5E-2", []tok{
+			{gqlInt, "1"}, {gqlInt, "-2"}, {gqlFloat, "3.5"}, {gqlFloat, "-4e3"}, {gqlFloat, "5E-2"},
+		}},
+		{"string escapes", `"a\"b\né"`, []tok{{gqlString, "a\"b\né"}}},
+		{"block string", `"""  multi
+line """`, []tok{{gqlString, "multi\nline"}}},
+		{"variable", "$limit: Int!", []tok{
+			{gqlPunct, "$"}, {gqlName, "limit"}, {gqlPunct, ":"}, {gqlName, "Int"}, {gqlPunct, "!"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := lexGraphQL(tt.src)
+			if err != nil {
+				t.Fatalf("lexGraphQL(%q): %v", tt.src, err)
+			}
+			if last := tokens[len(tokens)-1]; last.kind != gqlEOF || last.pos != len(tt.src) {
+				t.Fatalf("lexGraphQL(%q) does not end with EOF at %d: %+v", tt.src, len(tt.src), last)
+			}
+			var got []tok
+			for _, token := range tokens[:len(tokens)-1] {
+				got = append(got, tok{token.kind, token.text})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lexGraphQL(%q) = %+v, want %+v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLexGraphQLErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`"abc`, "unterminated string"},
+		{"\"a\nb\"", "unterminated string"},
+		{`"""abc`, "unterminated block string"},
+		{`"\q"`, "invalid string"},
+		{"- 1", "invalid number"},
+		{"a ; b", "unexpected character"},
+	}
+	for _, tt := range tests {
+		_, err := lexGraphQL(tt.src)
+		if !errors.Is(err, ErrGraphQL) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("lexGraphQL(%q) error = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestParseGraphQL(t *testing.T) {
+	doc, err := ParseGraphQL(`
+		query Clients($limit: Int = 10, $ids: [ID!]!) @cached {
+			list: clients(limit: $limit, sort: "-createdAt", where: {status: ACTIVE, tags: ["a", 2, 1.5, true, null]}) {
+				id
+				owner @include(if: $withOwner) { label: name }
+				...ClientFields
+				... on Client { vip }
+				... @skip(if: true) { hidden }
+			}
+		}
+		fragment ClientFields on Client { email }
+		{ other }
+	`)
+	if err != nil {
+		t.Fatalf("ParseGraphQL: %v", err)
+	}
+
+	if len(doc.Operations) != 2 {
+		t.Fatalf("got %d operations, want 2", len(doc.Operations))
+	}
+	op := doc.Operations[0]
+	if op.Type != "query" || op.Name != "Clients" {
+		t.Errorf("operation = %s %s, want query Clients", op.Type, op.Name)
+	}
+	wantVars := []GraphQLVariable{
+		{Name: "limit", Type: "Int", Default: int64(10), HasDefault: true},
+		{Name: "ids", Type: "[ID!]!"},
+	}
+	if !reflect.DeepEqual(op.Variables, wantVars) {
+		t.Errorf("variables = %+v, want %+v", op.Variables, wantVars)
+	}
+	if anon := doc.Operations[1]; anon.Type != "query" || anon.Name != "" || anon.SelectionSet[0].Name != "other" {
+		t.Errorf("shorthand operation = %+v", anon)
+	}
+
+	list := op.SelectionSet[0]
+	if list.Alias != "list" || list.Name != "clients" || list.ResponseKey() != "list" {
+		t.Errorf("field = %s: %s, want list: clients", list.Alias, list.Name)
+	}
+	wantArgs := map[string]any{
+		"limit": GraphQLVariableRef("limit"),
+		"sort":  "-createdAt",
+		"where": map[string]any{
+			"status": GraphQLEnum("ACTIVE"),
+			"tags":   []any{"a", int64(2), 1.5, true, nil},
+		},
+	}
+	if !reflect.DeepEqual(list.Arguments, wantArgs) {
+		t.Errorf("arguments = %#v, want %#v", list.Arguments, wantArgs)
+	}
+
+	set := list.SelectionSet
+	if len(set) != 5 {
+		t.Fatalf("got %d selections, want 5", len(set))
+	}
+	if owner := set[1]; owner.Name != "owner" || len(owner.Directives) != 1 || owner.Directives[0].Name != "include" ||
+		owner.SelectionSet[0].ResponseKey() != "label" {
+		t.Errorf("owner = %+v", owner)
+	}
+	if spread := set[2]; spread.Fragment != "ClientFields" || spread.Inline {
+		t.Errorf("spread = %+v", spread)
+	}
+	if inline := set[3]; !inline.Inline || inline.On != "Client" || inline.SelectionSet[0].Name != "vip" {
+		t.Errorf("inline fragment = %+v", inline)
+	}
+	if skipped := set[4]; !skipped.Inline || skipped.On != "" || skipped.Included(nil) {
+		t.Errorf("skipped inline fragment = %+v", skipped)
+	}
+
+	frag := doc.Fragments["ClientFields"]
+	if frag == nil || frag.On != "Client" || frag.SelectionSet[0].Name != "email" {
+		t.Errorf("fragment = %+v", frag)
+	}
+}
+
+func TestParseGraphQLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"empty document", "  # nothing\n", "no operation"},
+		{"only fragments", "fragment F on T { a }", "no operation"},
+		{"empty selection set", "{ }", "empty selection set"},
+		{"unterminated selection set", "{ a { b }", "unterminated selection set"},
+		{"type definition", "type Client { id: ID }", "unexpected"},
+		{"fragment defined twice", "{ a } fragment F on T { a } fragment F on T { b }", "defined twice"},
+		{"fragment without on", "{ a } fragment F T { a }", `expected "on"`},
+		{"variable in a default", "query ($a: Int = $b) { a }", "variable not allowed"},
+		{"missing colon", "query ($a Int) { a }", `expected ":"`},
+		{"bad argument value", "{ a(x: }) }", "unexpected"},
+		{"integer overflow", "{ a(x: 99999999999999999999) }", "invalid integer"},
+		{"too deep", "{" + strings.Repeat(" a {", maxGraphQLDepth+1) + " b" + strings.Repeat(" }", maxGraphQLDepth+2), "nested deeper"},
+		{"too long", "{ " + strings.Repeat("a ", maxGraphQLLength/2+1) + "}", "longer than"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseGraphQL(tt.src)
+			if !errors.Is(err, ErrGraphQL) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseGraphQL error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphQLOperationAndVariables(t *testing.T) {
+	doc, err := ParseGraphQL(`query A($n: Int = 3, $s: String!, $o: Boolean) { a } query B { b }`)
+	if err != nil {
+		t.Fatalf("ParseGraphQL: %v", err)
+	}
+
+	if _, err := doc.Operation(""); err == nil {
+		t.Error("Operation(\"\") on two operations: want an error")
+	}
+	if _, err := doc.Operation("C"); err == nil {
+		t.Error("Operation(\"C\"): want an error")
+	}
+	op, err := doc.Operation("A")
+	if err != nil {
+		t.Fatalf("Operation(\"A\"): %v", err)
+	}
+
+	if _, err := op.VariableValues(nil); err == nil || !strings.Contains(err.Error(), "$s") {
+		t.Errorf("VariableValues without the required $s: error = %v", err)
+	}
+	got, err := op.VariableValues(map[string]any{"s": "x", "extra": 1})
+	if err != nil {
+		t.Fatalf("VariableValues: %v", err)
+	}
+	want := map[string]any{"n": int64(3), "s": "x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("VariableValues = %v, want %v", got, want)
+	}
+}
+
+func TestResolveGraphQLValue(t *testing.T) {
+	vars := map[string]any{"limit": 5}
+	got := ResolveGraphQLValue(map[string]any{
+		"limit": GraphQLVariableRef("limit"),
+		"list":  []any{GraphQLEnum("ASC"), GraphQLVariableRef("missing")},
+	}, vars)
+	want := map[string]any{"limit": 5, "list": []any{"ASC", nil}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ResolveGraphQLValue = %v, want %v", got, want)
+	}
+}
+
+func TestGraphQLSelectionIncluded(t *testing.T) {
+	tests := []struct {
+		directive string
+		vars      map[string]any
+		want      bool
+	}{
+		{"", nil, true},
+		{"@skip(if: true)", nil, false},
+		{"@skip(if: false)", nil, true},
+		{"@include(if: false)", nil, false},
+		{"@include(if: $on)", map[string]any{"on": true}, true},
+		{"@include(if: $on)", nil, false},
+		{"@include(if: true) @skip(if: true)", nil, false},
+	}
+	for _, tt := range tests {
+		doc, err := ParseGraphQL("{ a " + tt.directive + " }")
+		if err != nil {
+			t.Fatalf("ParseGraphQL(%q): %v", tt.directive, err)
+		}
+		if got := doc.Operations[0].SelectionSet[0].Included(tt.vars); got != tt.want {
+			t.Errorf("Included(%q, %v) = %v, want %v", tt.directive, tt.vars, got, tt.want)
+		}
+	}
+}
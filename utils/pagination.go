@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"reflect"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Pagination is the ?page=&pageSize= of a list request. A zero PageSize
+// means the whole list, as a single page.
+type Pagination struct {
+	Page     int
+	PageSize int
+}
+
+// PageMeta is the `meta` block of every list response.
+type PageMeta struct {
+	Total    int64 `json:"total"`
+	Page     int   `json:"page"`
+	PageSize int   `json:"pageSize"`
+	HasMore  bool  `json:"hasMore"`
+}
+
+// ParsePagination reads ?page (from 1) and ?pageSize, capped at maxSize.
+// Without pageSize, defaultSize applies; 0 returns the whole list. Invalid
+// values fall back to the defaults.
+func ParsePagination(c *gin.Context, defaultSize, maxSize int) Pagination {
+	p := Pagination{Page: 1, PageSize: defaultSize}
+	if v, err := strconv.Atoi(c.Query("page")); err == nil && v > 1 {
+		p.Page = v
+	}
+	if v, err := strconv.Atoi(c.Query("pageSize")); err == nil && v > 0 {
+		p.PageSize = v
+	}
+	if p.PageSize > 0 && maxSize > 0 {
+		p.PageSize = min(p.PageSize, maxSize)
+	}
+	// A page past the first only makes sense with a page size.
+	if p.PageSize == 0 {
+		p.Page = 1
+	}
+	return p
+}
+
+// Paged reports whether p reads a slice of the list rather than all of it.
+func (p Pagination) Paged() bool {
+	return p.PageSize > 0
+}
+
+func (p Pagination) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// Meta describes the page of count rows out of total.
+func (p Pagination) Meta(total int64, count int) PageMeta {
+	if !p.Paged() {
+		return PageMeta{Total: int64(count), Page: 1, PageSize: count}
+	}
+	return PageMeta{
+		Total:    total,
+		Page:     p.Page,
+		PageSize: p.PageSize,
+		HasMore:  int64(p.Offset()+count) < total,
+	}
+}
+
+// FindPage loads page p of query into dest, a pointer to a slice, and counts
+// the rows of query. preload, when set, adds the associations to load: they
+// are left out of the count.
+func FindPage(query *gorm.DB, p Pagination, dest any, preload func(*gorm.DB) *gorm.DB) (PageMeta, error) {
+	var total int64
+	if p.Paged() {
+		if err := query.Session(&gorm.Session{}).Model(dest).Count(&total).Error; err != nil {
+			return PageMeta{}, err
+		}
+		query = query.Limit(p.PageSize).Offset(p.Offset())
+	}
+	if preload != nil {
+		query = preload(query)
+	}
+	if err := query.Find(dest).Error; err != nil {
+		return PageMeta{}, err
+	}
+	return p.Meta(total, reflect.ValueOf(dest).Elem().Len()), nil
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+	pdfMargin     = 50.0
+)
+
+type PDFDocument struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+func NewPDF() *PDFDocument {
+	d := &PDFDocument{}
+	d.newPage()
+	return d
+}
+
+func (d *PDFDocument) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pdfPageHeight - pdfMargin
+}
+
+func (d *PDFDocument) current() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+func (d *PDFDocument) line(font string, size float64, indent float64, text string) {
+	if d.y-size < pdfMargin {
+		d.newPage()
+	}
+	d.y -= size * 1.4
+	fmt.Fprintf(d.current(), "BT /%s %.1f Tf %.1f %.1f Td (%s) Tj ET\n",
+		font, size, pdfMargin+indent, d.y, pdfEscape(text))
+}
+
+func (d *PDFDocument) wrapped(font string, size float64, indent float64, text string) {
+	maxChars := int((pdfPageWidth - 2*pdfMargin - indent) / (size * 0.5))
+	for _, paragraph := range strings.Split(text, "\n") {
+		words := strings.Fields(paragraph)
+		if len(words) == 0 {
+			d.line(font, size, indent, "")
+			continue
+		}
+		current := ""
+		for _, w := range words {
+			for len([]rune(w)) > maxChars {
+				if current != "" {
+					d.line(font, size, indent, current)
+					current = ""
+				}
+				r := []rune(w)
+				d.line(font, size, indent, string(r[:maxChars]))
+				w = string(r[maxChars:])
+			}
+			switch {
+			case current == "":
+				current = w
+			case len([]rune(current))+1+len([]rune(w)) > maxChars:
+				d.line(font, size, indent, current)
+				current = w
+			default:
+				current += " " + w
+			}
+		}
+		if current != "" {
+			d.line(font, size, indent, current)
+		}
+	}
+}
+
+func (d *PDFDocument) Heading(text string) {
+	d.wrapped("F2", 18, 0, text)
+	d.y -= 6
+}
+
+func (d *PDFDocument) Subheading(text string) {
+	d.wrapped("F2", 12, 0, text)
+	d.y -= 4
+}
+
+func (d *PDFDocument) Text(text string) {
+	d.wrapped("F1", 10, 0, text)
+}
+
+func (d *PDFDocument) Field(label, value string) {
+	d.wrapped("F2", 10, 0, label)
+	d.wrapped("F1", 10, 12, value)
+	d.y -= 4
+}
+
+func (d *PDFDocument) Bytes() []byte {
+	var out bytes.Buffer
+	offsets := []int{}
+	obj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+
+	firstPage := 5
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+i*2)
+	}
+
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, content := range d.pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, firstPage+i*2+1))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+func pdfEscape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t':
+			b.WriteString("    ")
+		case r < 32:
+			continue
+		case r < 128:
+			b.WriteRune(r)
+		case r >= 0xA0 && r <= 0xFF:
+			fmt.Fprintf(&b, "\\%03o", r)
+		case r == '€':
+			b.WriteString("\\200")
+		case r == '’':
+			b.WriteString("'")
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPDFEscape(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain text", "plain text"},
+		{`a (b) \c`, `a \(b\) \\c`},
+		{"tab\there", "tab    here"},
+		{"new\nline\x00", "newline"},
+		{"été à Noël", `\351t\351 \340 No\353l`},
+		{"12 €", `12 \200`},
+		{"l’été", `l'\351t\351`},
+		{"日本", "??"},
+	}
+	for _, tt := range tests {
+		if got := pdfEscape(tt.in); got != tt.want {
+			t.Errorf("pdfEscape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+var pdfTextOp = regexp.MustCompile(`BT /(F\d) ([\d.]+) Tf ([\d.]+) ([\d.]+) Td \(((?:[^()\\]|\\.)*)\) Tj ET`)
+
+type pdfTextLine struct {
+	font   string
+	x, y   float64
+	text   string
+	pageNo int
+}
+
+func TestPDFLayout(t *testing.T) {
+	long := strings.Repeat("lorem ipsum ", 40)
+	tests := []struct {
+		name  string
+		build func(d *PDFDocument)
+		pages int
+		check func(t *testing.T, lines []pdfTextLine)
+	}{
+		{"empty document", func(d *PDFDocument) {}, 1, func(t *testing.T, lines []pdfTextLine) {
+			if len(lines) != 0 {
+				t.Errorf("got %d lines, want none", len(lines))
+			}
+		}},
+		{"heading and field", func(d *PDFDocument) {
+			d.Heading("Client (VIP)")
+			d.Field("Nom", "Ada")
+		}, 1, func(t *testing.T, lines []pdfTextLine) {
+			want := []pdfTextLine{
+				{font: "F2", x: 50, text: `Client \(VIP\)`},
+				{font: "F2", x: 50, text: "Nom"},
+				{font: "F1", x: 62, text: "Ada"},
+			}
+			if len(lines) != len(want) {
+				t.Fatalf("got %d lines, want %d", len(lines), len(want))
+			}
+			for i, w := range want {
+				if lines[i].font != w.font || lines[i].x != w.x || lines[i].text != w.text {
+					t.Errorf("line %d = %+v, want %+v", i, lines[i], w)
+				}
+			}
+		}},
+		{"wrapped paragraph", func(d *PDFDocument) { d.Text(long + "\n\nend") }, 1, func(t *testing.T, lines []pdfTextLine) {
+			var words []string
+			for _, l := range lines {
+				if len(l.text) > 99 {
+					t.Errorf("line %q is longer than the 99 characters that fit", l.text)
+				}
+				words = append(words, strings.Fields(l.text)...)
+			}
+			if got, want := strings.Join(words, " "), strings.Join(strings.Fields(long+" end"), " "); got != want {
+				t.Errorf("wrapping lost words:\n got %q\nwant %q", got, want)
+			}
+			if lines[len(lines)-2].text != "" {
+				t.Errorf("blank paragraph line = %q, want empty", lines[len(lines)-2].text)
+			}
+		}},
+		{"long word is split", func(d *PDFDocument) { d.Text("a " + strings.Repeat("x", 250)) }, 1, func(t *testing.T, lines []pdfTextLine) {
+			got := []string{}
+			for _, l := range lines {
+				got = append(got, l.text)
+			}
+			want := []string{"a", strings.Repeat("x", 99), strings.Repeat("x", 99), strings.Repeat("x", 52)}
+			if strings.Join(got, "|") != strings.Join(want, "|") {
+				t.Errorf("lines = %q, want %q", got, want)
+			}
+		}},
+		{"page break", func(d *PDFDocument) {
+			for i := 0; i < 120; i++ {
+				d.Text(fmt.Sprintf("row %d", i))
+			}
+		}, 3, func(t *testing.T, lines []pdfTextLine) {
+			if len(lines) != 120 {
+				t.Fatalf("got %d lines, want 120", len(lines))
+			}
+			for i := 1; i < len(lines); i++ {
+				prev, cur := lines[i-1], lines[i]
+				if cur.pageNo == prev.pageNo && cur.y >= prev.y {
+					t.Errorf("line %d at y=%.1f does not go down the page from %.1f", i, cur.y, prev.y)
+				}
+				if cur.pageNo > prev.pageNo && cur.y < 700 {
+					t.Errorf("line %d starts page %d at y=%.1f, want the top", i, cur.pageNo, cur.y)
+				}
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewPDF()
+			tt.build(d)
+			streams := checkPDFStructure(t, d.Bytes())
+			if len(streams) != tt.pages {
+				t.Fatalf("got %d pages, want %d", len(streams), tt.pages)
+			}
+			var lines []pdfTextLine
+			for i, s := range streams {
+				for _, m := range pdfTextOp.FindAllStringSubmatch(s, -1) {
+					x, _ := strconv.ParseFloat(m[3], 64)
+					y, _ := strconv.ParseFloat(m[4], 64)
+					if y <= 0 || y >= pdfPageHeight-pdfMargin {
+						t.Errorf("line %q at y=%.1f is outside the page", m[5], y)
+					}
+					lines = append(lines, pdfTextLine{font: m[1], x: x, y: y, text: m[5], pageNo: i})
+				}
+			}
+			tt.check(t, lines)
+		})
+	}
+}
+
+// checkPDFStructure verifies the cross-reference table and the stream
+// lengths, and returns the content stream of each page in order.
+func checkPDFStructure(t *testing.T, out []byte) []string {
+	t.Helper()
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatalf("missing PDF header or trailer")
+	}
+
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(out)
+	if m == nil {
+		t.Fatalf("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(out[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+
+	table := strings.Split(string(out[xref:]), "\n")
+	var count int
+	if _, err := fmt.Sscanf(table[1], "0 %d", &count); err != nil {
+		t.Fatalf("bad xref subsection %q", table[1])
+	}
+	if !strings.Contains(string(out), fmt.Sprintf("/Size %d /Root 1 0 R", count)) {
+		t.Errorf("trailer /Size does not match the %d xref entries", count)
+	}
+	objects := map[int]string{}
+	for n := 1; n < count; n++ {
+		var off int
+		if _, err := fmt.Sscanf(table[2+n], "%010d 00000 n ", &off); err != nil {
+			t.Fatalf("bad xref entry %q", table[2+n])
+		}
+		header := fmt.Sprintf("%d 0 obj\n", n)
+		if !bytes.HasPrefix(out[off:], []byte(header)) {
+			t.Fatalf("xref entry %d points at %q", n, out[off:off+10])
+		}
+		body := out[off+len(header):]
+		objects[n] = string(body[:bytes.Index(body, []byte("\nendobj\n"))])
+	}
+
+	kids := regexp.MustCompile(`/Kids \[([^\]]*)\] /Count (\d+)`).FindStringSubmatch(objects[2])
+	if kids == nil {
+		t.Fatalf("page tree = %q", objects[2])
+	}
+	var streams []string
+	refs := regexp.MustCompile(`(\d+) 0 R`).FindAllStringSubmatch(kids[1], -1)
+	if strconv.Itoa(len(refs)) != kids[2] {
+		t.Errorf("/Count %s does not match %d kids", kids[2], len(refs))
+	}
+	for _, ref := range refs {
+		n, _ := strconv.Atoi(ref[1])
+		page := objects[n]
+		c := regexp.MustCompile(`/Contents (\d+) 0 R`).FindStringSubmatch(page)
+		if !strings.Contains(page, "/Type /Page ") || c == nil {
+			t.Fatalf("object %d is not a page: %q", n, page)
+		}
+		cn, _ := strconv.Atoi(c[1])
+		s := regexp.MustCompile(`(?s)^<< /Length (\d+) >>\nstream\n(.*)endstream$`).FindStringSubmatch(objects[cn])
+		if s == nil {
+			t.Fatalf("object %d is not a stream: %q", cn, objects[cn])
+		}
+		if length, _ := strconv.Atoi(s[1]); length != len(s[2]) {
+			t.Errorf("stream %d declares /Length %d but holds %d bytes", cn, length, len(s[2]))
+		}
+		streams = append(streams, s[2])
+	}
+	return streams
+}
@@ -0,0 +1,453 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// QR code encoder limited to byte mode, error correction level M and
+// versions 1 to 10, which is plenty for short links.
+
+var ErrQRCodeTooLong = errors.New("text too long for QR code")
+
+var (
+	qrEccPerBlock = [11]int{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26}
+	qrNumBlocks   = [11]int{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5}
+)
+
+type qrCode struct {
+	size       int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+func QRCodePNG(text string, scale int) ([]byte, error) {
+	qr, err := encodeQRCode([]byte(text))
+	if err != nil {
+		return nil, err
+	}
+
+	const border = 4
+	dim := (qr.size + 2*border) * scale
+	img := image.NewGray(image.Rect(0, 0, dim, dim))
+	for y := 0; y < dim; y++ {
+		for x := 0; x < dim; x++ {
+			mx, my := x/scale-border, y/scale-border
+			c := color.Gray{Y: 255}
+			if mx >= 0 && my >= 0 && mx < qr.size && my < qr.size && qr.modules[my][mx] {
+				c = color.Gray{Y: 0}
+			}
+			img.SetGray(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeQRCode(data []byte) (*qrCode, error) {
+	version := 0
+	for v := 1; v <= 10; v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= qrDataCodewords(v)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrQRCodeTooLong
+	}
+
+	var bits []bool
+	appendBits := func(val, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, (val>>i)&1 == 1)
+		}
+	}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	appendBits(0x4, 4)
+	appendBits(len(data), countBits)
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+
+	capacity := qrDataCodewords(version) * 8
+	terminator := capacity - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	appendBits(0, terminator)
+	appendBits(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		appendBits(pad, 8)
+	}
+
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i>>3] |= 1 << (7 - uint(i&7))
+		}
+	}
+
+	qr := newQRCode(version)
+	qr.drawFunctionPatterns(version)
+	qr.drawCodewords(qrAddEcc(codewords, version))
+
+	bestMask, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		qr.applyMask(mask)
+		qr.drawFormatBits(mask)
+		if p := qr.penalty(); bestPenalty < 0 || p < bestPenalty {
+			bestMask, bestPenalty = mask, p
+		}
+		qr.applyMask(mask)
+	}
+	qr.applyMask(bestMask)
+	qr.drawFormatBits(bestMask)
+
+	return qr, nil
+}
+
+func newQRCode(version int) *qrCode {
+	size := version*4 + 17
+	qr := &qrCode{size: size}
+	qr.modules = make([][]bool, size)
+	qr.isFunction = make([][]bool, size)
+	for i := range qr.modules {
+		qr.modules[i] = make([]bool, size)
+		qr.isFunction[i] = make([]bool, size)
+	}
+	return qr
+}
+
+func qrRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func qrDataCodewords(version int) int {
+	return qrRawDataModules(version)/8 - qrEccPerBlock[version]*qrNumBlocks[version]
+}
+
+func qrAlignmentPositions(version, size int) []int {
+	if version == 1 {
+		return nil
+	}
+	numAlign := version/7 + 2
+	step := (version*8 + numAlign*3 + 5) / (numAlign*4 - 4) * 2
+	result := make([]int, numAlign)
+	result[0] = 6
+	for i, pos := numAlign-1, size-7; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+func (qr *qrCode) setFunction(x, y int, dark bool) {
+	qr.modules[y][x] = dark
+	qr.isFunction[y][x] = true
+}
+
+func (qr *qrCode) drawFunctionPatterns(version int) {
+	for i := 0; i < qr.size; i++ {
+		qr.setFunction(6, i, i%2 == 0)
+		qr.setFunction(i, 6, i%2 == 0)
+	}
+
+	for _, c := range [][2]int{{3, 3}, {qr.size - 4, 3}, {3, qr.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x < 0 || y < 0 || x >= qr.size || y >= qr.size {
+					continue
+				}
+				dist := qrMax(qrAbs(dx), qrAbs(dy))
+				qr.setFunction(x, y, dist != 2 && dist != 4)
+			}
+		}
+	}
+
+	positions := qrAlignmentPositions(version, qr.size)
+	last := len(positions) - 1
+	for i, px := range positions {
+		for j, py := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					qr.setFunction(px+dx, py+dy, qrMax(qrAbs(dx), qrAbs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	qr.drawFormatBits(0)
+
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			bit := (bits>>i)&1 == 1
+			a, b := qr.size-11+i%3, i/3
+			qr.setFunction(a, b, bit)
+			qr.setFunction(b, a, bit)
+		}
+	}
+}
+
+func (qr *qrCode) drawFormatBits(mask int) {
+	// Level M is encoded as 0b00 in the format information.
+	data := mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		qr.setFunction(8, i, bit(i))
+	}
+	qr.setFunction(8, 7, bit(6))
+	qr.setFunction(8, 8, bit(7))
+	qr.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		qr.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		qr.setFunction(qr.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.setFunction(8, qr.size-15+i, bit(i))
+	}
+	qr.setFunction(8, qr.size-8, true)
+}
+
+func (qr *qrCode) drawCodewords(data []byte) {
+	i := 0
+	for right := qr.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < qr.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = qr.size - 1 - vert
+				}
+				if !qr.isFunction[y][x] && i < len(data)*8 {
+					qr.modules[y][x] = (data[i>>3]>>(7-uint(i&7)))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (qr *qrCode) applyMask(mask int) {
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			if qr.isFunction[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				qr.modules[y][x] = !qr.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty applies the run, block and balance rules of the specification;
+// the finder-like pattern rule is omitted, which only affects mask choice.
+func (qr *qrCode) penalty() int {
+	result := 0
+	for _, horizontal := range []bool{true, false} {
+		for a := 0; a < qr.size; a++ {
+			run := 0
+			var prev bool
+			for b := 0; b < qr.size; b++ {
+				cur := qr.modules[a][b]
+				if !horizontal {
+					cur = qr.modules[b][a]
+				}
+				if b > 0 && cur == prev {
+					run++
+					if run == 5 {
+						result += 3
+					} else if run > 5 {
+						result++
+					}
+				} else {
+					run = 1
+				}
+				prev = cur
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			if qr.modules[y][x] {
+				dark++
+			}
+			if x < qr.size-1 && y < qr.size-1 {
+				c := qr.modules[y][x]
+				if c == qr.modules[y][x+1] && c == qr.modules[y+1][x] && c == qr.modules[y+1][x+1] {
+					result += 3
+				}
+			}
+		}
+	}
+
+	total := qr.size * qr.size
+	k := (qrAbs(dark*20-total*10)+total-1)/total - 1
+	return result + k*10
+}
+
+func qrAddEcc(data []byte, version int) []byte {
+	numBlocks := qrNumBlocks[version]
+	eccLen := qrEccPerBlock[version]
+	rawCodewords := qrRawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := qrReedSolomonDivisor(eccLen)
+	blocks := make([][]byte, 0, numBlocks)
+	k := 0
+	for i := 0; i < numBlocks; i++ {
+		n := shortBlockLen - eccLen
+		if i >= numShortBlocks {
+			n++
+		}
+		dat := append([]byte{}, data[k:k+n]...)
+		k += n
+		ecc := qrReedSolomonRemainder(dat, divisor)
+		if i < numShortBlocks {
+			dat = append(dat, 0)
+		}
+		blocks = append(blocks, append(dat, ecc...))
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortBlockLen-eccLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+func qrReedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := 0; j < degree; j++ {
+			result[j] = qrGfMultiply(result[j], root)
+			if j+1 < degree {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = qrGfMultiply(root, 0x02)
+	}
+	return result
+}
+
+func qrReedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= qrGfMultiply(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+func qrGfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+func qrAbs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func qrMax(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,368 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestQRCodePNGDecodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		version int
+	}{
+		{"empty", "", 1},
+		{"version 1 capacity", strings.Repeat("a", 14), 1},
+		{"share link", "https://ex.co/s/3f9c1a7e", 2},
+		{"utf-8", "https://exemple.fr/pages/été?q=ü", 3},
+		{"version info", "https://app.example.com/s/" + strings.Repeat("x", 94), 7},
+		{"two block sizes", strings.Repeat("b", 150), 8},
+		{"version 10 capacity", strings.Repeat("z", 213), 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, scale := range []int{1, 3} {
+				out, err := QRCodePNG(tt.text, scale)
+				if err != nil {
+					t.Fatalf("QRCodePNG: %v", err)
+				}
+				got, version, err := decodeQRCodePNG(out, scale)
+				if err != nil {
+					t.Fatalf("decode at scale %d: %v", scale, err)
+				}
+				if version != tt.version {
+					t.Errorf("version = %d, want %d", version, tt.version)
+				}
+				if got != tt.text {
+					t.Errorf("decoded %q, want %q", got, tt.text)
+				}
+			}
+		})
+	}
+}
+
+func TestQRCodePNGTooLong(t *testing.T) {
+	if _, err := QRCodePNG(strings.Repeat("z", 214), 8); !errors.Is(err, ErrQRCodeTooLong) {
+		t.Errorf("error = %v, want ErrQRCodeTooLong", err)
+	}
+}
+
+// The decoder below follows ISO/IEC 18004 on its own tables rather than the
+// encoder's helpers, so a shared mistake cannot make the round trip pass.
+
+// Level M block structure: total codewords, data codewords, block count.
+var testQRBlocks = [11][3]int{
+	{}, {26, 16, 1}, {44, 28, 1}, {70, 44, 1}, {100, 64, 2}, {134, 86, 2},
+	{172, 108, 4}, {196, 124, 4}, {242, 154, 4}, {292, 182, 5}, {346, 216, 5},
+}
+
+var testQRAlignment = [11][]int{
+	nil, nil, {6, 18}, {6, 22}, {6, 26}, {6, 30},
+	{6, 34}, {6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+}
+
+var testQRRemainderBits = [11]int{0, 0, 7, 7, 7, 7, 7, 0, 0, 0, 0}
+
+func decodeQRCodePNG(data []byte, scale int) (string, int, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", 0, err
+	}
+	const border = 4
+	dim := img.Bounds().Dx()
+	if img.Bounds().Dy() != dim || dim%scale != 0 {
+		return "", 0, fmt.Errorf("unexpected image size %v", img.Bounds())
+	}
+	size := dim/scale - 2*border
+	version := (size - 17) / 4
+	if version < 1 || version > 10 || size != 17+4*version {
+		return "", 0, fmt.Errorf("unexpected symbol size %d", size)
+	}
+
+	dark := func(x, y int) bool {
+		px := (x+border)*scale + scale/2
+		py := (y+border)*scale + scale/2
+		return color.GrayModel.Convert(img.At(px, py)).(color.Gray).Y < 128
+	}
+	for i := -border; i < size+border; i++ {
+		for _, p := range []image.Point{{i, -border}, {i, size}, {-border, i}, {size, i}} {
+			if dark(p.X, p.Y) {
+				return "", 0, fmt.Errorf("quiet zone is not blank at %v", p)
+			}
+		}
+	}
+	for i := 8; i < size-8; i++ {
+		if dark(i, 6) != (i%2 == 0) || dark(6, i) != (i%2 == 0) {
+			return "", 0, fmt.Errorf("broken timing pattern at %d", i)
+		}
+	}
+
+	mask, err := readQRFormat(dark, size)
+	if err != nil {
+		return "", 0, err
+	}
+	if version >= 7 {
+		if err := readQRVersion(dark, size, version); err != nil {
+			return "", 0, err
+		}
+	}
+
+	reserved := testQRReserved(size, version)
+	var bits []bool
+	upward := true
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < size; vert++ {
+			y := vert
+			if upward {
+				y = size - 1 - vert
+			}
+			for x := right; x >= right-1; x-- {
+				if reserved[y][x] {
+					continue
+				}
+				bits = append(bits, dark(x, y) != testQRMask(mask, x, y))
+			}
+		}
+		upward = !upward
+	}
+
+	total, dataLen, numBlocks := testQRBlocks[version][0], testQRBlocks[version][1], testQRBlocks[version][2]
+	if len(bits) != total*8+testQRRemainderBits[version] {
+		return "", 0, fmt.Errorf("found %d data modules, want %d", len(bits), total*8+testQRRemainderBits[version])
+	}
+	codewords := make([]byte, total)
+	for i := range codewords {
+		for j := 0; j < 8; j++ {
+			if bits[i*8+j] {
+				codewords[i] |= 1 << (7 - j)
+			}
+		}
+	}
+
+	// De-interleave: data codewords round-robin, the longer blocks last,
+	// then the error correction codewords round-robin.
+	eccLen := (total - dataLen) / numBlocks
+	numShort := numBlocks - dataLen%numBlocks
+	shortLen := dataLen / numBlocks
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i <= shortLen; i++ {
+		for b := range blocks {
+			if i < shortLen || b >= numShort {
+				blocks[b] = append(blocks[b], codewords[k])
+				k++
+			}
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], codewords[k])
+			k++
+		}
+	}
+
+	var payload []byte
+	for b, block := range blocks {
+		if !testQRCheckBlock(block, eccLen) {
+			return "", 0, fmt.Errorf("block %d fails the Reed-Solomon check", b)
+		}
+		payload = append(payload, block[:len(block)-eccLen]...)
+	}
+
+	pos := 0
+	read := func(n int) int {
+		v := 0
+		for i := 0; i < n; i++ {
+			v = v<<1 | int(payload[pos>>3]>>(7-pos&7)&1)
+			pos++
+		}
+		return v
+	}
+	if mode := read(4); mode != 0x4 {
+		return "", 0, fmt.Errorf("mode %04b is not byte mode", mode)
+	}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	n := read(countBits)
+	if pos+8*n > len(payload)*8 {
+		return "", 0, fmt.Errorf("count %d exceeds the payload", n)
+	}
+	text := make([]byte, n)
+	for i := range text {
+		text[i] = byte(read(8))
+	}
+	return string(text), version, nil
+}
+
+func readQRFormat(dark func(x, y int) bool, size int) (int, error) {
+	var first, second int
+	set := func(v *int, i int, on bool) {
+		if on {
+			*v |= 1 << i
+		}
+	}
+	for i := 0; i <= 5; i++ {
+		set(&first, i, dark(8, i))
+	}
+	set(&first, 6, dark(8, 7))
+	set(&first, 7, dark(8, 8))
+	set(&first, 8, dark(7, 8))
+	for i := 9; i < 15; i++ {
+		set(&first, i, dark(14-i, 8))
+	}
+	for i := 0; i < 8; i++ {
+		set(&second, i, dark(size-1-i, 8))
+	}
+	for i := 8; i < 15; i++ {
+		set(&second, i, dark(8, size-15+i))
+	}
+	if first != second {
+		return 0, fmt.Errorf("format copies differ: %015b and %015b", first, second)
+	}
+	if !dark(8, size-8) {
+		return 0, fmt.Errorf("dark module is missing")
+	}
+
+	format := first ^ 0x5412
+	if testQRBCH(format>>10, 10, 0x537) != format {
+		return 0, fmt.Errorf("format %015b fails its BCH check", format)
+	}
+	if level := format >> 13; level != 0 {
+		return 0, fmt.Errorf("error correction level %02b is not M", level)
+	}
+	return format >> 10 & 7, nil
+}
+
+func readQRVersion(dark func(x, y int) bool, size, version int) error {
+	var first, second int
+	for i := 0; i < 18; i++ {
+		a, b := size-11+i%3, i/3
+		if dark(a, b) {
+			first |= 1 << i
+		}
+		if dark(b, a) {
+			second |= 1 << i
+		}
+	}
+	if first != second {
+		return fmt.Errorf("version copies differ: %018b and %018b", first, second)
+	}
+	if first != testQRBCH(version, 12, 0x1F25) {
+		return fmt.Errorf("version information %018b does not encode %d", first, version)
+	}
+	return nil
+}
+
+// testQRBCH appends the remainder of data·x^n divided by poly.
+func testQRBCH(data, n, poly int) int {
+	degree := 0
+	for p := poly; p > 1; p >>= 1 {
+		degree++
+	}
+	rem := data << n
+	for i := degree + 16; i >= degree; i-- {
+		if rem>>i&1 == 1 {
+			rem ^= poly << (i - degree)
+		}
+	}
+	return data<<n | rem
+}
+
+func testQRReserved(size, version int) [][]bool {
+	reserved := make([][]bool, size)
+	for y := range reserved {
+		reserved[y] = make([]bool, size)
+		for x := range reserved[y] {
+			reserved[y][x] = x == 6 || y == 6 ||
+				x < 9 && y < 9 || x >= size-8 && y < 9 || x < 9 && y >= size-8 ||
+				version >= 7 && (x >= size-11 && x < size-8 && y < 6 || y >= size-11 && y < size-8 && x < 6)
+		}
+	}
+	positions := testQRAlignment[version]
+	for _, px := range positions {
+		for _, py := range positions {
+			if px < 9 && py < 9 || px > size-9 && py < 9 || px < 9 && py > size-9 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					reserved[py+dy][px+dx] = true
+				}
+			}
+		}
+	}
+	return reserved
+}
+
+func testQRMask(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (y+x)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (y+x)%3 == 0
+	case 4:
+		return (y/2+x/3)%2 == 0
+	case 5:
+		return y*x%2+y*x%3 == 0
+	case 6:
+		return (y*x%2+y*x%3)%2 == 0
+	}
+	return ((y+x)%2+y*x%3)%2 == 0
+}
+
+// testQRCheckBlock evaluates the block polynomial at the generator roots
+// α^0…α^(eccLen-1); every syndrome is zero for an intact block.
+func testQRCheckBlock(block []byte, eccLen int) bool {
+	mul := func(a byte, i int) byte {
+		// a·α^i by repeated doubling keeps the check free of log tables.
+		for ; i > 0; i-- {
+			hi := a&0x80 != 0
+			a <<= 1
+			if hi {
+				a ^= 0x1D
+			}
+		}
+		return a
+	}
+	for i := 0; i < eccLen; i++ {
+		var s byte
+		for _, c := range block {
+			s = mul(s, i) ^ c
+		}
+		if s != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -53,10 +53,21 @@ func Error(c *gin.Context, status int, code string, details string) {
     })
 }
 
-func HealthResponse(c *gin.Context) {
-    JSON(c, http.StatusOK, "API-Core opérationnelle 🚀", gin.H{
-        "service":   "api-core",
-        "status":    "online",
-        "timestamp": time.Now().UTC(),
+// HealthResponse reports the overall status of the API and of each of its
+// dependencies. A non-200 status marks the API as unavailable.
+func HealthResponse(c *gin.Context, httpStatus int, status string, dependencies any) {
+    message := "API-Core opérationnelle 🚀"
+    if httpStatus != http.StatusOK {
+        message = "API-Core indisponible"
+    }
+    c.JSON(httpStatus, APIResponse{
+        Success: httpStatus == http.StatusOK,
+        Message: message,
+        Data: gin.H{
+            "service":      "api-core",
+            "status":       status,
+            "timestamp":    time.Now().UTC(),
+            "dependencies": dependencies,
+        },
     })
 }
@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workers
+
+import (
+	"api-core-v2/models"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// deployStaleAfter is how long a deploy may stay running before the
+// scheduler assumes its instance died and runs it again. A deploy applies
+// the difference with the live table, so running it twice is harmless.
+const deployStaleAfter = 15 * time.Minute
+
+// DeployRunner deploys the page of a scheduled deploy and returns its plan.
+type DeployRunner func(ctx context.Context, deploy models.ScheduledDeploy) (any, error)
+
+// StartDeployScheduler runs the scheduled deploys that are due, checking
+// every DEPLOY_SCHEDULER_INTERVAL seconds (30 by default). The schedule
+// lives in Postgres, so it survives restarts, and each deploy is claimed by
+// a single instance.
+func StartDeployScheduler(db *gorm.DB, run DeployRunner) {
+	interval := 30 * time.Second
+	if n, err := strconv.Atoi(os.Getenv("DEPLOY_SCHEDULER_INTERVAL")); err == nil && n > 0 {
+		interval = time.Duration(n) * time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		for range ticker.C {
+			RunDueDeploys(db, run)
+		}
+	}()
+}
+
+// claimDueDeploy marks the next due deploy running and returns it, or
+// false when none is due.
+func claimDueDeploy(db *gorm.DB) (models.ScheduledDeploy, bool, error) {
+	var deploy models.ScheduledDeploy
+	res := db.Raw(`
+		UPDATE scheduled_deploys SET status = ?, started_at = now()
+		WHERE id = (
+			SELECT id FROM scheduled_deploys
+			WHERE scheduled_at <= now() AND (status = ? OR (status = ? AND started_at < ?))
+			ORDER BY scheduled_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		models.ScheduledDeployRunning, models.ScheduledDeployPending, models.ScheduledDeployRunning,
+		time.Now().Add(-deployStaleAfter)).Scan(&deploy)
+	if res.Error != nil {
+		return deploy, false, res.Error
+	}
+	return deploy, deploy.ID != "", nil
+}
+
+// RunDueDeploys runs the deploys that are due, oldest first, and records
+// their outcome.
+func RunDueDeploys(db *gorm.DB, run DeployRunner) {
+	for {
+		deploy, ok, err := claimDueDeploy(db)
+		if err != nil {
+			log.Printf("❌ [DEPLOY] Impossible de lire les déploiements planifiés: %v", err)
+			return
+		}
+		if !ok {
+			return
+		}
+
+		updates := map[string]any{"status": models.ScheduledDeploySucceeded, "finished_at": time.Now(), "error": ""}
+		plan, err := run(context.Background(), deploy)
+		if err != nil {
+			updates["status"] = models.ScheduledDeployFailed
+			updates["error"] = err.Error()
+			log.Printf("❌ [DEPLOY] Échec du déploiement planifié %s de la page %s: %v", deploy.ID, deploy.PageID, err)
+		} else {
+			log.Printf("🚀 [DEPLOY] Déploiement planifié %s de la page %s effectué", deploy.ID, deploy.PageID)
+		}
+		if plan != nil {
+			body, _ := json.Marshal(plan)
+			updates["plan"] = datatypes.JSON(body)
+		}
+		if err := db.Model(&models.ScheduledDeploy{}).Where("id = ?", deploy.ID).Updates(updates).Error; err != nil {
+			log.Printf("❌ [DEPLOY] Impossible d'enregistrer le résultat du déploiement %s: %v", deploy.ID, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workers
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/services"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func StartDigestWorker(db *gorm.DB) {
+
+	debug := os.Getenv("DEBUG") == "true"
+
+	intervalSec := 300
+	if v := os.Getenv("DIGEST_CHECK_INTERVAL"); v != "" {
+		fmt.Sscanf(v, "%d", &intervalSec)
+	}
+	// time.NewTicker panics on a non-positive interval.
+	if intervalSec <= 0 {
+		intervalSec = 300
+	}
+
+	go func() {
+
+		ticker := time.NewTicker(time.Duration(intervalSec) * time.Second)
+
+		for range ticker.C {
+			SendDueDigests(db, debug)
+		}
+	}()
+}
+
+func SendDueDigests(db *gorm.DB, debug bool) {
+
+	var subs []models.DigestSubscription
+	if err := db.Preload("User").Preload("Page").Find(&subs).Error; err != nil {
+		log.Printf("❌ [DIGEST] Impossible de charger les abonnements: %v", err)
+		return
+	}
+
+	now := time.Now()
+
+	for _, sub := range subs {
+		if sub.Page == nil {
+			continue
+		}
+		// An unpublished page is only reported to admins.
+		if sub.Page.Status != models.PageStatusPublished && (sub.User == nil || sub.User.IsAdmin == nil || !*sub.User.IsAdmin) {
+			continue
+		}
+
+		period := services.DigestPeriod(sub.Frequency)
+		from := now.Add(-period)
+		if sub.LastSentAt != nil {
+			if now.Sub(*sub.LastSentAt) < period {
+				continue
+			}
+			from = *sub.LastSentAt
+		}
+
+		digest, err := services.BuildPageDigest(db, *sub.Page, sub.Frequency, from, now)
+		if err != nil {
+			log.Printf("❌ [DIGEST] Erreur de calcul pour la page %s: %v", sub.PageID, err)
+			continue
+		}
+
+		job := services.DigestJob{SubscriptionID: sub.ID, Digest: digest}
+		err = services.RunJob(db, services.JobDigest, sub.Channel+":"+sub.Target, job)
+
+		// A failed delivery is kept as a dead letter: move on to the next
+		// period instead of re-sending it on every tick.
+		if uerr := db.Model(&sub).Update("last_sent_at", now).Error; uerr != nil {
+			log.Printf("❌ [DIGEST] Impossible de marquer l'abonnement %s comme envoyé: %v", sub.ID, uerr)
+		}
+
+		if err != nil {
+			log.Printf("❌ [DIGEST] Échec d'envoi (%s) pour l'abonnement %s: %v", sub.Channel, sub.ID, err)
+			continue
+		}
+
+		if debug {
+			log.Printf("📨 [DIGEST] Digest %s envoyé pour la page %s (%s)\n", sub.Frequency, sub.Page.Name, sub.Channel)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workers
+
+import (
+	"api-core-v2/models"
+	"api-core-v2/routes"
+	"api-core-v2/services"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	kubeVirtTemplate = "Kubevirt"
+	kubeVirtKey      = "uid"
+	kubeVirtAbsent   = "Absent"
+)
+
+// StartKubeVirtSync periodically mirrors the cluster VM inventory into the
+// deployed table of the page named by KUBEVIRT_SYNC_PAGE, or of every deployed
+// page using the Kubevirt fiche template when the variable is not set.
+func StartKubeVirtSync(db *gorm.DB) {
+
+	debug := os.Getenv("DEBUG") == "true"
+
+	client, err := services.NewKubeVirtClientFromEnv()
+	if err != nil {
+		log.Printf("❌ [KUBEVIRT] Configuration invalide: %v", err)
+		return
+	}
+	if client == nil {
+		return
+	}
+
+	intervalSec := 60
+	if v := os.Getenv("KUBEVIRT_SYNC_INTERVAL"); v != "" {
+		fmt.Sscanf(v, "%d", &intervalSec)
+	}
+
+	log.Printf("🔵 KubeVirt sync: every %ds", intervalSec)
+
+	go func() {
+
+		SyncKubeVirt(db, client, debug)
+
+		ticker := time.NewTicker(time.Duration(intervalSec) * time.Second)
+
+		for range ticker.C {
+			SyncKubeVirt(db, client, debug)
+		}
+	}()
+}
+
+func SyncKubeVirt(db *gorm.DB, client *services.KubeVirtClient, debug bool) {
+
+	pages, err := kubeVirtPages(db)
+	if err != nil {
+		log.Printf("❌ [KUBEVIRT] Impossible de charger les pages cibles: %v", err)
+		return
+	}
+	if len(pages) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	vms, err := client.ListVirtualMachines(ctx)
+	if err != nil {
+		log.Printf("❌ [KUBEVIRT] Lecture de l'inventaire impossible: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, page := range pages {
+		if err := syncKubeVirtTable(db, page, vms, now); err != nil {
+			log.Printf("❌ [KUBEVIRT] Échec de synchronisation de %s: %v", page.TableName, err)
+			continue
+		}
+		if debug {
+			log.Printf("🔄 [KUBEVIRT] %d VM synchronisées dans %s\n", len(vms), page.TableName)
+		}
+	}
+}
+
+func kubeVirtPages(db *gorm.DB) ([]models.Page, error) {
+	var pages []models.Page
+
+	scope := db.Where("deploy = ? AND table_name <> ''", true)
+	if id := os.Getenv("KUBEVIRT_SYNC_PAGE"); id != "" {
+		scope = scope.Where("id = ?", id)
+	} else {
+		scope = scope.Where("fiche_template_id IN (?)",
+			db.Model(&models.Template{}).Select("id").Where("name = ?", kubeVirtTemplate))
+	}
+
+	err := scope.Find(&pages).Error
+	return pages, err
+}
+
+func syncKubeVirtTable(db *gorm.DB, page models.Page, vms []services.KubeVirtVM, now time.Time) error {
+	if err := routes.CheckOwnedTable(db, page.Storage, page.TableName); err != nil {
+		return err
+	}
+
+	sqlDB, err := routes.PageSQL(db, page)
+	if err != nil {
+		return err
+	}
+
+	cols, err := routes.TableColumns(sqlDB, page.TableName)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(cols))
+	for _, col := range cols {
+		known[col] = true
+	}
+	if !known[kubeVirtKey] {
+		return fmt.Errorf("la table %s n'a pas de colonne %q", page.TableName, kubeVirtKey)
+	}
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	seen := make([]string, 0, len(vms))
+	for _, vm := range vms {
+		row := make(map[string]any)
+		for col, val := range vm.Row(now) {
+			if known[col] {
+				row[col] = val
+			}
+		}
+		if _, _, err := routes.UpsertDynamic(tx, page.TableName, kubeVirtKey, row, nil); err != nil {
+			return err
+		}
+		seen = append(seen, vm.UID)
+	}
+
+	if known["status"] {
+		missing := map[string]any{"status": kubeVirtAbsent}
+		if known["ready"] {
+			missing["ready"] = false
+		}
+		if err := routes.UpdateMissingDynamic(tx, page.TableName, kubeVirtKey, seen, missing); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -147,6 +147,9 @@ func StartTokenRefresher(rdb *redis.Client) {
 			keys, _ := rdb.Keys(ctx, "*").Result()
 
 			for _, token := range keys {
+				if !isTokenKey(token) {
+					continue
+				}
 				ProcessToken(ctx, rdb, token, debug)
 			}
 
@@ -157,6 +160,9 @@ func StartTokenRefresher(rdb *redis.Client) {
 	}()
 }
 
+func isTokenKey(key string) bool {
+	return strings.Count(key, ".") == 2 && !strings.Contains(key, ":")
+}
 
 func GetTokenExp(token string) (int64, error) {
 	parts := strings.Split(token, ".")
@@ -0,0 +1,42 @@
+/*
+ * Copyright (c) 2025 Enzo Amate
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workers
+
+import (
+	"api-core-v2/services"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StartTrashPurge empties the trash of the expired records every hour.
+func StartTrashPurge(db *gorm.DB) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		for range ticker.C {
+			n, err := services.PurgeExpiredTrash(db)
+			if err != nil {
+				log.Printf("❌ [TRASH] Purge de la corbeille impossible: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("🧹 [TRASH] %d éléments expirés supprimés définitivement", n)
+			}
+		}
+	}()
+}